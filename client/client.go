@@ -1,16 +1,35 @@
 package client
 
 import (
+	"context"
 	"errors"
+	"iter"
+	"sync"
 	"time"
 
 	"github.com/dsha256/gredis/internal/cache"
+	"github.com/dsha256/gredis/internal/pubsub"
 )
 
 // Common errors.
 var (
 	ErrKeyNotFound        = errors.New("key not found")
 	ErrKeyNotFoundOrEmpty = errors.New("key not found or empty list")
+	// ErrTimeout is returned by a blocking list operation whose timeout
+	// elapsed.
+	ErrTimeout = cache.ErrTimeout
+	// ErrKeyExists is returned by RenameNX and Copy if the destination
+	// exists.
+	ErrKeyExists = cache.ErrKeyExists
+)
+
+// ListEnd selects an end of a list in blocking list operations.
+type ListEnd = cache.ListEnd
+
+// List ends.
+const (
+	ListFront = cache.ListFront
+	ListBack  = cache.ListBack
 )
 
 // Client provides a client API for interacting with the cache.
@@ -20,12 +39,29 @@ type Client struct {
 
 // StringClient provides a client API for string operations.
 type StringClient struct {
-	cmdable cache.StringCmdable
+	cmdable stringCmdable
 }
 
 // ListClient provides a client API for list operations.
 type ListClient struct {
-	cmdable cache.ListCmdable
+	cmdable listCmdable
+	// cache is used by the blocking operations.
+	cache cache.Cache
+}
+
+// HashClient provides a client API for hash operations.
+type HashClient struct {
+	cmdable cache.HashCmdable
+}
+
+// SetClient provides a client API for set operations.
+type SetClient struct {
+	cmdable cache.SetCmdable
+}
+
+// SortedSetClient provides a client API for sorted set operations.
+type SortedSetClient struct {
+	cmdable cache.SortedSetCmdable
 }
 
 // New creates a new client with the given cache implementation.
@@ -42,16 +78,46 @@ func NewMemoryClient(cleanupInterval time.Duration) *Client {
 	}
 }
 
+// NewShardedMemoryClient creates a new client with an in-memory cache split
+// into the given number of independently locked shards.
+func NewShardedMemoryClient(shards int, cleanupInterval time.Duration) *Client {
+	return &Client{
+		cache: cache.NewShardedCache(shards, cleanupInterval),
+	}
+}
+
 // String returns a client for string operations.
 func (c *Client) String() *StringClient {
 	return &StringClient{
-		cmdable: c.cache,
+		cmdable: c.store(),
 	}
 }
 
 // List returns a client for list operations.
 func (c *Client) List() *ListClient {
 	return &ListClient{
+		cmdable: c.store(),
+		cache:   c.cache,
+	}
+}
+
+// Hash returns a client for hash operations.
+func (c *Client) Hash() *HashClient {
+	return &HashClient{
+		cmdable: c.cache,
+	}
+}
+
+// Sets returns a client for set operations.
+func (c *Client) Sets() *SetClient {
+	return &SetClient{
+		cmdable: c.cache,
+	}
+}
+
+// SortedSet returns a client for sorted set operations.
+func (c *Client) SortedSet() *SortedSetClient {
+	return &SortedSetClient{
 		cmdable: c.cache,
 	}
 }
@@ -77,11 +143,60 @@ func (c *StringClient) SetWithTTL(key string, value string, ttl time.Duration) e
 	return c.cmdable.SetWithTTL(key, value, ttl)
 }
 
+// SetWithOptions stores a string value under the conditions and with the
+// expiration given by opts, e.g. only if the key does not exist yet with
+// NX, and reports whether it was stored and the previous value.
+func (c *StringClient) SetWithOptions(key string, value string, opts cache.SetOptions) (cache.SetResult, error) {
+	return c.cmdable.SetWithOptions(key, value, opts)
+}
+
+// MGet returns the string values of keys, read atomically, mapped by key.
+// Keys that are missing or do not hold a string are left out.
+func (c *StringClient) MGet(keys ...string) map[string]string {
+	return c.cmdable.MGet(keys...)
+}
+
+// MSet atomically stores several string values.
+func (c *StringClient) MSet(values map[string]string) error {
+	return c.cmdable.MSet(values)
+}
+
+// MSetNX atomically stores several string values if none of the keys
+// exists, and reports whether it did.
+func (c *StringClient) MSetNX(values map[string]string) (bool, error) {
+	return c.cmdable.MSetNX(values)
+}
+
 // Update updates an existing string value in the cache.
 func (c *StringClient) Update(key string, value string) error {
 	return c.cmdable.Update(key, value)
 }
 
+// Incr atomically increments the integer value of a key by one and returns
+// the new value. A missing key is created at zero first.
+func (c *StringClient) Incr(key string) (int64, error) {
+	return c.cmdable.Incr(key)
+}
+
+// IncrBy atomically increments the integer value of a key and returns the
+// new value. A missing key is created at zero first; the TTL of an
+// existing key is kept.
+func (c *StringClient) IncrBy(key string, increment int64) (int64, error) {
+	return c.cmdable.IncrBy(key, increment)
+}
+
+// DecrBy atomically decrements the integer value of a key and returns the
+// new value.
+func (c *StringClient) DecrBy(key string, decrement int64) (int64, error) {
+	return c.cmdable.DecrBy(key, decrement)
+}
+
+// IncrByFloat atomically increments the numeric value of a key by a float
+// and returns the new value.
+func (c *StringClient) IncrByFloat(key string, increment float64) (float64, error) {
+	return c.cmdable.IncrByFloat(key, increment)
+}
+
 // Remove removes a key from the cache.
 func (c *Client) Remove(key string) error {
 	return c.cache.Remove(key)
@@ -117,11 +232,233 @@ func (c *ListClient) PopBack(key string) (string, error) {
 	return value, nil
 }
 
+// BPopFront removes and returns the first element of the first non-empty
+// list among keys, with the key of that list. If they are all empty it
+// waits for an element until timeout elapses, returning ErrTimeout, or ctx
+// is done; a timeout of zero waits for ctx only.
+func (c *ListClient) BPopFront(ctx context.Context, timeout time.Duration, keys ...string) (key, value string, err error) {
+	return cache.BPop(ctx, c.cache, keys, cache.ListFront, timeout)
+}
+
+// BPopBack removes and returns the last element of the first non-empty
+// list among keys, waiting for one as BPopFront does.
+func (c *ListClient) BPopBack(ctx context.Context, timeout time.Duration, keys ...string) (key, value string, err error) {
+	return cache.BPop(ctx, c.cache, keys, cache.ListBack, timeout)
+}
+
+// ListMove atomically moves an element from the from end of the source
+// list to the to end of the destination list and returns it, like LMOVE.
+// source and destination may be the same list, which rotates it. It
+// returns ErrKeyNotFoundOrEmpty if source has no element.
+func (c *ListClient) ListMove(source, destination string, from, to ListEnd) (string, error) {
+	value, moved, err := c.cmdable.ListMove(source, destination, from, to)
+	if err != nil {
+		return "", err
+	}
+	if !moved {
+		return "", ErrKeyNotFoundOrEmpty
+	}
+	return value, nil
+}
+
+// BMove atomically moves an element from the from end of the source list
+// to the to end of the destination list and returns it, waiting for one as
+// BPopFront does if source is empty.
+func (c *ListClient) BMove(ctx context.Context, source, destination string, from, to ListEnd, timeout time.Duration) (string, error) {
+	return cache.BMove(ctx, c.cache, source, destination, from, to, timeout)
+}
+
 // ListRange returns a range of elements from a list.
 func (c *ListClient) ListRange(key string, start, end int) ([]string, error) {
 	return c.cmdable.ListRange(key, start, end)
 }
 
+// ListLen returns the number of elements of a list, zero if it is missing.
+func (c *ListClient) ListLen(key string) (int, error) {
+	return c.cmdable.ListLen(key)
+}
+
+// ListIndex returns the element at index in a list. Negative indexes count
+// from the back, -1 being the last element.
+func (c *ListClient) ListIndex(key string, index int) (string, error) {
+	return c.cmdable.ListIndex(key, index)
+}
+
+// ListSet replaces the element at index in a list.
+func (c *ListClient) ListSet(key string, index int, value string) error {
+	return c.cmdable.ListSet(key, index, value)
+}
+
+// ListInsert inserts value before or after the first occurrence of pivot in
+// a list and returns the new length of the list.
+func (c *ListClient) ListInsert(key string, before bool, pivot, value string) (int, error) {
+	return c.cmdable.ListInsert(key, before, pivot, value)
+}
+
+// ListRemove removes the first count occurrences of value from a list, the
+// last ones if count is negative and all of them if it is zero, and returns
+// the number of removed elements.
+func (c *ListClient) ListRemove(key string, count int, value string) (int, error) {
+	return c.cmdable.ListRemove(key, count, value)
+}
+
+// ListTrim keeps only the elements of a list between start and end.
+func (c *ListClient) ListTrim(key string, start, end int) error {
+	return c.cmdable.ListTrim(key, start, end)
+}
+
+// ListPos returns the indexes of the elements of a list equal to value, as
+// selected by opts.
+func (c *ListClient) ListPos(key string, value string, opts cache.ListPosOptions) ([]int, error) {
+	return c.cmdable.ListPos(key, value, opts)
+}
+
+// Hash operations.
+
+// HSet sets fields in a hash and returns the number of added fields.
+func (c *HashClient) HSet(key string, fields map[string]string) (int, error) {
+	return c.cmdable.HSet(key, fields)
+}
+
+// HGet retrieves the value of a hash field.
+func (c *HashClient) HGet(key string, field string) (string, error) {
+	return c.cmdable.HGet(key, field)
+}
+
+// HDel removes fields from a hash and returns the number of removed fields.
+func (c *HashClient) HDel(key string, fields ...string) (int, error) {
+	return c.cmdable.HDel(key, fields...)
+}
+
+// HGetAll retrieves all fields and values of a hash.
+func (c *HashClient) HGetAll(key string) (map[string]string, error) {
+	return c.cmdable.HGetAll(key)
+}
+
+// HIncrBy increments the integer value of a hash field.
+func (c *HashClient) HIncrBy(key string, field string, increment int64) (int64, error) {
+	return c.cmdable.HIncrBy(key, field, increment)
+}
+
+// Set operations.
+
+// SAdd adds members to a set and returns the number of added members.
+func (c *SetClient) SAdd(key string, members ...string) (int, error) {
+	return c.cmdable.SAdd(key, members...)
+}
+
+// SRem removes members from a set and returns the number of removed members.
+func (c *SetClient) SRem(key string, members ...string) (int, error) {
+	return c.cmdable.SRem(key, members...)
+}
+
+// SIsMember reports whether member belongs to a set.
+func (c *SetClient) SIsMember(key string, member string) (bool, error) {
+	return c.cmdable.SIsMember(key, member)
+}
+
+// SMembers returns the members of a set in sorted order.
+func (c *SetClient) SMembers(key string) ([]string, error) {
+	return c.cmdable.SMembers(key)
+}
+
+// SCard returns the number of members of a set.
+func (c *SetClient) SCard(key string) (int, error) {
+	return c.cmdable.SCard(key)
+}
+
+// SInter returns the intersection of the given sets.
+func (c *SetClient) SInter(keys ...string) ([]string, error) {
+	return c.cmdable.SInter(keys...)
+}
+
+// SUnion returns the union of the given sets.
+func (c *SetClient) SUnion(keys ...string) ([]string, error) {
+	return c.cmdable.SUnion(keys...)
+}
+
+// SDiff returns the members of the first set that are not in the others.
+func (c *SetClient) SDiff(keys ...string) ([]string, error) {
+	return c.cmdable.SDiff(keys...)
+}
+
+// SInterStore stores the intersection of the given sets in destination.
+func (c *SetClient) SInterStore(destination string, keys ...string) (int, error) {
+	return c.cmdable.SInterStore(destination, keys...)
+}
+
+// SUnionStore stores the union of the given sets in destination.
+func (c *SetClient) SUnionStore(destination string, keys ...string) (int, error) {
+	return c.cmdable.SUnionStore(destination, keys...)
+}
+
+// SDiffStore stores the difference of the given sets in destination.
+func (c *SetClient) SDiffStore(destination string, keys ...string) (int, error) {
+	return c.cmdable.SDiffStore(destination, keys...)
+}
+
+// Sorted set operations.
+
+// ZAdd adds members to a sorted set or updates their scores according to opts.
+func (c *SortedSetClient) ZAdd(key string, opts cache.ZAddOptions, members ...cache.Z) (int, error) {
+	return c.cmdable.ZAdd(key, opts, members...)
+}
+
+// ZIncrBy increments the score of a sorted set member and returns the new score.
+func (c *SortedSetClient) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	return c.cmdable.ZIncrBy(key, increment, member)
+}
+
+// ZScore returns the score of a sorted set member.
+func (c *SortedSetClient) ZScore(key string, member string) (float64, error) {
+	return c.cmdable.ZScore(key, member)
+}
+
+// ZRank returns the 0-based rank of a sorted set member.
+func (c *SortedSetClient) ZRank(key string, member string, rev bool) (int, error) {
+	return c.cmdable.ZRank(key, member, rev)
+}
+
+// ZRange returns the sorted set members between two ranks.
+func (c *SortedSetClient) ZRange(key string, start, stop int, rev bool) ([]cache.Z, error) {
+	return c.cmdable.ZRange(key, start, stop, rev)
+}
+
+// ZRangeByScore returns the sorted set members with a score between min and max.
+func (c *SortedSetClient) ZRangeByScore(key string, min, max cache.ScoreBound, opts cache.ZRangeOptions) ([]cache.Z, error) {
+	return c.cmdable.ZRangeByScore(key, min, max, opts)
+}
+
+// ZRangeByLex returns the sorted set members between min and max lexicographically.
+func (c *SortedSetClient) ZRangeByLex(key string, min, max cache.LexBound, opts cache.ZRangeOptions) ([]string, error) {
+	return c.cmdable.ZRangeByLex(key, min, max, opts)
+}
+
+// ZCount returns the number of sorted set members with a score between min and max.
+func (c *SortedSetClient) ZCount(key string, min, max cache.ScoreBound) (int, error) {
+	return c.cmdable.ZCount(key, min, max)
+}
+
+// ZCard returns the number of members of a sorted set.
+func (c *SortedSetClient) ZCard(key string) (int, error) {
+	return c.cmdable.ZCard(key)
+}
+
+// ZRem removes members from a sorted set.
+func (c *SortedSetClient) ZRem(key string, members ...string) (int, error) {
+	return c.cmdable.ZRem(key, members...)
+}
+
+// ZPopMin removes and returns up to count members with the lowest scores.
+func (c *SortedSetClient) ZPopMin(key string, count int) ([]cache.Z, error) {
+	return c.cmdable.ZPopMin(key, count)
+}
+
+// ZPopMax removes and returns up to count members with the highest scores.
+func (c *SortedSetClient) ZPopMax(key string, count int) ([]cache.Z, error) {
+	return c.cmdable.ZPopMax(key, count)
+}
+
 // Get retrieves a string value from the cache.
 func (c *Client) Get(key string) (string, error) {
 	return c.String().Get(key)
@@ -137,11 +474,53 @@ func (c *Client) SetWithTTL(key string, value string, ttl time.Duration) error {
 	return c.String().SetWithTTL(key, value, ttl)
 }
 
+// SetWithOptions stores a string value under the conditions and with the
+// expiration given by opts.
+func (c *Client) SetWithOptions(key string, value string, opts cache.SetOptions) (cache.SetResult, error) {
+	return c.String().SetWithOptions(key, value, opts)
+}
+
+// MGet returns the string values of keys, read atomically, mapped by key.
+func (c *Client) MGet(keys ...string) map[string]string {
+	return c.String().MGet(keys...)
+}
+
+// MSet atomically stores several string values.
+func (c *Client) MSet(values map[string]string) error {
+	return c.String().MSet(values)
+}
+
+// MSetNX atomically stores several string values if none of the keys
+// exists, and reports whether it did.
+func (c *Client) MSetNX(values map[string]string) (bool, error) {
+	return c.String().MSetNX(values)
+}
+
 // Update updates an existing string value in the cache.
 func (c *Client) Update(key string, value string) error {
 	return c.String().Update(key, value)
 }
 
+// Incr atomically increments the integer value of a key by one.
+func (c *Client) Incr(key string) (int64, error) {
+	return c.String().Incr(key)
+}
+
+// IncrBy atomically increments the integer value of a key.
+func (c *Client) IncrBy(key string, increment int64) (int64, error) {
+	return c.String().IncrBy(key, increment)
+}
+
+// DecrBy atomically decrements the integer value of a key.
+func (c *Client) DecrBy(key string, decrement int64) (int64, error) {
+	return c.String().DecrBy(key, decrement)
+}
+
+// IncrByFloat atomically increments the numeric value of a key by a float.
+func (c *Client) IncrByFloat(key string, increment float64) (float64, error) {
+	return c.String().IncrByFloat(key, increment)
+}
+
 // PushFront adds a value to the front of a list.
 func (c *Client) PushFront(key string, value string) error {
 	return c.List().PushFront(key, value)
@@ -162,20 +541,220 @@ func (c *Client) PopBack(key string) (string, error) {
 	return c.List().PopBack(key)
 }
 
+// BPopFront removes and returns the first element of the first non-empty
+// list among keys, waiting for one until timeout elapses or ctx is done.
+func (c *Client) BPopFront(ctx context.Context, timeout time.Duration, keys ...string) (key, value string, err error) {
+	return c.List().BPopFront(ctx, timeout, keys...)
+}
+
+// BPopBack removes and returns the last element of the first non-empty
+// list among keys, waiting for one until timeout elapses or ctx is done.
+func (c *Client) BPopBack(ctx context.Context, timeout time.Duration, keys ...string) (key, value string, err error) {
+	return c.List().BPopBack(ctx, timeout, keys...)
+}
+
+// ListMove atomically moves an element between lists and returns it.
+func (c *Client) ListMove(source, destination string, from, to ListEnd) (string, error) {
+	return c.List().ListMove(source, destination, from, to)
+}
+
+// BMove atomically moves an element between lists, waiting for one until
+// timeout elapses or ctx is done.
+func (c *Client) BMove(ctx context.Context, source, destination string, from, to ListEnd, timeout time.Duration) (string, error) {
+	return c.List().BMove(ctx, source, destination, from, to, timeout)
+}
+
 // ListRange returns a range of elements from a list.
 func (c *Client) ListRange(key string, start, end int) ([]string, error) {
 	return c.List().ListRange(key, start, end)
 }
 
+// ListLen returns the number of elements of a list, zero if it is missing.
+func (c *Client) ListLen(key string) (int, error) {
+	return c.List().ListLen(key)
+}
+
+// ListIndex returns the element at index in a list.
+func (c *Client) ListIndex(key string, index int) (string, error) {
+	return c.List().ListIndex(key, index)
+}
+
+// ListSet replaces the element at index in a list.
+func (c *Client) ListSet(key string, index int, value string) error {
+	return c.List().ListSet(key, index, value)
+}
+
+// ListInsert inserts value before or after pivot in a list and returns the
+// new length of the list.
+func (c *Client) ListInsert(key string, before bool, pivot, value string) (int, error) {
+	return c.List().ListInsert(key, before, pivot, value)
+}
+
+// ListRemove removes occurrences of value from a list and returns their
+// number.
+func (c *Client) ListRemove(key string, count int, value string) (int, error) {
+	return c.List().ListRemove(key, count, value)
+}
+
+// ListTrim keeps only the elements of a list between start and end.
+func (c *Client) ListTrim(key string, start, end int) error {
+	return c.List().ListTrim(key, start, end)
+}
+
+// ListPos returns the indexes of the elements of a list equal to value.
+func (c *Client) ListPos(key string, value string, opts cache.ListPosOptions) ([]int, error) {
+	return c.List().ListPos(key, value, opts)
+}
+
+// HSet sets fields in a hash and returns the number of added fields.
+func (c *Client) HSet(key string, fields map[string]string) (int, error) {
+	return c.Hash().HSet(key, fields)
+}
+
+// HGet retrieves the value of a hash field.
+func (c *Client) HGet(key string, field string) (string, error) {
+	return c.Hash().HGet(key, field)
+}
+
+// HDel removes fields from a hash and returns the number of removed fields.
+func (c *Client) HDel(key string, fields ...string) (int, error) {
+	return c.Hash().HDel(key, fields...)
+}
+
+// HGetAll retrieves all fields and values of a hash.
+func (c *Client) HGetAll(key string) (map[string]string, error) {
+	return c.Hash().HGetAll(key)
+}
+
+// HIncrBy increments the integer value of a hash field.
+func (c *Client) HIncrBy(key string, field string, increment int64) (int64, error) {
+	return c.Hash().HIncrBy(key, field, increment)
+}
+
+// SAdd adds members to a set and returns the number of added members.
+func (c *Client) SAdd(key string, members ...string) (int, error) {
+	return c.Sets().SAdd(key, members...)
+}
+
+// SRem removes members from a set and returns the number of removed members.
+func (c *Client) SRem(key string, members ...string) (int, error) {
+	return c.Sets().SRem(key, members...)
+}
+
+// SIsMember reports whether member belongs to a set.
+func (c *Client) SIsMember(key string, member string) (bool, error) {
+	return c.Sets().SIsMember(key, member)
+}
+
+// SMembers returns the members of a set in sorted order.
+func (c *Client) SMembers(key string) ([]string, error) {
+	return c.Sets().SMembers(key)
+}
+
+// SCard returns the number of members of a set.
+func (c *Client) SCard(key string) (int, error) {
+	return c.Sets().SCard(key)
+}
+
+// SInter returns the intersection of the given sets.
+func (c *Client) SInter(keys ...string) ([]string, error) {
+	return c.Sets().SInter(keys...)
+}
+
+// SUnion returns the union of the given sets.
+func (c *Client) SUnion(keys ...string) ([]string, error) {
+	return c.Sets().SUnion(keys...)
+}
+
+// SDiff returns the members of the first set that are not in the others.
+func (c *Client) SDiff(keys ...string) ([]string, error) {
+	return c.Sets().SDiff(keys...)
+}
+
+// SInterStore stores the intersection of the given sets in destination.
+func (c *Client) SInterStore(destination string, keys ...string) (int, error) {
+	return c.Sets().SInterStore(destination, keys...)
+}
+
+// SUnionStore stores the union of the given sets in destination.
+func (c *Client) SUnionStore(destination string, keys ...string) (int, error) {
+	return c.Sets().SUnionStore(destination, keys...)
+}
+
+// SDiffStore stores the difference of the given sets in destination.
+func (c *Client) SDiffStore(destination string, keys ...string) (int, error) {
+	return c.Sets().SDiffStore(destination, keys...)
+}
+
+// ZAdd adds members to a sorted set or updates their scores according to opts.
+func (c *Client) ZAdd(key string, opts cache.ZAddOptions, members ...cache.Z) (int, error) {
+	return c.SortedSet().ZAdd(key, opts, members...)
+}
+
+// ZIncrBy increments the score of a sorted set member and returns the new score.
+func (c *Client) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	return c.SortedSet().ZIncrBy(key, increment, member)
+}
+
+// ZScore returns the score of a sorted set member.
+func (c *Client) ZScore(key string, member string) (float64, error) {
+	return c.SortedSet().ZScore(key, member)
+}
+
+// ZRank returns the 0-based rank of a sorted set member.
+func (c *Client) ZRank(key string, member string, rev bool) (int, error) {
+	return c.SortedSet().ZRank(key, member, rev)
+}
+
+// ZRange returns the sorted set members between two ranks.
+func (c *Client) ZRange(key string, start, stop int, rev bool) ([]cache.Z, error) {
+	return c.SortedSet().ZRange(key, start, stop, rev)
+}
+
+// ZRangeByScore returns the sorted set members with a score between min and max.
+func (c *Client) ZRangeByScore(key string, min, max cache.ScoreBound, opts cache.ZRangeOptions) ([]cache.Z, error) {
+	return c.SortedSet().ZRangeByScore(key, min, max, opts)
+}
+
+// ZRangeByLex returns the sorted set members between min and max lexicographically.
+func (c *Client) ZRangeByLex(key string, min, max cache.LexBound, opts cache.ZRangeOptions) ([]string, error) {
+	return c.SortedSet().ZRangeByLex(key, min, max, opts)
+}
+
+// ZCount returns the number of sorted set members with a score between min and max.
+func (c *Client) ZCount(key string, min, max cache.ScoreBound) (int, error) {
+	return c.SortedSet().ZCount(key, min, max)
+}
+
+// ZCard returns the number of members of a sorted set.
+func (c *Client) ZCard(key string) (int, error) {
+	return c.SortedSet().ZCard(key)
+}
+
+// ZRem removes members from a sorted set.
+func (c *Client) ZRem(key string, members ...string) (int, error) {
+	return c.SortedSet().ZRem(key, members...)
+}
+
+// ZPopMin removes and returns up to count members with the lowest scores.
+func (c *Client) ZPopMin(key string, count int) ([]cache.Z, error) {
+	return c.SortedSet().ZPopMin(key, count)
+}
+
+// ZPopMax removes and returns up to count members with the highest scores.
+func (c *Client) ZPopMax(key string, count int) ([]cache.Z, error) {
+	return c.SortedSet().ZPopMax(key, count)
+}
+
 // TTLClient provides a client API for TTL operations.
 type TTLClient struct {
-	cmdable cache.TTLCmdable
+	cmdable ttlCmdable
 }
 
 // TTL returns a client for TTL operations.
 func (c *Client) TTL() *TTLClient {
 	return &TTLClient{
-		cmdable: c.cache,
+		cmdable: c.store(),
 	}
 }
 
@@ -186,6 +765,11 @@ func (c *TTLClient) SetTTL(key string, ttl time.Duration) error {
 	return c.cmdable.SetTTL(key, ttl)
 }
 
+// ExpireAt sets the absolute expiration time of a key.
+func (c *TTLClient) ExpireAt(key string, at time.Time) error {
+	return c.cmdable.ExpireAt(key, at)
+}
+
 // GetTTL returns the remaining TTL for a key.
 func (c *TTLClient) GetTTL(key string) (time.Duration, error) {
 	ttl, ok := c.cmdable.GetTTL(key)
@@ -195,6 +779,29 @@ func (c *TTLClient) GetTTL(key string) (time.Duration, error) {
 	return ttl, nil
 }
 
+// ExpireWithOptions expires a key after ttl, with millisecond precision, if
+// opts allow it, and reports whether it did. A non-positive ttl deletes the
+// key.
+func (c *TTLClient) ExpireWithOptions(key string, ttl time.Duration, opts cache.ExpireOptions) (bool, error) {
+	return c.cmdable.ExpireWithOptions(key, ttl, opts)
+}
+
+// ExpireAtWithOptions sets the absolute expiration time of a key if opts
+// allow it, and reports whether it did. A time in the past deletes the key.
+func (c *TTLClient) ExpireAtWithOptions(key string, at time.Time, opts cache.ExpireOptions) (bool, error) {
+	return c.cmdable.ExpireAtWithOptions(key, at, opts)
+}
+
+// ExpireTime returns the absolute expiration time of a key, or the zero
+// time if it does not expire.
+func (c *TTLClient) ExpireTime(key string) (time.Time, error) {
+	at, ok := c.cmdable.ExpireTime(key)
+	if !ok {
+		return time.Time{}, ErrKeyNotFound
+	}
+	return at, nil
+}
+
 // RemoveTTL removes the TTL for a key.
 func (c *TTLClient) RemoveTTL(key string) error {
 	return c.cmdable.RemoveTTL(key)
@@ -205,16 +812,152 @@ func (c *Client) SetTTL(key string, ttl time.Duration) error {
 	return c.TTL().SetTTL(key, ttl)
 }
 
+// ExpireAt sets the absolute expiration time of a key.
+func (c *Client) ExpireAt(key string, at time.Time) error {
+	return c.TTL().ExpireAt(key, at)
+}
+
 // GetTTL returns the remaining TTL for a key.
 func (c *Client) GetTTL(key string) (time.Duration, error) {
 	return c.TTL().GetTTL(key)
 }
 
+// ExpireWithOptions expires a key after ttl if opts allow it.
+func (c *Client) ExpireWithOptions(key string, ttl time.Duration, opts cache.ExpireOptions) (bool, error) {
+	return c.TTL().ExpireWithOptions(key, ttl, opts)
+}
+
+// ExpireAtWithOptions sets the absolute expiration time of a key if opts
+// allow it.
+func (c *Client) ExpireAtWithOptions(key string, at time.Time, opts cache.ExpireOptions) (bool, error) {
+	return c.TTL().ExpireAtWithOptions(key, at, opts)
+}
+
+// ExpireTime returns the absolute expiration time of a key.
+func (c *Client) ExpireTime(key string) (time.Time, error) {
+	return c.TTL().ExpireTime(key)
+}
+
 // RemoveTTL removes the TTL for a key.
 func (c *Client) RemoveTTL(key string) error {
 	return c.TTL().RemoveTTL(key)
 }
 
+// Message is a message received from a pub/sub subscription.
+type Message = pubsub.Message
+
+// Event is a keyspace notification.
+type Event = cache.Event
+
+// PubSubClient provides a client API for publish/subscribe messaging.
+type PubSubClient struct {
+	cmdable cache.PubSubCmdable
+}
+
+// PubSub returns a client for publish/subscribe messaging.
+func (c *Client) PubSub() *PubSubClient {
+	return &PubSubClient{
+		cmdable: c.cache,
+	}
+}
+
+// Pub/sub operations.
+
+// Publish posts a message to a channel and returns the number of
+// subscribers that received it.
+func (c *PubSubClient) Publish(channel string, message string) int {
+	return c.cmdable.Publish(channel, message)
+}
+
+// Subscribe listens on the given channels. Messages are delivered on the
+// returned channel until cancel is called. The channel is also closed if
+// the subscriber falls too far behind the published messages.
+func (c *PubSubClient) Subscribe(channels ...string) (<-chan Message, func()) {
+	return subscription(c.cmdable.Subscribe(channels...))
+}
+
+// PSubscribe listens on the channels matching the given glob patterns, such
+// as "news.*". Messages are delivered on the returned channel until cancel
+// is called. The channel is also closed if the subscriber falls too far
+// behind the published messages.
+func (c *PubSubClient) PSubscribe(patterns ...string) (<-chan Message, func()) {
+	return subscription(c.cmdable.PSubscribe(patterns...))
+}
+
+// subscription exposes sub as a message channel and a cancel function.
+func subscription(sub *pubsub.Subscription) (<-chan Message, func()) {
+	return sub.C(), func() {
+		_ = sub.Close()
+	}
+}
+
+// Events listens for keyspace notifications of the given types, such as
+// "set" or "expired", or of every type if none are given. They are read
+// from the keyevent channels, so the cache must publish them with
+// cache.EventsKeyevent. Events are delivered on the returned channel until
+// cancel is called. The channel is also closed if the subscriber falls too
+// far behind the published events.
+func (c *PubSubClient) Events(types ...string) (<-chan Event, func()) {
+	var sub *pubsub.Subscription
+	if len(types) == 0 {
+		sub = c.cmdable.PSubscribe(cache.KeyeventPrefix + "*")
+	} else {
+		channels := make([]string, len(types))
+		for i, typ := range types {
+			channels[i] = cache.KeyeventPrefix + typ
+		}
+		sub = c.cmdable.Subscribe(channels...)
+	}
+
+	events := make(chan Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(events)
+		for msg := range sub.C() {
+			event, ok := cache.ParseEvent(msg)
+			if !ok {
+				continue
+			}
+			select {
+			case events <- event:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			close(done)
+			_ = sub.Close()
+		})
+	}
+}
+
+// Publish posts a message to a channel and returns the number of
+// subscribers that received it.
+func (c *Client) Publish(channel string, message string) int {
+	return c.PubSub().Publish(channel, message)
+}
+
+// Subscribe listens on the given channels until cancel is called.
+func (c *Client) Subscribe(channels ...string) (<-chan Message, func()) {
+	return c.PubSub().Subscribe(channels...)
+}
+
+// PSubscribe listens on the channels matching the given glob patterns until
+// cancel is called.
+func (c *Client) PSubscribe(patterns ...string) (<-chan Message, func()) {
+	return c.PubSub().PSubscribe(patterns...)
+}
+
+// Events listens for keyspace notifications of the given types, or of every
+// type if none are given, until cancel is called.
+func (c *Client) Events(types ...string) (<-chan Event, func()) {
+	return c.PubSub().Events(types...)
+}
+
 // General operations.
 
 // Exists checks if a key exists in the cache.
@@ -231,6 +974,61 @@ func (c *Client) Type(key string) (cache.DataType, error) {
 	return dataType, nil
 }
 
+// Rename renames key to newKey, keeping its value, type and TTL, and
+// replaces newKey if it exists. It returns cache.ErrKeyNotFound if key is
+// missing.
+func (c *Client) Rename(key, newKey string) error {
+	return c.cache.Rename(key, newKey, true)
+}
+
+// RenameNX renames key to newKey like Rename, unless newKey exists, in
+// which case it returns ErrKeyExists.
+func (c *Client) RenameNX(key, newKey string) error {
+	return c.cache.Rename(key, newKey, false)
+}
+
+// Copy stores a deep copy of source, with its type and TTL, at
+// destination. An existing destination is replaced if replace is true;
+// otherwise Copy returns ErrKeyExists.
+func (c *Client) Copy(source, destination string, replace bool) error {
+	return c.cache.Copy(source, destination, replace)
+}
+
+// Scan returns some of the keys matching opts, starting at cursor, and the
+// cursor of the next call, which is 0 once the scan is complete. Use
+// ScanIter to go through every key.
+func (c *Client) Scan(cursor uint64, opts cache.ScanOptions) ([]string, uint64) {
+	return c.cache.Scan(cursor, opts)
+}
+
+// ScanIter iterates over the keys matching opts, a page at a time, so that
+// the cache is never locked for the whole iteration. Every key present for
+// the whole iteration is yielded at least once, and possibly more than
+// once; keys added or removed in the meantime may or may not be yielded.
+func (c *Client) ScanIter(opts cache.ScanOptions) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		var cursor uint64
+		for {
+			var keys []string
+			keys, cursor = c.cache.Scan(cursor, opts)
+			for _, key := range keys {
+				if !yield(key) {
+					return
+				}
+			}
+			if cursor == 0 {
+				return
+			}
+		}
+	}
+}
+
+// Keys returns every key matching the glob pattern, in sorted order. It is
+// meant for small caches; ScanIter does not block the cache.
+func (c *Client) Keys(pattern string) []string {
+	return c.cache.Keys(pattern)
+}
+
 // Clear removes all items from the cache.
 func (c *Client) Clear() error {
 	return c.cache.Clear()
@@ -238,8 +1036,8 @@ func (c *Client) Clear() error {
 
 // Close closes the client and releases any resources.
 func (c *Client) Close() error {
-	if memCache, ok := c.cache.(*cache.MemoryCache); ok {
-		memCache.Stop()
+	if stopper, ok := c.cache.(interface{ Stop() }); ok {
+		stopper.Stop()
 	}
 	return nil
 }
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Codec converts values of type T to and from the strings stored in the
+// cache, for Typed and TypedList.
+type Codec[T any] interface {
+	Encode(value T) (string, error)
+	Decode(data string) (T, error)
+}
+
+// Typed value errors.
+var (
+	// ErrEncode is returned by typed clients if their codec cannot encode
+	// a value; nothing is then written.
+	ErrEncode = errors.New("cannot encode value")
+	// ErrDecode is matched by the DecodeError that typed clients return
+	// if their codec cannot decode a stored value.
+	ErrDecode = errors.New("cannot decode value")
+	// errTrailingData is returned by BinaryCodec for data longer than the
+	// value it decodes.
+	errTrailingData = errors.New("trailing data after value")
+)
+
+// DecodeError reports a value stored at Key that the codec of a typed
+// client could not decode. It matches ErrDecode, unlike a missing key,
+// which is reported with ErrKeyNotFound or ErrKeyNotFoundOrEmpty.
+type DecodeError struct {
+	Key string
+	// Data is the stored value, which is lost if it was popped.
+	Data string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%v of %q: %v", ErrDecode, e.Key, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrDecode.
+func (e *DecodeError) Is(target error) bool {
+	return target == ErrDecode
+}
+
+// JSONCodec encodes values as JSON.
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(value T) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+func (JSONCodec[T]) Decode(data string) (T, error) {
+	var value T
+	err := json.Unmarshal([]byte(data), &value)
+	return value, err
+}
+
+// GobCodec encodes values with encoding/gob. Every value carries its type
+// description, so it is larger than with JSONCodec but decodes faster.
+// Maps are encoded in no particular order, so equal values may have
+// different encodings.
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Encode(value T) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (GobCodec[T]) Decode(data string) (T, error) {
+	var value T
+	err := gob.NewDecoder(strings.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+// BytesCodec stores byte slices as they are.
+type BytesCodec struct{}
+
+func (BytesCodec) Encode(value []byte) (string, error) {
+	return string(value), nil
+}
+
+func (BytesCodec) Decode(data string) ([]byte, error) {
+	return []byte(data), nil
+}
+
+// BinaryCodec encodes values in a compact binary form. A T implementing
+// encoding.BinaryMarshaler, with *T implementing
+// encoding.BinaryUnmarshaler, encodes itself, as time.Time does. Any other
+// T must have a fixed size, like the numbers, booleans and arrays and
+// structs of them, and is encoded with encoding/binary in big-endian
+// order.
+type BinaryCodec[T any] struct{}
+
+func (BinaryCodec[T]) Encode(value T) (string, error) {
+	if m, ok := any(value).(encoding.BinaryMarshaler); ok {
+		data, err := m.MarshalBinary()
+		return string(data), err
+	}
+	data, err := binary.Append(nil, binary.BigEndian, value)
+	return string(data), err
+}
+
+func (BinaryCodec[T]) Decode(data string) (T, error) {
+	var value T
+	if u, ok := any(&value).(encoding.BinaryUnmarshaler); ok {
+		err := u.UnmarshalBinary([]byte(data))
+		return value, err
+	}
+	n, err := binary.Decode([]byte(data), binary.BigEndian, &value)
+	if err == nil && n < len(data) {
+		err = errTrailingData
+	}
+	return value, err
+}
@@ -0,0 +1,507 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// The methods with a Context suffix are the methods without it that take a
+// context. They fail with the error of the context once it is done, and
+// Scan, Keys and Clear may stop partway through; Clear then removes
+// nothing.
+
+// stringCmdable is what a StringClient operates on.
+type stringCmdable interface {
+	cache.StringCmdable
+	cache.StringCmdableContext
+}
+
+// listCmdable is what a ListClient operates on.
+type listCmdable interface {
+	cache.ListCmdable
+	cache.ListCmdableContext
+}
+
+// ttlCmdable is what a TTLClient operates on.
+type ttlCmdable interface {
+	cache.TTLCmdable
+	cache.TTLCmdableContext
+}
+
+// store returns the cache with the variants of its operations that take a
+// context.
+func (c *Client) store() cache.ContextCache {
+	return cache.WithContext(c.cache)
+}
+
+// String operations.
+
+// GetContext retrieves a string value from the cache.
+func (c *StringClient) GetContext(ctx context.Context, key string) (string, error) {
+	value, ok, err := c.cmdable.GetContext(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrKeyNotFound
+	}
+	return value, nil
+}
+
+// SetContext stores a string value in the cache.
+func (c *StringClient) SetContext(ctx context.Context, key string, value string) error {
+	return c.cmdable.SetContext(ctx, key, value)
+}
+
+// SetWithTTLContext stores a string value in the cache with a TTL.
+func (c *StringClient) SetWithTTLContext(ctx context.Context, key string, value string, ttl time.Duration) error {
+	return c.cmdable.SetWithTTLContext(ctx, key, value, ttl)
+}
+
+// SetWithOptionsContext stores a string value under the conditions and
+// with the expiration given by opts.
+func (c *StringClient) SetWithOptionsContext(ctx context.Context, key string, value string, opts cache.SetOptions) (cache.SetResult, error) {
+	return c.cmdable.SetWithOptionsContext(ctx, key, value, opts)
+}
+
+// MGetContext returns the string values of keys, read atomically, mapped
+// by key.
+func (c *StringClient) MGetContext(ctx context.Context, keys ...string) (map[string]string, error) {
+	return c.cmdable.MGetContext(ctx, keys...)
+}
+
+// MSetContext atomically stores several string values.
+func (c *StringClient) MSetContext(ctx context.Context, values map[string]string) error {
+	return c.cmdable.MSetContext(ctx, values)
+}
+
+// MSetNXContext atomically stores several string values if none of the
+// keys exists, and reports whether it did.
+func (c *StringClient) MSetNXContext(ctx context.Context, values map[string]string) (bool, error) {
+	return c.cmdable.MSetNXContext(ctx, values)
+}
+
+// UpdateContext updates an existing string value in the cache.
+func (c *StringClient) UpdateContext(ctx context.Context, key string, value string) error {
+	return c.cmdable.UpdateContext(ctx, key, value)
+}
+
+// IncrContext atomically increments the integer value of a key by one.
+func (c *StringClient) IncrContext(ctx context.Context, key string) (int64, error) {
+	return c.cmdable.IncrContext(ctx, key)
+}
+
+// IncrByContext atomically increments the integer value of a key.
+func (c *StringClient) IncrByContext(ctx context.Context, key string, increment int64) (int64, error) {
+	return c.cmdable.IncrByContext(ctx, key, increment)
+}
+
+// DecrByContext atomically decrements the integer value of a key.
+func (c *StringClient) DecrByContext(ctx context.Context, key string, decrement int64) (int64, error) {
+	return c.cmdable.DecrByContext(ctx, key, decrement)
+}
+
+// IncrByFloatContext atomically increments the numeric value of a key by a
+// float.
+func (c *StringClient) IncrByFloatContext(ctx context.Context, key string, increment float64) (float64, error) {
+	return c.cmdable.IncrByFloatContext(ctx, key, increment)
+}
+
+// GetContext retrieves a string value from the cache.
+func (c *Client) GetContext(ctx context.Context, key string) (string, error) {
+	return c.String().GetContext(ctx, key)
+}
+
+// SetContext stores a string value in the cache.
+func (c *Client) SetContext(ctx context.Context, key string, value string) error {
+	return c.String().SetContext(ctx, key, value)
+}
+
+// SetWithTTLContext stores a string value in the cache with a TTL.
+func (c *Client) SetWithTTLContext(ctx context.Context, key string, value string, ttl time.Duration) error {
+	return c.String().SetWithTTLContext(ctx, key, value, ttl)
+}
+
+// SetWithOptionsContext stores a string value under the conditions and
+// with the expiration given by opts.
+func (c *Client) SetWithOptionsContext(ctx context.Context, key string, value string, opts cache.SetOptions) (cache.SetResult, error) {
+	return c.String().SetWithOptionsContext(ctx, key, value, opts)
+}
+
+// MGetContext returns the string values of keys, read atomically, mapped
+// by key.
+func (c *Client) MGetContext(ctx context.Context, keys ...string) (map[string]string, error) {
+	return c.String().MGetContext(ctx, keys...)
+}
+
+// MSetContext atomically stores several string values.
+func (c *Client) MSetContext(ctx context.Context, values map[string]string) error {
+	return c.String().MSetContext(ctx, values)
+}
+
+// MSetNXContext atomically stores several string values if none of the
+// keys exists, and reports whether it did.
+func (c *Client) MSetNXContext(ctx context.Context, values map[string]string) (bool, error) {
+	return c.String().MSetNXContext(ctx, values)
+}
+
+// UpdateContext updates an existing string value in the cache.
+func (c *Client) UpdateContext(ctx context.Context, key string, value string) error {
+	return c.String().UpdateContext(ctx, key, value)
+}
+
+// IncrContext atomically increments the integer value of a key by one.
+func (c *Client) IncrContext(ctx context.Context, key string) (int64, error) {
+	return c.String().IncrContext(ctx, key)
+}
+
+// IncrByContext atomically increments the integer value of a key.
+func (c *Client) IncrByContext(ctx context.Context, key string, increment int64) (int64, error) {
+	return c.String().IncrByContext(ctx, key, increment)
+}
+
+// DecrByContext atomically decrements the integer value of a key.
+func (c *Client) DecrByContext(ctx context.Context, key string, decrement int64) (int64, error) {
+	return c.String().DecrByContext(ctx, key, decrement)
+}
+
+// IncrByFloatContext atomically increments the numeric value of a key by a
+// float.
+func (c *Client) IncrByFloatContext(ctx context.Context, key string, increment float64) (float64, error) {
+	return c.String().IncrByFloatContext(ctx, key, increment)
+}
+
+// List operations.
+
+// PushFrontContext adds a value to the front of a list.
+func (c *ListClient) PushFrontContext(ctx context.Context, key string, value string) error {
+	return c.cmdable.PushFrontContext(ctx, key, value)
+}
+
+// PushBackContext adds a value to the back of a list.
+func (c *ListClient) PushBackContext(ctx context.Context, key string, value string) error {
+	return c.cmdable.PushBackContext(ctx, key, value)
+}
+
+// PopFrontContext removes and returns the first element of a list.
+func (c *ListClient) PopFrontContext(ctx context.Context, key string) (string, error) {
+	value, ok, err := c.cmdable.PopFrontContext(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrKeyNotFoundOrEmpty
+	}
+	return value, nil
+}
+
+// PopBackContext removes and returns the last element of a list.
+func (c *ListClient) PopBackContext(ctx context.Context, key string) (string, error) {
+	value, ok, err := c.cmdable.PopBackContext(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrKeyNotFoundOrEmpty
+	}
+	return value, nil
+}
+
+// ListMoveContext atomically moves an element from the from end of the
+// source list to the to end of the destination list and returns it. It
+// returns ErrKeyNotFoundOrEmpty if source has no element.
+func (c *ListClient) ListMoveContext(ctx context.Context, source, destination string, from, to ListEnd) (string, error) {
+	value, moved, err := c.cmdable.ListMoveContext(ctx, source, destination, from, to)
+	if err != nil {
+		return "", err
+	}
+	if !moved {
+		return "", ErrKeyNotFoundOrEmpty
+	}
+	return value, nil
+}
+
+// ListRangeContext returns a range of elements from a list.
+func (c *ListClient) ListRangeContext(ctx context.Context, key string, start, end int) ([]string, error) {
+	return c.cmdable.ListRangeContext(ctx, key, start, end)
+}
+
+// ListLenContext returns the number of elements of a list, zero if it is
+// missing.
+func (c *ListClient) ListLenContext(ctx context.Context, key string) (int, error) {
+	return c.cmdable.ListLenContext(ctx, key)
+}
+
+// ListIndexContext returns the element at index in a list.
+func (c *ListClient) ListIndexContext(ctx context.Context, key string, index int) (string, error) {
+	return c.cmdable.ListIndexContext(ctx, key, index)
+}
+
+// ListSetContext replaces the element at index in a list.
+func (c *ListClient) ListSetContext(ctx context.Context, key string, index int, value string) error {
+	return c.cmdable.ListSetContext(ctx, key, index, value)
+}
+
+// ListInsertContext inserts value before or after the first occurrence of
+// pivot in a list and returns the new length of the list.
+func (c *ListClient) ListInsertContext(ctx context.Context, key string, before bool, pivot, value string) (int, error) {
+	return c.cmdable.ListInsertContext(ctx, key, before, pivot, value)
+}
+
+// ListRemoveContext removes occurrences of value from a list, as
+// ListRemove does, and returns the number of removed elements.
+func (c *ListClient) ListRemoveContext(ctx context.Context, key string, count int, value string) (int, error) {
+	return c.cmdable.ListRemoveContext(ctx, key, count, value)
+}
+
+// ListTrimContext keeps only the elements of a list between start and end.
+func (c *ListClient) ListTrimContext(ctx context.Context, key string, start, end int) error {
+	return c.cmdable.ListTrimContext(ctx, key, start, end)
+}
+
+// ListPosContext returns the indexes of the elements of a list equal to
+// value, as selected by opts.
+func (c *ListClient) ListPosContext(ctx context.Context, key string, value string, opts cache.ListPosOptions) ([]int, error) {
+	return c.cmdable.ListPosContext(ctx, key, value, opts)
+}
+
+// PushFrontContext adds a value to the front of a list.
+func (c *Client) PushFrontContext(ctx context.Context, key string, value string) error {
+	return c.List().PushFrontContext(ctx, key, value)
+}
+
+// PushBackContext adds a value to the back of a list.
+func (c *Client) PushBackContext(ctx context.Context, key string, value string) error {
+	return c.List().PushBackContext(ctx, key, value)
+}
+
+// PopFrontContext removes and returns the first element of a list.
+func (c *Client) PopFrontContext(ctx context.Context, key string) (string, error) {
+	return c.List().PopFrontContext(ctx, key)
+}
+
+// PopBackContext removes and returns the last element of a list.
+func (c *Client) PopBackContext(ctx context.Context, key string) (string, error) {
+	return c.List().PopBackContext(ctx, key)
+}
+
+// ListMoveContext atomically moves an element between lists.
+func (c *Client) ListMoveContext(ctx context.Context, source, destination string, from, to ListEnd) (string, error) {
+	return c.List().ListMoveContext(ctx, source, destination, from, to)
+}
+
+// ListRangeContext returns a range of elements from a list.
+func (c *Client) ListRangeContext(ctx context.Context, key string, start, end int) ([]string, error) {
+	return c.List().ListRangeContext(ctx, key, start, end)
+}
+
+// ListLenContext returns the number of elements of a list.
+func (c *Client) ListLenContext(ctx context.Context, key string) (int, error) {
+	return c.List().ListLenContext(ctx, key)
+}
+
+// ListIndexContext returns the element at index in a list.
+func (c *Client) ListIndexContext(ctx context.Context, key string, index int) (string, error) {
+	return c.List().ListIndexContext(ctx, key, index)
+}
+
+// ListSetContext replaces the element at index in a list.
+func (c *Client) ListSetContext(ctx context.Context, key string, index int, value string) error {
+	return c.List().ListSetContext(ctx, key, index, value)
+}
+
+// ListInsertContext inserts value before or after pivot in a list.
+func (c *Client) ListInsertContext(ctx context.Context, key string, before bool, pivot, value string) (int, error) {
+	return c.List().ListInsertContext(ctx, key, before, pivot, value)
+}
+
+// ListRemoveContext removes occurrences of value from a list.
+func (c *Client) ListRemoveContext(ctx context.Context, key string, count int, value string) (int, error) {
+	return c.List().ListRemoveContext(ctx, key, count, value)
+}
+
+// ListTrimContext keeps only the elements of a list between start and end.
+func (c *Client) ListTrimContext(ctx context.Context, key string, start, end int) error {
+	return c.List().ListTrimContext(ctx, key, start, end)
+}
+
+// ListPosContext returns the indexes of the elements of a list equal to
+// value.
+func (c *Client) ListPosContext(ctx context.Context, key string, value string, opts cache.ListPosOptions) ([]int, error) {
+	return c.List().ListPosContext(ctx, key, value, opts)
+}
+
+// TTL operations.
+
+// SetTTLContext sets the TTL for a key.
+func (c *TTLClient) SetTTLContext(ctx context.Context, key string, ttl time.Duration) error {
+	return c.cmdable.SetTTLContext(ctx, key, ttl)
+}
+
+// ExpireAtContext sets the absolute expiration time of a key.
+func (c *TTLClient) ExpireAtContext(ctx context.Context, key string, at time.Time) error {
+	return c.cmdable.ExpireAtContext(ctx, key, at)
+}
+
+// GetTTLContext returns the remaining TTL for a key.
+func (c *TTLClient) GetTTLContext(ctx context.Context, key string) (time.Duration, error) {
+	ttl, ok, err := c.cmdable.GetTTLContext(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrKeyNotFound
+	}
+	return ttl, nil
+}
+
+// ExpireWithOptionsContext expires a key after ttl if opts allow it, and
+// reports whether it did.
+func (c *TTLClient) ExpireWithOptionsContext(ctx context.Context, key string, ttl time.Duration, opts cache.ExpireOptions) (bool, error) {
+	return c.cmdable.ExpireWithOptionsContext(ctx, key, ttl, opts)
+}
+
+// ExpireAtWithOptionsContext sets the absolute expiration time of a key if
+// opts allow it, and reports whether it did.
+func (c *TTLClient) ExpireAtWithOptionsContext(ctx context.Context, key string, at time.Time, opts cache.ExpireOptions) (bool, error) {
+	return c.cmdable.ExpireAtWithOptionsContext(ctx, key, at, opts)
+}
+
+// ExpireTimeContext returns the absolute expiration time of a key, or the
+// zero time if it does not expire.
+func (c *TTLClient) ExpireTimeContext(ctx context.Context, key string) (time.Time, error) {
+	at, ok, err := c.cmdable.ExpireTimeContext(ctx, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !ok {
+		return time.Time{}, ErrKeyNotFound
+	}
+	return at, nil
+}
+
+// RemoveTTLContext removes the TTL for a key.
+func (c *TTLClient) RemoveTTLContext(ctx context.Context, key string) error {
+	return c.cmdable.RemoveTTLContext(ctx, key)
+}
+
+// SetTTLContext sets the TTL for a key.
+func (c *Client) SetTTLContext(ctx context.Context, key string, ttl time.Duration) error {
+	return c.TTL().SetTTLContext(ctx, key, ttl)
+}
+
+// ExpireAtContext sets the absolute expiration time of a key.
+func (c *Client) ExpireAtContext(ctx context.Context, key string, at time.Time) error {
+	return c.TTL().ExpireAtContext(ctx, key, at)
+}
+
+// GetTTLContext returns the remaining TTL for a key.
+func (c *Client) GetTTLContext(ctx context.Context, key string) (time.Duration, error) {
+	return c.TTL().GetTTLContext(ctx, key)
+}
+
+// ExpireWithOptionsContext expires a key after ttl if opts allow it.
+func (c *Client) ExpireWithOptionsContext(ctx context.Context, key string, ttl time.Duration, opts cache.ExpireOptions) (bool, error) {
+	return c.TTL().ExpireWithOptionsContext(ctx, key, ttl, opts)
+}
+
+// ExpireAtWithOptionsContext sets the absolute expiration time of a key if
+// opts allow it.
+func (c *Client) ExpireAtWithOptionsContext(ctx context.Context, key string, at time.Time, opts cache.ExpireOptions) (bool, error) {
+	return c.TTL().ExpireAtWithOptionsContext(ctx, key, at, opts)
+}
+
+// ExpireTimeContext returns the absolute expiration time of a key.
+func (c *Client) ExpireTimeContext(ctx context.Context, key string) (time.Time, error) {
+	return c.TTL().ExpireTimeContext(ctx, key)
+}
+
+// RemoveTTLContext removes the TTL for a key.
+func (c *Client) RemoveTTLContext(ctx context.Context, key string) error {
+	return c.TTL().RemoveTTLContext(ctx, key)
+}
+
+// General operations.
+
+// RemoveContext removes a key from the cache.
+func (c *Client) RemoveContext(ctx context.Context, key string) error {
+	return c.store().RemoveContext(ctx, key)
+}
+
+// ExistsContext checks if a key exists in the cache.
+func (c *Client) ExistsContext(ctx context.Context, key string) (bool, error) {
+	return c.store().ExistsContext(ctx, key)
+}
+
+// TypeContext returns the type of a key.
+func (c *Client) TypeContext(ctx context.Context, key string) (cache.DataType, error) {
+	dataType, ok, err := c.store().TypeContext(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrKeyNotFound
+	}
+	return dataType, nil
+}
+
+// RenameContext renames key to newKey, replacing newKey if it exists.
+func (c *Client) RenameContext(ctx context.Context, key, newKey string) error {
+	return c.store().RenameContext(ctx, key, newKey, true)
+}
+
+// RenameNXContext renames key to newKey unless newKey exists, in which
+// case it returns ErrKeyExists.
+func (c *Client) RenameNXContext(ctx context.Context, key, newKey string) error {
+	return c.store().RenameContext(ctx, key, newKey, false)
+}
+
+// CopyContext stores a deep copy of source at destination.
+func (c *Client) CopyContext(ctx context.Context, source, destination string, replace bool) error {
+	return c.store().CopyContext(ctx, source, destination, replace)
+}
+
+// ScanContext returns some of the keys matching opts, starting at cursor,
+// and the cursor of the next call.
+func (c *Client) ScanContext(ctx context.Context, cursor uint64, opts cache.ScanOptions) ([]string, uint64, error) {
+	return c.store().ScanContext(ctx, cursor, opts)
+}
+
+// ScanIterContext iterates over the keys matching opts like ScanIter. Once
+// ctx is done it yields its error and stops.
+func (c *Client) ScanIterContext(ctx context.Context, opts cache.ScanOptions) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		store := c.store()
+		var cursor uint64
+		for {
+			keys, next, err := store.ScanContext(ctx, cursor, opts)
+			if err != nil {
+				yield("", err)
+				return
+			}
+			for _, key := range keys {
+				if !yield(key, nil) {
+					return
+				}
+			}
+			if cursor = next; cursor == 0 {
+				return
+			}
+		}
+	}
+}
+
+// KeysContext returns every key matching the glob pattern, in sorted
+// order.
+func (c *Client) KeysContext(ctx context.Context, pattern string) ([]string, error) {
+	return c.store().KeysContext(ctx, pattern)
+}
+
+// ClearContext removes all items from the cache, or nothing if ctx is done
+// first.
+func (c *Client) ClearContext(ctx context.Context) error {
+	return c.store().ClearContext(ctx)
+}
@@ -0,0 +1,232 @@
+package client
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// Transaction errors.
+var (
+	// ErrTxUnsupported is returned by TxPipeline.Exec if the cache of the
+	// client cannot apply transactions.
+	ErrTxUnsupported = errors.New("transactions are not supported by the cache")
+	// ErrTxAborted is the error of the operations of a transaction that was
+	// not applied because a watched key changed.
+	ErrTxAborted = errors.New("transaction aborted: a watched key changed")
+)
+
+// TxPipeline queues operations and applies them atomically with Exec: no
+// other operation on the cache is applied in between. An operation that
+// fails does not prevent the others from being applied.
+//
+// Keys can be watched for optimistic locking: a check-and-set reads the
+// watched keys with the client, queues its writes and retries if Exec
+// reports that one of the keys changed in the meantime.
+type TxPipeline struct {
+	tx *cache.Tx
+	// aborted marks the queued operations as aborted.
+	aborted []func()
+}
+
+// TxCmd holds the result of an operation queued on a TxPipeline, which is
+// set once the pipeline is executed.
+type TxCmd[T any] struct {
+	val T
+	err error
+}
+
+// Result returns the value and error of the operation.
+func (c *TxCmd[T]) Result() (T, error) {
+	return c.val, c.err
+}
+
+// Val returns the value of the operation.
+func (c *TxCmd[T]) Val() T {
+	return c.val
+}
+
+// Err returns the error of the operation.
+func (c *TxCmd[T]) Err() error {
+	return c.err
+}
+
+// TxPipeline returns a pipeline whose operations are applied atomically.
+func (c *Client) TxPipeline() *TxPipeline {
+	p := &TxPipeline{}
+	if t, ok := c.cache.(cache.Transactor); ok {
+		p.tx = cache.NewTx(t)
+	}
+	return p
+}
+
+// Queue adds op to p and returns its pending result. op is called by Exec
+// with a client for the transaction; it must not use any other client of
+// the same cache, nor retain the one it is given.
+func Queue[T any](p *TxPipeline, op func(c *Client) (T, error)) *TxCmd[T] {
+	cmd := &TxCmd[T]{}
+	if p.tx != nil {
+		p.tx.Queue(func(c cache.Cache) (any, error) {
+			cmd.val, cmd.err = op(New(c))
+			return cmd.val, cmd.err
+		})
+		p.aborted = append(p.aborted, func() { cmd.err = ErrTxAborted })
+	}
+	return cmd
+}
+
+// queueErr adds op, which only reports an error, to p.
+func queueErr(p *TxPipeline, op func(c *Client) error) *TxCmd[struct{}] {
+	return Queue(p, func(c *Client) (struct{}, error) {
+		return struct{}{}, op(c)
+	})
+}
+
+// Len returns the number of queued operations.
+func (p *TxPipeline) Len() int {
+	if p.tx == nil {
+		return 0
+	}
+	return p.tx.Len()
+}
+
+// Watch makes Exec apply nothing if one of keys is written, removed or
+// expires from now on.
+func (p *TxPipeline) Watch(keys ...string) {
+	if p.tx != nil {
+		p.tx.Watch(keys...)
+	}
+}
+
+// Unwatch forgets the watched keys.
+func (p *TxPipeline) Unwatch() {
+	if p.tx != nil {
+		p.tx.Unwatch()
+	}
+}
+
+// Discard drops the queued operations and forgets the watched keys.
+func (p *TxPipeline) Discard() {
+	if p.tx != nil {
+		p.tx.Discard()
+	}
+	p.aborted = nil
+}
+
+// Exec applies the queued operations atomically, in order, and returns
+// their results, which are also set on the TxCmd returned when they were
+// queued. If a watched key has changed, nothing is applied, ok is false and
+// the error of every TxCmd is ErrTxAborted. The pipeline is empty
+// afterwards, with no watched keys, and can be reused.
+func (p *TxPipeline) Exec() (results []cache.TxResult, ok bool, err error) {
+	if p.tx == nil {
+		return nil, false, ErrTxUnsupported
+	}
+
+	aborted := p.aborted
+	p.aborted = nil
+	results, ok = p.tx.Exec()
+	if !ok {
+		for _, abort := range aborted {
+			abort()
+		}
+	}
+	return results, ok, nil
+}
+
+// Get queues the retrieval of a string value.
+func (p *TxPipeline) Get(key string) *TxCmd[string] {
+	return Queue(p, func(c *Client) (string, error) { return c.Get(key) })
+}
+
+// Set queues storing a string value.
+func (p *TxPipeline) Set(key string, value string) *TxCmd[struct{}] {
+	return queueErr(p, func(c *Client) error { return c.Set(key, value) })
+}
+
+// SetWithTTL queues storing a string value with a TTL.
+func (p *TxPipeline) SetWithTTL(key string, value string, ttl time.Duration) *TxCmd[struct{}] {
+	return queueErr(p, func(c *Client) error { return c.SetWithTTL(key, value, ttl) })
+}
+
+// SetWithOptions queues storing a string value under the conditions and
+// with the expiration given by opts.
+func (p *TxPipeline) SetWithOptions(key string, value string, opts cache.SetOptions) *TxCmd[cache.SetResult] {
+	return Queue(p, func(c *Client) (cache.SetResult, error) { return c.SetWithOptions(key, value, opts) })
+}
+
+// MSet queues storing several string values.
+func (p *TxPipeline) MSet(values map[string]string) *TxCmd[struct{}] {
+	return queueErr(p, func(c *Client) error { return c.MSet(values) })
+}
+
+// IncrBy queues incrementing the integer value of a key.
+func (p *TxPipeline) IncrBy(key string, increment int64) *TxCmd[int64] {
+	return Queue(p, func(c *Client) (int64, error) { return c.IncrBy(key, increment) })
+}
+
+// IncrByFloat queues incrementing the numeric value of a key by a float.
+func (p *TxPipeline) IncrByFloat(key string, increment float64) *TxCmd[float64] {
+	return Queue(p, func(c *Client) (float64, error) { return c.IncrByFloat(key, increment) })
+}
+
+// Remove queues the removal of a key.
+func (p *TxPipeline) Remove(key string) *TxCmd[struct{}] {
+	return queueErr(p, func(c *Client) error { return c.Remove(key) })
+}
+
+// PushFront queues adding a value to the front of a list.
+func (p *TxPipeline) PushFront(key string, value string) *TxCmd[struct{}] {
+	return queueErr(p, func(c *Client) error { return c.PushFront(key, value) })
+}
+
+// PushBack queues adding a value to the back of a list.
+func (p *TxPipeline) PushBack(key string, value string) *TxCmd[struct{}] {
+	return queueErr(p, func(c *Client) error { return c.PushBack(key, value) })
+}
+
+// PopFront queues removing the first element of a list.
+func (p *TxPipeline) PopFront(key string) *TxCmd[string] {
+	return Queue(p, func(c *Client) (string, error) { return c.PopFront(key) })
+}
+
+// PopBack queues removing the last element of a list.
+func (p *TxPipeline) PopBack(key string) *TxCmd[string] {
+	return Queue(p, func(c *Client) (string, error) { return c.PopBack(key) })
+}
+
+// HSet queues setting fields of a hash.
+func (p *TxPipeline) HSet(key string, fields map[string]string) *TxCmd[int] {
+	return Queue(p, func(c *Client) (int, error) { return c.HSet(key, fields) })
+}
+
+// HIncrBy queues incrementing a field of a hash.
+func (p *TxPipeline) HIncrBy(key string, field string, increment int64) *TxCmd[int64] {
+	return Queue(p, func(c *Client) (int64, error) { return c.HIncrBy(key, field, increment) })
+}
+
+// SAdd queues adding members to a set.
+func (p *TxPipeline) SAdd(key string, members ...string) *TxCmd[int] {
+	return Queue(p, func(c *Client) (int, error) { return c.SAdd(key, members...) })
+}
+
+// SRem queues removing members from a set.
+func (p *TxPipeline) SRem(key string, members ...string) *TxCmd[int] {
+	return Queue(p, func(c *Client) (int, error) { return c.SRem(key, members...) })
+}
+
+// ZAdd queues adding members to a sorted set.
+func (p *TxPipeline) ZAdd(key string, opts cache.ZAddOptions, members ...cache.Z) *TxCmd[int] {
+	return Queue(p, func(c *Client) (int, error) { return c.ZAdd(key, opts, members...) })
+}
+
+// ZIncrBy queues incrementing the score of a member of a sorted set.
+func (p *TxPipeline) ZIncrBy(key string, increment float64, member string) *TxCmd[float64] {
+	return Queue(p, func(c *Client) (float64, error) { return c.ZIncrBy(key, increment, member) })
+}
+
+// SetTTL queues setting the TTL of a key.
+func (p *TxPipeline) SetTTL(key string, ttl time.Duration) *TxCmd[struct{}] {
+	return queueErr(p, func(c *Client) error { return c.SetTTL(key, ttl) })
+}
@@ -0,0 +1,458 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// Typed stores values of type T as strings, encoded with a codec, through
+// a StringClient. The keys are ordinary string keys, so the TTLClient and
+// the general operations of Client apply to them too.
+//
+// Values that the codec cannot decode are reported with a DecodeError,
+// which matches ErrDecode, so that they are told apart from missing keys.
+type Typed[T any] struct {
+	strings *StringClient
+	codec   Codec[T]
+}
+
+// NewTyped creates a typed client for the values of type T, encoded with
+// codec, e.g.
+//
+//	users := client.NewTyped(c.String(), client.JSONCodec[User]{})
+func NewTyped[T any](strings *StringClient, codec Codec[T]) *Typed[T] {
+	return &Typed[T]{
+		strings: strings,
+		codec:   codec,
+	}
+}
+
+// TypedSetResult is the result of Typed.SetWithOptions.
+type TypedSetResult[T any] struct {
+	// Stored reports whether the value was stored.
+	Stored bool
+	// Previous is the previous value of the key, if HadPrevious. Both are
+	// only filled in with SetOptions.Get.
+	Previous    T
+	HadPrevious bool
+}
+
+// encode encodes the value to store at key.
+func encode[T any](codec Codec[T], key string, value T) (string, error) {
+	data, err := codec.Encode(value)
+	if err != nil {
+		return "", fmt.Errorf("%w of %q: %w", ErrEncode, key, err)
+	}
+	return data, nil
+}
+
+// decode decodes the data stored at key.
+func decode[T any](codec Codec[T], key string, data string) (T, error) {
+	value, err := codec.Decode(data)
+	if err != nil {
+		return value, &DecodeError{Key: key, Data: data, Err: err}
+	}
+	return value, nil
+}
+
+// Get retrieves and decodes the value of a key.
+func (t *Typed[T]) Get(key string) (T, error) {
+	return t.GetContext(context.Background(), key)
+}
+
+// GetContext retrieves and decodes the value of a key.
+func (t *Typed[T]) GetContext(ctx context.Context, key string) (T, error) {
+	data, err := t.strings.GetContext(ctx, key)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return decode(t.codec, key, data)
+}
+
+// Set encodes and stores a value.
+func (t *Typed[T]) Set(key string, value T) error {
+	return t.SetContext(context.Background(), key, value)
+}
+
+// SetContext encodes and stores a value.
+func (t *Typed[T]) SetContext(ctx context.Context, key string, value T) error {
+	data, err := encode(t.codec, key, value)
+	if err != nil {
+		return err
+	}
+	return t.strings.SetContext(ctx, key, data)
+}
+
+// SetWithTTL encodes and stores a value with a TTL.
+func (t *Typed[T]) SetWithTTL(key string, value T, ttl time.Duration) error {
+	return t.SetWithTTLContext(context.Background(), key, value, ttl)
+}
+
+// SetWithTTLContext encodes and stores a value with a TTL.
+func (t *Typed[T]) SetWithTTLContext(ctx context.Context, key string, value T, ttl time.Duration) error {
+	data, err := encode(t.codec, key, value)
+	if err != nil {
+		return err
+	}
+	return t.strings.SetWithTTLContext(ctx, key, data, ttl)
+}
+
+// SetWithOptions encodes and stores a value under the conditions and with
+// the expiration given by opts, e.g. keeping the TTL of the key with
+// KeepTTL. If the previous value cannot be decoded, the result is returned
+// with a DecodeError, the new value being stored all the same.
+func (t *Typed[T]) SetWithOptions(key string, value T, opts cache.SetOptions) (TypedSetResult[T], error) {
+	return t.SetWithOptionsContext(context.Background(), key, value, opts)
+}
+
+// SetWithOptionsContext encodes and stores a value under the conditions
+// and with the expiration given by opts, see SetWithOptions.
+func (t *Typed[T]) SetWithOptionsContext(ctx context.Context, key string, value T, opts cache.SetOptions) (TypedSetResult[T], error) {
+	data, err := encode(t.codec, key, value)
+	if err != nil {
+		return TypedSetResult[T]{}, err
+	}
+	result, err := t.strings.SetWithOptionsContext(ctx, key, data, opts)
+	if err != nil {
+		return TypedSetResult[T]{}, err
+	}
+
+	typed := TypedSetResult[T]{Stored: result.Stored, HadPrevious: result.HadPrevious}
+	if result.HadPrevious {
+		typed.Previous, err = decode(t.codec, key, result.Previous)
+	}
+	return typed, err
+}
+
+// Update encodes and stores the value of an existing key.
+func (t *Typed[T]) Update(key string, value T) error {
+	return t.UpdateContext(context.Background(), key, value)
+}
+
+// UpdateContext encodes and stores the value of an existing key.
+func (t *Typed[T]) UpdateContext(ctx context.Context, key string, value T) error {
+	data, err := encode(t.codec, key, value)
+	if err != nil {
+		return err
+	}
+	return t.strings.UpdateContext(ctx, key, data)
+}
+
+// MGet returns the decoded values of keys, read atomically, mapped by key.
+// Keys that are missing or do not hold a string are left out. If a value
+// cannot be decoded, MGet returns the DecodeError of the first such key
+// in keys.
+func (t *Typed[T]) MGet(keys ...string) (map[string]T, error) {
+	return t.MGetContext(context.Background(), keys...)
+}
+
+// MGetContext returns the decoded values of keys, see MGet.
+func (t *Typed[T]) MGetContext(ctx context.Context, keys ...string) (map[string]T, error) {
+	data, err := t.strings.MGetContext(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]T, len(data))
+	for _, key := range keys {
+		d, found := data[key]
+		if !found {
+			continue
+		}
+		if values[key], err = decode(t.codec, key, d); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// MSet atomically encodes and stores several values. Nothing is stored if
+// any of them cannot be encoded.
+func (t *Typed[T]) MSet(values map[string]T) error {
+	return t.MSetContext(context.Background(), values)
+}
+
+// MSetContext atomically encodes and stores several values, see MSet.
+func (t *Typed[T]) MSetContext(ctx context.Context, values map[string]T) error {
+	data := make(map[string]string, len(values))
+	for key, value := range values {
+		d, err := encode(t.codec, key, value)
+		if err != nil {
+			return err
+		}
+		data[key] = d
+	}
+	return t.strings.MSetContext(ctx, data)
+}
+
+// TypedList stores lists of values of type T, each element encoded with a
+// codec, through a ListClient. The keys are ordinary list keys, so the
+// TTLClient and the general operations of Client apply to them too.
+//
+// Elements that the codec cannot decode are reported with a DecodeError,
+// which matches ErrDecode. Elements are compared by their encodings, e.g.
+// by ListRemove, so a codec that encodes equal values differently, like
+// GobCodec for maps, does not find them.
+type TypedList[T any] struct {
+	lists *ListClient
+	codec Codec[T]
+}
+
+// NewTypedList creates a typed client for the lists of values of type T,
+// encoded with codec, e.g.
+//
+//	jobs := client.NewTypedList(c.List(), client.JSONCodec[Job]{})
+func NewTypedList[T any](lists *ListClient, codec Codec[T]) *TypedList[T] {
+	return &TypedList[T]{
+		lists: lists,
+		codec: codec,
+	}
+}
+
+// PushFront encodes and adds a value to the front of a list.
+func (t *TypedList[T]) PushFront(key string, value T) error {
+	return t.PushFrontContext(context.Background(), key, value)
+}
+
+// PushFrontContext encodes and adds a value to the front of a list.
+func (t *TypedList[T]) PushFrontContext(ctx context.Context, key string, value T) error {
+	data, err := encode(t.codec, key, value)
+	if err != nil {
+		return err
+	}
+	return t.lists.PushFrontContext(ctx, key, data)
+}
+
+// PushBack encodes and adds a value to the back of a list.
+func (t *TypedList[T]) PushBack(key string, value T) error {
+	return t.PushBackContext(context.Background(), key, value)
+}
+
+// PushBackContext encodes and adds a value to the back of a list.
+func (t *TypedList[T]) PushBackContext(ctx context.Context, key string, value T) error {
+	data, err := encode(t.codec, key, value)
+	if err != nil {
+		return err
+	}
+	return t.lists.PushBackContext(ctx, key, data)
+}
+
+// PopFront removes and decodes the first element of a list. An element
+// that cannot be decoded is removed all the same; its DecodeError holds
+// it.
+func (t *TypedList[T]) PopFront(key string) (T, error) {
+	return t.PopFrontContext(context.Background(), key)
+}
+
+// PopFrontContext removes and decodes the first element of a list, see
+// PopFront.
+func (t *TypedList[T]) PopFrontContext(ctx context.Context, key string) (T, error) {
+	data, err := t.lists.PopFrontContext(ctx, key)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return decode(t.codec, key, data)
+}
+
+// PopBack removes and decodes the last element of a list, see PopFront.
+func (t *TypedList[T]) PopBack(key string) (T, error) {
+	return t.PopBackContext(context.Background(), key)
+}
+
+// PopBackContext removes and decodes the last element of a list, see
+// PopFront.
+func (t *TypedList[T]) PopBackContext(ctx context.Context, key string) (T, error) {
+	data, err := t.lists.PopBackContext(ctx, key)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return decode(t.codec, key, data)
+}
+
+// BPopFront removes and decodes the first element of the first non-empty
+// list among keys, with the key of that list, waiting for one as
+// ListClient.BPopFront does.
+func (t *TypedList[T]) BPopFront(ctx context.Context, timeout time.Duration, keys ...string) (string, T, error) {
+	key, data, err := t.lists.BPopFront(ctx, timeout, keys...)
+	if err != nil {
+		var zero T
+		return "", zero, err
+	}
+	value, err := decode(t.codec, key, data)
+	return key, value, err
+}
+
+// BPopBack removes and decodes the last element of the first non-empty
+// list among keys, waiting for one as ListClient.BPopFront does.
+func (t *TypedList[T]) BPopBack(ctx context.Context, timeout time.Duration, keys ...string) (string, T, error) {
+	key, data, err := t.lists.BPopBack(ctx, timeout, keys...)
+	if err != nil {
+		var zero T
+		return "", zero, err
+	}
+	value, err := decode(t.codec, key, data)
+	return key, value, err
+}
+
+// ListMove atomically moves an element from the from end of the source
+// list to the to end of the destination list and returns it decoded. The
+// element is moved even if it cannot be decoded.
+func (t *TypedList[T]) ListMove(source, destination string, from, to ListEnd) (T, error) {
+	return t.ListMoveContext(context.Background(), source, destination, from, to)
+}
+
+// ListMoveContext atomically moves an element between lists and returns
+// it decoded, see ListMove.
+func (t *TypedList[T]) ListMoveContext(ctx context.Context, source, destination string, from, to ListEnd) (T, error) {
+	data, err := t.lists.ListMoveContext(ctx, source, destination, from, to)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return decode(t.codec, destination, data)
+}
+
+// BMove atomically moves an element between lists and returns it decoded,
+// waiting for one as ListClient.BMove does.
+func (t *TypedList[T]) BMove(ctx context.Context, source, destination string, from, to ListEnd, timeout time.Duration) (T, error) {
+	data, err := t.lists.BMove(ctx, source, destination, from, to, timeout)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return decode(t.codec, destination, data)
+}
+
+// ListRange returns a range of elements from a list, decoded. It returns
+// the DecodeError of the first element that cannot be decoded.
+func (t *TypedList[T]) ListRange(key string, start, end int) ([]T, error) {
+	return t.ListRangeContext(context.Background(), key, start, end)
+}
+
+// ListRangeContext returns a range of elements from a list, decoded, see
+// ListRange.
+func (t *TypedList[T]) ListRangeContext(ctx context.Context, key string, start, end int) ([]T, error) {
+	data, err := t.lists.ListRangeContext(ctx, key, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]T, len(data))
+	for i, d := range data {
+		if values[i], err = decode(t.codec, key, d); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// ListLen returns the number of elements of a list, zero if it is missing.
+func (t *TypedList[T]) ListLen(key string) (int, error) {
+	return t.ListLenContext(context.Background(), key)
+}
+
+// ListLenContext returns the number of elements of a list.
+func (t *TypedList[T]) ListLenContext(ctx context.Context, key string) (int, error) {
+	return t.lists.ListLenContext(ctx, key)
+}
+
+// ListIndex returns the element at index in a list, decoded. Negative
+// indexes count from the back, -1 being the last element.
+func (t *TypedList[T]) ListIndex(key string, index int) (T, error) {
+	return t.ListIndexContext(context.Background(), key, index)
+}
+
+// ListIndexContext returns the element at index in a list, decoded.
+func (t *TypedList[T]) ListIndexContext(ctx context.Context, key string, index int) (T, error) {
+	data, err := t.lists.ListIndexContext(ctx, key, index)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return decode(t.codec, key, data)
+}
+
+// ListSet encodes a value and replaces the element at index in a list with
+// it.
+func (t *TypedList[T]) ListSet(key string, index int, value T) error {
+	return t.ListSetContext(context.Background(), key, index, value)
+}
+
+// ListSetContext encodes a value and replaces the element at index in a
+// list with it.
+func (t *TypedList[T]) ListSetContext(ctx context.Context, key string, index int, value T) error {
+	data, err := encode(t.codec, key, value)
+	if err != nil {
+		return err
+	}
+	return t.lists.ListSetContext(ctx, key, index, data)
+}
+
+// ListInsert encodes a value and inserts it before or after the first
+// element of a list encoded like pivot, and returns the new length of the
+// list.
+func (t *TypedList[T]) ListInsert(key string, before bool, pivot, value T) (int, error) {
+	return t.ListInsertContext(context.Background(), key, before, pivot, value)
+}
+
+// ListInsertContext encodes a value and inserts it next to pivot in a
+// list, see ListInsert.
+func (t *TypedList[T]) ListInsertContext(ctx context.Context, key string, before bool, pivot, value T) (int, error) {
+	pivotData, err := encode(t.codec, key, pivot)
+	if err != nil {
+		return 0, err
+	}
+	data, err := encode(t.codec, key, value)
+	if err != nil {
+		return 0, err
+	}
+	return t.lists.ListInsertContext(ctx, key, before, pivotData, data)
+}
+
+// ListRemove removes the elements of a list encoded like value, as
+// ListClient.ListRemove does, and returns the number of removed elements.
+func (t *TypedList[T]) ListRemove(key string, count int, value T) (int, error) {
+	return t.ListRemoveContext(context.Background(), key, count, value)
+}
+
+// ListRemoveContext removes the elements of a list encoded like value, see
+// ListRemove.
+func (t *TypedList[T]) ListRemoveContext(ctx context.Context, key string, count int, value T) (int, error) {
+	data, err := encode(t.codec, key, value)
+	if err != nil {
+		return 0, err
+	}
+	return t.lists.ListRemoveContext(ctx, key, count, data)
+}
+
+// ListTrim keeps only the elements of a list between start and end.
+func (t *TypedList[T]) ListTrim(key string, start, end int) error {
+	return t.ListTrimContext(context.Background(), key, start, end)
+}
+
+// ListTrimContext keeps only the elements of a list between start and end.
+func (t *TypedList[T]) ListTrimContext(ctx context.Context, key string, start, end int) error {
+	return t.lists.ListTrimContext(ctx, key, start, end)
+}
+
+// ListPos returns the indexes of the elements of a list encoded like
+// value, as selected by opts.
+func (t *TypedList[T]) ListPos(key string, value T, opts cache.ListPosOptions) ([]int, error) {
+	return t.ListPosContext(context.Background(), key, value, opts)
+}
+
+// ListPosContext returns the indexes of the elements of a list encoded
+// like value, see ListPos.
+func (t *TypedList[T]) ListPosContext(ctx context.Context, key string, value T, opts cache.ListPosOptions) ([]int, error) {
+	data, err := encode(t.codec, key, value)
+	if err != nil {
+		return nil, err
+	}
+	return t.lists.ListPosContext(ctx, key, data, opts)
+}
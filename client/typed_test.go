@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+type user struct {
+	Name string
+	Age  int
+}
+
+type point struct {
+	X, Y int32
+}
+
+func requireNoError(t *testing.T, err error, format string, args ...any) {
+	t.Helper()
+	require(t, errors.Is(err, nil), format, args...)
+}
+
+func require(t *testing.T, condition bool, format string, args ...any) {
+	t.Helper()
+	if !condition {
+		t.Fatalf(format, args...)
+	}
+}
+
+// testCodec encodes and decodes value with codec and checks that it comes
+// back unchanged.
+func testCodec[T any](t *testing.T, codec Codec[T], value T) {
+	t.Helper()
+
+	data, err := codec.Encode(value)
+	requireNoError(t, err, "Encode(%v) failed: %v", value, err)
+	got, err := codec.Decode(data)
+	requireNoError(t, err, "Decode(%q) failed: %v", data, err)
+	require(t, reflect.DeepEqual(got, value), "Decode(Encode(%v)) = %v", value, got)
+}
+
+func TestCodecs(t *testing.T) {
+	t.Parallel()
+
+	testCodec(t, JSONCodec[user]{}, user{Name: "ada", Age: 36})
+	testCodec(t, GobCodec[user]{}, user{Name: "ada", Age: 36})
+	testCodec(t, GobCodec[map[string]int]{}, map[string]int{"a": 1, "b": 2})
+	testCodec(t, BytesCodec{}, []byte{0, 1, 0xff})
+	testCodec(t, BinaryCodec[point]{}, point{X: -1, Y: 1 << 20})
+	testCodec(t, BinaryCodec[float64]{}, 3.25)
+	testCodec(t, BinaryCodec[time.Time]{}, time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC))
+
+	data, err := BinaryCodec[int32]{}.Encode(1)
+	require(t, err == nil && data == "\x00\x00\x00\x01", "BinaryCodec.Encode(1) = %q, %v, want big-endian", data, err)
+	_, err = BinaryCodec[int32]{}.Decode("\x00\x00\x00\x01\x00")
+	require(t, err != nil, "BinaryCodec.Decode() accepted trailing data")
+	_, err = BinaryCodec[int32]{}.Decode("\x00")
+	require(t, err != nil, "BinaryCodec.Decode() accepted short data")
+	_, err = BinaryCodec[string]{}.Encode("not fixed-size")
+	require(t, err != nil, "BinaryCodec.Encode() accepted a value without a fixed size")
+}
+
+func TestTyped(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryClient(0)
+	defer c.Close()
+	users := NewTyped(c.String(), JSONCodec[user]{})
+
+	ada := user{Name: "ada", Age: 36}
+	requireNoError(t, users.Set("user:1", ada), "Set() failed")
+	got, err := users.Get("user:1")
+	require(t, err == nil && got == ada, "Get() = %v, %v, want %v", got, err, ada)
+	raw, _ := c.Get("user:1")
+	require(t, raw == `{"Name":"ada","Age":36}`, "stored value = %s", raw)
+
+	// The TTL helpers work on typed keys.
+	requireNoError(t, users.SetWithTTL("user:2", user{Name: "bob"}, time.Hour), "SetWithTTL() failed")
+	ttl, err := c.TTL().GetTTL("user:2")
+	require(t, err == nil && ttl > 59*time.Minute, "GetTTL() = %v, %v", ttl, err)
+	result, err := users.SetWithOptions("user:2", user{Name: "bob", Age: 7}, cache.SetOptions{KeepTTL: true, Get: true})
+	requireNoError(t, err, "SetWithOptions() failed: %v", err)
+	require(t, result.Stored && result.HadPrevious && result.Previous.Name == "bob", "SetWithOptions() = %+v", result)
+	ttl, _ = c.TTL().GetTTL("user:2")
+	require(t, ttl > 59*time.Minute, "SetWithOptions() with KeepTTL dropped the TTL: %v", ttl)
+
+	requireNoError(t, users.MSet(map[string]user{"user:3": {Name: "cy"}}), "MSet() failed")
+	values, err := users.MGet("user:1", "user:3", "missing")
+	require(t, err == nil && len(values) == 2 && values["user:3"].Name == "cy", "MGet() = %v, %v", values, err)
+
+	// Missing keys and undecodable values fail differently.
+	_, err = users.Get("missing")
+	require(t, errors.Is(err, ErrKeyNotFound) && !errors.Is(err, ErrDecode), "Get() of a missing key error = %v", err)
+	requireNoError(t, c.Set("corrupt", "{"), "Set() failed")
+	_, err = users.Get("corrupt")
+	var decodeErr *DecodeError
+	require(t, errors.Is(err, ErrDecode) && !errors.Is(err, ErrKeyNotFound), "Get() of a corrupt value error = %v", err)
+	require(t, errors.As(err, &decodeErr) && decodeErr.Key == "corrupt" && decodeErr.Data == "{", "Get() error = %#v", err)
+	_, err = users.MGet("user:1", "corrupt")
+	require(t, errors.Is(err, ErrDecode), "MGet() error = %v, want ErrDecode", err)
+
+	// A value that cannot be encoded is not stored.
+	chans := NewTyped(c.String(), JSONCodec[chan int]{})
+	err = chans.Set("chan", make(chan int))
+	require(t, errors.Is(err, ErrEncode) && !c.Exists("chan"), "Set() of an unencodable value error = %v", err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = users.SetContext(ctx, "user:4", ada)
+	require(t, errors.Is(err, context.Canceled) && !c.Exists("user:4"), "SetContext() error = %v, want context.Canceled", err)
+}
+
+func TestTypedList(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryClient(0)
+	defer c.Close()
+	points := NewTypedList(c.List(), BinaryCodec[point]{})
+
+	for i := range int32(4) {
+		requireNoError(t, points.PushBack("points", point{X: i, Y: -i}), "PushBack() failed")
+	}
+	all, err := points.ListRange("points", 0, -1)
+	require(t, err == nil && len(all) == 4 && all[3] == point{X: 3, Y: -3}, "ListRange() = %v, %v", all, err)
+
+	positions, err := points.ListPos("points", point{X: 2, Y: -2}, cache.ListPosOptions{})
+	require(t, err == nil && reflect.DeepEqual(positions, []int{2}), "ListPos() = %v, %v", positions, err)
+	removed, err := points.ListRemove("points", 0, point{X: 1, Y: -1})
+	require(t, err == nil && removed == 1, "ListRemove() = %d, %v", removed, err)
+	length, err := points.ListInsert("points", true, point{X: 2, Y: -2}, point{X: 9})
+	require(t, err == nil && length == 4, "ListInsert() = %d, %v", length, err)
+	value, err := points.ListIndex("points", 1)
+	require(t, err == nil && value == point{X: 9}, "ListIndex() = %v, %v", value, err)
+
+	value, err = points.PopFront("points")
+	require(t, err == nil && value == point{}, "PopFront() = %v, %v", value, err)
+	value, err = points.ListMove("points", "done", ListBack, ListFront)
+	require(t, err == nil && value == point{X: 3, Y: -3}, "ListMove() = %v, %v", value, err)
+	key, value, err := points.BPopFront(context.Background(), time.Second, "empty", "done")
+	require(t, err == nil && key == "done" && value == point{X: 3, Y: -3}, "BPopFront() = %q, %v, %v", key, value, err)
+
+	// The TTL helpers work on typed lists.
+	requireNoError(t, c.TTL().SetTTL("points", time.Hour), "SetTTL() failed")
+	ttl, err := c.TTL().GetTTL("points")
+	require(t, err == nil && ttl > 59*time.Minute, "GetTTL() = %v, %v", ttl, err)
+
+	// Missing lists and undecodable elements fail differently.
+	_, err = points.PopBack("missing")
+	require(t, errors.Is(err, ErrKeyNotFoundOrEmpty) && !errors.Is(err, ErrDecode), "PopBack() of a missing list error = %v", err)
+	requireNoError(t, c.PushBack("points", "short"), "PushBack() failed")
+	_, err = points.ListRange("points", 0, -1)
+	require(t, errors.Is(err, ErrDecode), "ListRange() error = %v, want ErrDecode", err)
+	_, err = points.PopBack("points")
+	var decodeErr *DecodeError
+	require(t, errors.As(err, &decodeErr) && decodeErr.Data == "short", "PopBack() error = %v, want a DecodeError holding the element", err)
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,8 +14,12 @@ import (
 	"time"
 
 	"github.com/dsha256/gredis/internal/cache"
+	"github.com/dsha256/gredis/internal/command"
 	"github.com/dsha256/gredis/internal/config"
 	"github.com/dsha256/gredis/internal/handler"
+	"github.com/dsha256/gredis/internal/persistence"
+	"github.com/dsha256/gredis/internal/replication"
+	"github.com/dsha256/gredis/internal/resp"
 )
 
 func main() {
@@ -30,10 +36,105 @@ func main() {
 
 	logger.Info("Starting dispatcher service")
 
-	newCache := cache.NewMemoryCache(5 * time.Minute)
+	evictionPolicy, err := cache.ParseEvictionPolicy(cfg.Memory.EvictionPolicy)
+	if err != nil {
+		logger.Error("Invalid memory configuration", "error", err)
+		os.Exit(1)
+	}
+	events, err := cache.ParseEventClasses(cfg.Notifications.KeyspaceEvents)
+	if err != nil {
+		logger.Error("Invalid notifications configuration", "error", err)
+		os.Exit(1)
+	}
+	newCache := newStore(cfg.Cache, 5*time.Minute,
+		cache.WithMaxMemory(cfg.Memory.MaxMemory, evictionPolicy),
+		cache.WithNotifications(events),
+	)
 	defer newCache.Stop()
 
-	newHandler := handler.New(newCache, logger)
+	// Every write goes through the recorder, which feeds the append-only
+	// file when it is enabled. It only serializes writes to the same shard.
+	recorder := command.NewRecorder(newCache)
+
+	handlerOpts := []handler.Option{handler.WithMemoryReporter(newCache)}
+	var snapshotter *persistence.Snapshotter
+	if cfg.Persistence.Snapshot.Enabled {
+		snapshotter = persistence.NewSnapshotter(recorder, cfg.Persistence.Snapshot.Path, cfg.Persistence.Snapshot.Interval, logger)
+		handlerOpts = append(handlerOpts, handler.WithSnapshotter(snapshotter))
+	}
+
+	// The append-only file is more complete than the snapshot, so it takes
+	// precedence when both exist.
+	loadSnapshot := snapshotter != nil
+	var aofExists bool
+	if cfg.Persistence.AOF.Enabled {
+		start := time.Now()
+		replayed, err := persistence.ReplayAOF(cfg.Persistence.AOF.Path, newCache, logger)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			logger.Error("Failed to replay append-only file", "path", cfg.Persistence.AOF.Path, "error", err)
+			os.Exit(1)
+		default:
+			loadSnapshot, aofExists = false, true
+			logger.Info("Append-only file replayed", "path", cfg.Persistence.AOF.Path, "commands", replayed, "duration", time.Since(start).String())
+		}
+	}
+
+	if loadSnapshot {
+		start := time.Now()
+		loaded, err := snapshotter.Load()
+		if err != nil {
+			logger.Error("Failed to load snapshot", "path", cfg.Persistence.Snapshot.Path, "error", err)
+			os.Exit(1)
+		}
+		logger.Info("Snapshot loaded", "path", cfg.Persistence.Snapshot.Path, "keys", loaded, "duration", time.Since(start).String())
+	}
+
+	var aof *persistence.AOF
+	if cfg.Persistence.AOF.Enabled {
+		aof, err = openAOF(cfg.Persistence.AOF, recorder, !aofExists, logger)
+		if err != nil {
+			logger.Error("Failed to open append-only file", "path", cfg.Persistence.AOF.Path, "error", err)
+			os.Exit(1)
+		}
+		handlerOpts = append(handlerOpts, handler.WithAOF(aof))
+	}
+
+	if snapshotter != nil {
+		snapshotter.Start()
+	}
+
+	var respOpts []resp.Option
+	var follower *replication.Follower
+	switch cfg.Replication.Role {
+	case "", "leader":
+		backlogSize := cfg.Replication.BacklogSize
+		if backlogSize <= 0 {
+			backlogSize = replication.DefaultBacklogSize
+		}
+		leader := replication.NewLeader(recorder, backlogSize, logger)
+		recorder.AddSink(leader)
+		respOpts = append(respOpts, resp.WithReplicator(leader))
+	case "follower":
+		// Followers only change through the replication stream.
+		follower = replication.NewFollower(cfg.Replication.LeaderAddr, recorder, logger)
+		handlerOpts = append(handlerOpts, handler.WithReadOnly())
+		respOpts = append(respOpts, resp.WithReadOnly())
+
+		logger.Info("Replicating leader", "leader_addr", cfg.Replication.LeaderAddr)
+		follower.Start()
+	default:
+		logger.Error("Invalid replication role", "role", cfg.Replication.Role)
+		os.Exit(1)
+	}
+
+	newHandler := handler.New(recorder, logger, handlerOpts...)
+
+	// Long-lived requests such as pub/sub event streams are canceled through
+	// this context when the server shuts down.
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
 
 	srv := &http.Server{
 		Addr: fmt.Sprintf(":%d", cfg.Server.Port),
@@ -50,7 +151,9 @@ func main() {
 		ReadTimeout:       cfg.Server.ReadTimeout,
 		WriteTimeout:      cfg.Server.WriteTimeout,
 		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
 	}
+	srv.RegisterOnShutdown(cancelBase)
 
 	go func() {
 		logger.Info("Server starting", "port", cfg.Server.Port)
@@ -60,6 +163,18 @@ func main() {
 		}
 	}()
 
+	var respSrv *resp.Server
+	if cfg.RESP.Enabled {
+		respSrv = resp.NewServer(recorder, logger, respOpts...)
+		go func() {
+			logger.Info("RESP server starting", "port", cfg.RESP.Port)
+			if err := respSrv.ListenAndServe(fmt.Sprintf(":%d", cfg.RESP.Port)); err != nil && !errors.Is(err, resp.ErrServerClosed) {
+				logger.Error("RESP server failed", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -73,5 +188,76 @@ func main() {
 		logger.Error("Server forced to shutdown", "error", err)
 	}
 
+	if respSrv != nil {
+		if err = respSrv.Shutdown(ctx); err != nil {
+			logger.Error("RESP server forced to shutdown", "error", err)
+		}
+	}
+
+	if follower != nil {
+		follower.Stop()
+	}
+
+	if snapshotter != nil {
+		snapshotter.Stop()
+		if info, err := snapshotter.Save(); err != nil {
+			logger.Error("Failed to save snapshot", "path", cfg.Persistence.Snapshot.Path, "error", err)
+		} else {
+			logger.Info("Snapshot saved", "path", info.Path, "keys", info.Keys, "duration", info.Duration.String())
+		}
+	}
+
+	if aof != nil {
+		if err = aof.Close(); err != nil {
+			logger.Error("Failed to close append-only file", "path", cfg.Persistence.AOF.Path, "error", err)
+		}
+	}
+
 	logger.Info("Server exited properly")
 }
+
+// store is the cache served by the process.
+type store interface {
+	cache.Store
+	cache.Loader
+	cache.MemoryReporter
+	Stop()
+}
+
+// newStore creates a sharded cache if more than one shard is configured
+// and a single MemoryCache otherwise.
+func newStore(cfg config.Cache, cleanupInterval time.Duration, opts ...cache.Option) store {
+	if cfg.Shards > 1 {
+		return cache.NewShardedCache(cfg.Shards, cleanupInterval, opts...)
+	}
+	return cache.NewMemoryCache(cleanupInterval, opts...)
+}
+
+// openAOF opens the append-only file and attaches it to the recorder. A new
+// file is started with the current contents of the cache, which may have
+// been loaded from a snapshot.
+func openAOF(cfg config.AOF, recorder *command.Recorder, fresh bool, logger *slog.Logger) (*persistence.AOF, error) {
+	fsync, err := persistence.ParseFsyncPolicy(cfg.Fsync)
+	if err != nil {
+		return nil, err
+	}
+
+	aof, err := persistence.OpenAOF(cfg.Path, recorder, persistence.AOFOptions{
+		Fsync:             fsync,
+		RewritePercentage: cfg.RewritePercentage,
+		RewriteMinSize:    cfg.RewriteMinSize,
+	}, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if fresh {
+		if _, err = aof.Rewrite(); err != nil {
+			_ = aof.Close()
+			return nil, err
+		}
+	}
+	recorder.AddSink(aof)
+
+	return aof, nil
+}
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"maps"
+	"slices"
+)
+
+// MGet returns the string values of keys, which are read together under
+// one lock. Keys that are missing or hold another type are left out of the
+// result.
+func (c *MemoryCache) MGet(keys ...string) map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		c.getString(values, key)
+	}
+	return values
+}
+
+// MSet stores the string values of several keys at once, like Set, so
+// that no reader sees some of them stored and not the others.
+func (c *MemoryCache) MSet(values map[string]string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reserve(); err != nil {
+		return err
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		c.setString(key, values[key])
+	}
+	return nil
+}
+
+// MSetNX stores the string values of several keys at once, like MSet, if
+// none of the keys exists. Otherwise nothing is stored and it returns
+// false.
+func (c *MemoryCache) MSetNX(values map[string]string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reserve(); err != nil {
+		return false, err
+	}
+
+	keys := slices.Sorted(maps.Keys(values))
+	for _, key := range keys {
+		if c.lookupItem(key) != nil {
+			return false, nil
+		}
+	}
+	for _, key := range keys {
+		c.setString(key, values[key])
+	}
+	return true, nil
+}
+
+// getString adds the string value of key to values, if it has one. The
+// caller must hold at least the read lock.
+func (c *MemoryCache) getString(values map[string]string, key string) {
+	if item := c.peekItem(key); item != nil && item.dataType == StringType {
+		values[key] = item.value.(string)
+	}
+}
+
+// setString stores a string value without expiration, replacing a value
+// of any type. The caller must hold the write lock.
+func (c *MemoryCache) setString(key string, value string) {
+	c.storeItem(key, &cacheItem{
+		dataType: StringType,
+		value:    value,
+	})
+	c.notify(EventsString, "set", key)
+}
+
+// MGet returns the string values of keys, which are read together with the
+// shards holding them locked. Keys that are missing or hold another type
+// are left out of the result.
+func (s *ShardedCache) MGet(keys ...string) map[string]string {
+	unlock := s.lockShards(keys, false)
+	defer unlock()
+
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		s.shard(key).getString(values, key)
+	}
+	return values
+}
+
+// MSet stores the string values of several keys at once, with the shards
+// holding them locked.
+func (s *ShardedCache) MSet(values map[string]string) error {
+	keys := slices.Sorted(maps.Keys(values))
+	unlock := s.lockShards(keys, true)
+	defer unlock()
+
+	if err := s.reserve(keys); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		s.shard(key).setString(key, values[key])
+	}
+	return nil
+}
+
+// MSetNX stores the string values of several keys at once, like MSet, if
+// none of the keys exists. Otherwise nothing is stored and it returns
+// false.
+func (s *ShardedCache) MSetNX(values map[string]string) (bool, error) {
+	keys := slices.Sorted(maps.Keys(values))
+	unlock := s.lockShards(keys, true)
+	defer unlock()
+
+	if err := s.reserve(keys); err != nil {
+		return false, err
+	}
+
+	for _, key := range keys {
+		if s.shard(key).lookupItem(key) != nil {
+			return false, nil
+		}
+	}
+	for _, key := range keys {
+		s.shard(key).setString(key, values[key])
+	}
+	return true, nil
+}
+
+// reserve makes room for a write in each of the shards holding keys. The
+// caller must hold their write locks.
+func (s *ShardedCache) reserve(keys []string) error {
+	for _, key := range keys {
+		if err := s.shard(key).reserve(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
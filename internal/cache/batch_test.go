@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMGetMSet(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []Cache{NewMemoryCache(0), NewShardedCache(4, 0)} {
+		requireNoError(t, c.SetWithTTL("ttl", "old", time.Hour), "SetWithTTL failed")
+		requireNoError(t, c.SetWithTTL("expired", "old", time.Millisecond), "SetWithTTL failed")
+		requireNoError(t, c.PushBack("list", "a"), "PushBack failed")
+		time.Sleep(5 * time.Millisecond)
+
+		requireNoError(t, c.MSet(map[string]string{"a": "1", "b": "2", "ttl": "new"}), "MSet failed")
+		ttl, _ := c.GetTTL("ttl")
+		require(t, ttl == -1, "MSet kept the TTL %v", ttl)
+
+		got := c.MGet("a", "b", "ttl", "expired", "list", "missing", "a")
+		want := map[string]string{"a": "1", "b": "2", "ttl": "new"}
+		require(t, maps.Equal(got, want), "MGet = %v, want %v", got, want)
+
+		// Nothing is stored if any key exists, whatever its type.
+		set, err := c.MSetNX(map[string]string{"c": "3", "list": "x"})
+		requireNoError(t, err, "MSetNX failed")
+		require(t, !set, "MSetNX stored over an existing key")
+		_, found := c.Get("c")
+		require(t, !found, "MSetNX stored part of the values")
+
+		set, err = c.MSetNX(map[string]string{"c": "3", "expired": "4"})
+		requireNoError(t, err, "MSetNX failed")
+		require(t, set, "MSetNX did not store new keys")
+		got = c.MGet("c", "expired")
+		require(t, maps.Equal(got, map[string]string{"c": "3", "expired": "4"}), "MGet after MSetNX = %v", got)
+	}
+}
+
+func TestMSet_Atomic(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []Cache{NewMemoryCache(0), NewShardedCache(8, 0)} {
+		keys := []string{"k1", "k2", "k3", "k4", "k5"}
+
+		var wg sync.WaitGroup
+		done := make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				values := make(map[string]string, len(keys))
+				for _, key := range keys {
+					values[key] = fmt.Sprint(i)
+				}
+				requireNoError(t, c.MSet(values), "MSet failed")
+			}
+			close(done)
+		}()
+
+		// A reader always sees all the keys written by the same MSet.
+		for writing := true; writing; {
+			select {
+			case <-done:
+				writing = false
+			default:
+			}
+			values := c.MGet(keys...)
+			for _, key := range keys {
+				require(t, values[key] == values[keys[0]], "MGet saw a partial MSet: %v", values)
+			}
+		}
+		wg.Wait()
+	}
+}
+
+func TestMSet_OutOfMemory(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0, WithMaxMemory(1, NoEviction))
+	requireNoError(t, c.Set("a", "v"), "Set failed")
+
+	err := c.MSet(map[string]string{"b": "v", "c": "v"})
+	require(t, errors.Is(err, ErrOutOfMemory), "MSet error = %v, want ErrOutOfMemory", err)
+	_, err = c.MSetNX(map[string]string{"b": "v"})
+	require(t, errors.Is(err, ErrOutOfMemory), "MSetNX error = %v, want ErrOutOfMemory", err)
+	require(t, len(c.MGet("b", "c")) == 0, "values stored over the memory limit")
+}
@@ -0,0 +1,278 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Blocking list errors
+var (
+	// ErrTimeout is returned by a blocking operation whose timeout elapsed
+	// before an element could be popped.
+	ErrTimeout = errors.New("timed out waiting for a list element")
+	// ErrBlockingUnsupported is returned by a blocking operation on a cache
+	// that cannot wake waiting callers.
+	ErrBlockingUnsupported = errors.New("blocking list operations are not supported by the cache")
+	// ErrInvalidListEnd is returned by ParseListEnd for an unknown name.
+	ErrInvalidListEnd = errors.New("invalid list end")
+)
+
+// ListEnd selects an end of a list.
+type ListEnd int
+
+const (
+	// ListFront is the first element of a list, LEFT in Redis.
+	ListFront ListEnd = iota
+	// ListBack is the last element of a list, RIGHT in Redis.
+	ListBack
+)
+
+// ParseListEnd parses "front" or "back", or their Redis names "left" and
+// "right", in any case.
+func ParseListEnd(name string) (ListEnd, error) {
+	switch strings.ToLower(name) {
+	case "front", "left":
+		return ListFront, nil
+	case "back", "right":
+		return ListBack, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidListEnd, name)
+	}
+}
+
+// String returns "front" or "back".
+func (e ListEnd) String() string {
+	if e == ListBack {
+		return "back"
+	}
+	return "front"
+}
+
+// ListWaiter is implemented by caches that can wake callers waiting for
+// elements to be pushed to lists, see BPop.
+type ListWaiter interface {
+	// WaitPush registers a waiter on keys. ready receives a value when an
+	// element is pushed to one of them; waiters are woken one per push, in
+	// the order they were registered. turn reports whether the waiter is
+	// the first one registered on key, which it must be to pop from key, so
+	// that a caller arriving while an earlier waiter is being woken does
+	// not take its element. cancel unregisters the waiter and must be
+	// called once it stops waiting; it wakes the next waiter on each key
+	// whose list still has elements.
+	WaitPush(keys ...string) (ready <-chan struct{}, turn func(key string) bool, cancel func())
+}
+
+// BPop removes and returns an element from the end of the first non-empty
+// list among keys, like BLPOP and BRPOP in Redis. If they are all empty it
+// waits for an element to be pushed to one of them, until timeout elapses
+// (ErrTimeout) or ctx is done; a timeout of zero or less waits for ctx
+// only. The element is popped through c, so a recording cache records a
+// plain pop.
+func BPop(ctx context.Context, c Cache, keys []string, end ListEnd, timeout time.Duration) (key, value string, err error) {
+	err = block(ctx, c, keys, timeout, func(turn func(key string) bool) (bool, error) {
+		for _, k := range keys {
+			if dataType, found := c.Type(k); found && dataType != ListType {
+				return false, ErrTypeMismatch
+			}
+			if !turn(k) {
+				continue
+			}
+			var ok bool
+			if value, ok = pop(c, k, end); ok {
+				key = k
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	return key, value, err
+}
+
+// BMove atomically removes an element from the from end of the list at
+// source and pushes it to the to end of the list at destination, like
+// BLMOVE in Redis, and returns it. source and destination may be the same
+// list, which rotates it. If source is empty it waits for an element as
+// BPop does. The element is moved with ListMove through c.
+func BMove(ctx context.Context, c Cache, source, destination string, from, to ListEnd, timeout time.Duration) (string, error) {
+	var value string
+	err := block(ctx, c, []string{source}, timeout, func(turn func(key string) bool) (moved bool, err error) {
+		if !turn(source) {
+			return false, nil
+		}
+		value, moved, err = c.ListMove(source, destination, from, to)
+		return moved, err
+	})
+	return value, err
+}
+
+// block calls try until it succeeds or fails, waiting for a push to one of
+// keys before each new attempt. try must only pop from the keys turn
+// reports true for.
+func block(ctx context.Context, c Cache, keys []string, timeout time.Duration, try func(turn func(key string) bool) (bool, error)) error {
+	w, ok := c.(ListWaiter)
+	if !ok {
+		return ErrBlockingUnsupported
+	}
+
+	// The waiter is registered before the first attempt, so that no push
+	// made after the attempt goes unnoticed.
+	ready, turn, cancel := w.WaitPush(keys...)
+	defer cancel()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		done, err := try(turn)
+		if done || err != nil {
+			return err
+		}
+
+		select {
+		case <-ready:
+		case <-expired:
+			return ErrTimeout
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// pop removes an element from the given end of the list at key.
+func pop(c Cache, key string, end ListEnd) (string, bool) {
+	if end == ListBack {
+		return c.PopBack(key)
+	}
+	return c.PopFront(key)
+}
+
+// pushWaiter is a caller registered with WaitPush.
+type pushWaiter struct {
+	ready chan struct{}
+}
+
+// newPushWaiter creates a waiter that has not been woken.
+func newPushWaiter() *pushWaiter {
+	return &pushWaiter{ready: make(chan struct{}, 1)}
+}
+
+// WaitPush registers a waiter woken by pushes to keys, see ListWaiter.
+func (c *MemoryCache) WaitPush(keys ...string) (<-chan struct{}, func(key string) bool, func()) {
+	w := newPushWaiter()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		c.addWaiter(w, key)
+	}
+
+	turn := func(key string) bool {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+
+		return c.firstWaiter(w, key)
+	}
+	return w.ready, turn, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for _, key := range keys {
+			c.removeWaiter(w, key)
+		}
+		for _, key := range keys {
+			c.wakeWaiter(key)
+		}
+	}
+}
+
+// WaitPush registers a waiter woken by pushes to keys, see ListWaiter.
+func (s *ShardedCache) WaitPush(keys ...string) (<-chan struct{}, func(key string) bool, func()) {
+	w := newPushWaiter()
+
+	for _, key := range keys {
+		shard := s.shard(key)
+		shard.mu.Lock()
+		shard.addWaiter(w, key)
+		shard.mu.Unlock()
+	}
+
+	turn := func(key string) bool {
+		shard := s.shard(key)
+		shard.mu.RLock()
+		defer shard.mu.RUnlock()
+
+		return shard.firstWaiter(w, key)
+	}
+	return w.ready, turn, func() {
+		for _, key := range keys {
+			shard := s.shard(key)
+			shard.mu.Lock()
+			shard.removeWaiter(w, key)
+			shard.mu.Unlock()
+		}
+		for _, key := range keys {
+			shard := s.shard(key)
+			shard.mu.Lock()
+			shard.wakeWaiter(key)
+			shard.mu.Unlock()
+		}
+	}
+}
+
+// addWaiter queues w on key. The caller must hold the write lock.
+func (c *MemoryCache) addWaiter(w *pushWaiter, key string) {
+	if c.waiters == nil {
+		c.waiters = make(map[string][]*pushWaiter)
+	}
+	c.waiters[key] = append(c.waiters[key], w)
+}
+
+// removeWaiter removes w from the queue of key. The caller must hold the
+// write lock.
+func (c *MemoryCache) removeWaiter(w *pushWaiter, key string) {
+	queue := slices.DeleteFunc(c.waiters[key], func(x *pushWaiter) bool { return x == w })
+	if len(queue) == 0 {
+		delete(c.waiters, key)
+	} else {
+		c.waiters[key] = queue
+	}
+}
+
+// firstWaiter reports whether w is the first waiter on key. The caller must
+// hold the read lock.
+func (c *MemoryCache) firstWaiter(w *pushWaiter, key string) bool {
+	queue := c.waiters[key]
+	return len(queue) > 0 && queue[0] == w
+}
+
+// wakeWaiter wakes the first waiter on key that is not awake yet, if the
+// list at key has elements. The caller must hold the write lock.
+func (c *MemoryCache) wakeWaiter(key string) {
+	queue := c.waiters[key]
+	if len(queue) == 0 {
+		return
+	}
+	item, found := c.items[key]
+	if !found || c.expired(item) || item.dataType != ListType || item.value.(*list.List).Len() == 0 {
+		return
+	}
+
+	for _, w := range queue {
+		select {
+		case w.ready <- struct{}{}:
+			return
+		default:
+			// Already woken by another key or push.
+		}
+	}
+}
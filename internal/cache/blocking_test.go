@@ -0,0 +1,236 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitForWaiters waits until n callers are waiting on key in c.
+func waitForWaiters(t *testing.T, c *MemoryCache, key string, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.mu.RLock()
+		got := len(c.waiters[key])
+		c.mu.RUnlock()
+		if got == n {
+			return
+		}
+		require(t, time.Now().Before(deadline), "%d waiters on %q, want %d", got, key, n)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBPop(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+	ctx := context.Background()
+
+	// A non-empty list is popped without waiting, in key order.
+	requireNoError(t, c.PushBack("b", "1"), "PushBack failed")
+	requireNoError(t, c.PushBack("b", "2"), "PushBack failed")
+	key, value, err := BPop(ctx, c, []string{"a", "b"}, ListBack, time.Second)
+	requireNoError(t, err, "BPop failed")
+	require(t, key == "b" && value == "2", "BPop = %q, %q, want b, 2", key, value)
+
+	// An empty list is waited for.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		key, value, err = BPop(ctx, c, []string{"a", "c"}, ListFront, 0)
+	}()
+	waitForWaiters(t, c, "c", 1)
+	requireNoError(t, c.PushBack("c", "x"), "PushBack failed")
+	<-done
+	requireNoError(t, err, "BPop failed")
+	require(t, key == "c" && value == "x", "BPop = %q, %q, want c, x", key, value)
+	require(t, len(c.waiters) == 0, "waiters left after BPop: %v", c.waiters)
+
+	_, _, err = BPop(ctx, c, []string{"a"}, ListFront, 10*time.Millisecond)
+	require(t, errors.Is(err, ErrTimeout), "BPop error = %v, want ErrTimeout", err)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	go func() {
+		waitForWaiters(t, c, "a", 1)
+		cancel()
+	}()
+	_, _, err = BPop(cancelled, c, []string{"a"}, ListFront, 0)
+	require(t, errors.Is(err, context.Canceled), "BPop error = %v, want context.Canceled", err)
+
+	requireNoError(t, c.Set("s", "v"), "Set failed")
+	_, _, err = BPop(ctx, c, []string{"a", "s"}, ListFront, time.Second)
+	require(t, errors.Is(err, ErrTypeMismatch), "BPop error = %v, want ErrTypeMismatch", err)
+}
+
+func TestBPop_FIFO(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+
+	const waiters = 5
+	results := make([]chan string, waiters)
+	for i := range waiters {
+		results[i] = make(chan string, 1)
+		go func() {
+			_, value, err := BPop(context.Background(), c, []string{"l"}, ListFront, 0)
+			requireNoError(t, err, "BPop failed")
+			results[i] <- value
+		}()
+		waitForWaiters(t, c, "l", i+1)
+	}
+
+	// Each push wakes the waiter that has waited longest.
+	for i, value := range []string{"a", "b", "c", "d", "e"} {
+		requireNoError(t, c.PushBack("l", value), "PushBack failed")
+		got := <-results[i]
+		require(t, got == value, "waiter %d popped %q, want %q", i, got, value)
+	}
+}
+
+func TestBPop_CancelPassesWakeUp(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+
+	// The first waiter is woken by a push but cancels without consuming the
+	// wake-up; the second must still get the element.
+	ready, _, cancel := c.WaitPush("l")
+	got := make(chan string, 1)
+	go func() {
+		_, value, err := BPop(context.Background(), c, []string{"l"}, ListFront, time.Second)
+		requireNoError(t, err, "BPop failed")
+		got <- value
+	}()
+	waitForWaiters(t, c, "l", 2)
+
+	requireNoError(t, c.PushBack("l", "x"), "PushBack failed")
+	require(t, len(ready) == 1, "first waiter was not woken")
+	cancel()
+	require(t, <-got == "x", "second waiter did not pop the element")
+}
+
+func TestBPop_SignaledWaiterKeepsItsTurn(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+
+	// The first waiter is woken by a push but has not popped yet when a
+	// new caller arrives; the new caller must not take the element.
+	ready, turn, cancel := c.WaitPush("l")
+	requireNoError(t, c.PushBack("l", "x"), "PushBack failed")
+	require(t, len(ready) == 1, "first waiter was not woken")
+	_, _, err := BPop(context.Background(), c, []string{"l"}, ListFront, 20*time.Millisecond)
+	require(t, errors.Is(err, ErrTimeout), "BPop error = %v, want ErrTimeout", err)
+
+	// A caller queued behind the first waiter pops once it is done, from
+	// what it left.
+	requireNoError(t, c.PushBack("l", "y"), "PushBack failed")
+	got := make(chan string, 1)
+	go func() {
+		_, value, err := BPop(context.Background(), c, []string{"l"}, ListFront, time.Second)
+		requireNoError(t, err, "BPop failed")
+		got <- value
+	}()
+	waitForWaiters(t, c, "l", 2)
+
+	<-ready
+	require(t, turn("l"), "first waiter is not first on l")
+	value, ok := c.PopFront("l")
+	require(t, ok && value == "x", "first waiter popped %q, %v, want x", value, ok)
+	cancel()
+	require(t, <-got == "y", "queued caller did not pop the element left")
+}
+
+func TestBPop_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []Cache{NewMemoryCache(0), NewShardedCache(4, 0)} {
+		const n = 100
+		keys := []string{"q1", "q2", "q3"}
+
+		var wg sync.WaitGroup
+		popped := make(chan string, n)
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, value, err := BPop(context.Background(), c, keys, ListFront, 5*time.Second)
+				requireNoError(t, err, "BPop failed")
+				popped <- value
+			}()
+		}
+		for i := range n {
+			requireNoError(t, c.PushBack(keys[i%len(keys)], string(rune('a'+i%26))), "PushBack failed")
+		}
+		wg.Wait()
+		close(popped)
+
+		require(t, len(popped) == n, "%d elements popped, want %d", len(popped), n)
+		for _, key := range keys {
+			values, _ := c.ListRange(key, 0, -1)
+			require(t, len(values) == 0, "%q not drained: %v", key, values)
+		}
+	}
+}
+
+func TestBMove(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []Cache{NewMemoryCache(0), NewShardedCache(4, 0)} {
+		ctx := context.Background()
+		for _, v := range []string{"a", "b", "c"} {
+			requireNoError(t, c.PushBack("src", v), "PushBack failed")
+		}
+
+		value, err := BMove(ctx, c, "src", "dst", ListFront, ListBack, time.Second)
+		requireNoError(t, err, "BMove failed")
+		require(t, value == "a", "BMove = %q, want a", value)
+
+		// Moving within one list rotates it.
+		value, err = BMove(ctx, c, "src", "src", ListBack, ListFront, time.Second)
+		requireNoError(t, err, "BMove failed")
+		require(t, value == "c", "BMove = %q, want c", value)
+		got, _ := c.ListRange("src", 0, -1)
+		require(t, slices.Equal(got, []string{"c", "b"}), "src = %v, want [c b]", got)
+
+		// A destination of another type leaves the source untouched.
+		requireNoError(t, c.Set("str", "v"), "Set failed")
+		_, err = BMove(ctx, c, "src", "str", ListFront, ListBack, time.Second)
+		require(t, errors.Is(err, ErrTypeMismatch), "BMove error = %v, want ErrTypeMismatch", err)
+		got, _ = c.ListRange("src", 0, -1)
+		require(t, slices.Equal(got, []string{"c", "b"}), "src = %v after failed move, want [c b]", got)
+
+		_, err = BMove(ctx, c, "empty", "dst", ListFront, ListBack, 10*time.Millisecond)
+		require(t, errors.Is(err, ErrTimeout), "BMove error = %v, want ErrTimeout", err)
+
+		done := make(chan string)
+		go func() {
+			value, err := BMove(ctx, c, "empty", "dst", ListFront, ListFront, 0)
+			requireNoError(t, err, "BMove failed")
+			done <- value
+		}()
+		time.Sleep(10 * time.Millisecond)
+		requireNoError(t, c.PushBack("empty", "z"), "PushBack failed")
+		require(t, <-done == "z", "blocked BMove did not move the pushed element")
+		got, _ = c.ListRange("dst", 0, -1)
+		require(t, slices.Equal(got, []string{"z", "a"}), "dst = %v, want [z a]", got)
+	}
+}
+
+func TestParseListEnd(t *testing.T) {
+	t.Parallel()
+
+	for name, want := range map[string]ListEnd{"front": ListFront, "LEFT": ListFront, "back": ListBack, "Right": ListBack} {
+		got, err := ParseListEnd(name)
+		requireNoError(t, err, "ParseListEnd(%q) failed", name)
+		require(t, got == want, "ParseListEnd(%q) = %v, want %v", name, got, want)
+	}
+	_, err := ParseListEnd("middle")
+	require(t, errors.Is(err, ErrInvalidListEnd), "ParseListEnd(middle) error = %v, want ErrInvalidListEnd", err)
+}
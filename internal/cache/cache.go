@@ -1,7 +1,11 @@
 package cache
 
 import (
+	"errors"
+	"fmt"
 	"time"
+
+	"github.com/dsha256/gredis/internal/pubsub"
 )
 
 // DataType represents the type of data stored in the cache.
@@ -12,29 +16,128 @@ const (
 	StringType DataType = iota
 	// ListType represents a list value.
 	ListType
+	// HashType represents a hash value.
+	HashType
+	// SetType represents an unordered set of unique strings.
+	SetType
+	// SortedSetType represents a set of unique strings ordered by score.
+	SortedSetType
 )
 
+// String returns the Redis-style name of the data type.
+func (t DataType) String() string {
+	switch t {
+	case StringType:
+		return "string"
+	case ListType:
+		return "list"
+	case HashType:
+		return "hash"
+	case SetType:
+		return "set"
+	case SortedSetType:
+		return "zset"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrInvalidDataType is returned by ParseDataType for an unknown name.
+var ErrInvalidDataType = errors.New("invalid data type")
+
+// ParseDataType parses the Redis-style name of a data type, as returned by
+// DataType.String.
+func ParseDataType(name string) (DataType, error) {
+	for t := StringType; t <= SortedSetType; t++ {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrInvalidDataType, name)
+}
+
 // StringCmdable defines the interface for string operations.
 type StringCmdable interface {
 	Get(key string) (string, bool)
 	Set(key string, value string) error
 	SetWithTTL(key string, value string, ttl time.Duration) error
+	SetWithOptions(key string, value string, opts SetOptions) (SetResult, error)
 	Update(key string, value string) error
+	Incr(key string) (int64, error)
+	IncrBy(key string, increment int64) (int64, error)
+	DecrBy(key string, decrement int64) (int64, error)
+	IncrByFloat(key string, increment float64) (float64, error)
+	MGet(keys ...string) map[string]string
+	MSet(values map[string]string) error
+	MSetNX(values map[string]string) (bool, error)
 }
 
 // ListCmdable defines the interface for list operations.
 type ListCmdable interface {
 	PushFront(key string, value string) error
 	PushBack(key string, value string) error
+	ListPush(key string, end ListEnd, values ...string) (int, error)
 	PopFront(key string) (string, bool)
 	PopBack(key string) (string, bool)
 	ListRange(key string, start, end int) ([]string, error)
+	ListLen(key string) (int, error)
+	ListIndex(key string, index int) (string, error)
+	ListSet(key string, index int, value string) error
+	ListInsert(key string, before bool, pivot, value string) (int, error)
+	ListRemove(key string, count int, value string) (int, error)
+	ListTrim(key string, start, end int) error
+	ListPos(key string, value string, opts ListPosOptions) ([]int, error)
+	ListMove(source, destination string, from, to ListEnd) (string, bool, error)
+}
+
+// HashCmdable defines the interface for hash operations.
+type HashCmdable interface {
+	HSet(key string, fields map[string]string) (int, error)
+	HGet(key string, field string) (string, error)
+	HDel(key string, fields ...string) (int, error)
+	HGetAll(key string) (map[string]string, error)
+	HIncrBy(key string, field string, increment int64) (int64, error)
+}
+
+// SetCmdable defines the interface for set operations.
+type SetCmdable interface {
+	SAdd(key string, members ...string) (int, error)
+	SRem(key string, members ...string) (int, error)
+	SIsMember(key string, member string) (bool, error)
+	SMembers(key string) ([]string, error)
+	SCard(key string) (int, error)
+	SInter(keys ...string) ([]string, error)
+	SUnion(keys ...string) ([]string, error)
+	SDiff(keys ...string) ([]string, error)
+	SInterStore(destination string, keys ...string) (int, error)
+	SUnionStore(destination string, keys ...string) (int, error)
+	SDiffStore(destination string, keys ...string) (int, error)
+}
+
+// SortedSetCmdable defines the interface for sorted set operations.
+type SortedSetCmdable interface {
+	ZAdd(key string, opts ZAddOptions, members ...Z) (int, error)
+	ZIncrBy(key string, increment float64, member string) (float64, error)
+	ZScore(key string, member string) (float64, error)
+	ZRank(key string, member string, rev bool) (int, error)
+	ZRange(key string, start, stop int, rev bool) ([]Z, error)
+	ZRangeByScore(key string, min, max ScoreBound, opts ZRangeOptions) ([]Z, error)
+	ZRangeByLex(key string, min, max LexBound, opts ZRangeOptions) ([]string, error)
+	ZCount(key string, min, max ScoreBound) (int, error)
+	ZCard(key string) (int, error)
+	ZRem(key string, members ...string) (int, error)
+	ZPopMin(key string, count int) ([]Z, error)
+	ZPopMax(key string, count int) ([]Z, error)
 }
 
 // TTLCmdable defines the interface for TTL operations.
 type TTLCmdable interface {
 	SetTTL(key string, ttl time.Duration) error
+	ExpireAt(key string, at time.Time) error
+	ExpireWithOptions(key string, ttl time.Duration, opts ExpireOptions) (bool, error)
+	ExpireAtWithOptions(key string, at time.Time, opts ExpireOptions) (bool, error)
 	GetTTL(key string) (time.Duration, bool)
+	ExpireTime(key string) (time.Time, bool)
 	RemoveTTL(key string) error
 }
 
@@ -43,13 +146,83 @@ type GeneralCmdable interface {
 	Remove(key string) error
 	Exists(key string) bool
 	Type(key string) (DataType, bool)
+	Rename(key, newKey string, replace bool) error
+	Copy(source, destination string, replace bool) error
+	Scan(cursor uint64, opts ScanOptions) ([]string, uint64)
+	Keys(pattern string) []string
 	Clear() error
 }
 
+// PubSubCmdable defines the interface for publish/subscribe messaging.
+type PubSubCmdable interface {
+	Publish(channel string, message string) int
+	Subscribe(channels ...string) *pubsub.Subscription
+	PSubscribe(patterns ...string) *pubsub.Subscription
+}
+
+// Dumper is implemented by caches whose contents can be copied out and
+// restored, e.g. for persistence.
+type Dumper interface {
+	Dump() []Entry
+	Restore(entries []Entry) error
+}
+
+// Loader is implemented by caches that can suspend key expiration while
+// their contents are rebuilt, e.g. from an append-only file.
+type Loader interface {
+	BeginLoad()
+	EndLoad()
+}
+
+// MemoryReporter is implemented by caches that track their memory use.
+type MemoryReporter interface {
+	MemoryStats() MemoryStats
+}
+
+// Evictor is implemented by caches that evict keys to stay within a memory
+// limit. The function passed to OnEvict is called with every evicted key.
+type Evictor interface {
+	OnEvict(fn func(key string))
+}
+
+// Partitioner is implemented by caches that spread their keys over shards
+// locked independently of each other. A write only changes the shards of
+// the keys it is given, including through the evictions it causes.
+type Partitioner interface {
+	ShardCount() int
+	// ShardIndex returns the shard of key, from 0 to ShardCount()-1.
+	ShardIndex(key string) int
+}
+
+// Transactor is implemented by caches that can apply several operations
+// atomically, see Tx.
+type Transactor interface {
+	Atomically(fn func(c Store))
+	// Version returns the version of key, which changes whenever the key
+	// is written, removed or expires, including when it is created and
+	// removed again, or when the cache is cleared. A missing key has
+	// version 0 until a key is first removed; the version of a missing
+	// key may also change when other keys are removed.
+	Version(key string) uint64
+}
+
 // Cache defines the interface for all cache operations.
 type Cache interface {
 	StringCmdable
 	ListCmdable
+	HashCmdable
+	SetCmdable
+	SortedSetCmdable
 	TTLCmdable
 	GeneralCmdable
+	PubSubCmdable
+}
+
+// Store is a cache whose contents can be dumped and restored and that
+// supports transactions and blocking list operations.
+type Store interface {
+	Cache
+	Dumper
+	Transactor
+	ListWaiter
 }
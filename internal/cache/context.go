@@ -0,0 +1,420 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// contextCheckInterval is the number of keys a long operation goes through
+// between two checks of its context.
+const contextCheckInterval = 1024
+
+// StringCmdableContext defines the interface for string operations that
+// take a context. Each method is the one of StringCmdable without the
+// suffix, and fails with the error of the context once it is done.
+type StringCmdableContext interface {
+	GetContext(ctx context.Context, key string) (string, bool, error)
+	SetContext(ctx context.Context, key string, value string) error
+	SetWithTTLContext(ctx context.Context, key string, value string, ttl time.Duration) error
+	SetWithOptionsContext(ctx context.Context, key string, value string, opts SetOptions) (SetResult, error)
+	UpdateContext(ctx context.Context, key string, value string) error
+	IncrContext(ctx context.Context, key string) (int64, error)
+	IncrByContext(ctx context.Context, key string, increment int64) (int64, error)
+	DecrByContext(ctx context.Context, key string, decrement int64) (int64, error)
+	IncrByFloatContext(ctx context.Context, key string, increment float64) (float64, error)
+	MGetContext(ctx context.Context, keys ...string) (map[string]string, error)
+	MSetContext(ctx context.Context, values map[string]string) error
+	MSetNXContext(ctx context.Context, values map[string]string) (bool, error)
+}
+
+// ListCmdableContext defines the interface for list operations that take a
+// context, see StringCmdableContext.
+type ListCmdableContext interface {
+	PushFrontContext(ctx context.Context, key string, value string) error
+	PushBackContext(ctx context.Context, key string, value string) error
+	ListPushContext(ctx context.Context, key string, end ListEnd, values ...string) (int, error)
+	PopFrontContext(ctx context.Context, key string) (string, bool, error)
+	PopBackContext(ctx context.Context, key string) (string, bool, error)
+	ListRangeContext(ctx context.Context, key string, start, end int) ([]string, error)
+	ListLenContext(ctx context.Context, key string) (int, error)
+	ListIndexContext(ctx context.Context, key string, index int) (string, error)
+	ListSetContext(ctx context.Context, key string, index int, value string) error
+	ListInsertContext(ctx context.Context, key string, before bool, pivot, value string) (int, error)
+	ListRemoveContext(ctx context.Context, key string, count int, value string) (int, error)
+	ListTrimContext(ctx context.Context, key string, start, end int) error
+	ListPosContext(ctx context.Context, key string, value string, opts ListPosOptions) ([]int, error)
+	ListMoveContext(ctx context.Context, source, destination string, from, to ListEnd) (string, bool, error)
+}
+
+// TTLCmdableContext defines the interface for TTL operations that take a
+// context, see StringCmdableContext.
+type TTLCmdableContext interface {
+	SetTTLContext(ctx context.Context, key string, ttl time.Duration) error
+	ExpireAtContext(ctx context.Context, key string, at time.Time) error
+	ExpireWithOptionsContext(ctx context.Context, key string, ttl time.Duration, opts ExpireOptions) (bool, error)
+	ExpireAtWithOptionsContext(ctx context.Context, key string, at time.Time, opts ExpireOptions) (bool, error)
+	GetTTLContext(ctx context.Context, key string) (time.Duration, bool, error)
+	ExpireTimeContext(ctx context.Context, key string) (time.Time, bool, error)
+	RemoveTTLContext(ctx context.Context, key string) error
+}
+
+// GeneralCmdableContext defines the interface for general operations that
+// take a context, see StringCmdableContext. Scan, Keys and Clear may also
+// stop partway through once the context is done; Clear then removes
+// nothing.
+type GeneralCmdableContext interface {
+	RemoveContext(ctx context.Context, key string) error
+	ExistsContext(ctx context.Context, key string) (bool, error)
+	TypeContext(ctx context.Context, key string) (DataType, bool, error)
+	RenameContext(ctx context.Context, key, newKey string, replace bool) error
+	CopyContext(ctx context.Context, source, destination string, replace bool) error
+	ScanContext(ctx context.Context, cursor uint64, opts ScanOptions) ([]string, uint64, error)
+	KeysContext(ctx context.Context, pattern string) ([]string, error)
+	ClearContext(ctx context.Context) error
+}
+
+// ContextCache is a cache whose string, list, TTL and general operations
+// also come in variants that take a context, so that deadlines and
+// cancellation reach the cache. Use WithContext to get one from any Cache.
+type ContextCache interface {
+	Cache
+	StringCmdableContext
+	ListCmdableContext
+	TTLCmdableContext
+	GeneralCmdableContext
+}
+
+// contextRunner is implemented by caches whose long operations stop once
+// their context is done, as MemoryCache and ShardedCache do.
+type contextRunner interface {
+	ScanContext(ctx context.Context, cursor uint64, opts ScanOptions) ([]string, uint64, error)
+	KeysContext(ctx context.Context, pattern string) ([]string, error)
+	ClearContext(ctx context.Context) error
+}
+
+// WithContext returns c as a ContextCache. If c is not one already, every
+// context variant checks its context before calling the method of c, and
+// Scan, Keys and Clear use the context variants of c if it has them, so
+// that they can stop partway through.
+func WithContext(c Cache) ContextCache {
+	if cc, ok := c.(ContextCache); ok {
+		return cc
+	}
+	return contextCache{c}
+}
+
+// contextCache adds the context variants to a Cache, see WithContext.
+type contextCache struct {
+	Cache
+}
+
+// String operations.
+
+func (c contextCache) GetContext(ctx context.Context, key string) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	value, found := c.Get(key)
+	return value, found, nil
+}
+
+func (c contextCache) SetContext(ctx context.Context, key string, value string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Set(key, value)
+}
+
+func (c contextCache) SetWithTTLContext(ctx context.Context, key string, value string, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.SetWithTTL(key, value, ttl)
+}
+
+func (c contextCache) SetWithOptionsContext(ctx context.Context, key string, value string, opts SetOptions) (SetResult, error) {
+	if err := ctx.Err(); err != nil {
+		return SetResult{}, err
+	}
+	return c.SetWithOptions(key, value, opts)
+}
+
+func (c contextCache) UpdateContext(ctx context.Context, key string, value string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Update(key, value)
+}
+
+func (c contextCache) IncrContext(ctx context.Context, key string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.Incr(key)
+}
+
+func (c contextCache) IncrByContext(ctx context.Context, key string, increment int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.IncrBy(key, increment)
+}
+
+func (c contextCache) DecrByContext(ctx context.Context, key string, decrement int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.DecrBy(key, decrement)
+}
+
+func (c contextCache) IncrByFloatContext(ctx context.Context, key string, increment float64) (float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.IncrByFloat(key, increment)
+}
+
+func (c contextCache) MGetContext(ctx context.Context, keys ...string) (map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.MGet(keys...), nil
+}
+
+func (c contextCache) MSetContext(ctx context.Context, values map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.MSet(values)
+}
+
+func (c contextCache) MSetNXContext(ctx context.Context, values map[string]string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return c.MSetNX(values)
+}
+
+// List operations.
+
+func (c contextCache) PushFrontContext(ctx context.Context, key string, value string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.PushFront(key, value)
+}
+
+func (c contextCache) PushBackContext(ctx context.Context, key string, value string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.PushBack(key, value)
+}
+
+func (c contextCache) ListPushContext(ctx context.Context, key string, end ListEnd, values ...string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.ListPush(key, end, values...)
+}
+
+func (c contextCache) PopFrontContext(ctx context.Context, key string) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	value, found := c.PopFront(key)
+	return value, found, nil
+}
+
+func (c contextCache) PopBackContext(ctx context.Context, key string) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	value, found := c.PopBack(key)
+	return value, found, nil
+}
+
+func (c contextCache) ListRangeContext(ctx context.Context, key string, start, end int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.ListRange(key, start, end)
+}
+
+func (c contextCache) ListLenContext(ctx context.Context, key string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.ListLen(key)
+}
+
+func (c contextCache) ListIndexContext(ctx context.Context, key string, index int) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return c.ListIndex(key, index)
+}
+
+func (c contextCache) ListSetContext(ctx context.Context, key string, index int, value string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.ListSet(key, index, value)
+}
+
+func (c contextCache) ListInsertContext(ctx context.Context, key string, before bool, pivot, value string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.ListInsert(key, before, pivot, value)
+}
+
+func (c contextCache) ListRemoveContext(ctx context.Context, key string, count int, value string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.ListRemove(key, count, value)
+}
+
+func (c contextCache) ListTrimContext(ctx context.Context, key string, start, end int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.ListTrim(key, start, end)
+}
+
+func (c contextCache) ListPosContext(ctx context.Context, key string, value string, opts ListPosOptions) ([]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.ListPos(key, value, opts)
+}
+
+func (c contextCache) ListMoveContext(ctx context.Context, source, destination string, from, to ListEnd) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	return c.ListMove(source, destination, from, to)
+}
+
+// TTL operations.
+
+func (c contextCache) SetTTLContext(ctx context.Context, key string, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.SetTTL(key, ttl)
+}
+
+func (c contextCache) ExpireAtContext(ctx context.Context, key string, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.ExpireAt(key, at)
+}
+
+func (c contextCache) ExpireWithOptionsContext(ctx context.Context, key string, ttl time.Duration, opts ExpireOptions) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return c.ExpireWithOptions(key, ttl, opts)
+}
+
+func (c contextCache) ExpireAtWithOptionsContext(ctx context.Context, key string, at time.Time, opts ExpireOptions) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return c.ExpireAtWithOptions(key, at, opts)
+}
+
+func (c contextCache) GetTTLContext(ctx context.Context, key string) (time.Duration, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, false, err
+	}
+	ttl, found := c.GetTTL(key)
+	return ttl, found, nil
+}
+
+func (c contextCache) ExpireTimeContext(ctx context.Context, key string) (time.Time, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return time.Time{}, false, err
+	}
+	at, found := c.ExpireTime(key)
+	return at, found, nil
+}
+
+func (c contextCache) RemoveTTLContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.RemoveTTL(key)
+}
+
+// General operations.
+
+func (c contextCache) RemoveContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Remove(key)
+}
+
+func (c contextCache) ExistsContext(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return c.Exists(key), nil
+}
+
+func (c contextCache) TypeContext(ctx context.Context, key string) (DataType, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, false, err
+	}
+	dataType, found := c.Type(key)
+	return dataType, found, nil
+}
+
+func (c contextCache) RenameContext(ctx context.Context, key, newKey string, replace bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Rename(key, newKey, replace)
+}
+
+func (c contextCache) CopyContext(ctx context.Context, source, destination string, replace bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Copy(source, destination, replace)
+}
+
+func (c contextCache) ScanContext(ctx context.Context, cursor uint64, opts ScanOptions) ([]string, uint64, error) {
+	if r, ok := c.Cache.(contextRunner); ok {
+		return r.ScanContext(ctx, cursor, opts)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	keys, next := c.Scan(cursor, opts)
+	return keys, next, nil
+}
+
+func (c contextCache) KeysContext(ctx context.Context, pattern string) ([]string, error) {
+	if r, ok := c.Cache.(contextRunner); ok {
+		return r.KeysContext(ctx, pattern)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Keys(pattern), nil
+}
+
+func (c contextCache) ClearContext(ctx context.Context) error {
+	if r, ok := c.Cache.(contextRunner); ok {
+		return r.ClearContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Clear()
+}
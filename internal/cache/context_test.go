@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// countdownContext is a context that is done once its Err method has been
+// called n times, so that a test can cancel an operation partway through.
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (c *countdownContext) Err() error {
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+// plainCache hides the context variants of the cache it wraps, like a
+// Cache implemented outside of this package.
+type plainCache struct {
+	Cache
+}
+
+func TestWithContext(t *testing.T) {
+	t.Parallel()
+
+	caches := []struct {
+		name string
+		c    Cache
+	}{
+		{"memory", NewMemoryCache(0)},
+		{"sharded", NewShardedCache(4, 0)},
+		{"plain", plainCache{NewMemoryCache(0)}},
+	}
+
+	for _, tc := range caches {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := WithContext(tc.c)
+			ctx := context.Background()
+			requireNoError(t, c.SetContext(ctx, "s", "v"), "SetContext() failed")
+			requireNoError(t, c.PushBackContext(ctx, "l", "x"), "PushBackContext() failed")
+			requireNoError(t, c.SetTTLContext(ctx, "s", time.Hour), "SetTTLContext() failed")
+
+			value, found, err := c.GetContext(ctx, "s")
+			require(t, err == nil && found && value == "v", "GetContext() = %q, %v, %v", value, found, err)
+			ttl, found, err := c.GetTTLContext(ctx, "s")
+			require(t, err == nil && found && ttl > 0, "GetTTLContext() = %v, %v, %v", ttl, found, err)
+			keys, err := c.KeysContext(ctx, "*")
+			require(t, err == nil && len(keys) == 2, "KeysContext() = %v, %v", keys, err)
+			scanned := 0
+			for cursor := uint64(0); ; {
+				keys, cursor, err = c.ScanContext(ctx, cursor, ScanOptions{})
+				requireNoError(t, err, "ScanContext() failed: %v", err)
+				if scanned += len(keys); cursor == 0 {
+					break
+				}
+			}
+			require(t, scanned == 2, "ScanContext() returned %d keys, want 2", scanned)
+
+			cancelled, cancel := context.WithCancel(ctx)
+			cancel()
+			err = c.SetContext(cancelled, "other", "v")
+			require(t, errors.Is(err, context.Canceled), "SetContext() error = %v, want context.Canceled", err)
+			require(t, !c.Exists("other"), "SetContext() stored a key with a cancelled context")
+			_, _, err = c.PopFrontContext(cancelled, "l")
+			require(t, errors.Is(err, context.Canceled), "PopFrontContext() error = %v, want context.Canceled", err)
+			_, err = c.KeysContext(cancelled, "*")
+			require(t, errors.Is(err, context.Canceled), "KeysContext() error = %v, want context.Canceled", err)
+			_, _, err = c.ScanContext(cancelled, 0, ScanOptions{})
+			require(t, errors.Is(err, context.Canceled), "ScanContext() error = %v, want context.Canceled", err)
+			err = c.ClearContext(cancelled)
+			require(t, errors.Is(err, context.Canceled), "ClearContext() error = %v, want context.Canceled", err)
+			require(t, c.Exists("s") && c.Exists("l"), "ClearContext() removed keys with a cancelled context")
+
+			requireNoError(t, c.ClearContext(ctx), "ClearContext() failed")
+			require(t, len(c.Keys("*")) == 0, "ClearContext() left keys")
+		})
+	}
+}
+
+func TestContextCancelsLongOperations(t *testing.T) {
+	t.Parallel()
+
+	const n = 4 * contextCheckInterval
+	for _, c := range []Cache{NewMemoryCache(0), NewShardedCache(4, 0)} {
+		cc := WithContext(c)
+		for i := range n {
+			requireNoError(t, c.Set("key:"+strconv.Itoa(i), "v"), "Set() failed")
+		}
+
+		// The contexts are done after a few checks, while going through
+		// the keys or locking the shards.
+		_, err := cc.KeysContext(&countdownContext{context.Background(), 2}, "*")
+		require(t, errors.Is(err, context.Canceled), "KeysContext() error = %v, want context.Canceled", err)
+
+		// A clear cancelled before it could lock the whole cache removes
+		// nothing and leaves it unlocked.
+		err = cc.ClearContext(&countdownContext{context.Background(), 1})
+		require(t, errors.Is(err, context.Canceled), "ClearContext() error = %v, want context.Canceled", err)
+		require(t, len(c.Keys("*")) == n, "ClearContext() removed keys when cancelled")
+		requireNoError(t, c.Set("after", "v"), "Set() failed")
+	}
+
+	// A scan step over a single cache examines every key for a count of
+	// n, checking its context before locking and after every
+	// contextCheckInterval keys, so the third check fails.
+	c := NewMemoryCache(0)
+	for i := range n {
+		requireNoError(t, c.Set("key:"+strconv.Itoa(i), "v"), "Set() failed")
+	}
+	_, _, err := c.ScanContext(&countdownContext{context.Background(), 2}, 0, ScanOptions{Count: n})
+	require(t, errors.Is(err, context.Canceled), "ScanContext() error = %v, want context.Canceled", err)
+	keys, _, err := c.ScanContext(&countdownContext{context.Background(), n}, 0, ScanOptions{Count: n})
+	require(t, err == nil && len(keys) == n, "ScanContext() = %d keys, %v, want every key", len(keys), err)
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"math"
+	"strconv"
+)
+
+// Incr increments the integer stored at key by one, see IncrBy.
+func (c *MemoryCache) Incr(key string) (int64, error) {
+	return c.IncrBy(key, 1)
+}
+
+// IncrBy increments the integer stored as a string at key and returns the
+// new value. A missing key is created at zero first; the expiration of an
+// existing key is kept. It fails with ErrNotInteger if the value is not a
+// decimal 64-bit integer and with ErrOverflow if the result would not be.
+func (c *MemoryCache) IncrBy(key string, increment int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reserve(); err != nil {
+		return 0, err
+	}
+
+	item, err := c.counter(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var current int64
+	if item != nil {
+		if current, err = strconv.ParseInt(item.value.(string), 10, 64); err != nil {
+			return 0, ErrNotInteger
+		}
+	}
+
+	if (increment > 0 && current > math.MaxInt64-increment) ||
+		(increment < 0 && current < math.MinInt64-increment) {
+		return 0, ErrOverflow
+	}
+	current += increment
+
+	c.storeCounter(key, item, strconv.FormatInt(current, 10), "incrby")
+	return current, nil
+}
+
+// DecrBy decrements the integer stored at key, see IncrBy.
+func (c *MemoryCache) DecrBy(key string, decrement int64) (int64, error) {
+	if decrement == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+	return c.IncrBy(key, -decrement)
+}
+
+// IncrByFloat increments the number stored as a string at key and returns
+// the new value. A missing key is created at zero first; the expiration of
+// an existing key is kept. It fails with ErrNotFloat if the value or the
+// increment is not a finite number and with ErrOverflow if the result
+// would be infinite.
+func (c *MemoryCache) IncrByFloat(key string, increment float64) (float64, error) {
+	if math.IsNaN(increment) || math.IsInf(increment, 0) {
+		return 0, ErrNotFloat
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reserve(); err != nil {
+		return 0, err
+	}
+
+	item, err := c.counter(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var current float64
+	if item != nil {
+		current, err = strconv.ParseFloat(item.value.(string), 64)
+		if err != nil || math.IsNaN(current) || math.IsInf(current, 0) {
+			return 0, ErrNotFloat
+		}
+	}
+
+	current += increment
+	if math.IsInf(current, 0) {
+		return 0, ErrOverflow
+	}
+
+	c.storeCounter(key, item, FormatFloat(current), "incrbyfloat")
+	return current, nil
+}
+
+// FormatFloat formats a number the way IncrByFloat stores it: in decimal,
+// without an exponent, with as many digits as needed to parse it back.
+func FormatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// counter returns the live string item at key, or nil if there is none.
+// The caller must hold the write lock.
+func (c *MemoryCache) counter(key string) (*cacheItem, error) {
+	item := c.lookupItem(key)
+	if item != nil && item.dataType != StringType {
+		return nil, ErrTypeMismatch
+	}
+	return item, nil
+}
+
+// storeCounter stores value at key, in item if the key exists, and
+// publishes event. The caller must hold the write lock.
+func (c *MemoryCache) storeCounter(key string, item *cacheItem, value string, event string) {
+	if item == nil {
+		c.storeItem(key, &cacheItem{
+			dataType: StringType,
+			value:    value,
+		})
+	} else {
+		c.resize(item, stringSize(value)-stringSize(item.value.(string)))
+		item.value = value
+	}
+	c.notify(EventsString, event, key)
+}
@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_Counters(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		setup     func(c *MemoryCache)
+		operation func(c *MemoryCache) (any, error)
+		want      any
+		wantErr   error
+		// stored is the value expected at key "n" afterwards, if not empty.
+		stored string
+	}{
+		{
+			name:      "Incr missing key",
+			operation: func(c *MemoryCache) (any, error) { return c.Incr("n") },
+			want:      int64(1),
+			stored:    "1",
+		},
+		{
+			name:      "IncrBy existing value",
+			setup:     func(c *MemoryCache) { requireNoError(t, c.Set("n", "-5"), "Setup failed") },
+			operation: func(c *MemoryCache) (any, error) { return c.IncrBy("n", 8) },
+			want:      int64(3),
+			stored:    "3",
+		},
+		{
+			name:      "DecrBy",
+			setup:     func(c *MemoryCache) { requireNoError(t, c.Set("n", "10"), "Setup failed") },
+			operation: func(c *MemoryCache) (any, error) { return c.DecrBy("n", 15) },
+			want:      int64(-5),
+			stored:    "-5",
+		},
+		{
+			name:      "IncrBy non-integer",
+			setup:     func(c *MemoryCache) { requireNoError(t, c.Set("n", "1.5"), "Setup failed") },
+			operation: func(c *MemoryCache) (any, error) { return c.IncrBy("n", 1) },
+			wantErr:   ErrNotInteger,
+			stored:    "1.5",
+		},
+		{
+			name:      "IncrBy overflow",
+			setup:     func(c *MemoryCache) { requireNoError(t, c.Set("n", "9223372036854775800"), "Setup failed") },
+			operation: func(c *MemoryCache) (any, error) { return c.IncrBy("n", 8) },
+			wantErr:   ErrOverflow,
+			stored:    "9223372036854775800",
+		},
+		{
+			name:      "DecrBy minimum",
+			operation: func(c *MemoryCache) (any, error) { return c.DecrBy("n", math.MinInt64) },
+			wantErr:   ErrOverflow,
+		},
+		{
+			name: "IncrBy list",
+			setup: func(c *MemoryCache) {
+				requireNoError(t, c.PushBack("n", "1"), "Setup failed")
+			},
+			operation: func(c *MemoryCache) (any, error) { return c.IncrBy("n", 1) },
+			wantErr:   ErrTypeMismatch,
+		},
+		{
+			name:      "IncrByFloat integer value",
+			setup:     func(c *MemoryCache) { requireNoError(t, c.Set("n", "10"), "Setup failed") },
+			operation: func(c *MemoryCache) (any, error) { return c.IncrByFloat("n", 0.5) },
+			want:      10.5,
+			stored:    "10.5",
+		},
+		{
+			name:      "IncrByFloat to an integer",
+			setup:     func(c *MemoryCache) { requireNoError(t, c.Set("n", "1.5"), "Setup failed") },
+			operation: func(c *MemoryCache) (any, error) { return c.IncrByFloat("n", 1.5) },
+			want:      3.0,
+			stored:    "3",
+		},
+		{
+			name:      "IncrByFloat exponent",
+			setup:     func(c *MemoryCache) { requireNoError(t, c.Set("n", "5.0e3"), "Setup failed") },
+			operation: func(c *MemoryCache) (any, error) { return c.IncrByFloat("n", 200) },
+			want:      5200.0,
+			stored:    "5200",
+		},
+		{
+			name:      "IncrByFloat non-numeric",
+			setup:     func(c *MemoryCache) { requireNoError(t, c.Set("n", "abc"), "Setup failed") },
+			operation: func(c *MemoryCache) (any, error) { return c.IncrByFloat("n", 1) },
+			wantErr:   ErrNotFloat,
+		},
+		{
+			name:      "IncrByFloat infinite value",
+			setup:     func(c *MemoryCache) { requireNoError(t, c.Set("n", "inf"), "Setup failed") },
+			operation: func(c *MemoryCache) (any, error) { return c.IncrByFloat("n", 1) },
+			wantErr:   ErrNotFloat,
+		},
+		{
+			name:      "IncrByFloat NaN increment",
+			operation: func(c *MemoryCache) (any, error) { return c.IncrByFloat("n", math.NaN()) },
+			wantErr:   ErrNotFloat,
+		},
+		{
+			name:      "IncrByFloat overflow",
+			setup:     func(c *MemoryCache) { requireNoError(t, c.Set("n", "1e308"), "Setup failed") },
+			operation: func(c *MemoryCache) (any, error) { return c.IncrByFloat("n", 1e308) },
+			wantErr:   ErrOverflow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := NewMemoryCache(0)
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+
+			got, err := tt.operation(c)
+			if tt.wantErr != nil {
+				require(t, errors.Is(err, tt.wantErr), "error = %v, want %v", err, tt.wantErr)
+			} else {
+				requireNoError(t, err, "unexpected error")
+				require(t, got == tt.want, "got %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+			if tt.stored != "" {
+				value, _ := c.Get("n")
+				require(t, value == tt.stored, "stored value = %q, want %q", value, tt.stored)
+			}
+		})
+	}
+}
+
+func TestMemoryCache_CounterKeepsTTL(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+	requireNoError(t, c.SetWithTTL("n", "1", time.Hour), "SetWithTTL failed")
+	_, err := c.IncrBy("n", 1)
+	requireNoError(t, err, "IncrBy failed")
+	_, err = c.IncrByFloat("n", 1)
+	requireNoError(t, err, "IncrByFloat failed")
+
+	ttl, found := c.GetTTL("n")
+	require(t, found && ttl > 59*time.Minute, "TTL = %v after increments, want about an hour", ttl)
+
+	// An expired counter starts again from zero, without a TTL.
+	requireNoError(t, c.SetWithTTL("old", "41", time.Millisecond), "SetWithTTL failed")
+	time.Sleep(5 * time.Millisecond)
+	n, err := c.Incr("old")
+	requireNoError(t, err, "Incr failed")
+	require(t, n == 1, "Incr of expired key = %d, want 1", n)
+	ttl, found = c.GetTTL("old")
+	require(t, found && ttl < 0, "TTL = %v, want none", ttl)
+}
+
+func TestCounters_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []Cache{NewMemoryCache(0), NewShardedCache(4, 0)} {
+		const workers, increments = 8, 500
+
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range increments {
+					_, err := c.Incr("hits")
+					requireNoError(t, err, "Incr failed")
+					_, err = c.IncrByFloat("load", 0.5)
+					requireNoError(t, err, "IncrByFloat failed")
+				}
+			}()
+		}
+		wg.Wait()
+
+		hits, _ := c.Get("hits")
+		require(t, hits == "4000", "hits = %s, want 4000", hits)
+		load, _ := c.Get("load")
+		require(t, load == "2000", "load = %s, want 2000", load)
+	}
+}
@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidEntry is returned when an Entry cannot be restored.
+var ErrInvalidEntry = errors.New("invalid entry")
+
+// Entry is a self-contained copy of a key, its value and its expiration.
+// Entries move data in and out of a cache, e.g. for persistence.
+//
+// Value holds a string for StringType, a []string for ListType (front to
+// back) and SetType (sorted), a map[string]string for HashType and a []Z
+// for SortedSetType (ordered by score).
+type Entry struct {
+	Key      string
+	Type     DataType
+	Value    any
+	ExpireAt time.Time // Zero time means no expiration
+}
+
+// Dump returns a copy of every live key in the cache, ordered by key. The
+// copy shares no memory with the cache.
+func (c *MemoryCache) Dump() []Entry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := c.appendEntries(make([]Entry, 0, len(c.items)))
+	sortEntries(entries)
+
+	return entries
+}
+
+// appendEntries appends a copy of every live key to entries. The caller
+// must hold at least the read lock.
+func (c *MemoryCache) appendEntries(entries []Entry) []Entry {
+	for key, item := range c.items {
+		if c.expired(item) {
+			continue
+		}
+		entries = append(entries, item.entry(key))
+	}
+	return entries
+}
+
+// sortEntries orders entries by key.
+func sortEntries(entries []Entry) {
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+}
+
+// Restore stores the given entries, replacing existing keys with the same
+// name. Entries that have already expired are skipped. No entry is stored
+// if any of them is invalid.
+func (c *MemoryCache) Restore(entries []Entry) error {
+	items, err := newItems(entries)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, item := range items {
+		c.restoreItem(entries[i].Key, item)
+	}
+
+	return nil
+}
+
+// restoreItem stores a restored item unless it has already expired or is
+// an empty list, which snapshots taken before pops deleted the lists they
+// emptied may hold. The caller must hold the write lock.
+func (c *MemoryCache) restoreItem(key string, item *cacheItem) {
+	if l, ok := item.value.(*list.List); ok && l.Len() == 0 {
+		return
+	}
+	if !c.expired(item) {
+		c.storeItem(key, item)
+		c.notify(EventsGeneric, "restore", key)
+		c.wakeWaiter(key)
+	}
+}
+
+// newItems builds the cache items of entries, failing if any of them is
+// invalid.
+func newItems(entries []Entry) ([]*cacheItem, error) {
+	items := make([]*cacheItem, len(entries))
+	for i, entry := range entries {
+		item, err := newItem(entry)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+// entry returns a deep copy of the item as an Entry.
+func (i *cacheItem) entry(key string) Entry {
+	entry := Entry{
+		Key:      key,
+		Type:     i.dataType,
+		ExpireAt: i.expireAt,
+	}
+
+	switch i.dataType {
+	case StringType:
+		entry.Value = i.value.(string)
+	case ListType:
+		l := i.value.(*list.List)
+		values := make([]string, 0, l.Len())
+		for e := l.Front(); e != nil; e = e.Next() {
+			values = append(values, e.Value.(string))
+		}
+		entry.Value = values
+	case HashType:
+		entry.Value = maps.Clone(i.value.(map[string]string))
+	case SetType:
+		entry.Value = i.value.(memberSet).members()
+	case SortedSetType:
+		z := i.value.(*sortedSet)
+		members := make([]Z, 0, len(z.scores))
+		for node := z.list.first(); node != nil; node = node.levels[0].forward {
+			members = append(members, Z{Member: node.member, Score: node.score})
+		}
+		entry.Value = members
+	}
+
+	return entry
+}
+
+// newItem builds a cache item from a deep copy of entry.
+func newItem(entry Entry) (*cacheItem, error) {
+	item := &cacheItem{
+		dataType: entry.Type,
+		expireAt: entry.ExpireAt,
+	}
+
+	var ok bool
+	switch entry.Type {
+	case StringType:
+		item.value, ok = entry.Value.(string)
+	case ListType:
+		var values []string
+		if values, ok = entry.Value.([]string); ok {
+			l := list.New()
+			for _, value := range values {
+				l.PushBack(value)
+			}
+			item.value = l
+		}
+	case HashType:
+		var hash map[string]string
+		if hash, ok = entry.Value.(map[string]string); ok {
+			item.value = maps.Clone(hash)
+		}
+	case SetType:
+		var members []string
+		if members, ok = entry.Value.([]string); ok {
+			set := make(memberSet, len(members))
+			for _, member := range members {
+				set[member] = struct{}{}
+			}
+			item.value = set
+		}
+	case SortedSetType:
+		var members []Z
+		if members, ok = entry.Value.([]Z); ok {
+			z := newSortedSet()
+			for _, m := range members {
+				z.set(m.Member, m.Score)
+			}
+			item.value = z
+		}
+	default:
+		return nil, fmt.Errorf("%w: key %q has unknown type %d", ErrInvalidEntry, entry.Key, entry.Type)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("%w: key %q holds %T, not a %s value", ErrInvalidEntry, entry.Key, entry.Value, entry.Type)
+	}
+
+	return item, nil
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_DumpRestore(t *testing.T) {
+	t.Parallel()
+
+	src := NewMemoryCache(0)
+	expireAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+
+	requireNoError(t, src.Set("string", "value"), "Set() failed")
+	requireNoError(t, src.PushBack("list", "a"), "PushBack() failed")
+	requireNoError(t, src.PushBack("list", "b"), "PushBack() failed")
+	_, err := src.HSet("hash", map[string]string{"f": "v"})
+	requireNoError(t, err, "HSet() failed: %v", err)
+	_, err = src.SAdd("set", "y", "x")
+	requireNoError(t, err, "SAdd() failed: %v", err)
+	_, err = src.ZAdd("zset", ZAddOptions{}, Z{Member: "m", Score: 2}, Z{Member: "n", Score: 1})
+	requireNoError(t, err, "ZAdd() failed: %v", err)
+	requireNoError(t, src.SetTTL("string", time.Until(expireAt)), "SetTTL() failed")
+	requireNoError(t, src.SetWithTTL("expired", "value", time.Nanosecond), "SetWithTTL() failed")
+	time.Sleep(time.Millisecond)
+
+	entries := src.Dump()
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+	}
+	require(t, reflect.DeepEqual(keys, []string{"hash", "list", "set", "string", "zset"}), "Dump() keys = %v", keys)
+
+	// The dump must not share memory with the cache.
+	entries[2].Value.([]string)[0] = "changed"
+	entries[0].Value.(map[string]string)["f"] = "changed"
+	require(t, reflect.DeepEqual(src.Dump()[2].Value, []string{"x", "y"}), "Dump() shares set memory with the cache")
+
+	dst := NewMemoryCache(0)
+	requireNoError(t, dst.Restore(src.Dump()), "Restore() failed")
+	require(t, reflect.DeepEqual(dst.Dump(), src.Dump()), "Restore() did not reproduce the dump")
+
+	members, err := dst.ZRange("zset", 0, -1, false)
+	requireNoError(t, err, "ZRange() failed: %v", err)
+	require(t, reflect.DeepEqual(members, []Z{{Member: "n", Score: 1}, {Member: "m", Score: 2}}), "ZRange() = %v", members)
+
+	ttl, ok := dst.GetTTL("string")
+	require(t, ok && ttl > 59*time.Minute, "GetTTL() = %v, %v", ttl, ok)
+
+	err = dst.Restore([]Entry{
+		{Key: "ok", Type: StringType, Value: "value"},
+		{Key: "bad", Type: ListType, Value: "not a list"},
+	})
+	require(t, errors.Is(err, ErrInvalidEntry), "Restore() error = %v, want %v", err, ErrInvalidEntry)
+	require(t, !dst.Exists("ok"), "Restore() stored entries despite an invalid one")
+
+	requireNoError(t, dst.Restore([]Entry{
+		{Key: "gone", Type: StringType, Value: "value", ExpireAt: time.Now().Add(-time.Second)},
+	}), "Restore() failed")
+	require(t, !dst.Exists("gone"), "Restore() stored an expired entry")
+}
@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Eviction errors
+var (
+	ErrOutOfMemory           = errors.New("command not allowed when used memory exceeds the limit")
+	ErrInvalidEvictionPolicy = errors.New("invalid eviction policy")
+)
+
+// EvictionPolicy selects the keys evicted when the cache is over its memory
+// limit.
+type EvictionPolicy string
+
+// Eviction policies
+const (
+	// NoEviction rejects writes with ErrOutOfMemory instead of evicting.
+	NoEviction EvictionPolicy = "noeviction"
+	// AllKeysLRU evicts the least recently used keys.
+	AllKeysLRU EvictionPolicy = "allkeys-lru"
+	// AllKeysLFU evicts the least frequently used keys.
+	AllKeysLFU EvictionPolicy = "allkeys-lfu"
+	// VolatileLRU evicts the least recently used keys that have a TTL.
+	VolatileLRU EvictionPolicy = "volatile-lru"
+	// VolatileTTL evicts the keys with the nearest expiration.
+	VolatileTTL EvictionPolicy = "volatile-ttl"
+	// AllKeysRandom evicts random keys.
+	AllKeysRandom EvictionPolicy = "allkeys-random"
+)
+
+// ParseEvictionPolicy parses the name of an eviction policy. An empty name
+// selects NoEviction.
+func ParseEvictionPolicy(name string) (EvictionPolicy, error) {
+	switch policy := EvictionPolicy(name); policy {
+	case "":
+		return NoEviction, nil
+	case NoEviction, AllKeysLRU, AllKeysLFU, VolatileLRU, VolatileTTL, AllKeysRandom:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidEvictionPolicy, name)
+	}
+}
+
+// volatile reports whether the policy only evicts keys that have a TTL.
+func (p EvictionPolicy) volatile() bool {
+	return p == VolatileLRU || p == VolatileTTL
+}
+
+// WithMaxMemory limits the approximate memory used by the cache to
+// maxMemory bytes. Writes made while the cache is over the limit first
+// evict keys according to policy, or fail with ErrOutOfMemory if there is
+// nothing left to evict. Zero means no limit.
+func WithMaxMemory(maxMemory int64, policy EvictionPolicy) Option {
+	return func(c *MemoryCache) {
+		c.maxMemory = maxMemory
+		c.policy = policy
+	}
+}
+
+// MemoryStats describes the memory use of a cache.
+type MemoryStats struct {
+	// UsedMemory is the approximate number of bytes used by the keys.
+	UsedMemory int64
+	// MaxMemory is the memory limit; zero means no limit.
+	MaxMemory int64
+	Policy    EvictionPolicy
+	Keys      int
+	// EvictedKeys counts the keys evicted to stay within the limit.
+	EvictedKeys int64
+	// RejectedWrites counts the writes that failed with ErrOutOfMemory.
+	RejectedWrites int64
+}
+
+// MemoryStats returns the memory use and eviction counters of the cache.
+func (c *MemoryCache) MemoryStats() MemoryStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return MemoryStats{
+		UsedMemory:     c.used,
+		MaxMemory:      c.maxMemory,
+		Policy:         c.policy,
+		Keys:           len(c.items),
+		EvictedKeys:    c.evicted,
+		RejectedWrites: c.rejected,
+	}
+}
+
+// OnEvict registers fn to be called with every key the cache evicts. It is
+// called while the cache is locked, during the write that caused the
+// eviction, so it must not call back into the cache.
+func (c *MemoryCache) OnEvict(fn func(key string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onEvict = fn
+}
+
+// evictionSamples is the number of keys compared to pick each key to evict.
+// Like Redis, the cache approximates its policies by sampling rather than
+// keeping the keys ordered, except for VolatileTTL, which takes the key
+// expiring first from the expiration index.
+const evictionSamples = 5
+
+// reserve makes room for a write that may grow the cache by evicting keys
+// while the cache is over its memory limit. Nothing is evicted while the
+// cache is loading. Any key may be evicted, including the one being
+// written, so writes call it before looking up their key. The caller must
+// hold the write lock.
+func (c *MemoryCache) reserve() error {
+	if c.maxMemory <= 0 || c.loading {
+		return nil
+	}
+
+	for c.used > c.maxMemory {
+		key, found := c.evictionCandidate()
+		if !found {
+			c.rejected++
+			return ErrOutOfMemory
+		}
+
+		c.deleteItem(key)
+		c.evicted++
+		c.notify(EventsEvicted, "evicted", key)
+		if c.onEvict != nil {
+			c.onEvict(key)
+		}
+	}
+
+	return nil
+}
+
+// evictionCandidate picks the key to evict next among a sample of the keys
+// the policy allows to evict. The caller must hold the write lock.
+func (c *MemoryCache) evictionCandidate() (string, bool) {
+	if c.policy == NoEviction {
+		return "", false
+	}
+
+	now := time.Now().UnixNano()
+	var victim string
+	var best *cacheItem
+	switch {
+	case len(c.expiries) == 0 && c.policy.volatile():
+		return "", false
+	case c.policy == VolatileTTL:
+		// The expiration index is ordered, so no sampling is needed.
+		return c.expiries[0].key, true
+	case c.policy.volatile():
+		// The keys that have a TTL are those of the expiration index.
+		// They are all compared if there are no more than the samples.
+		n := len(c.expiries)
+		for i := range min(n, evictionSamples) {
+			if n > evictionSamples {
+				i = rand.IntN(n)
+			}
+			entry := c.expiries[i]
+			if best == nil || c.evictsBefore(entry.item, best, now) {
+				victim, best = entry.key, entry.item
+			}
+		}
+		return victim, best != nil
+	}
+
+	sampled := 0
+	// Map iteration starts at a random key.
+	for key, item := range c.items {
+		if best == nil || c.evictsBefore(item, best, now) {
+			victim, best = key, item
+		}
+		if sampled++; sampled == evictionSamples || c.policy == AllKeysRandom {
+			break
+		}
+	}
+
+	return victim, best != nil
+}
+
+// evictsBefore reports whether the policy evicts a before b.
+func (c *MemoryCache) evictsBefore(a, b *cacheItem, now int64) bool {
+	switch c.policy {
+	case AllKeysLRU, VolatileLRU:
+		return a.lastAccess.Load() < b.lastAccess.Load()
+	case AllKeysLFU:
+		return a.frequency(now) < b.frequency(now)
+	default:
+		return false
+	}
+}
+
+// LFU counters follow Redis: an 8-bit logarithmic counter that starts at
+// lfuInitValue, becomes less likely to grow the higher it is and decays by
+// one for every lfuDecayTime the key is not accessed.
+const (
+	lfuInitValue = 5
+	lfuLogFactor = 10
+	lfuDecayTime = time.Minute
+	lfuMaxValue  = 255
+)
+
+// touch records an access to item for the LRU and LFU policies. It only
+// uses atomics, so the caller may hold either the read or the write lock.
+func (c *MemoryCache) touch(item *cacheItem) {
+	switch c.policy {
+	case AllKeysLRU, VolatileLRU:
+		item.lastAccess.Store(time.Now().UnixNano())
+	case AllKeysLFU:
+		now := time.Now().UnixNano()
+		freq := item.frequency(now)
+		item.lastAccess.Store(now)
+		if freq < lfuMaxValue {
+			base := float64(max(int(freq)-lfuInitValue, 0))
+			if rand.Float64() < 1/(base*lfuLogFactor+1) {
+				freq++
+			}
+		}
+		item.freq.Store(freq)
+	}
+}
+
+// frequency returns the LFU counter of the item decayed up to now.
+func (i *cacheItem) frequency(now int64) uint32 {
+	freq := i.freq.Load()
+	periods := time.Duration(now-i.lastAccess.Load()) / lfuDecayTime
+	if periods >= time.Duration(freq) {
+		return 0
+	}
+	return freq - uint32(periods)
+}
@@ -0,0 +1,257 @@
+package cache
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// requireAccounted fails unless the memory accounted by c matches the size
+// of its items.
+func requireAccounted(t *testing.T, c *MemoryCache) {
+	t.Helper()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var want int64
+	for key, item := range c.items {
+		want += itemSize(key, item)
+	}
+	require(t, c.used == want, "used memory = %d, want %d", c.used, want)
+}
+
+func TestMemoryCache_MemoryAccounting(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"Set", func() error { return c.Set("string", "value") }},
+		{"Set replace", func() error { return c.Set("string", "a longer value") }},
+		{"Update", func() error { return c.Update("string", "v") }},
+		{"PushBack", func() error { return c.PushBack("list", "a") }},
+		{"PushFront", func() error { return c.PushFront("list", "bb") }},
+		{"PopBack", func() error { c.PopBack("list"); return nil }},
+		{"HSet", func() error { _, err := c.HSet("hash", map[string]string{"f": "1", "g": "22"}); return err }},
+		{"HSet replace", func() error { _, err := c.HSet("hash", map[string]string{"f": "111"}); return err }},
+		{"HIncrBy", func() error { _, err := c.HIncrBy("hash", "n", 100); return err }},
+		{"HDel", func() error { _, err := c.HDel("hash", "g"); return err }},
+		{"SAdd", func() error { _, err := c.SAdd("set", "a", "b", "c"); return err }},
+		{"SAdd existing", func() error { _, err := c.SAdd("set", "a", "d"); return err }},
+		{"SRem", func() error { _, err := c.SRem("set", "b", "x"); return err }},
+		{"SUnionStore", func() error { _, err := c.SUnionStore("union", "set", "missing"); return err }},
+		{"ZAdd", func() error {
+			_, err := c.ZAdd("zset", ZAddOptions{}, Z{Member: "a", Score: 1}, Z{Member: "bb", Score: 2}, Z{Member: "a", Score: 3})
+			return err
+		}},
+		{"ZIncrBy", func() error { _, err := c.ZIncrBy("zset", 1, "ccc"); return err }},
+		{"ZRem", func() error { _, err := c.ZRem("zset", "a"); return err }},
+		{"ZPopMin", func() error { _, err := c.ZPopMin("zset", 1); return err }},
+		{"Restore", func() error {
+			return c.Restore([]Entry{{Key: "string", Type: ListType, Value: []string{"x", "y"}}})
+		}},
+		{"Remove", func() error { return c.Remove("union") }},
+	}
+	for _, step := range steps {
+		requireNoError(t, step.run(), "%s failed", step.name)
+		requireAccounted(t, c)
+	}
+
+	for _, key := range []string{"string", "list", "hash", "set", "zset"} {
+		if c.Exists(key) {
+			requireNoError(t, c.Remove(key), "Remove(%q) failed", key)
+		}
+	}
+	require(t, c.MemoryStats().UsedMemory == 0, "used memory = %d after removing every key", c.MemoryStats().UsedMemory)
+
+	requireNoError(t, c.Set("key", "value"), "Set() failed")
+	requireNoError(t, c.Clear(), "Clear() failed")
+	require(t, c.MemoryStats().UsedMemory == 0, "used memory = %d after Clear()", c.MemoryStats().UsedMemory)
+}
+
+func TestMemoryCache_Eviction(t *testing.T) {
+	t.Parallel()
+
+	// Each key holds a one-byte string under a one-byte name, so four keys
+	// exceed the limit by one byte and every key is in the sample.
+	keySize := itemOverhead + 1 + stringSize("v")
+	limit := 4*keySize - 1
+
+	tests := []struct {
+		name   string
+		policy EvictionPolicy
+		// setup adjusts the items a, b, c and d before the cache is
+		// written to again.
+		setup func(items map[string]*cacheItem, now time.Time)
+		// want lists the keys that may be evicted; none means the write
+		// fails with ErrOutOfMemory.
+		want []string
+	}{
+		{
+			name:   "noeviction rejects writes",
+			policy: NoEviction,
+			setup:  func(map[string]*cacheItem, time.Time) {},
+		},
+		{
+			name:   "allkeys-lru evicts the least recently used key",
+			policy: AllKeysLRU,
+			setup: func(items map[string]*cacheItem, now time.Time) {
+				for key, age := range map[string]time.Duration{"a": 3, "b": 4, "c": 1, "d": 2} {
+					items[key].lastAccess.Store(now.Add(-age * time.Second).UnixNano())
+				}
+			},
+			want: []string{"b"},
+		},
+		{
+			name:   "allkeys-lfu evicts the least frequently used key",
+			policy: AllKeysLFU,
+			setup: func(items map[string]*cacheItem, now time.Time) {
+				for key, freq := range map[string]uint32{"a": 10, "b": 8, "c": 2, "d": 9} {
+					items[key].freq.Store(freq)
+					items[key].lastAccess.Store(now.UnixNano())
+				}
+			},
+			want: []string{"c"},
+		},
+		{
+			name:   "volatile-lru only evicts keys with a TTL",
+			policy: VolatileLRU,
+			setup: func(items map[string]*cacheItem, now time.Time) {
+				for key, age := range map[string]time.Duration{"a": 3, "b": 4, "c": 1, "d": 2} {
+					items[key].lastAccess.Store(now.Add(-age * time.Second).UnixNano())
+				}
+				items["a"].expireAt = now.Add(time.Hour)
+				items["d"].expireAt = now.Add(time.Hour)
+			},
+			want: []string{"a"},
+		},
+		{
+			name:   "volatile-ttl evicts the key expiring first",
+			policy: VolatileTTL,
+			setup: func(items map[string]*cacheItem, now time.Time) {
+				items["a"].expireAt = now.Add(2 * time.Hour)
+				items["c"].expireAt = now.Add(time.Hour)
+				items["d"].expireAt = now.Add(3 * time.Hour)
+			},
+			want: []string{"c"},
+		},
+		{
+			name:   "volatile-ttl rejects writes without keys with a TTL",
+			policy: VolatileTTL,
+			setup:  func(map[string]*cacheItem, time.Time) {},
+		},
+		{
+			name:   "allkeys-random evicts any key",
+			policy: AllKeysRandom,
+			setup:  func(map[string]*cacheItem, time.Time) {},
+			want:   []string{"a", "b", "c", "d"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := NewMemoryCache(0, WithMaxMemory(limit, tt.policy))
+			var evicted []string
+			c.OnEvict(func(key string) { evicted = append(evicted, key) })
+
+			for _, key := range []string{"a", "b", "c", "d"} {
+				requireNoError(t, c.Set(key, "v"), "Set(%q) failed", key)
+			}
+			tt.setup(c.items, time.Now())
+			// The setup changes expirations behind the back of the index.
+			for key, item := range c.items {
+				c.indexExpiry(key, item)
+			}
+
+			err := c.Set("e", "v")
+			stats := c.MemoryStats()
+			if len(tt.want) == 0 {
+				require(t, errors.Is(err, ErrOutOfMemory), "Set() error = %v, want ErrOutOfMemory", err)
+				require(t, evicted == nil, "evicted %v", evicted)
+				require(t, stats.RejectedWrites == 1 && stats.EvictedKeys == 0, "stats = %+v", stats)
+
+				// Removing keys is still allowed and makes room again.
+				requireNoError(t, c.Remove("a"), "Remove() failed")
+				requireNoError(t, c.Set("e", "v"), "Set() failed after Remove()")
+				return
+			}
+
+			requireNoError(t, err, "Set() failed: %v", err)
+			require(t, len(evicted) == 1 && slices.Contains(tt.want, evicted[0]), "evicted %v, want one of %v", evicted, tt.want)
+			require(t, !c.Exists(evicted[0]) && c.Exists("e"), "evicted key %q still exists or written key is missing", evicted[0])
+			require(t, stats.EvictedKeys == 1 && stats.RejectedWrites == 0, "stats = %+v", stats)
+			require(t, stats.UsedMemory == 4*keySize && stats.Keys == 4, "stats = %+v", stats)
+			requireAccounted(t, c)
+		})
+	}
+}
+
+func TestMemoryCache_EvictionKeepsWrittenKey(t *testing.T) {
+	t.Parallel()
+
+	// A write over the limit may evict the very key it writes to; the key is
+	// then written from scratch rather than lost.
+	c := NewMemoryCache(0, WithMaxMemory(1, AllKeysRandom))
+	_, err := c.SAdd("set", "a", "b")
+	requireNoError(t, err, "SAdd() failed: %v", err)
+	_, err = c.SAdd("set", "c")
+	requireNoError(t, err, "SAdd() failed: %v", err)
+
+	members, err := c.SMembers("set")
+	requireNoError(t, err, "SMembers() failed: %v", err)
+	require(t, slices.Equal(members, []string{"c"}), "SMembers() = %v, want [c]", members)
+	require(t, c.MemoryStats().EvictedKeys == 1, "EvictedKeys = %d, want 1", c.MemoryStats().EvictedKeys)
+	requireAccounted(t, c)
+}
+
+func TestMemoryCache_LFU(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0, WithMaxMemory(1<<20, AllKeysLFU))
+	requireNoError(t, c.Set("key", "value"), "Set() failed")
+	item := c.items["key"]
+	require(t, item.freq.Load() == lfuInitValue, "initial counter = %d, want %d", item.freq.Load(), lfuInitValue)
+
+	for range 1000 {
+		c.Get("key")
+	}
+	freq := item.freq.Load()
+	require(t, freq > lfuInitValue && freq < 50, "counter after 1000 accesses = %d, want logarithmic growth", freq)
+
+	// The counter decays by one for every minute without access.
+	now := time.Now()
+	item.lastAccess.Store(now.Add(-3 * lfuDecayTime).UnixNano())
+	require(t, item.frequency(now.UnixNano()) == freq-3, "decayed counter = %d, want %d", item.frequency(now.UnixNano()), freq-3)
+	item.lastAccess.Store(now.Add(-time.Duration(freq+1) * lfuDecayTime).UnixNano())
+	require(t, item.frequency(now.UnixNano()) == 0, "decayed counter = %d, want 0", item.frequency(now.UnixNano()))
+}
+
+func TestParseEvictionPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		want    EvictionPolicy
+		wantErr error
+	}{
+		{name: "", want: NoEviction},
+		{name: "noeviction", want: NoEviction},
+		{name: "allkeys-lru", want: AllKeysLRU},
+		{name: "allkeys-lfu", want: AllKeysLFU},
+		{name: "volatile-lru", want: VolatileLRU},
+		{name: "volatile-ttl", want: VolatileTTL},
+		{name: "allkeys-random", want: AllKeysRandom},
+		{name: "volatile-lfu", wantErr: ErrInvalidEvictionPolicy},
+	}
+	for _, tt := range tests {
+		got, err := ParseEvictionPolicy(tt.name)
+		require(t, errors.Is(err, tt.wantErr) && got == tt.want,
+			"ParseEvictionPolicy(%q) = %q, %v, want %q, %v", tt.name, got, err, tt.want, tt.wantErr)
+	}
+}
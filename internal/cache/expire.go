@@ -0,0 +1,108 @@
+package cache
+
+import "time"
+
+// ExpireOptions sets the conditions under which ExpireWithOptions and
+// ExpireAtWithOptions change the expiration of a key, like the options of
+// EXPIRE in Redis. A key without an expiration counts as never expiring,
+// so GT never applies to it and LT always does.
+type ExpireOptions struct {
+	// NX only sets the expiration if the key has none.
+	NX bool
+	// XX only sets the expiration if the key has one.
+	XX bool
+	// GT only sets the expiration if it is later than the current one.
+	GT bool
+	// LT only sets the expiration if it is earlier than the current one.
+	LT bool
+}
+
+// validate reports whether the options can be combined.
+func (o ExpireOptions) validate() error {
+	if (o.NX && (o.XX || o.GT || o.LT)) || (o.GT && o.LT) {
+		return ErrInvalidOptions
+	}
+	return nil
+}
+
+// allows reports whether the options allow replacing the expiration
+// current, zero for none, with at.
+func (o ExpireOptions) allows(current, at time.Time) bool {
+	switch {
+	case o.NX && !current.IsZero():
+		return false
+	case o.XX && current.IsZero():
+		return false
+	case o.GT && (current.IsZero() || !at.After(current)):
+		return false
+	case o.LT && !current.IsZero() && !at.Before(current):
+		return false
+	}
+	return true
+}
+
+// ExpireWithOptions expires the key after ttl, like PEXPIRE in Redis, see
+// ExpireAtWithOptions. Unlike SetTTL, a non-positive ttl deletes the key.
+func (c *MemoryCache) ExpireWithOptions(key string, ttl time.Duration, opts ExpireOptions) (bool, error) {
+	return c.ExpireAtWithOptions(key, time.Now().Add(ttl), opts)
+}
+
+// ExpireAtWithOptions sets the absolute expiration time of a key if opts
+// allow it, like PEXPIREAT in Redis, and reports whether it did. A time in
+// the past deletes the key. It fails with ErrKeyNotFound if the key is
+// missing and with ErrInvalidOptions if opts cannot be combined.
+func (c *MemoryCache) ExpireAtWithOptions(key string, at time.Time, opts ExpireOptions) (bool, error) {
+	if err := opts.validate(); err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := c.lookupItem(key)
+	if item == nil {
+		return false, ErrKeyNotFound
+	}
+	if !opts.allows(item.expireAt, at) {
+		return false, nil
+	}
+
+	c.setExpireAt(key, item, at)
+	if c.expired(item) {
+		c.deleteItem(key)
+		c.notify(EventsGeneric, "del", key)
+	} else {
+		c.notify(EventsGeneric, "expire", key)
+	}
+	return true, nil
+}
+
+// ExpireTime returns the absolute expiration time of a key, like
+// PEXPIRETIME in Redis, or the zero time if the key does not expire. It
+// returns false if the key is missing.
+func (c *MemoryCache) ExpireTime(key string) (time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	item := c.peekItem(key)
+	if item == nil {
+		return time.Time{}, false
+	}
+	return item.expireAt, true
+}
+
+// ExpireWithOptions expires the key after ttl if opts allow it.
+func (s *ShardedCache) ExpireWithOptions(key string, ttl time.Duration, opts ExpireOptions) (bool, error) {
+	return s.shard(key).ExpireWithOptions(key, ttl, opts)
+}
+
+// ExpireAtWithOptions sets the absolute expiration time of a key if opts
+// allow it.
+func (s *ShardedCache) ExpireAtWithOptions(key string, at time.Time, opts ExpireOptions) (bool, error) {
+	return s.shard(key).ExpireAtWithOptions(key, at, opts)
+}
+
+// ExpireTime returns the absolute expiration time of a key.
+func (s *ShardedCache) ExpireTime(key string) (time.Time, bool) {
+	return s.shard(key).ExpireTime(key)
+}
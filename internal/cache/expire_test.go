@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExpireWithOptions(t *testing.T) {
+	t.Parallel()
+
+	hour := time.Now().Add(time.Hour)
+	tests := []struct {
+		name    string
+		current time.Time // zero for no expiration
+		at      time.Time
+		opts    ExpireOptions
+		want    bool
+		wantErr error
+	}{
+		{name: "no options", at: hour, want: true},
+		{name: "NX without expiration", at: hour, opts: ExpireOptions{NX: true}, want: true},
+		{name: "NX with expiration", current: hour, at: hour.Add(time.Hour), opts: ExpireOptions{NX: true}},
+		{name: "XX without expiration", at: hour, opts: ExpireOptions{XX: true}},
+		{name: "XX with expiration", current: hour, at: hour.Add(time.Hour), opts: ExpireOptions{XX: true}, want: true},
+		{name: "GT later", current: hour, at: hour.Add(time.Hour), opts: ExpireOptions{GT: true}, want: true},
+		{name: "GT earlier", current: hour, at: hour.Add(-time.Minute), opts: ExpireOptions{GT: true}},
+		{name: "GT equal", current: hour, at: hour, opts: ExpireOptions{GT: true}},
+		{name: "GT without expiration", at: hour, opts: ExpireOptions{GT: true}},
+		{name: "LT earlier", current: hour, at: hour.Add(-time.Minute), opts: ExpireOptions{LT: true}, want: true},
+		{name: "LT later", current: hour, at: hour.Add(time.Hour), opts: ExpireOptions{LT: true}},
+		{name: "LT without expiration", at: hour, opts: ExpireOptions{LT: true}, want: true},
+		{name: "XX and GT", current: hour, at: hour.Add(time.Hour), opts: ExpireOptions{XX: true, GT: true}, want: true},
+		{name: "NX and XX", at: hour, opts: ExpireOptions{NX: true, XX: true}, wantErr: ErrInvalidOptions},
+		{name: "NX and GT", at: hour, opts: ExpireOptions{NX: true, GT: true}, wantErr: ErrInvalidOptions},
+		{name: "GT and LT", at: hour, opts: ExpireOptions{GT: true, LT: true}, wantErr: ErrInvalidOptions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, c := range []Cache{NewMemoryCache(0), NewShardedCache(4, 0)} {
+				requireNoError(t, c.Set("k", "v"), "Set() failed")
+				if !tt.current.IsZero() {
+					requireNoError(t, c.ExpireAt("k", tt.current), "ExpireAt() failed")
+				}
+
+				set, err := c.ExpireAtWithOptions("k", tt.at, tt.opts)
+				if tt.wantErr != nil {
+					require(t, errors.Is(err, tt.wantErr), "ExpireAtWithOptions() error = %v, want %v", err, tt.wantErr)
+					continue
+				}
+				requireNoError(t, err, "ExpireAtWithOptions() failed: %v", err)
+				require(t, set == tt.want, "ExpireAtWithOptions() = %v, want %v", set, tt.want)
+
+				want := tt.current
+				if set {
+					want = tt.at
+				}
+				at, found := c.ExpireTime("k")
+				require(t, found && at.Equal(want), "ExpireTime() = %v, %v, want %v", at, found, want)
+			}
+		})
+	}
+}
+
+func TestExpireWithOptions_Deletes(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []Cache{NewMemoryCache(0), NewShardedCache(4, 0)} {
+		requireNoError(t, c.Set("past", "v"), "Set() failed")
+		set, err := c.ExpireAtWithOptions("past", time.Now().Add(-time.Second), ExpireOptions{})
+		requireNoError(t, err, "ExpireAtWithOptions() failed: %v", err)
+		require(t, set && !c.Exists("past"), "a past expiration did not delete the key")
+
+		requireNoError(t, c.Set("zero", "v"), "Set() failed")
+		set, err = c.ExpireWithOptions("zero", 0, ExpireOptions{})
+		requireNoError(t, err, "ExpireWithOptions() failed: %v", err)
+		require(t, set && !c.Exists("zero"), "a zero TTL did not delete the key")
+
+		_, err = c.ExpireWithOptions("missing", time.Second, ExpireOptions{})
+		require(t, errors.Is(err, ErrKeyNotFound), "ExpireWithOptions() error = %v, want ErrKeyNotFound", err)
+		_, found := c.ExpireTime("missing")
+		require(t, !found, "ExpireTime() found a missing key")
+	}
+}
+
+func TestExpireWithOptions_Milliseconds(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+	requireNoError(t, c.Set("k", "v"), "Set() failed")
+
+	set, err := c.ExpireWithOptions("k", 1500*time.Millisecond, ExpireOptions{})
+	requireNoError(t, err, "ExpireWithOptions() failed: %v", err)
+	require(t, set, "ExpireWithOptions() did not set the expiration")
+	ttl, _ := c.GetTTL("k")
+	require(t, ttl > 1400*time.Millisecond && ttl <= 1500*time.Millisecond, "GetTTL() = %v, want about 1.5s", ttl)
+
+	at, _ := c.ExpireTime("k")
+	require(t, time.Until(at) > 1400*time.Millisecond, "ExpireTime() = %v, want about 1.5s from now", at)
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expireSlice bounds the number of keys that active expiry deletes while
+// holding the write lock. Between slices the lock is released, so that
+// requests are delayed by at most one slice however many keys expire at
+// once.
+const expireSlice = 64
+
+// expiryEntry places an item that has an expiration in the expiration
+// index.
+type expiryEntry struct {
+	key   string
+	item  *cacheItem
+	index int
+}
+
+// expiryIndex is a min-heap of the items that have an expiration, earliest
+// first, so that active expiry only visits the keys that are due. It
+// implements heap.Interface.
+type expiryIndex []*expiryEntry
+
+func (x expiryIndex) Len() int { return len(x) }
+
+func (x expiryIndex) Less(i, j int) bool {
+	return x[i].item.expireAt.Before(x[j].item.expireAt)
+}
+
+func (x expiryIndex) Swap(i, j int) {
+	x[i], x[j] = x[j], x[i]
+	x[i].index = i
+	x[j].index = j
+}
+
+func (x *expiryIndex) Push(v any) {
+	entry := v.(*expiryEntry)
+	entry.index = len(*x)
+	*x = append(*x, entry)
+}
+
+func (x *expiryIndex) Pop() any {
+	old := *x
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*x = old[:len(old)-1]
+	return entry
+}
+
+// indexExpiry adds the item stored at key to the expiration index, moves
+// it after its expiration changed, or removes it if it no longer has one.
+// The caller must hold the write lock.
+func (c *MemoryCache) indexExpiry(key string, item *cacheItem) {
+	switch {
+	case item.expireAt.IsZero():
+		c.unindexExpiry(item)
+	case item.expiry != nil:
+		heap.Fix(&c.expiries, item.expiry.index)
+	default:
+		item.expiry = &expiryEntry{key: key, item: item}
+		heap.Push(&c.expiries, item.expiry)
+	}
+}
+
+// unindexExpiry removes item from the expiration index, if it is there.
+// The caller must hold the write lock.
+func (c *MemoryCache) unindexExpiry(item *cacheItem) {
+	if item.expiry != nil {
+		heap.Remove(&c.expiries, item.expiry.index)
+		item.expiry = nil
+	}
+}
+
+// setExpireAt changes the expiration of the item stored at key; the zero
+// time removes it. The caller must hold the write lock.
+func (c *MemoryCache) setExpireAt(key string, item *cacheItem, at time.Time) {
+	item.expireAt = at
+	c.indexExpiry(key, item)
+	c.modified(item)
+}
+
+// cleanup deletes the expired items, earliest first, in slices of at most
+// expireSlice keys between which it releases the write lock. It only visits
+// the keys that are due, so its cost does not grow with the number of keys.
+func (c *MemoryCache) cleanup() {
+	for {
+		if c.expireDue(expireSlice) < expireSlice {
+			return
+		}
+	}
+}
+
+// expireDue deletes up to limit expired items, earliest first, and returns
+// how many it deleted.
+func (c *MemoryCache) expireDue(limit int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	deleted := 0
+	for deleted < limit && len(c.expiries) > 0 && !c.loading {
+		entry := c.expiries[0]
+		if !entry.item.expireAt.Before(now) {
+			break
+		}
+		c.expire(entry.key)
+		deleted++
+	}
+	return deleted
+}
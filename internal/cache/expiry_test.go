@@ -0,0 +1,251 @@
+package cache
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// requireIndexed checks that the expiration index holds exactly the items
+// of c that have an expiration, in heap order.
+func requireIndexed(t *testing.T, c *MemoryCache) {
+	t.Helper()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	volatile := 0
+	for key, item := range c.items {
+		if item.expireAt.IsZero() {
+			require(t, item.expiry == nil, "%q is indexed without an expiration", key)
+			continue
+		}
+		volatile++
+		require(t, item.expiry != nil && item.expiry.key == key, "%q is not indexed", key)
+		require(t, c.expiries[item.expiry.index] == item.expiry, "%q is indexed at the wrong place", key)
+	}
+	require(t, len(c.expiries) == volatile, "%d keys indexed, want %d", len(c.expiries), volatile)
+	for i := 1; i < len(c.expiries); i++ {
+		require(t, !c.expiries.Less(i, (i-1)/2), "index is not a heap at %d", i)
+	}
+}
+
+func TestExpiryIndex(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+	hour := time.Now().Add(time.Hour)
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"SetWithTTL", func() error { return c.SetWithTTL("a", "v", time.Hour) }},
+		{"SetWithOptions", func() error {
+			_, err := c.SetWithOptions("b", "v", SetOptions{ExpireAt: hour.Add(-time.Minute)})
+			return err
+		}},
+		{"SetWithOptions KeepTTL", func() error {
+			_, err := c.SetWithOptions("b", "w", SetOptions{KeepTTL: true})
+			return err
+		}},
+		{"Set replaces", func() error { return c.Set("a", "v") }},
+		{"PushBack", func() error { return c.PushBack("l", "x") }},
+		{"SetTTL", func() error { return c.SetTTL("l", time.Minute) }},
+		{"ExpireAt later", func() error { return c.ExpireAt("l", hour.Add(time.Hour)) }},
+		{"ExpireAt past", func() error { return c.ExpireAt("a", time.Now().Add(-time.Second)) }},
+		{"Copy", func() error { return c.Copy("l", "copy", false) }},
+		{"Rename", func() error { return c.Rename("copy", "b", true) }},
+		{"RemoveTTL", func() error { return c.RemoveTTL("l") }},
+		{"SetTTL zero", func() error { return c.SetTTL("b", 0) }},
+		{"SetTTL again", func() error { return c.SetTTL("b", time.Minute) }},
+		{"Remove", func() error { return c.Remove("b") }},
+		{"Restore", func() error {
+			return c.Restore([]Entry{{Key: "r", Type: StringType, Value: "v", ExpireAt: hour}})
+		}},
+		{"Clear", c.Clear},
+	}
+
+	for _, step := range steps {
+		requireNoError(t, step.run(), "%s failed", step.name)
+		requireIndexed(t, c)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+	const due = 3*expireSlice + 1
+	for i := range due {
+		requireNoError(t, c.SetWithTTL("due:"+strconv.Itoa(i), "v", time.Millisecond), "SetWithTTL() failed")
+	}
+	requireNoError(t, c.SetWithTTL("later", "v", time.Hour), "SetWithTTL() failed")
+	requireNoError(t, c.Set("persistent", "v"), "Set() failed")
+	time.Sleep(5 * time.Millisecond)
+
+	// Each slice deletes at most its limit.
+	require(t, c.expireDue(expireSlice) == expireSlice, "first slice did not delete %d keys", expireSlice)
+	c.mu.RLock()
+	remaining := len(c.items)
+	c.mu.RUnlock()
+	require(t, remaining == due-expireSlice+2, "%d keys left after the first slice, want %d", remaining, due-expireSlice+2)
+
+	c.cleanup()
+	keys := c.Keys("*")
+	slices.Sort(keys)
+	require(t, slices.Equal(keys, []string{"later", "persistent"}), "keys after cleanup = %v", keys)
+	requireIndexed(t, c)
+
+	// Nothing expires while the cache is loading.
+	requireNoError(t, c.SetWithTTL("loaded", "v", time.Millisecond), "SetWithTTL() failed")
+	c.BeginLoad()
+	time.Sleep(5 * time.Millisecond)
+	require(t, c.expireDue(expireSlice) == 0, "a key expired while loading")
+	c.EndLoad()
+	require(t, len(c.Keys("loaded")) == 0, "EndLoad() kept an expired key")
+}
+
+// fullScanCleanup is the active expiry the expiration index replaced: it
+// visits every key under one hold of the write lock.
+func fullScanCleanup(c *MemoryCache) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, item := range c.items {
+		if c.expired(item) {
+			c.expire(key)
+		}
+	}
+}
+
+// cleanupBenchmarks are the active expiry strategies compared by the
+// benchmarks.
+var cleanupBenchmarks = []struct {
+	name  string
+	sweep func(c *MemoryCache)
+}{
+	{"index", (*MemoryCache).cleanup},
+	{"fullscan", fullScanCleanup},
+}
+
+// holdTimer is a cache lock that records how long its write lock is held.
+type holdTimer struct {
+	sync.RWMutex
+	locked time.Time
+	holds  []time.Duration
+}
+
+func (l *holdTimer) Lock() {
+	l.RWMutex.Lock()
+	l.locked = time.Now()
+}
+
+func (l *holdTimer) Unlock() {
+	l.holds = append(l.holds, time.Since(l.locked))
+	l.RWMutex.Unlock()
+}
+
+// reportPercentiles reports the 99th and 99.9th percentiles and the maximum
+// of durations, with the given metric name prefix.
+func reportPercentiles(b *testing.B, durations []time.Duration, prefix string) {
+	slices.Sort(durations)
+	b.ReportMetric(float64(durations[len(durations)*99/100].Nanoseconds()), prefix+"p99-ns")
+	b.ReportMetric(float64(durations[len(durations)*999/1000].Nanoseconds()), prefix+"p99.9-ns")
+	b.ReportMetric(float64(durations[len(durations)-1].Nanoseconds()), prefix+"max-ns")
+}
+
+// BenchmarkCleanup_LockHold sweeps a large keyspace after a batch of keys
+// expired and reports how long each sweep holds the write lock at a time,
+// which is the longest any request arriving during the sweep waits for it.
+func BenchmarkCleanup_LockHold(b *testing.B) {
+	const (
+		keys     = 200_000
+		expiring = 10_000
+	)
+
+	for _, bc := range cleanupBenchmarks {
+		b.Run(bc.name, func(b *testing.B) {
+			lock := new(holdTimer)
+			c := NewMemoryCache(0)
+			c.mu = lock
+			for i := range keys {
+				_ = c.Set("key:"+strconv.Itoa(i), "value")
+			}
+
+			var holds []time.Duration
+			for range b.N {
+				b.StopTimer()
+				for i := range expiring {
+					_ = c.SetWithTTL("volatile:"+strconv.Itoa(i), "value", time.Nanosecond)
+				}
+				lock.holds = lock.holds[:0]
+				b.StartTimer()
+
+				bc.sweep(c)
+				holds = append(holds, lock.holds...)
+			}
+			reportPercentiles(b, holds, "hold-")
+		})
+	}
+}
+
+// BenchmarkCleanup_GetLatency measures the latency of concurrent reads
+// while active expiry runs every millisecond over a large keyspace, with a
+// batch of keys expiring before every sweep, and reports its percentiles.
+// A read waits for at most one slice of the sweep with the index, but for
+// the whole sweep with a full scan.
+func BenchmarkCleanup_GetLatency(b *testing.B) {
+	const (
+		keys     = 200_000
+		expiring = 1_000
+	)
+
+	for _, bc := range cleanupBenchmarks {
+		b.Run(bc.name, func(b *testing.B) {
+			c := NewMemoryCache(0)
+			for i := range keys {
+				_ = c.Set("key:"+strconv.Itoa(i), "value")
+			}
+
+			stop, stopped := make(chan struct{}), make(chan struct{})
+			go func() {
+				defer close(stopped)
+				ticker := time.NewTicker(time.Millisecond)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-stop:
+						return
+					case <-ticker.C:
+					}
+					for i := range expiring {
+						_ = c.SetWithTTL("volatile:"+strconv.Itoa(i), "value", time.Nanosecond)
+					}
+					bc.sweep(c)
+				}
+			}()
+
+			var mu sync.Mutex
+			var latencies []time.Duration
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				var local []time.Duration
+				for i := 0; pb.Next(); i++ {
+					start := time.Now()
+					c.Get("key:" + strconv.Itoa(i%keys))
+					local = append(local, time.Since(start))
+				}
+				mu.Lock()
+				latencies = append(latencies, local...)
+				mu.Unlock()
+			})
+			b.StopTimer()
+			close(stop)
+			<-stopped
+
+			reportPercentiles(b, latencies, "")
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"maps"
+	"math"
+	"strconv"
+)
+
+// HSet sets fields in the hash stored at key, creating the hash if needed.
+// It returns the number of fields that were added.
+func (c *MemoryCache) HSet(key string, fields map[string]string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(fields) == 0 {
+		if item := c.lookupItem(key); item != nil && item.dataType != HashType {
+			return 0, ErrTypeMismatch
+		}
+		return 0, nil
+	}
+	if err := c.reserve(); err != nil {
+		return 0, err
+	}
+
+	item, err := c.writableHash(key)
+	if err != nil {
+		return 0, err
+	}
+
+	hash := item.value.(map[string]string)
+	added := 0
+	for field, value := range fields {
+		if _, exists := hash[field]; !exists {
+			added++
+		}
+		c.setHashField(item, field, value)
+	}
+	c.notify(EventsHash, "hset", key)
+
+	return added, nil
+}
+
+// HGet returns the value of a field in the hash stored at key.
+func (c *MemoryCache) HGet(key string, field string) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	hash, err := c.readableHash(key)
+	if err != nil {
+		return "", err
+	}
+
+	value, found := hash[field]
+	if !found {
+		return "", ErrFieldNotFound
+	}
+
+	return value, nil
+}
+
+// HDel removes fields from the hash stored at key and returns the number of
+// fields that were removed. The key is deleted once the hash is empty.
+func (c *MemoryCache) HDel(key string, fields ...string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := c.lookupItem(key)
+	if item == nil {
+		return 0, ErrKeyNotFound
+	}
+	if item.dataType != HashType {
+		return 0, ErrTypeMismatch
+	}
+
+	hash := item.value.(map[string]string)
+	removed := 0
+	for _, field := range fields {
+		if value, exists := hash[field]; exists {
+			delete(hash, field)
+			c.resize(item, -hashFieldSize(field, value))
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		c.notify(EventsHash, "hdel", key)
+	}
+	if len(hash) == 0 {
+		c.deleteItem(key)
+		c.notify(EventsGeneric, "del", key)
+	}
+
+	return removed, nil
+}
+
+// HGetAll returns a copy of all fields and values of the hash stored at key.
+func (c *MemoryCache) HGetAll(key string) (map[string]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	hash, err := c.readableHash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return maps.Clone(hash), nil
+}
+
+// HIncrBy increments the integer value of a field in the hash stored at key.
+// A missing field is treated as zero.
+func (c *MemoryCache) HIncrBy(key string, field string, increment int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reserve(); err != nil {
+		return 0, err
+	}
+
+	item := c.lookupItem(key)
+	if item != nil && item.dataType != HashType {
+		return 0, ErrTypeMismatch
+	}
+
+	var current int64
+	if item != nil {
+		if value, exists := item.value.(map[string]string)[field]; exists {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return 0, ErrNotInteger
+			}
+			current = n
+		}
+	}
+
+	if (increment > 0 && current > math.MaxInt64-increment) ||
+		(increment < 0 && current < math.MinInt64-increment) {
+		return 0, ErrOverflow
+	}
+	current += increment
+
+	item, err := c.writableHash(key)
+	if err != nil {
+		return 0, err
+	}
+	c.setHashField(item, field, strconv.FormatInt(current, 10))
+	c.notify(EventsHash, "hincrby", key)
+
+	return current, nil
+}
+
+// readableHash returns the hash stored at key. The caller must hold at
+// least the read lock.
+func (c *MemoryCache) readableHash(key string) (map[string]string, error) {
+	item := c.peekItem(key)
+	if item == nil {
+		return nil, ErrKeyNotFound
+	}
+	if item.dataType != HashType {
+		return nil, ErrTypeMismatch
+	}
+
+	return item.value.(map[string]string), nil
+}
+
+// writableHash returns the item holding the hash stored at key, creating an
+// empty hash if the key is missing or expired. The caller must hold the
+// write lock.
+func (c *MemoryCache) writableHash(key string) (*cacheItem, error) {
+	item := c.lookupItem(key)
+	if item != nil && item.dataType != HashType {
+		return nil, ErrTypeMismatch
+	}
+
+	if item == nil {
+		item = &cacheItem{
+			dataType: HashType,
+			value:    make(map[string]string),
+		}
+		c.storeItem(key, item)
+	}
+
+	return item, nil
+}
+
+// setHashField sets a field of the hash held by item. The caller must hold
+// the write lock.
+func (c *MemoryCache) setHashField(item *cacheItem, field, value string) {
+	hash := item.value.(map[string]string)
+	if old, exists := hash[field]; exists {
+		c.resize(item, int64(len(value))-int64(len(old)))
+	} else {
+		c.resize(item, hashFieldSize(field, value))
+	}
+	hash[field] = value
+}
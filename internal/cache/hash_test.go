@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_Hash(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		setup     func(c *MemoryCache)
+		operation func(c *MemoryCache) (any, error)
+		want      any
+		wantErr   error
+	}{
+		{
+			name:  "HSet new hash",
+			setup: func(c *MemoryCache) {},
+			operation: func(c *MemoryCache) (any, error) {
+				return c.HSet("hash", map[string]string{"a": "1", "b": "2"})
+			},
+			want: 2,
+		},
+		{
+			name: "HSet existing fields",
+			setup: func(c *MemoryCache) {
+				_, err := c.HSet("hash", map[string]string{"a": "1"})
+				requireNoError(t, err, "Setup failed: %v", err)
+			},
+			operation: func(c *MemoryCache) (any, error) {
+				return c.HSet("hash", map[string]string{"a": "10", "b": "2"})
+			},
+			want: 1,
+		},
+		{
+			name: "HSet on string key",
+			setup: func(c *MemoryCache) {
+				err := c.Set("str", "value")
+				requireNoError(t, err, "Setup failed: %v", err)
+			},
+			operation: func(c *MemoryCache) (any, error) {
+				return c.HSet("str", map[string]string{"a": "1"})
+			},
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name: "HGet existing field",
+			setup: func(c *MemoryCache) {
+				_, err := c.HSet("hash", map[string]string{"a": "1"})
+				requireNoError(t, err, "Setup failed: %v", err)
+			},
+			operation: func(c *MemoryCache) (any, error) {
+				return c.HGet("hash", "a")
+			},
+			want: "1",
+		},
+		{
+			name: "HGet missing field",
+			setup: func(c *MemoryCache) {
+				_, err := c.HSet("hash", map[string]string{"a": "1"})
+				requireNoError(t, err, "Setup failed: %v", err)
+			},
+			operation: func(c *MemoryCache) (any, error) {
+				return c.HGet("hash", "b")
+			},
+			wantErr: ErrFieldNotFound,
+		},
+		{
+			name:  "HGet missing key",
+			setup: func(c *MemoryCache) {},
+			operation: func(c *MemoryCache) (any, error) {
+				return c.HGet("hash", "a")
+			},
+			wantErr: ErrKeyNotFound,
+		},
+		{
+			name: "HGet expired hash",
+			setup: func(c *MemoryCache) {
+				_, err := c.HSet("hash", map[string]string{"a": "1"})
+				requireNoError(t, err, "Setup failed: %v", err)
+				err = c.SetTTL("hash", time.Millisecond)
+				requireNoError(t, err, "Setup failed: %v", err)
+				time.Sleep(10 * time.Millisecond)
+			},
+			operation: func(c *MemoryCache) (any, error) {
+				return c.HGet("hash", "a")
+			},
+			wantErr: ErrKeyNotFound,
+		},
+		{
+			name: "HDel removes fields and empty hash",
+			setup: func(c *MemoryCache) {
+				_, err := c.HSet("hash", map[string]string{"a": "1", "b": "2"})
+				requireNoError(t, err, "Setup failed: %v", err)
+			},
+			operation: func(c *MemoryCache) (any, error) {
+				removed, err := c.HDel("hash", "a", "b", "c")
+				if err != nil {
+					return nil, err
+				}
+				return []any{removed, c.Exists("hash")}, nil
+			},
+			want: []any{2, false},
+		},
+		{
+			name: "HGetAll returns a copy",
+			setup: func(c *MemoryCache) {
+				_, err := c.HSet("hash", map[string]string{"a": "1"})
+				requireNoError(t, err, "Setup failed: %v", err)
+			},
+			operation: func(c *MemoryCache) (any, error) {
+				all, err := c.HGetAll("hash")
+				if err != nil {
+					return nil, err
+				}
+				all["a"] = "changed"
+				return c.HGet("hash", "a")
+			},
+			want: "1",
+		},
+		{
+			name: "HIncrBy keeps TTL",
+			setup: func(c *MemoryCache) {
+				_, err := c.HSet("hash", map[string]string{"n": "5"})
+				requireNoError(t, err, "Setup failed: %v", err)
+				err = c.SetTTL("hash", time.Minute)
+				requireNoError(t, err, "Setup failed: %v", err)
+			},
+			operation: func(c *MemoryCache) (any, error) {
+				n, err := c.HIncrBy("hash", "n", -7)
+				if err != nil {
+					return nil, err
+				}
+				ttl, _ := c.GetTTL("hash")
+				return []any{n, ttl > 0}, nil
+			},
+			want: []any{int64(-2), true},
+		},
+		{
+			name:  "HIncrBy creates hash",
+			setup: func(c *MemoryCache) {},
+			operation: func(c *MemoryCache) (any, error) {
+				return c.HIncrBy("hash", "n", 3)
+			},
+			want: int64(3),
+		},
+		{
+			name: "HIncrBy non-integer field",
+			setup: func(c *MemoryCache) {
+				_, err := c.HSet("hash", map[string]string{"n": "abc"})
+				requireNoError(t, err, "Setup failed: %v", err)
+			},
+			operation: func(c *MemoryCache) (any, error) {
+				return c.HIncrBy("hash", "n", 1)
+			},
+			wantErr: ErrNotInteger,
+		},
+		{
+			name: "HIncrBy overflow",
+			setup: func(c *MemoryCache) {
+				_, err := c.HIncrBy("hash", "n", math.MaxInt64)
+				requireNoError(t, err, "Setup failed: %v", err)
+			},
+			operation: func(c *MemoryCache) (any, error) {
+				return c.HIncrBy("hash", "n", 1)
+			},
+			wantErr: ErrOverflow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMemoryCache(100 * time.Millisecond)
+			defer c.Stop()
+
+			tt.setup(c)
+
+			got, err := tt.operation(c)
+			require(t, errors.Is(err, tt.wantErr), "error = %v, want %v", err, tt.wantErr)
+			if tt.wantErr == nil {
+				require(t, equal(got, tt.want), "got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// equal compares test results, descending into slices of results.
+func equal(got, want any) bool {
+	if gotStrings, ok := got.([]string); ok {
+		wantStrings, ok := want.([]string)
+		return ok && slices.Equal(gotStrings, wantStrings)
+	}
+	if gotInts, ok := got.([]int); ok {
+		wantInts, ok := want.([]int)
+		return ok && slices.Equal(gotInts, wantInts)
+	}
+
+	gotSlice, ok := got.([]any)
+	if !ok {
+		return got == want
+	}
+	wantSlice, ok := want.([]any)
+	if !ok || len(gotSlice) != len(wantSlice) {
+		return false
+	}
+	for i := range gotSlice {
+		if !equal(gotSlice[i], wantSlice[i]) {
+			return false
+		}
+	}
+	return true
+}
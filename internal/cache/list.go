@@ -0,0 +1,448 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+)
+
+// List errors.
+var (
+	// ErrIndexOutOfRange is returned for an index past either end of a list.
+	ErrIndexOutOfRange = errors.New("index out of range")
+	// ErrPivotNotFound is returned by ListInsert if the list does not hold
+	// the pivot element.
+	ErrPivotNotFound = errors.New("pivot not found")
+)
+
+// ListPosOptions controls which matches ListPos returns.
+type ListPosOptions struct {
+	// Rank selects the first match to return: 1 for the first, 2 for the
+	// second and so on. A negative rank searches from the back of the list,
+	// -1 being the last match. Zero means 1.
+	Rank int
+	// Count limits the number of returned positions; zero returns every
+	// match.
+	Count int
+	// MaxLen limits the number of elements compared, from the end the
+	// search starts at; zero compares every element.
+	MaxLen int
+}
+
+// ListLen returns the number of elements of the list stored at key. A
+// missing key counts as an empty list.
+func (c *MemoryCache) ListLen(key string) (int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	l, err := c.readableList(key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return l.Len(), nil
+}
+
+// ListIndex returns the element at index in the list stored at key.
+// Negative indexes count from the back of the list, as in ListRange, -1
+// being the last element.
+func (c *MemoryCache) ListIndex(key string, index int) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	l, err := c.readableList(key)
+	if err != nil {
+		return "", err
+	}
+
+	e := listElement(l, index)
+	if e == nil {
+		return "", ErrIndexOutOfRange
+	}
+	return e.Value.(string), nil
+}
+
+// ListSet replaces the element at index in the list stored at key, see
+// ListIndex.
+func (c *MemoryCache) ListSet(key string, index int, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reserve(); err != nil {
+		return err
+	}
+
+	item, err := c.writableList(key)
+	if err != nil {
+		return err
+	}
+
+	e := listElement(item.value.(*list.List), index)
+	if e == nil {
+		return ErrIndexOutOfRange
+	}
+	c.resize(item, stringSize(value)-stringSize(e.Value.(string)))
+	e.Value = value
+	c.notify(EventsList, "lset", key)
+	return nil
+}
+
+// ListPush adds values one after the other to the end of the list stored
+// at key, creating the list if the key does not exist, and returns the new
+// length of the list. The values are pushed atomically: if the cache is out
+// of memory or key holds another type, none of them is.
+func (c *MemoryCache) ListPush(key string, end ListEnd, values ...string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reserve(); err != nil {
+		return 0, err
+	}
+
+	item, err := c.writableList(key)
+	if errors.Is(err, ErrKeyNotFound) {
+		if len(values) == 0 {
+			return 0, nil
+		}
+		item = &cacheItem{
+			dataType: ListType,
+			value:    list.New(),
+		}
+		c.storeItem(key, item)
+	} else if err != nil {
+		return 0, err
+	}
+
+	l := item.value.(*list.List)
+	var size int64
+	for _, value := range values {
+		if end == ListBack {
+			l.PushBack(value)
+		} else {
+			l.PushFront(value)
+		}
+		size += listElementSize(value)
+	}
+	c.resize(item, size)
+
+	event := "lpush"
+	if end == ListBack {
+		event = "rpush"
+	}
+	c.notify(EventsList, event, key)
+	// Every pushed element may serve a waiter.
+	for range values {
+		c.wakeWaiter(key)
+	}
+	return l.Len(), nil
+}
+
+// ListInsert inserts value before or after the first occurrence of pivot
+// in the list stored at key and returns the new length of the list. It
+// fails with ErrPivotNotFound if the list does not hold pivot.
+func (c *MemoryCache) ListInsert(key string, before bool, pivot, value string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reserve(); err != nil {
+		return 0, err
+	}
+
+	item, err := c.writableList(key)
+	if err != nil {
+		return 0, err
+	}
+
+	l := item.value.(*list.List)
+	for e := l.Front(); e != nil; e = e.Next() {
+		if e.Value.(string) != pivot {
+			continue
+		}
+		if before {
+			l.InsertBefore(value, e)
+		} else {
+			l.InsertAfter(value, e)
+		}
+		c.resize(item, listElementSize(value))
+		c.notify(EventsList, "linsert", key)
+		return l.Len(), nil
+	}
+	return 0, ErrPivotNotFound
+}
+
+// ListRemove removes the first count occurrences of value from the list
+// stored at key and returns the number of removed elements. A negative
+// count removes the last occurrences instead, and zero removes them all. A
+// list left empty is deleted.
+func (c *MemoryCache) ListRemove(key string, count int, value string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, err := c.writableList(key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	l := item.value.(*list.List)
+	first, next := l.Front, (*list.Element).Next
+	if count < 0 {
+		first, next, count = l.Back, (*list.Element).Prev, -count
+	}
+
+	removed := 0
+	for e := first(); e != nil && (count == 0 || removed < count); {
+		current := e
+		e = next(e)
+		if current.Value.(string) == value {
+			l.Remove(current)
+			c.resize(item, -listElementSize(value))
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		c.notify(EventsList, "lrem", key)
+		c.deleteEmptyList(key, l)
+	}
+	return removed, nil
+}
+
+// ListTrim keeps only the elements of the list stored at key between start
+// and end, which are interpreted as in ListRange. A list left empty is
+// deleted.
+func (c *MemoryCache) ListTrim(key string, start, end int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, err := c.writableList(key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	l := item.value.(*list.List)
+	start, end, ok := listBounds(l.Len(), start, end)
+	if !ok {
+		start, end = l.Len(), l.Len()-1
+	}
+	for range start {
+		c.resize(item, -listElementSize(l.Remove(l.Front()).(string)))
+	}
+	for range l.Len() - (end - start + 1) {
+		c.resize(item, -listElementSize(l.Remove(l.Back()).(string)))
+	}
+
+	c.notify(EventsList, "ltrim", key)
+	c.deleteEmptyList(key, l)
+	return nil
+}
+
+// ListPos returns the indexes of the elements equal to value in the list
+// stored at key, in search order, as selected by opts. It returns an empty
+// slice if there are none or the key is missing, and fails with
+// ErrInvalidOptions if opts.Count or opts.MaxLen is negative.
+func (c *MemoryCache) ListPos(key string, value string, opts ListPosOptions) ([]int, error) {
+	if opts.Count < 0 || opts.MaxLen < 0 {
+		return nil, ErrInvalidOptions
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	l, err := c.readableList(key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return []int{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rank := max(opts.Rank, 1)
+	e, next, index, step := l.Front(), (*list.Element).Next, 0, 1
+	if opts.Rank < 0 {
+		rank = -opts.Rank
+		e, next, index, step = l.Back(), (*list.Element).Prev, l.Len()-1, -1
+	}
+
+	positions := []int{}
+	for compared := 0; e != nil && (opts.MaxLen == 0 || compared < opts.MaxLen); compared++ {
+		if e.Value.(string) == value {
+			if rank > 1 {
+				rank--
+			} else {
+				positions = append(positions, index)
+				if len(positions) == opts.Count {
+					break
+				}
+			}
+		}
+		e, index = next(e), index+step
+	}
+	return positions, nil
+}
+
+// ListMove atomically removes an element from the from end of the list at
+// source and pushes it to the to end of the list at destination, like LMOVE
+// in Redis, and returns it. source and destination may be the same list,
+// which rotates it. It returns false if source is missing or empty, and
+// fails with ErrTypeMismatch, without moving anything, if source or, when
+// it has an element to move, destination holds another type. A destination
+// that expired is replaced by a new list, and a source left empty is
+// deleted.
+func (c *MemoryCache) ListMove(source, destination string, from, to ListEnd) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reserve(); err != nil {
+		return "", false, err
+	}
+	return moveListElement(c, c, source, destination, from, to)
+}
+
+// ListMove atomically moves an element between lists, see
+// MemoryCache.ListMove, with the shards holding them locked.
+func (s *ShardedCache) ListMove(source, destination string, from, to ListEnd) (string, bool, error) {
+	keys := []string{source, destination}
+	unlock := s.lockShards(keys, true)
+	defer unlock()
+
+	if err := s.reserve(keys); err != nil {
+		return "", false, err
+	}
+	return moveListElement(s.shard(source), s.shard(destination), source, destination, from, to)
+}
+
+// moveListElement moves an element from the list at source in src to the
+// list at destination in dst, see MemoryCache.ListMove. The caller must
+// hold the write locks of both.
+func moveListElement(src, dst *MemoryCache, source, destination string, from, to ListEnd) (string, bool, error) {
+	sourceItem, err := src.writableList(source)
+	if errors.Is(err, ErrKeyNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	l := sourceItem.value.(*list.List)
+	if l.Len() == 0 {
+		return "", false, nil
+	}
+
+	destinationItem := dst.lookupItem(destination)
+	if destinationItem != nil && destinationItem.dataType != ListType {
+		return "", false, ErrTypeMismatch
+	}
+
+	e, event := l.Front(), "lpop"
+	if from == ListBack {
+		e, event = l.Back(), "rpop"
+	}
+	value := l.Remove(e).(string)
+	src.resize(sourceItem, -listElementSize(value))
+	src.notify(EventsList, event, source)
+
+	if destinationItem == nil {
+		destinationItem = &cacheItem{
+			dataType: ListType,
+			value:    list.New(),
+		}
+		dst.storeItem(destination, destinationItem)
+	}
+	if to == ListBack {
+		destinationItem.value.(*list.List).PushBack(value)
+		event = "rpush"
+	} else {
+		destinationItem.value.(*list.List).PushFront(value)
+		event = "lpush"
+	}
+	dst.resize(destinationItem, listElementSize(value))
+	dst.pushed(destination, event)
+
+	// After the push, so that rotating a single element list keeps it.
+	src.deleteEmptyList(source, l)
+	return value, true, nil
+}
+
+// listBounds resolves the start and end indexes of a range of a list of
+// the given length, as ListRange does, and reports whether the range holds
+// any element.
+func listBounds(length, start, end int) (int, int, bool) {
+	if start < 0 {
+		start = length + start
+	}
+	if end < 0 {
+		end = length + end
+	}
+	start = max(start, 0)
+	end = min(end, length-1)
+	return start, end, start <= end && start < length
+}
+
+// listElement returns the element at index in l, counting from the back if
+// index is negative, or nil if it is out of range.
+func listElement(l *list.List, index int) *list.Element {
+	if index < 0 {
+		index += l.Len()
+	}
+	if index < 0 || index >= l.Len() {
+		return nil
+	}
+
+	// Walk from the nearer end.
+	if index < l.Len()/2 {
+		e := l.Front()
+		for range index {
+			e = e.Next()
+		}
+		return e
+	}
+	e := l.Back()
+	for range l.Len() - 1 - index {
+		e = e.Prev()
+	}
+	return e
+}
+
+// readableList returns the list stored at key. The caller must hold at
+// least the read lock.
+func (c *MemoryCache) readableList(key string) (*list.List, error) {
+	item := c.peekItem(key)
+	if item == nil {
+		return nil, ErrKeyNotFound
+	}
+	if item.dataType != ListType {
+		return nil, ErrTypeMismatch
+	}
+
+	return item.value.(*list.List), nil
+}
+
+// writableList returns the item holding the list stored at key. The caller
+// must hold the write lock.
+func (c *MemoryCache) writableList(key string) (*cacheItem, error) {
+	item := c.lookupItem(key)
+	if item == nil {
+		return nil, ErrKeyNotFound
+	}
+	if item.dataType != ListType {
+		return nil, ErrTypeMismatch
+	}
+
+	return item, nil
+}
+
+// deleteEmptyList deletes the list l stored at key if it has no elements
+// left. The caller must hold the write lock.
+func (c *MemoryCache) deleteEmptyList(key string, l *list.List) {
+	if l.Len() == 0 {
+		c.deleteItem(key)
+		c.notify(EventsGeneric, "del", key)
+	}
+}
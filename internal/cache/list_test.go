@@ -0,0 +1,353 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	// Every case starts from the list [a b c a b c a] at "list" and the
+	// string "str".
+	tests := []struct {
+		name      string
+		operation func(c Cache) (any, error)
+		want      any
+		wantErr   error
+		// wantList is the list left at "list", if not nil.
+		wantList []string
+	}{
+		{
+			name:      "ListLen",
+			operation: func(c Cache) (any, error) { return c.ListLen("list") },
+			want:      7,
+		},
+		{
+			name:      "ListLen missing key",
+			operation: func(c Cache) (any, error) { return c.ListLen("missing") },
+			want:      0,
+		},
+		{
+			name:      "ListLen wrong type",
+			operation: func(c Cache) (any, error) { return c.ListLen("str") },
+			wantErr:   ErrTypeMismatch,
+		},
+		{
+			name:      "ListPush front",
+			operation: func(c Cache) (any, error) { return c.ListPush("list", ListFront, "x", "y") },
+			want:      9,
+			wantList:  []string{"y", "x", "a", "b", "c", "a", "b", "c", "a"},
+		},
+		{
+			name:      "ListPush back",
+			operation: func(c Cache) (any, error) { return c.ListPush("list", ListBack, "x", "y") },
+			want:      9,
+			wantList:  []string{"a", "b", "c", "a", "b", "c", "a", "x", "y"},
+		},
+		{
+			name:      "ListPush missing key",
+			operation: func(c Cache) (any, error) { return c.ListPush("missing", ListBack, "x", "y") },
+			want:      2,
+		},
+		{
+			name:      "ListPush wrong type",
+			operation: func(c Cache) (any, error) { return c.ListPush("str", ListBack, "x") },
+			wantErr:   ErrTypeMismatch,
+		},
+		{
+			name:      "ListIndex",
+			operation: func(c Cache) (any, error) { return c.ListIndex("list", 2) },
+			want:      "c",
+		},
+		{
+			name:      "ListIndex negative",
+			operation: func(c Cache) (any, error) { return c.ListIndex("list", -2) },
+			want:      "c",
+		},
+		{
+			name:      "ListIndex out of range",
+			operation: func(c Cache) (any, error) { return c.ListIndex("list", -8) },
+			wantErr:   ErrIndexOutOfRange,
+		},
+		{
+			name:      "ListIndex missing key",
+			operation: func(c Cache) (any, error) { return c.ListIndex("missing", 0) },
+			wantErr:   ErrKeyNotFound,
+		},
+		{
+			name:      "ListSet",
+			operation: func(c Cache) (any, error) { return nil, c.ListSet("list", -1, "z") },
+			wantList:  []string{"a", "b", "c", "a", "b", "c", "z"},
+		},
+		{
+			name:      "ListSet out of range",
+			operation: func(c Cache) (any, error) { return nil, c.ListSet("list", 7, "z") },
+			wantErr:   ErrIndexOutOfRange,
+		},
+		{
+			name:      "ListSet missing key",
+			operation: func(c Cache) (any, error) { return nil, c.ListSet("missing", 0, "z") },
+			wantErr:   ErrKeyNotFound,
+		},
+		{
+			name:      "ListInsert before",
+			operation: func(c Cache) (any, error) { return c.ListInsert("list", true, "c", "x") },
+			want:      8,
+			wantList:  []string{"a", "b", "x", "c", "a", "b", "c", "a"},
+		},
+		{
+			name:      "ListInsert after",
+			operation: func(c Cache) (any, error) { return c.ListInsert("list", false, "c", "x") },
+			want:      8,
+			wantList:  []string{"a", "b", "c", "x", "a", "b", "c", "a"},
+		},
+		{
+			name:      "ListInsert missing pivot",
+			operation: func(c Cache) (any, error) { return c.ListInsert("list", true, "z", "x") },
+			wantErr:   ErrPivotNotFound,
+		},
+		{
+			name:      "ListInsert wrong type",
+			operation: func(c Cache) (any, error) { return c.ListInsert("str", true, "a", "x") },
+			wantErr:   ErrTypeMismatch,
+		},
+		{
+			name:      "ListRemove from front",
+			operation: func(c Cache) (any, error) { return c.ListRemove("list", 2, "a") },
+			want:      2,
+			wantList:  []string{"b", "c", "b", "c", "a"},
+		},
+		{
+			name:      "ListRemove from back",
+			operation: func(c Cache) (any, error) { return c.ListRemove("list", -2, "a") },
+			want:      2,
+			wantList:  []string{"a", "b", "c", "b", "c"},
+		},
+		{
+			name:      "ListRemove all",
+			operation: func(c Cache) (any, error) { return c.ListRemove("list", 0, "b") },
+			want:      2,
+			wantList:  []string{"a", "c", "a", "c", "a"},
+		},
+		{
+			name:      "ListRemove missing key",
+			operation: func(c Cache) (any, error) { return c.ListRemove("missing", 0, "a") },
+			want:      0,
+		},
+		{
+			name:      "ListTrim",
+			operation: func(c Cache) (any, error) { return nil, c.ListTrim("list", 1, -3) },
+			wantList:  []string{"b", "c", "a", "b"},
+		},
+		{
+			name:      "ListTrim out of range end",
+			operation: func(c Cache) (any, error) { return nil, c.ListTrim("list", -2, 100) },
+			wantList:  []string{"c", "a"},
+		},
+		{
+			name:      "ListTrim missing key",
+			operation: func(c Cache) (any, error) { return nil, c.ListTrim("missing", 0, 1) },
+		},
+		{
+			name:      "ListPos every match",
+			operation: func(c Cache) (any, error) { return c.ListPos("list", "a", ListPosOptions{}) },
+			want:      []int{0, 3, 6},
+		},
+		{
+			name:      "ListPos rank and count",
+			operation: func(c Cache) (any, error) { return c.ListPos("list", "a", ListPosOptions{Rank: 2, Count: 1}) },
+			want:      []int{3},
+		},
+		{
+			name:      "ListPos negative rank",
+			operation: func(c Cache) (any, error) { return c.ListPos("list", "b", ListPosOptions{Rank: -1}) },
+			want:      []int{4, 1},
+		},
+		{
+			name:      "ListPos max len",
+			operation: func(c Cache) (any, error) { return c.ListPos("list", "a", ListPosOptions{MaxLen: 4}) },
+			want:      []int{0, 3},
+		},
+		{
+			name:      "ListPos no match",
+			operation: func(c Cache) (any, error) { return c.ListPos("list", "z", ListPosOptions{}) },
+			want:      []int{},
+		},
+		{
+			name:      "ListPos negative count",
+			operation: func(c Cache) (any, error) { return c.ListPos("list", "a", ListPosOptions{Count: -1}) },
+			wantErr:   ErrInvalidOptions,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, c := range []Cache{NewMemoryCache(0), NewShardedCache(4, 0)} {
+				for _, v := range []string{"a", "b", "c", "a", "b", "c", "a"} {
+					requireNoError(t, c.PushBack("list", v), "PushBack() failed")
+				}
+				requireNoError(t, c.Set("str", "v"), "Set() failed")
+
+				got, err := tt.operation(c)
+				require(t, errors.Is(err, tt.wantErr), "error = %v, want %v", err, tt.wantErr)
+				if tt.wantErr == nil {
+					require(t, equal(got, tt.want), "got %v, want %v", got, tt.want)
+				}
+				if tt.wantList != nil {
+					list, err := c.ListRange("list", 0, -1)
+					requireNoError(t, err, "ListRange() failed: %v", err)
+					require(t, slices.Equal(list, tt.wantList), "list = %v, want %v", list, tt.wantList)
+				}
+			}
+		})
+	}
+}
+
+func TestListPush_OutOfMemory(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0, WithMaxMemory(1, NoEviction))
+	requireNoError(t, c.Set("a", "v"), "Set() failed")
+
+	_, err := c.ListPush("list", ListBack, "x", "y", "z")
+	require(t, errors.Is(err, ErrOutOfMemory), "ListPush() error = %v, want ErrOutOfMemory", err)
+	require(t, !c.Exists("list"), "ListPush() pushed values over the memory limit")
+}
+
+func TestList_EmptiedListIsDeleted(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+	keys := []string{"removed", "trimmed", "popped front", "popped back", "blocking popped"}
+	for _, key := range keys {
+		for _, v := range []string{"a", "a"} {
+			requireNoError(t, c.PushBack(key, v), "PushBack() failed")
+		}
+	}
+
+	removed, err := c.ListRemove("removed", 0, "a")
+	requireNoError(t, err, "ListRemove() failed: %v", err)
+	require(t, removed == 2, "ListRemove() = %d, want 2", removed)
+	requireNoError(t, c.ListTrim("trimmed", 2, -1), "ListTrim() failed")
+	for range 2 {
+		c.PopFront("popped front")
+		c.PopBack("popped back")
+		_, _, err = BPop(context.Background(), c, []string{"blocking popped"}, ListFront, time.Second)
+		requireNoError(t, err, "BPop() failed: %v", err)
+	}
+
+	for _, key := range keys {
+		require(t, !c.Exists(key), "%q still exists after it was emptied", key)
+	}
+	require(t, c.MemoryStats().UsedMemory == 0, "UsedMemory = %d after all keys were deleted", c.MemoryStats().UsedMemory)
+}
+
+func TestListMove(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []Cache{NewMemoryCache(0), NewShardedCache(4, 0)} {
+		for _, v := range []string{"a", "b", "c"} {
+			requireNoError(t, c.PushBack("src", v), "PushBack() failed")
+		}
+		requireNoError(t, c.Set("str", "v"), "Set() failed")
+		requireNoError(t, c.PushBack("old", "x"), "PushBack() failed")
+		requireNoError(t, c.SetTTL("old", time.Millisecond), "SetTTL() failed")
+		time.Sleep(5 * time.Millisecond)
+
+		requireList := func(key string, want ...string) {
+			t.Helper()
+			got, _ := c.ListRange(key, 0, -1)
+			require(t, slices.Equal(got, want), "%s = %v, want %v", key, got, want)
+		}
+
+		value, moved, err := c.ListMove("src", "dst", ListFront, ListBack)
+		requireNoError(t, err, "ListMove() failed: %v", err)
+		require(t, moved && value == "a", "ListMove() = %q, %v, want a, true", value, moved)
+		requireList("src", "b", "c")
+		requireList("dst", "a")
+
+		// Moving within one list rotates it.
+		value, _, err = c.ListMove("src", "src", ListBack, ListFront)
+		requireNoError(t, err, "ListMove() failed: %v", err)
+		require(t, value == "c", "ListMove() = %q, want c", value)
+		requireList("src", "c", "b")
+
+		// An expired destination is replaced, not appended to.
+		_, _, err = c.ListMove("src", "old", ListFront, ListFront)
+		requireNoError(t, err, "ListMove() failed: %v", err)
+		requireList("old", "c")
+		ttl, _ := c.GetTTL("old")
+		require(t, ttl == -1, "replaced destination has a TTL of %v, want none", ttl)
+
+		// A destination of another type leaves the source untouched.
+		_, _, err = c.ListMove("src", "str", ListFront, ListBack)
+		require(t, errors.Is(err, ErrTypeMismatch), "ListMove() error = %v, want ErrTypeMismatch", err)
+		requireList("src", "b")
+
+		_, _, err = c.ListMove("str", "dst", ListFront, ListBack)
+		require(t, errors.Is(err, ErrTypeMismatch), "ListMove() error = %v, want ErrTypeMismatch", err)
+
+		_, moved, err = c.ListMove("missing", "dst", ListFront, ListBack)
+		require(t, err == nil && !moved, "ListMove(missing) = %v, %v, want false, nil", moved, err)
+
+		// A single element list rotates onto itself, then the source is
+		// deleted once it is emptied.
+		_, _, err = c.ListMove("src", "src", ListFront, ListBack)
+		requireNoError(t, err, "ListMove() failed: %v", err)
+		requireList("src", "b")
+		_, _, err = c.ListMove("src", "dst", ListFront, ListBack)
+		requireNoError(t, err, "ListMove() failed: %v", err)
+		require(t, !c.Exists("src"), "emptied source still exists")
+		requireList("dst", "a", "b")
+	}
+}
+
+func TestListMove_Atomic(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []Cache{NewMemoryCache(0), NewShardedCache(4, 0)} {
+		const n = 1000
+		for i := range n {
+			requireNoError(t, c.PushBack("pending", fmt.Sprint(i)), "PushBack() failed")
+		}
+
+		// Readers never see an element in neither list, nor in both.
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for moving := true; moving; {
+				// The lists are read together to see one state of both.
+				var pending, processing int
+				c.(Transactor).Atomically(func(s Store) {
+					pending, _ = s.ListLen("pending")
+					processing, _ = s.ListLen("processing")
+				})
+				require(t, pending+processing == n, "%d pending and %d processing, want %d together", pending, processing, n)
+				moving = processing < n
+			}
+		}()
+
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					if _, moved, err := c.ListMove("pending", "processing", ListFront, ListBack); err != nil || !moved {
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		<-done
+	}
+}
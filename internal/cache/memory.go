@@ -2,15 +2,24 @@ package cache
 
 import (
 	"container/list"
+	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/dsha256/gredis/internal/pubsub"
 )
 
 // Common errors
 var (
-	ErrKeyNotFound  = errors.New("key not found")
-	ErrTypeMismatch = errors.New("type mismatch")
+	ErrKeyNotFound   = errors.New("key not found")
+	ErrKeyExists     = errors.New("key already exists")
+	ErrTypeMismatch  = errors.New("type mismatch")
+	ErrFieldNotFound = errors.New("field not found")
+	ErrNotInteger    = errors.New("value is not an integer")
+	ErrOverflow      = errors.New("increment or decrement would overflow")
+	ErrNotFloat      = errors.New("value is not a valid float")
 )
 
 // cacheItem represents a value stored in the cache
@@ -18,6 +27,17 @@ type cacheItem struct {
 	dataType DataType
 	value    any
 	expireAt time.Time // Zero time means no expiration
+	// expiry is the place of the item in the expiration index, nil if it
+	// does not expire.
+	expiry *expiryEntry
+	// size is the approximate number of bytes used by the item.
+	size int64
+	// version identifies the last write to the item, see Version.
+	version uint64
+	// lastAccess (in Unix nanoseconds) and freq track accesses for the
+	// eviction policies.
+	lastAccess atomic.Int64
+	freq       atomic.Uint32
 }
 
 // isExpired checks if the item has expired
@@ -25,21 +45,76 @@ func (i *cacheItem) isExpired() bool {
 	return !i.expireAt.IsZero() && time.Now().After(i.expireAt)
 }
 
+// expired reports whether item should be treated as missing. Nothing
+// expires while the cache is loading. The caller must hold the lock.
+func (c *MemoryCache) expired(item *cacheItem) bool {
+	return !c.loading && item.isExpired()
+}
+
 // MemoryCache implements the Cache interface with in-memory storage
 type MemoryCache struct {
-	mu    sync.RWMutex
+	// mu guards the keyspace. It is a no-op in the view a transaction runs
+	// against, since the transaction already holds the lock.
+	mu rwLocker
+	*keyspace
+}
+
+// keyspace is the state of a MemoryCache, shared with the views of its
+// transactions.
+type keyspace struct {
 	items map[string]*cacheItem
+	// scan indexes the keys of items for Scan.
+	scan *scanIndex
+	// loading suspends expiration while the cache is rebuilt, see BeginLoad.
+	loading bool
+	// broker delivers published messages; it has its own locking.
+	broker *pubsub.Broker
+	// events are the classes of keyspace notifications published on broker,
+	// see WithNotifications.
+	events EventClass
+	// waiters are the callers waiting for a push to each list, in the
+	// order they started waiting, see WaitPush.
+	waiters map[string][]*pushWaiter
+	// version is the last version given to a written item or a removed
+	// key. It only grows, so that a key removed and written again gets a
+	// new version.
+	version uint64
+	// tombstones give versions to missing keys, see Version.
+	tombstones *tombstones
+	// Memory accounting and eviction, see WithMaxMemory.
+	used      int64
+	maxMemory int64
+	policy    EvictionPolicy
+	evicted   int64
+	rejected  int64
+	onEvict   func(key string)
+	// expiries indexes the items that have an expiration for active
+	// expiry, see cleanup.
+	expiries expiryIndex
 	// For TTL cleanup
 	cleanupInterval time.Duration
 	stopCleanup     chan struct{}
 }
 
+// Option configures optional MemoryCache settings
+type Option func(*MemoryCache)
+
 // NewMemoryCache creates a new in-memory cache
-func NewMemoryCache(cleanupInterval time.Duration) *MemoryCache {
+func NewMemoryCache(cleanupInterval time.Duration, opts ...Option) *MemoryCache {
 	cache := &MemoryCache{
-		items:           make(map[string]*cacheItem),
-		cleanupInterval: cleanupInterval,
-		stopCleanup:     make(chan struct{}),
+		mu: new(sync.RWMutex),
+		keyspace: &keyspace{
+			items:           make(map[string]*cacheItem),
+			scan:            newScanIndex(),
+			tombstones:      newTombstones(),
+			broker:          pubsub.NewBroker(),
+			policy:          NoEviction,
+			cleanupInterval: cleanupInterval,
+			stopCleanup:     make(chan struct{}),
+		},
+	}
+	for _, opt := range opts {
+		opt(cache)
 	}
 
 	// Start cleanup goroutine if interval is positive
@@ -65,17 +140,24 @@ func (c *MemoryCache) startCleanup() {
 	}
 }
 
-// cleanup removes expired items
-func (c *MemoryCache) cleanup() {
+// BeginLoad suspends key expiration until EndLoad is called. Replaying
+// recorded writes in this mode reproduces them faithfully even if some of
+// the keys they touched have expired since they were recorded.
+func (c *MemoryCache) BeginLoad() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	now := time.Now()
-	for key, item := range c.items {
-		if !item.expireAt.IsZero() && now.After(item.expireAt) {
-			delete(c.items, key)
-		}
-	}
+	c.loading = true
+}
+
+// EndLoad resumes key expiration and removes the keys that expired while
+// the cache was loading.
+func (c *MemoryCache) EndLoad() {
+	c.mu.Lock()
+	c.loading = false
+	c.mu.Unlock()
+
+	c.cleanup()
 }
 
 // Stop stops the cleanup goroutine
@@ -85,20 +167,58 @@ func (c *MemoryCache) Stop() {
 	}
 }
 
+// peekItem returns the live item stored under key, or nil if it is missing
+// or expired. It never modifies the cache, so the caller may hold either the
+// read or the write lock.
+func (c *MemoryCache) peekItem(key string) *cacheItem {
+	item, found := c.items[key]
+	if !found || c.expired(item) {
+		return nil
+	}
+	c.touch(item)
+	return item
+}
+
+// lookupItem returns the live item stored under key, or nil if it is
+// missing. An expired item is deleted. The caller must hold the write lock.
+func (c *MemoryCache) lookupItem(key string) *cacheItem {
+	item, found := c.items[key]
+	if !found {
+		return nil
+	}
+	if c.expired(item) {
+		c.expire(key)
+		return nil
+	}
+	c.touch(item)
+	return item
+}
+
+// expireStale deletes item, found expired at key by a caller holding the
+// read lock, releasing the read lock for the write lock in the meantime.
+// The key may be written in between, so item is only deleted if it is
+// still the one stored at key and still expired.
+func (c *MemoryCache) expireStale(key string, item *cacheItem) {
+	c.mu.RUnlock()
+	defer c.mu.RLock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.items[key] == item && c.expired(item) {
+		c.expire(key)
+	}
+}
+
 // Get retrieves a string value from the cache
 func (c *MemoryCache) Get(key string) (string, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	item, found := c.items[key]
-	if !found || item.isExpired() {
-		if found && item.isExpired() {
-			// Cleanup expired item..
-			c.mu.RUnlock()
-			c.mu.Lock()
-			delete(c.items, key)
-			c.mu.Unlock()
-			c.mu.RLock()
+	if !found || c.expired(item) {
+		if found && c.expired(item) {
+			c.expireStale(key, item)
 		}
 		return "", false
 	}
@@ -107,6 +227,7 @@ func (c *MemoryCache) Get(key string) (string, bool) {
 		return "", false
 	}
 
+	c.touch(item)
 	return item.value.(string), true
 }
 
@@ -122,21 +243,106 @@ func (c *MemoryCache) SetWithTTL(key string, value string, ttl time.Duration) er
 
 // set is a helper function for Set and SetWithTTL
 func (c *MemoryCache) set(key string, value string, ttl time.Duration) error {
+	_, err := c.SetWithOptions(key, value, SetOptions{TTL: max(ttl, 0)})
+	return err
+}
+
+// SetOptions controls whether SetWithOptions stores a value and how it sets
+// its expiration. TTL, ExpireAt and KeepTTL are mutually exclusive; without
+// any of them the key does not expire.
+type SetOptions struct {
+	// NX only stores the value if the key does not exist.
+	NX bool
+	// XX only stores the value if the key exists.
+	XX bool
+	// Get returns the previous string value of the key. The key must then
+	// hold a string, if anything.
+	Get bool
+	// KeepTTL keeps the expiration of an existing key.
+	KeepTTL bool
+	// TTL expires the key after the given duration, if positive.
+	TTL time.Duration
+	// ExpireAt expires the key at the given time, if not zero. A time in
+	// the past stores a key that has already expired.
+	ExpireAt time.Time
+}
+
+// validate reports whether the options can be combined.
+func (o SetOptions) validate() error {
+	expirations := 0
+	for _, set := range []bool{o.KeepTTL, o.TTL != 0, !o.ExpireAt.IsZero()} {
+		if set {
+			expirations++
+		}
+	}
+	if (o.NX && o.XX) || expirations > 1 || o.TTL < 0 {
+		return ErrInvalidOptions
+	}
+	return nil
+}
+
+// SetResult is the outcome of SetWithOptions.
+type SetResult struct {
+	// Stored reports whether the value was stored; NX or XX may prevent it.
+	Stored bool
+	// Previous is the previous value of the key, if HadPrevious. Both are
+	// only filled in with SetOptions.Get.
+	Previous    string
+	HadPrevious bool
+	// ExpireAt is the expiration time given to the stored value, zero if
+	// it does not expire.
+	ExpireAt time.Time
+}
+
+// SetWithOptions stores a string value in the cache under the conditions
+// and with the expiration given by opts, replacing a value of any type. It
+// fails with ErrInvalidOptions if opts cannot be combined and, with Get,
+// with ErrTypeMismatch if the key holds another type.
+func (c *MemoryCache) SetWithOptions(key string, value string, opts SetOptions) (SetResult, error) {
+	if err := opts.validate(); err != nil {
+		return SetResult{}, err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	var expireAt time.Time
-	if ttl > 0 {
-		expireAt = time.Now().Add(ttl)
+	if err := c.reserve(); err != nil {
+		return SetResult{}, err
+	}
+
+	var result SetResult
+	item := c.lookupItem(key)
+	if opts.Get && item != nil {
+		if item.dataType != StringType {
+			return SetResult{}, ErrTypeMismatch
+		}
+		result.Previous, result.HadPrevious = item.value.(string), true
+	}
+	if (opts.NX && item != nil) || (opts.XX && item == nil) {
+		return result, nil
+	}
+
+	expireAt := opts.ExpireAt
+	switch {
+	case opts.TTL > 0:
+		expireAt = time.Now().Add(opts.TTL)
+	case opts.KeepTTL && item != nil:
+		expireAt = item.expireAt
 	}
 
-	c.items[key] = &cacheItem{
+	c.storeItem(key, &cacheItem{
 		dataType: StringType,
 		value:    value,
 		expireAt: expireAt,
+	})
+	c.notify(EventsString, "set", key)
+	if !expireAt.IsZero() && !opts.KeepTTL {
+		c.notify(EventsGeneric, "expire", key)
 	}
 
-	return nil
+	result.Stored = true
+	result.ExpireAt = expireAt
+	return result, nil
 }
 
 // Update updates an existing string value in the cache
@@ -144,10 +350,14 @@ func (c *MemoryCache) Update(key string, value string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if err := c.reserve(); err != nil {
+		return err
+	}
+
 	item, found := c.items[key]
-	if !found || item.isExpired() {
-		if found && item.isExpired() {
-			delete(c.items, key)
+	if !found || c.expired(item) {
+		if found && c.expired(item) {
+			c.expire(key)
 		}
 		return ErrKeyNotFound
 	}
@@ -156,7 +366,10 @@ func (c *MemoryCache) Update(key string, value string) error {
 		return ErrTypeMismatch
 	}
 
+	c.touch(item)
+	c.resize(item, stringSize(value)-stringSize(item.value.(string)))
 	item.value = value
+	c.notify(EventsString, "set", key)
 	return nil
 }
 
@@ -165,93 +378,37 @@ func (c *MemoryCache) Remove(key string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	_, found := c.items[key]
+	item, found := c.items[key]
 	if !found {
 		return ErrKeyNotFound
 	}
+	if c.expired(item) {
+		c.expire(key)
+		return ErrKeyNotFound
+	}
 
-	delete(c.items, key)
+	c.deleteItem(key)
+	c.notify(EventsGeneric, "del", key)
 	return nil
 }
 
 // PushFront adds a value to the front of a list.
 func (c *MemoryCache) PushFront(key string, value string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	item, found := c.items[key]
-	if !found {
-		// Create a new list. if the key doesn't exist..
-		l := list.New()
-		l.PushFront(value)
-		c.items[key] = &cacheItem{
-			dataType: ListType,
-			value:    l,
-			expireAt: time.Time{},
-		}
-		return nil
-	}
-
-	if item.isExpired() {
-		delete(c.items, key)
-		// Create a new list..
-		l := list.New()
-		l.PushFront(value)
-		c.items[key] = &cacheItem{
-			dataType: ListType,
-			value:    l,
-			expireAt: time.Time{},
-		}
-		return nil
-	}
-
-	if item.dataType != ListType {
-		return ErrTypeMismatch
-	}
-
-	l := item.value.(*list.List)
-	l.PushFront(value)
-	return nil
+	_, err := c.ListPush(key, ListFront, value)
+	return err
 }
 
 // PushBack adds a value to the back of a list.
 func (c *MemoryCache) PushBack(key string, value string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	item, found := c.items[key]
-	if !found {
-		// Create a new list. if the key doesn't exist..
-		l := list.New()
-		l.PushBack(value)
-		c.items[key] = &cacheItem{
-			dataType: ListType,
-			value:    l,
-			expireAt: time.Time{},
-		}
-		return nil
-	}
-
-	if item.isExpired() {
-		delete(c.items, key)
-		// Create a new list..
-		l := list.New()
-		l.PushBack(value)
-		c.items[key] = &cacheItem{
-			dataType: ListType,
-			value:    l,
-			expireAt: time.Time{},
-		}
-		return nil
-	}
-
-	if item.dataType != ListType {
-		return ErrTypeMismatch
-	}
+	_, err := c.ListPush(key, ListBack, value)
+	return err
+}
 
-	l := item.value.(*list.List)
-	l.PushBack(value)
-	return nil
+// pushed publishes the push event of type event on key and wakes a caller
+// waiting for it. The caller must hold the write lock.
+func (c *MemoryCache) pushed(key string, event string) {
+	c.notify(EventsList, event, key)
+	c.wakeWaiter(key)
 }
 
 // PopFront removes and returns the first element of a list.
@@ -260,9 +417,9 @@ func (c *MemoryCache) PopFront(key string) (string, bool) {
 	defer c.mu.Unlock()
 
 	item, found := c.items[key]
-	if !found || item.isExpired() {
-		if found && item.isExpired() {
-			delete(c.items, key)
+	if !found || c.expired(item) {
+		if found && c.expired(item) {
+			c.expire(key)
 		}
 		return "", false
 	}
@@ -272,12 +429,11 @@ func (c *MemoryCache) PopFront(key string) (string, bool) {
 	}
 
 	l := item.value.(*list.List)
-	if l.Len() == 0 {
-		return "", false
-	}
-
 	element := l.Front()
 	l.Remove(element)
+	c.resize(item, -listElementSize(element.Value.(string)))
+	c.notify(EventsList, "lpop", key)
+	c.deleteEmptyList(key, l)
 	return element.Value.(string), true
 }
 
@@ -287,9 +443,9 @@ func (c *MemoryCache) PopBack(key string) (string, bool) {
 	defer c.mu.Unlock()
 
 	item, found := c.items[key]
-	if !found || item.isExpired() {
-		if found && item.isExpired() {
-			delete(c.items, key)
+	if !found || c.expired(item) {
+		if found && c.expired(item) {
+			c.expire(key)
 		}
 		return "", false
 	}
@@ -299,12 +455,11 @@ func (c *MemoryCache) PopBack(key string) (string, bool) {
 	}
 
 	l := item.value.(*list.List)
-	if l.Len() == 0 {
-		return "", false
-	}
-
 	element := l.Back()
 	l.Remove(element)
+	c.resize(item, -listElementSize(element.Value.(string)))
+	c.notify(EventsList, "rpop", key)
+	c.deleteEmptyList(key, l)
 	return element.Value.(string), true
 }
 
@@ -314,14 +469,9 @@ func (c *MemoryCache) ListRange(key string, start, end int) ([]string, error) {
 	defer c.mu.RUnlock()
 
 	item, found := c.items[key]
-	if !found || item.isExpired() {
-		if found && item.isExpired() {
-			// Cleanup expired item..
-			c.mu.RUnlock()
-			c.mu.Lock()
-			delete(c.items, key)
-			c.mu.Unlock()
-			c.mu.RLock()
+	if !found || c.expired(item) {
+		if found && c.expired(item) {
+			c.expireStale(key, item)
 		}
 		return nil, ErrKeyNotFound
 	}
@@ -330,25 +480,10 @@ func (c *MemoryCache) ListRange(key string, start, end int) ([]string, error) {
 		return nil, ErrTypeMismatch
 	}
 
+	c.touch(item)
 	l := item.value.(*list.List)
-	length := l.Len()
-
-	// Handle negative indices..
-	if start < 0 {
-		start = length + start
-	}
-	if end < 0 {
-		end = length + end
-	}
-
-	// Validate indices..
-	if start < 0 {
-		start = 0
-	}
-	if end >= length {
-		end = length - 1
-	}
-	if start > end || start >= length {
+	start, end, ok := listBounds(l.Len(), start, end)
+	if !ok {
 		return []string{}, nil
 	}
 
@@ -372,17 +507,19 @@ func (c *MemoryCache) SetTTL(key string, ttl time.Duration) error {
 	defer c.mu.Unlock()
 
 	item, found := c.items[key]
-	if !found || item.isExpired() {
-		if found && item.isExpired() {
-			delete(c.items, key)
+	if !found || c.expired(item) {
+		if found && c.expired(item) {
+			c.expire(key)
 		}
 		return ErrKeyNotFound
 	}
 
 	if ttl <= 0 {
-		item.expireAt = time.Time{}
+		c.setExpireAt(key, item, time.Time{})
+		c.notify(EventsGeneric, "persist", key)
 	} else {
-		item.expireAt = time.Now().Add(ttl)
+		c.setExpireAt(key, item, time.Now().Add(ttl))
+		c.notify(EventsGeneric, "expire", key)
 	}
 
 	return nil
@@ -394,14 +531,9 @@ func (c *MemoryCache) GetTTL(key string) (time.Duration, bool) {
 	defer c.mu.RUnlock()
 
 	item, found := c.items[key]
-	if !found || item.isExpired() {
-		if found && item.isExpired() {
-			// Cleanup expired item..
-			c.mu.RUnlock()
-			c.mu.Lock()
-			delete(c.items, key)
-			c.mu.Unlock()
-			c.mu.RLock()
+	if !found || c.expired(item) {
+		if found && c.expired(item) {
+			c.expireStale(key, item)
 		}
 		return 0, false
 	}
@@ -410,12 +542,15 @@ func (c *MemoryCache) GetTTL(key string) (time.Duration, bool) {
 		return -1, true // -1 indicates no expiration...
 	}
 
-	ttl := time.Until(item.expireAt)
-	if ttl < 0 {
-		return 0, false
-	}
+	// The item may be past its expiration while the cache is loading.
+	return max(time.Until(item.expireAt), 0), true
+}
 
-	return ttl, true
+// ExpireAt sets the absolute expiration time of a key. A time in the past
+// expires the key immediately.
+func (c *MemoryCache) ExpireAt(key string, at time.Time) error {
+	_, err := c.ExpireAtWithOptions(key, at, ExpireOptions{})
+	return err
 }
 
 // RemoveTTL removes the TTL for a key.
@@ -424,14 +559,15 @@ func (c *MemoryCache) RemoveTTL(key string) error {
 	defer c.mu.Unlock()
 
 	item, found := c.items[key]
-	if !found || item.isExpired() {
-		if found && item.isExpired() {
-			delete(c.items, key)
+	if !found || c.expired(item) {
+		if found && c.expired(item) {
+			c.expire(key)
 		}
 		return ErrKeyNotFound
 	}
 
-	item.expireAt = time.Time{}
+	c.setExpireAt(key, item, time.Time{})
+	c.notify(EventsGeneric, "persist", key)
 	return nil
 }
 
@@ -445,13 +581,8 @@ func (c *MemoryCache) Exists(key string) bool {
 		return false
 	}
 
-	if item.isExpired() {
-		// Cleanup expired item..
-		c.mu.RUnlock()
-		c.mu.Lock()
-		delete(c.items, key)
-		c.mu.Unlock()
-		c.mu.RLock()
+	if c.expired(item) {
+		c.expireStale(key, item)
 		return false
 	}
 
@@ -464,14 +595,9 @@ func (c *MemoryCache) Type(key string) (DataType, bool) {
 	defer c.mu.RUnlock()
 
 	item, found := c.items[key]
-	if !found || item.isExpired() {
-		if found && item.isExpired() {
-			// Cleanup expired item..
-			c.mu.RUnlock()
-			c.mu.Lock()
-			delete(c.items, key)
-			c.mu.Unlock()
-			c.mu.RLock()
+	if !found || c.expired(item) {
+		if found && c.expired(item) {
+			c.expireStale(key, item)
 		}
 		return 0, false
 	}
@@ -479,11 +605,34 @@ func (c *MemoryCache) Type(key string) (DataType, bool) {
 	return item.dataType, true
 }
 
-// Clear removes all items from the cache.
+// Clear removes all items from the cache. Like FLUSHALL in Redis, it
+// publishes no keyspace notifications.
 func (c *MemoryCache) Clear() error {
+	return c.ClearContext(context.Background())
+}
+
+// ClearContext is Clear, failing with the error of ctx, having removed
+// nothing, if ctx is done before the cache is locked.
+func (c *MemoryCache) ClearContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.items = make(map[string]*cacheItem)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.clear()
 	return nil
 }
+
+// clear removes all items. The caller must hold the write lock.
+func (c *MemoryCache) clear() {
+	c.items = make(map[string]*cacheItem)
+	c.scan = newScanIndex()
+	c.expiries = nil
+	c.used = 0
+	c.cleared()
+}
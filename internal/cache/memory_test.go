@@ -2,6 +2,9 @@ package cache
 
 import (
 	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -115,6 +118,137 @@ func TestMemoryCache_String(t *testing.T) {
 	}
 }
 
+func TestMemoryCache_SetWithOptions(t *testing.T) {
+	t.Parallel()
+
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		name    string
+		setup   func(c *MemoryCache)
+		opts    SetOptions
+		want    SetResult
+		wantErr error
+		// value is the value expected afterwards, empty if the key must
+		// not exist.
+		value string
+		// ttl reports whether the key must have a TTL afterwards.
+		ttl bool
+	}{
+		{name: "plain", opts: SetOptions{}, want: SetResult{Stored: true}, value: "new"},
+		{name: "NX missing", opts: SetOptions{NX: true}, want: SetResult{Stored: true}, value: "new"},
+		{
+			name:  "NX existing",
+			setup: func(c *MemoryCache) { requireNoError(t, c.Set("k", "old"), "Setup failed") },
+			opts:  SetOptions{NX: true, Get: true},
+			want:  SetResult{Previous: "old", HadPrevious: true},
+			value: "old",
+		},
+		{
+			name:  "NX expired",
+			setup: func(c *MemoryCache) { requireNoError(t, c.SetWithTTL("k", "old", time.Millisecond), "Setup failed") },
+			opts:  SetOptions{NX: true},
+			want:  SetResult{Stored: true},
+			value: "new",
+		},
+		{name: "XX missing", opts: SetOptions{XX: true}, want: SetResult{}},
+		{
+			name:  "XX existing list",
+			setup: func(c *MemoryCache) { requireNoError(t, c.PushBack("k", "a"), "Setup failed") },
+			opts:  SetOptions{XX: true},
+			want:  SetResult{Stored: true},
+			value: "new",
+		},
+		{
+			name:  "Get",
+			setup: func(c *MemoryCache) { requireNoError(t, c.SetWithTTL("k", "old", time.Hour), "Setup failed") },
+			opts:  SetOptions{Get: true},
+			want:  SetResult{Stored: true, Previous: "old", HadPrevious: true},
+			value: "new",
+		},
+		{
+			name:    "Get list",
+			setup:   func(c *MemoryCache) { requireNoError(t, c.PushBack("k", "a"), "Setup failed") },
+			opts:    SetOptions{Get: true},
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name:  "KeepTTL",
+			setup: func(c *MemoryCache) { requireNoError(t, c.SetWithTTL("k", "old", time.Hour), "Setup failed") },
+			opts:  SetOptions{KeepTTL: true},
+			want:  SetResult{Stored: true},
+			value: "new",
+			ttl:   true,
+		},
+		{
+			name:  "without KeepTTL",
+			setup: func(c *MemoryCache) { requireNoError(t, c.SetWithTTL("k", "old", time.Hour), "Setup failed") },
+			opts:  SetOptions{},
+			want:  SetResult{Stored: true},
+			value: "new",
+		},
+		{name: "TTL", opts: SetOptions{TTL: time.Hour}, want: SetResult{Stored: true}, value: "new", ttl: true},
+		{name: "ExpireAt", opts: SetOptions{ExpireAt: future}, want: SetResult{Stored: true}, value: "new", ttl: true},
+		{name: "ExpireAt past", opts: SetOptions{ExpireAt: time.Now().Add(-time.Second)}, want: SetResult{Stored: true}},
+		{name: "NX and XX", opts: SetOptions{NX: true, XX: true}, wantErr: ErrInvalidOptions},
+		{name: "TTL and KeepTTL", opts: SetOptions{TTL: time.Hour, KeepTTL: true}, wantErr: ErrInvalidOptions},
+		{name: "TTL and ExpireAt", opts: SetOptions{TTL: time.Hour, ExpireAt: future}, wantErr: ErrInvalidOptions},
+		{name: "negative TTL", opts: SetOptions{TTL: -time.Second}, wantErr: ErrInvalidOptions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := NewMemoryCache(0)
+			if tt.setup != nil {
+				tt.setup(c)
+				time.Sleep(2 * time.Millisecond)
+			}
+
+			got, err := c.SetWithOptions("k", "new", tt.opts)
+			require(t, errors.Is(err, tt.wantErr), "SetWithOptions() error = %v, want %v", err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+			// The expiration is checked against the stored key.
+			expireAt := got.ExpireAt
+			got.ExpireAt = time.Time{}
+			require(t, got == tt.want, "SetWithOptions() = %+v, want %+v", got, tt.want)
+
+			value, found := c.Get("k")
+			require(t, value == tt.value, "value = %q (found %v), want %q", value, found, tt.value)
+			if tt.value != "" {
+				ttl, _ := c.GetTTL("k")
+				require(t, (ttl > 0) == tt.ttl, "TTL = %v, want a TTL: %v", ttl, tt.ttl)
+				at, _ := c.ExpireTime("k")
+				require(t, expireAt.Equal(at), "SetWithOptions() ExpireAt = %v, want the expiration of the key %v", expireAt, at)
+			}
+		})
+	}
+}
+
+func TestSetWithOptions_NXLock(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []Cache{NewMemoryCache(0), NewShardedCache(4, 0)} {
+		var acquired atomic.Int32
+		var wg sync.WaitGroup
+		for i := range 50 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result, err := c.SetWithOptions("lock", fmt.Sprint("owner", i), SetOptions{NX: true, TTL: time.Minute})
+				requireNoError(t, err, "SetWithOptions() failed")
+				if result.Stored {
+					acquired.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		require(t, acquired.Load() == 1, "%d callers acquired the lock, want 1", acquired.Load())
+	}
+}
+
 func TestMemoryCache_TTL(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -253,6 +387,32 @@ func TestMemoryCache_TTL(t *testing.T) {
 	}
 }
 
+func TestMemoryCache_ExpireAtLoading(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+	requireNoError(t, c.Set("key", "value"), "Set() failed")
+
+	at := time.Now().Add(time.Hour)
+	requireNoError(t, c.ExpireAt("key", at), "ExpireAt() failed")
+	ttl, ok := c.GetTTL("key")
+	require(t, ok && ttl > 59*time.Minute, "GetTTL() = %v, %v", ttl, ok)
+
+	err := c.ExpireAt("missing", at)
+	require(t, errors.Is(err, ErrKeyNotFound), "ExpireAt() error = %v, want %v", err, ErrKeyNotFound)
+
+	// Keys past their expiration stay visible until loading ends.
+	c.BeginLoad()
+	requireNoError(t, c.ExpireAt("key", time.Now().Add(-time.Second)), "ExpireAt() failed")
+	requireNoError(t, c.Update("key", "updated"), "Update() failed")
+	value, ok := c.Get("key")
+	require(t, ok && value == "updated", "Get() while loading = %q, %v", value, ok)
+	c.EndLoad()
+
+	require(t, !c.Exists("key"), "Exists() = true after loading ended")
+	require(t, c.Dump() != nil && len(c.Dump()) == 0, "Dump() = %v, want no entries", c.Dump())
+}
+
 func TestMemoryCache_General(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -281,6 +441,17 @@ func TestMemoryCache_General(t *testing.T) {
 			operation: "Remove",
 			wantErr:   ErrKeyNotFound,
 		},
+		{
+			name: "Remove expired key",
+			setup: func(c *MemoryCache) {
+				err := c.SetWithTTL("expired", "value", time.Millisecond)
+				requireNoError(t, err, "Setup failed: %v", err)
+				time.Sleep(5 * time.Millisecond)
+			},
+			key:       "expired",
+			operation: "Remove",
+			wantErr:   ErrKeyNotFound,
+		},
 		{
 			name: "Exists with existing key",
 			setup: func(c *MemoryCache) {
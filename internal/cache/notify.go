@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dsha256/gredis/internal/pubsub"
+)
+
+// ErrInvalidEventClasses is returned by ParseEventClasses for an unknown
+// class flag.
+var ErrInvalidEventClasses = errors.New("invalid keyspace event classes")
+
+// Channel prefixes of keyspace notifications, as in Redis. An event is
+// published to KeyspacePrefix+key with the event type as payload, and to
+// KeyeventPrefix+type with the key as payload.
+const (
+	KeyspacePrefix = "__keyspace@0__:"
+	KeyeventPrefix = "__keyevent@0__:"
+)
+
+// EventClass is a set of keyspace notification classes. Events are only
+// published if their class is enabled and at least one of
+// EventsKeyspace and EventsKeyevent selects where to publish them.
+type EventClass uint16
+
+// Event classes, named after the flags of notify-keyspace-events in Redis.
+const (
+	// EventsKeyspace (K) publishes events to KeyspacePrefix+key.
+	EventsKeyspace EventClass = 1 << iota
+	// EventsKeyevent (E) publishes events to KeyeventPrefix+type.
+	EventsKeyevent
+	// EventsGeneric (g) covers del, expire, persist, restore, rename_from,
+	// rename_to and copy_to.
+	EventsGeneric
+	// EventsString ($) covers set, incrby and incrbyfloat.
+	EventsString
+	// EventsList (l) covers lpush, rpush, lpop, rpop, lset, linsert, lrem
+	// and ltrim.
+	EventsList
+	// EventsSet (s) covers sadd, srem and the store operations.
+	EventsSet
+	// EventsHash (h) covers hset, hdel and hincrby.
+	EventsHash
+	// EventsSortedSet (z) covers zadd, zincr, zrem, zpopmin and zpopmax.
+	EventsSortedSet
+	// EventsExpired (x) covers expired, published when a key expires.
+	EventsExpired
+	// EventsEvicted (e) covers evicted, published when a key is evicted.
+	EventsEvicted
+
+	// EventsAll (A) is every class of events.
+	EventsAll = EventsGeneric | EventsString | EventsList | EventsSet | EventsHash |
+		EventsSortedSet | EventsExpired | EventsEvicted
+)
+
+// eventClassFlags maps each class to its flag, in the order String lists
+// them.
+var eventClassFlags = []struct {
+	class EventClass
+	flag  byte
+}{
+	{EventsKeyspace, 'K'},
+	{EventsKeyevent, 'E'},
+	{EventsGeneric, 'g'},
+	{EventsString, '$'},
+	{EventsList, 'l'},
+	{EventsSet, 's'},
+	{EventsHash, 'h'},
+	{EventsSortedSet, 'z'},
+	{EventsExpired, 'x'},
+	{EventsEvicted, 'e'},
+}
+
+// ParseEventClasses parses event classes written as Redis flags, e.g.
+// "KEA" for every event on both kinds of channels or "Kx" for expirations
+// on keyspace channels. An empty string disables notifications.
+func ParseEventClasses(flags string) (EventClass, error) {
+	var classes EventClass
+	for i := 0; i < len(flags); i++ {
+		if flags[i] == 'A' {
+			classes |= EventsAll
+			continue
+		}
+
+		found := false
+		for _, f := range eventClassFlags {
+			if f.flag == flags[i] {
+				classes |= f.class
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("%w: unknown flag %q", ErrInvalidEventClasses, flags[i])
+		}
+	}
+	return classes, nil
+}
+
+// String returns the classes as Redis flags, using A for every class.
+func (e EventClass) String() string {
+	var b strings.Builder
+	all := e&EventsAll == EventsAll
+	for _, f := range eventClassFlags {
+		if e&f.class != 0 && (!all || f.class&EventsAll == 0) {
+			b.WriteByte(f.flag)
+		}
+	}
+	if all {
+		b.WriteByte('A')
+	}
+	return b.String()
+}
+
+// Event is a keyspace notification: an operation of type Type applied to
+// Key, such as "set", "del", "expired" or "lpush".
+type Event struct {
+	Type string `json:"event"`
+	Key  string `json:"key"`
+}
+
+// ParseEvent extracts the event from a message received on a keyspace or
+// keyevent channel. It reports false for any other message.
+func ParseEvent(msg pubsub.Message) (Event, bool) {
+	if key, ok := strings.CutPrefix(msg.Channel, KeyspacePrefix); ok {
+		return Event{Type: msg.Payload, Key: key}, true
+	}
+	if typ, ok := strings.CutPrefix(msg.Channel, KeyeventPrefix); ok {
+		return Event{Type: typ, Key: msg.Payload}, true
+	}
+	return Event{}, false
+}
+
+// WithNotifications enables keyspace notifications for the given classes.
+func WithNotifications(classes EventClass) Option {
+	return func(c *MemoryCache) {
+		c.events = classes
+	}
+}
+
+// notify publishes an event of type event on key if its class is enabled.
+// The caller must hold the write lock.
+func (c *MemoryCache) notify(class EventClass, event, key string) {
+	if c.events&class == 0 {
+		return
+	}
+	if c.events&EventsKeyspace != 0 {
+		c.broker.Publish(KeyspacePrefix+key, event)
+	}
+	if c.events&EventsKeyevent != 0 {
+		c.broker.Publish(KeyeventPrefix+event, key)
+	}
+}
+
+// expire deletes the expired item stored under key and publishes an
+// expired event. The caller must hold the write lock.
+func (c *MemoryCache) expire(key string) {
+	c.deleteItem(key)
+	c.notify(EventsExpired, "expired", key)
+}
@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dsha256/gredis/internal/pubsub"
+)
+
+// receivedEvents returns the events already delivered to sub. Publishing
+// is synchronous, so they include every event of the operations applied
+// so far.
+func receivedEvents(t *testing.T, sub *pubsub.Subscription) []Event {
+	t.Helper()
+
+	var events []Event
+	for {
+		select {
+		case msg := <-sub.C():
+			event, ok := ParseEvent(msg)
+			require(t, ok, "unexpected message %+v", msg)
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// ignore returns nil if err is target, and err otherwise.
+func ignore(err, target error) error {
+	if errors.Is(err, target) {
+		return nil
+	}
+	return err
+}
+
+func TestParseEventClasses(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		flags   string
+		want    EventClass
+		wantErr error
+		// str is the expected String of the classes, the flags if empty.
+		str string
+	}{
+		{flags: "", want: 0},
+		{flags: "KEA", want: EventsKeyspace | EventsKeyevent | EventsAll},
+		{flags: "Kx", want: EventsKeyspace | EventsExpired},
+		{flags: "E$lshz", want: EventsKeyevent | EventsString | EventsList | EventsSet | EventsHash | EventsSortedSet},
+		{flags: "Eg$lshzxe", want: EventsKeyevent | EventsAll, str: "EA"},
+		{flags: "xK", want: EventsKeyspace | EventsExpired, str: "Kx"},
+		{flags: "KQ", wantErr: ErrInvalidEventClasses},
+	}
+	for _, tt := range tests {
+		got, err := ParseEventClasses(tt.flags)
+		require(t, errors.Is(err, tt.wantErr), "ParseEventClasses(%q) error = %v, want %v", tt.flags, err, tt.wantErr)
+		require(t, got == tt.want, "ParseEventClasses(%q) = %v, want %v", tt.flags, got, tt.want)
+		if tt.wantErr != nil {
+			continue
+		}
+
+		want := tt.str
+		if want == "" {
+			want = tt.flags
+		}
+		require(t, got.String() == want, "ParseEventClasses(%q).String() = %q, want %q", tt.flags, got.String(), want)
+	}
+}
+
+func TestMemoryCache_Notifications(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0, WithNotifications(EventsKeyevent|EventsAll))
+	sub := c.PSubscribe(KeyeventPrefix + "*")
+	defer sub.Close()
+
+	steps := []struct {
+		name string
+		run  func() error
+		want []Event
+	}{
+		{"Set", func() error { return c.Set("s", "v") }, []Event{{"set", "s"}}},
+		{"SetWithTTL", func() error { return c.SetWithTTL("s", "v", time.Hour) }, []Event{{"set", "s"}, {"expire", "s"}}},
+		{"Update", func() error { return c.Update("s", "w") }, []Event{{"set", "s"}}},
+		{"IncrBy", func() error { _, err := c.IncrBy("n", 2); return err }, []Event{{"incrby", "n"}}},
+		{"IncrByFloat", func() error { _, err := c.IncrByFloat("n", 0.5); return err }, []Event{{"incrbyfloat", "n"}}},
+		{"IncrBy not an integer", func() error { _, err := c.IncrBy("n", 1); return ignore(err, ErrNotInteger) }, nil},
+		{"SetTTL", func() error { return c.SetTTL("s", time.Minute) }, []Event{{"expire", "s"}}},
+		{"SetTTL zero", func() error { return c.SetTTL("s", 0) }, []Event{{"persist", "s"}}},
+		{"RemoveTTL", func() error { return c.RemoveTTL("s") }, []Event{{"persist", "s"}}},
+		{"ExpireAt", func() error { return c.ExpireAt("s", time.Now().Add(time.Hour)) }, []Event{{"expire", "s"}}},
+		{"ExpireWithOptions GT", func() error { _, err := c.ExpireWithOptions("s", 2*time.Hour, ExpireOptions{GT: true}); return err }, []Event{{"expire", "s"}}},
+		{"ExpireWithOptions NX not set", func() error { _, err := c.ExpireWithOptions("s", time.Hour, ExpireOptions{NX: true}); return err }, nil},
+		{"ExpireAt past", func() error { return c.ExpireAt("s", time.Now().Add(-time.Hour)) }, []Event{{"del", "s"}}},
+		{"PushFront", func() error { return c.PushFront("l", "a") }, []Event{{"lpush", "l"}}},
+		{"PushBack", func() error { return c.PushBack("l", "b") }, []Event{{"rpush", "l"}}},
+		{"PopFront", func() error { c.PopFront("l"); return nil }, []Event{{"lpop", "l"}}},
+		{"PopBack", func() error { c.PopBack("l"); return nil }, []Event{{"rpop", "l"}, {"del", "l"}}},
+		{"PopBack empty", func() error { c.PopBack("l"); return nil }, nil},
+		{"HSet", func() error { _, err := c.HSet("h", map[string]string{"f": "1"}); return err }, []Event{{"hset", "h"}}},
+		{"HIncrBy", func() error { _, err := c.HIncrBy("h", "f", 1); return err }, []Event{{"hincrby", "h"}}},
+		{"HDel missing field", func() error { _, err := c.HDel("h", "x"); return err }, nil},
+		{"HDel", func() error { _, err := c.HDel("h", "f"); return err }, []Event{{"hdel", "h"}, {"del", "h"}}},
+		{"SAdd", func() error { _, err := c.SAdd("a", "x", "y"); return err }, []Event{{"sadd", "a"}}},
+		{"SAdd existing", func() error { _, err := c.SAdd("a", "x"); return err }, nil},
+		{"SInterStore", func() error { _, err := c.SInterStore("i", "a"); return err }, []Event{{"sinterstore", "i"}}},
+		{"SUnionStore", func() error { _, err := c.SUnionStore("u", "a"); return err }, []Event{{"sunionstore", "u"}}},
+		{"SDiffStore empty", func() error { _, err := c.SDiffStore("i", "a", "a"); return err }, []Event{{"del", "i"}}},
+		{"SDiffStore empty missing", func() error { _, err := c.SDiffStore("d", "a", "a"); return err }, nil},
+		{"SRem", func() error { _, err := c.SRem("a", "x", "y"); return err }, []Event{{"srem", "a"}, {"del", "a"}}},
+		{"ZAdd", func() error {
+			_, err := c.ZAdd("z", ZAddOptions{}, Z{Member: "a", Score: 1}, Z{Member: "b", Score: 2}, Z{Member: "c", Score: 3})
+			return err
+		}, []Event{{"zadd", "z"}}},
+		{"ZAdd unchanged", func() error { _, err := c.ZAdd("z", ZAddOptions{}, Z{Member: "a", Score: 1}); return err }, nil},
+		{"ZIncrBy", func() error { _, err := c.ZIncrBy("z", 1, "a"); return err }, []Event{{"zincr", "z"}}},
+		{"ZPopMin", func() error { _, err := c.ZPopMin("z", 1); return err }, []Event{{"zpopmin", "z"}}},
+		{"ZPopMax", func() error { _, err := c.ZPopMax("z", 1); return err }, []Event{{"zpopmax", "z"}}},
+		{"ZRem", func() error { _, err := c.ZRem("z", "b"); return err }, []Event{{"zrem", "z"}, {"del", "z"}}},
+		{"Restore", func() error {
+			return c.Restore([]Entry{{Key: "r", Type: StringType, Value: "v"}})
+		}, []Event{{"restore", "r"}}},
+		{"Copy", func() error { return c.Copy("r", "r2", false) }, []Event{{"copy_to", "r2"}}},
+		{"Rename", func() error { return c.Rename("r2", "r3", true) }, []Event{{"rename_from", "r2"}, {"rename_to", "r3"}}},
+		{"Rename existing", func() error { return ignore(c.Rename("r3", "r", false), ErrKeyExists) }, nil},
+		{"Remove", func() error { return c.Remove("r") }, []Event{{"del", "r"}}},
+		{"Clear", func() error { return c.Clear() }, nil},
+	}
+	for _, step := range steps {
+		requireNoError(t, step.run(), "%s failed", step.name)
+		got := receivedEvents(t, sub)
+		require(t, slices.Equal(got, step.want), "%s events = %v, want %v", step.name, got, step.want)
+	}
+}
+
+func TestMemoryCache_NotificationClasses(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0, WithNotifications(EventsKeyspace|EventsExpired|EventsList))
+	sub := c.PSubscribe("__key*")
+	defer sub.Close()
+
+	requireNoError(t, c.SetWithTTL("s", "v", time.Millisecond), "SetWithTTL failed")
+	requireNoError(t, c.PushBack("l", "a"), "PushBack failed")
+	time.Sleep(5 * time.Millisecond)
+	c.cleanup()
+
+	// Only the enabled classes are published, and only on the keyspace
+	// channels.
+	var got []pubsub.Message
+	for len(sub.C()) > 0 {
+		got = append(got, <-sub.C())
+	}
+	want := []pubsub.Message{
+		{Channel: KeyspacePrefix + "l", Payload: "rpush", Pattern: "__key*"},
+		{Channel: KeyspacePrefix + "s", Payload: "expired", Pattern: "__key*"},
+	}
+	require(t, slices.Equal(got, want), "messages = %v, want %v", got, want)
+}
+
+func TestMemoryCache_NotificationsDisabled(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+	sub := c.PSubscribe("*")
+	defer sub.Close()
+
+	requireNoError(t, c.Set("s", "v"), "Set failed")
+	requireNoError(t, c.Remove("s"), "Remove failed")
+	require(t, len(receivedEvents(t, sub)) == 0, "events published with notifications disabled")
+}
+
+func TestMemoryCache_ExpiredAndEvictedEvents(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0, WithNotifications(EventsKeyevent|EventsExpired|EventsEvicted), WithMaxMemory(1, AllKeysLRU))
+	sub := c.PSubscribe(KeyeventPrefix + "*")
+	defer sub.Close()
+
+	requireNoError(t, c.SetWithTTL("old", "v", time.Millisecond), "SetWithTTL failed")
+	time.Sleep(5 * time.Millisecond)
+	_, found := c.Get("old")
+	require(t, !found, "expired key found")
+	got := receivedEvents(t, sub)
+	require(t, slices.Equal(got, []Event{{"expired", "old"}}), "events = %v, want an expired event", got)
+
+	requireNoError(t, c.Set("a", "v"), "Set failed")
+	requireNoError(t, c.Set("b", "v"), "Set failed")
+	got = receivedEvents(t, sub)
+	require(t, slices.Equal(got, []Event{{"evicted", "a"}}), "events = %v, want an evicted event", got)
+}
+
+func TestShardedCache_Notifications(t *testing.T) {
+	t.Parallel()
+
+	s := NewShardedCache(4, 0, WithNotifications(EventsKeyevent|EventsAll))
+	sub := s.PSubscribe(KeyeventPrefix + "*")
+	defer sub.Close()
+
+	keys := keysOnShards(t, s, "key", 8)
+	for i, key := range keys {
+		if s.shardIndex(key) != s.shardIndex(keys[0]) {
+			keys = []string{keys[0], keys[i]}
+			break
+		}
+	}
+	requireNoError(t, s.Set(keys[0], "v"), "Set failed")
+	_, err := s.SAdd(keys[1], "m")
+	requireNoError(t, err, "SAdd failed")
+	_, err = s.SUnionStore(keys[0], keys[1])
+	requireNoError(t, err, "SUnionStore failed")
+
+	got := receivedEvents(t, sub)
+	want := []Event{{"set", keys[0]}, {"sadd", keys[1]}, {"sunionstore", keys[0]}}
+	require(t, slices.Equal(got, want), "events = %v, want %v", got, want)
+}
+
+// racingLock is a cache lock that runs beforeLock, once, when the write
+// lock is next requested, to write to the cache before it is acquired.
+type racingLock struct {
+	sync.RWMutex
+	beforeLock func()
+}
+
+func (l *racingLock) Lock() {
+	if f := l.beforeLock; f != nil {
+		l.beforeLock = nil
+		f()
+	}
+	l.RWMutex.Lock()
+}
+
+func TestMemoryCache_ExpiredReplacedBeforeCleanup(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0, WithNotifications(EventsKeyevent|EventsExpired))
+	lock := new(racingLock)
+	c.mu = lock
+	sub := c.PSubscribe(KeyeventPrefix + "*")
+	defer sub.Close()
+
+	// The key is set again while a read trades its read lock for the write
+	// lock to remove the expired value, so the new value is kept and only
+	// the Set reports the expiration.
+	requireNoError(t, c.SetWithTTL("k", "old", time.Millisecond), "SetWithTTL failed")
+	time.Sleep(5 * time.Millisecond)
+	lock.beforeLock = func() {
+		requireNoError(t, c.Set("k", "new"), "Set failed")
+	}
+	_, found := c.Get("k")
+	require(t, !found, "expired key found")
+	value, found := c.Get("k")
+	require(t, found && value == "new", "Get() = %q, %v, want the value set meanwhile", value, found)
+	got := receivedEvents(t, sub)
+	require(t, slices.Equal(got, []Event{{"expired", "k"}}), "events = %v, want a single expired event", got)
+}
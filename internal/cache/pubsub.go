@@ -0,0 +1,24 @@
+package cache
+
+import (
+	"github.com/dsha256/gredis/internal/pubsub"
+)
+
+// Publish posts a message to a channel and returns the number of
+// subscribers that received it.
+func (c *MemoryCache) Publish(channel string, message string) int {
+	return c.broker.Publish(channel, message)
+}
+
+// Subscribe creates a subscription to the given channels. The caller must
+// close the subscription once it is no longer used.
+func (c *MemoryCache) Subscribe(channels ...string) *pubsub.Subscription {
+	return c.broker.Subscribe(channels...)
+}
+
+// PSubscribe creates a subscription to the channels matching the given glob
+// patterns. The caller must close the subscription once it is no longer
+// used.
+func (c *MemoryCache) PSubscribe(patterns ...string) *pubsub.Subscription {
+	return c.broker.PSubscribe(patterns...)
+}
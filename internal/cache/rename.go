@@ -0,0 +1,107 @@
+package cache
+
+import "errors"
+
+// ErrSameKey is returned by Copy if the source and destination are the
+// same key.
+var ErrSameKey = errors.New("source and destination are the same key")
+
+// Rename renames key to newKey, keeping its value, type and expiration. If
+// newKey exists it is replaced, or with replace false Rename fails with
+// ErrKeyExists. It fails with ErrKeyNotFound if key is missing.
+func (c *MemoryCache) Rename(key, newKey string, replace bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return renameKey(c, c, key, newKey, replace)
+}
+
+// Copy stores a copy of the value of source at destination, with the same
+// type and expiration. The copy shares no memory with the source, so that
+// writing one leaves the other unchanged. If destination exists it is
+// replaced, or with replace false Copy fails with ErrKeyExists. It fails
+// with ErrKeyNotFound if source is missing.
+func (c *MemoryCache) Copy(source, destination string, replace bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reserve(); err != nil {
+		return err
+	}
+	return copyKey(c, c, source, destination, replace)
+}
+
+// Rename renames key to newKey, see MemoryCache.Rename, with the shards
+// holding them locked.
+func (s *ShardedCache) Rename(key, newKey string, replace bool) error {
+	unlock := s.lockShards([]string{key, newKey}, true)
+	defer unlock()
+
+	return renameKey(s.shard(key), s.shard(newKey), key, newKey, replace)
+}
+
+// Copy stores a copy of the value of source at destination, see
+// MemoryCache.Copy, with the shards holding them locked.
+func (s *ShardedCache) Copy(source, destination string, replace bool) error {
+	keys := []string{source, destination}
+	unlock := s.lockShards(keys, true)
+	defer unlock()
+
+	if err := s.reserve(keys); err != nil {
+		return err
+	}
+	return copyKey(s.shard(source), s.shard(destination), source, destination, replace)
+}
+
+// renameKey moves the item at key in src to newKey in dst, see
+// MemoryCache.Rename. The caller must hold the write locks of both.
+func renameKey(src, dst *MemoryCache, key, newKey string, replace bool) error {
+	item := src.lookupItem(key)
+	if item == nil {
+		return ErrKeyNotFound
+	}
+	if key == newKey {
+		if !replace {
+			return ErrKeyExists
+		}
+		return nil
+	}
+	if !replace && dst.lookupItem(newKey) != nil {
+		return ErrKeyExists
+	}
+
+	src.deleteItem(key)
+	src.notify(EventsGeneric, "rename_from", key)
+	dst.storeItem(newKey, item)
+	dst.notify(EventsGeneric, "rename_to", newKey)
+	dst.wakeWaiter(newKey)
+	return nil
+}
+
+// copyKey stores a deep copy of the item at source in src at destination
+// in dst, see MemoryCache.Copy. The caller must hold the write locks of
+// both.
+func copyKey(src, dst *MemoryCache, source, destination string, replace bool) error {
+	if source == destination {
+		return ErrSameKey
+	}
+	item := src.lookupItem(source)
+	if item == nil {
+		return ErrKeyNotFound
+	}
+	if !replace && dst.lookupItem(destination) != nil {
+		return ErrKeyExists
+	}
+
+	dst.storeItem(destination, item.clone())
+	dst.notify(EventsGeneric, "copy_to", destination)
+	dst.wakeWaiter(destination)
+	return nil
+}
+
+// clone returns a deep copy of the item.
+func (i *cacheItem) clone() *cacheItem {
+	// An entry made from an item is always valid.
+	item, _ := newItem(i.entry(""))
+	return item
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRename(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []Cache{NewMemoryCache(0), NewShardedCache(4, 0)} {
+		requireNoError(t, c.PushBack("list", "a"), "PushBack() failed")
+		requireNoError(t, c.SetTTL("list", time.Hour), "SetTTL() failed")
+		requireNoError(t, c.Set("str", "v"), "Set() failed")
+
+		requireNoError(t, c.Rename("list", "renamed", false), "Rename() failed")
+		require(t, !c.Exists("list"), "source still exists after Rename")
+		values, err := c.ListRange("renamed", 0, -1)
+		requireNoError(t, err, "ListRange() failed: %v", err)
+		require(t, slices.Equal(values, []string{"a"}), "renamed = %v, want [a]", values)
+		ttl, _ := c.GetTTL("renamed")
+		require(t, ttl > 59*time.Minute, "renamed TTL = %v, want about an hour", ttl)
+
+		err = c.Rename("renamed", "str", false)
+		require(t, errors.Is(err, ErrKeyExists), "Rename() error = %v, want ErrKeyExists", err)
+		err = c.Rename("renamed", "renamed", false)
+		require(t, errors.Is(err, ErrKeyExists), "Rename() to itself error = %v, want ErrKeyExists", err)
+		requireNoError(t, c.Rename("renamed", "renamed", true), "Rename() to itself failed")
+
+		// Replacing changes the type of the destination.
+		requireNoError(t, c.Rename("renamed", "str", true), "Rename() failed")
+		dataType, _ := c.Type("str")
+		require(t, dataType == ListType, "Type(str) = %v, want list", dataType)
+
+		err = c.Rename("missing", "other", true)
+		require(t, errors.Is(err, ErrKeyNotFound), "Rename() error = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []Cache{NewMemoryCache(0), NewShardedCache(4, 0)} {
+		for _, v := range []string{"a", "b"} {
+			requireNoError(t, c.PushBack("list", v), "PushBack() failed")
+		}
+		requireNoError(t, c.SetTTL("list", time.Hour), "SetTTL() failed")
+		_, err := c.HSet("hash", map[string]string{"f": "1"})
+		requireNoError(t, err, "HSet() failed: %v", err)
+
+		requireNoError(t, c.Copy("list", "copy", false), "Copy() failed")
+		ttl, _ := c.GetTTL("copy")
+		require(t, ttl > 59*time.Minute, "copy TTL = %v, want about an hour", ttl)
+
+		// The copy is independent of the source.
+		requireNoError(t, c.PushBack("copy", "c"), "PushBack() failed")
+		c.PopFront("list")
+		values, _ := c.ListRange("list", 0, -1)
+		require(t, slices.Equal(values, []string{"b"}), "list = %v, want [b]", values)
+		values, _ = c.ListRange("copy", 0, -1)
+		require(t, slices.Equal(values, []string{"a", "b", "c"}), "copy = %v, want [a b c]", values)
+
+		err = c.Copy("hash", "copy", false)
+		require(t, errors.Is(err, ErrKeyExists), "Copy() error = %v, want ErrKeyExists", err)
+		requireNoError(t, c.Copy("hash", "copy", true), "Copy() failed")
+		fields, _ := c.HGetAll("copy")
+		require(t, fields["f"] == "1", "copy = %v, want the hash", fields)
+		ttl, _ = c.GetTTL("copy")
+		require(t, ttl == -1, "copy TTL = %v, want none", ttl)
+
+		err = c.Copy("missing", "other", true)
+		require(t, errors.Is(err, ErrKeyNotFound), "Copy() error = %v, want ErrKeyNotFound", err)
+		err = c.Copy("hash", "hash", true)
+		require(t, errors.Is(err, ErrSameKey), "Copy() error = %v, want ErrSameKey", err)
+	}
+}
+
+func TestRename_Size(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+	requireNoError(t, c.Set("a", "value"), "Set() failed")
+	requireNoError(t, c.Copy("a", "b", false), "Copy() failed")
+	requireNoError(t, c.Rename("b", "a", true), "Rename() failed")
+	requireNoError(t, c.Rename("a", "c", true), "Rename() failed")
+	requireNoError(t, c.Remove("c"), "Remove() failed")
+	require(t, c.MemoryStats().UsedMemory == 0, "UsedMemory = %d after all keys were removed", c.MemoryStats().UsedMemory)
+}
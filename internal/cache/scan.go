@@ -0,0 +1,291 @@
+package cache
+
+import (
+	"context"
+	"hash/maphash"
+	"math/bits"
+	"slices"
+
+	"github.com/dsha256/gredis/internal/glob"
+)
+
+// DefaultScanCount is the number of keys a scan step examines when
+// ScanOptions.Count is not positive.
+const DefaultScanCount = 10
+
+// MaxScanCount is the largest number of keys a scan step examines; a
+// larger ScanOptions.Count is lowered to it.
+const MaxScanCount = 1 << 20
+
+// minScanBuckets is the smallest size of a scanIndex.
+const minScanBuckets = 16
+
+// ScanOptions filters the keys returned by Scan.
+type ScanOptions struct {
+	// Match is a glob pattern the keys must match; empty matches every key.
+	Match string
+	// Count is the number of keys to examine in one step, DefaultScanCount
+	// if zero or negative and at most MaxScanCount. It is a hint: a step
+	// may return more or fewer keys, as filtered keys are examined without
+	// being returned.
+	Count int
+	// Type is the name of the type of the keys, as returned by
+	// DataType.String; empty matches every type.
+	Type string
+}
+
+// count returns the number of keys a scan step examines for opts.
+func (opts ScanOptions) count() int {
+	if opts.Count <= 0 {
+		return DefaultScanCount
+	}
+	return min(opts.Count, MaxScanCount)
+}
+
+// scanIndex spreads the keys of a keyspace over a power of two number of
+// buckets, so that they can be scanned a few buckets at a time while the
+// keyspace changes in between.
+//
+// As in Redis, the cursor of a scan is the next bucket to visit with its
+// bits reversed: incrementing the reversed cursor visits the buckets so
+// that those already visited map to buckets already visited after the
+// index grows or shrinks. A scan thus returns every key present for its
+// whole duration at least once, and possibly some keys more than once.
+type scanIndex struct {
+	seed    maphash.Seed
+	buckets [][]string
+	count   int
+}
+
+// newScanIndex creates an empty index.
+func newScanIndex() *scanIndex {
+	return &scanIndex{
+		seed:    maphash.MakeSeed(),
+		buckets: make([][]string, minScanBuckets),
+	}
+}
+
+// bucket returns the bucket key belongs to.
+func (x *scanIndex) bucket(key string) int {
+	return int(maphash.String(x.seed, key) & uint64(len(x.buckets)-1))
+}
+
+// add indexes key, which must not be indexed yet.
+func (x *scanIndex) add(key string) {
+	i := x.bucket(key)
+	x.buckets[i] = append(x.buckets[i], key)
+	x.count++
+	if x.count > len(x.buckets) {
+		x.resize(len(x.buckets) * 2)
+	}
+}
+
+// remove removes key from the index, if present.
+func (x *scanIndex) remove(key string) {
+	i := x.bucket(key)
+	b := x.buckets[i]
+	j := slices.Index(b, key)
+	if j < 0 {
+		return
+	}
+	b[j] = b[len(b)-1]
+	b[len(b)-1] = ""
+	x.buckets[i] = b[:len(b)-1]
+	x.count--
+	if len(x.buckets) > minScanBuckets && x.count < len(x.buckets)/8 {
+		x.resize(len(x.buckets) / 2)
+	}
+}
+
+// resize redistributes the keys over n buckets.
+func (x *scanIndex) resize(n int) {
+	old := x.buckets
+	x.buckets = make([][]string, n)
+	for _, b := range old {
+		for _, key := range b {
+			i := x.bucket(key)
+			x.buckets[i] = append(x.buckets[i], key)
+		}
+	}
+}
+
+// visit calls fn with the keys of the bucket cursor designates and returns
+// the cursor of the next bucket, or 0 once every bucket was visited.
+func (x *scanIndex) visit(cursor uint64, fn func(key string)) uint64 {
+	mask := uint64(len(x.buckets) - 1)
+	for _, key := range x.buckets[cursor&mask] {
+		fn(key)
+	}
+
+	// Increment the reversed cursor, setting the bits above the mask
+	// first so that the increment carries into the bucket bits.
+	cursor |= ^mask
+	cursor = bits.Reverse64(cursor)
+	cursor++
+	return bits.Reverse64(cursor)
+}
+
+// Scan returns some of the keys matching opts, starting at cursor, and the
+// cursor to pass to the next call. A scan starts with cursor 0 and is
+// complete once the returned cursor is 0 again. Every key present for the
+// whole scan is returned at least once; keys added or removed during the
+// scan may or may not be returned, and a key may be returned more than
+// once. The cache is only locked for the duration of each call.
+func (c *MemoryCache) Scan(cursor uint64, opts ScanOptions) ([]string, uint64) {
+	keys, next, _ := c.ScanContext(context.Background(), cursor, opts)
+	return keys, next
+}
+
+// ScanContext is Scan, stopping with the error of ctx once it is done.
+func (c *MemoryCache) ScanContext(ctx context.Context, cursor uint64, opts ScanOptions) ([]string, uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	count := opts.count()
+	keys := []string{}
+	examined, checked := 0, 0
+	// Long runs of empty buckets are bounded too.
+	for steps := 0; steps < count*10; steps++ {
+		cursor = c.scan.visit(cursor, func(key string) {
+			examined++
+			if c.matches(key, opts) {
+				keys = append(keys, key)
+			}
+		})
+		if examined-checked >= contextCheckInterval {
+			if err := ctx.Err(); err != nil {
+				return nil, 0, err
+			}
+			checked = examined
+		}
+		if cursor == 0 || examined >= count {
+			break
+		}
+	}
+
+	return keys, cursor, nil
+}
+
+// Keys returns every key matching the glob pattern, in sorted order; an
+// empty pattern matches every key. It locks the cache while going through
+// all the keys, so Scan is preferable for large caches.
+func (c *MemoryCache) Keys(pattern string) []string {
+	keys, _ := c.KeysContext(context.Background(), pattern)
+	return keys
+}
+
+// KeysContext is Keys, stopping with the error of ctx once it is done.
+func (c *MemoryCache) KeysContext(ctx context.Context, pattern string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys, err := c.keys(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// keys returns every key matching the glob pattern, or the error of ctx
+// once it is done. The caller must hold at least the read lock.
+func (c *MemoryCache) keys(ctx context.Context, pattern string) ([]string, error) {
+	keys := []string{}
+	visited := 0
+	for key := range c.items {
+		if visited++; visited%contextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if c.matches(key, ScanOptions{Match: pattern}) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// matches reports whether key is live and matches opts. The caller must
+// hold at least the read lock.
+func (c *MemoryCache) matches(key string, opts ScanOptions) bool {
+	item, found := c.items[key]
+	if !found || c.expired(item) {
+		return false
+	}
+	if opts.Match != "" && !glob.Match(opts.Match, key) {
+		return false
+	}
+	return opts.Type == "" || item.dataType.String() == opts.Type
+}
+
+// Scan returns some of the keys matching opts, starting at cursor, and the
+// cursor to pass to the next call, with the guarantees of
+// MemoryCache.Scan. The shards are scanned one after the other; the low
+// bits of the cursor select the shard and the others the position in it.
+func (s *ShardedCache) Scan(cursor uint64, opts ScanOptions) ([]string, uint64) {
+	keys, next, _ := s.ScanContext(context.Background(), cursor, opts)
+	return keys, next
+}
+
+// ScanContext is Scan, stopping with the error of ctx once it is done.
+func (s *ShardedCache) ScanContext(ctx context.Context, cursor uint64, opts ScanOptions) ([]string, uint64, error) {
+	shardBits := bits.Len(uint(len(s.shards) - 1))
+	i := int(cursor & (1<<shardBits - 1))
+	cursor >>= shardBits
+
+	keys := []string{}
+	for i < len(s.shards) {
+		found, next, err := s.shards[i].ScanContext(ctx, cursor, opts)
+		if err != nil {
+			return nil, 0, err
+		}
+		keys, cursor = append(keys, found...), next
+		if cursor != 0 {
+			return keys, cursor<<shardBits | uint64(i), nil
+		}
+
+		// The next shard starts at position 0, so its cursor is its index.
+		i++
+		if len(keys) > 0 && i < len(s.shards) {
+			return keys, uint64(i), nil
+		}
+	}
+
+	return keys, 0, nil
+}
+
+// Keys returns every key matching the glob pattern, in sorted order; an
+// empty pattern matches every key. It locks every shard while going
+// through all the keys, so Scan is preferable for large caches.
+func (s *ShardedCache) Keys(pattern string) []string {
+	keys, _ := s.KeysContext(context.Background(), pattern)
+	return keys
+}
+
+// KeysContext is Keys, stopping with the error of ctx once it is done.
+func (s *ShardedCache) KeysContext(ctx context.Context, pattern string) ([]string, error) {
+	unlock, err := s.lockAllContext(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	keys := []string{}
+	for _, shard := range s.shards {
+		found, err := shard.keys(ctx, pattern)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, found...)
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
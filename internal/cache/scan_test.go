@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// scanAll runs a complete scan of c and returns the number of times each
+// key was returned. between is called after every step.
+func scanAll(t *testing.T, c Cache, opts ScanOptions, between func(step int)) map[string]int {
+	t.Helper()
+
+	seen := make(map[string]int)
+	var cursor uint64
+	for step := 0; ; step++ {
+		require(t, step < 100000, "scan did not complete")
+
+		var keys []string
+		keys, cursor = c.Scan(cursor, opts)
+		for _, key := range keys {
+			seen[key]++
+		}
+		if cursor == 0 {
+			return seen
+		}
+		if between != nil {
+			between(step)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	t.Parallel()
+
+	caches := []struct {
+		name string
+		c    Cache
+	}{
+		{"memory", NewMemoryCache(0)},
+		{"sharded", NewShardedCache(5, 0)},
+	}
+	for _, tt := range caches {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := tt.c
+			for i := range 500 {
+				requireNoError(t, c.Set(fmt.Sprint("string:", i), "v"), "Set() failed")
+			}
+			for i := range 100 {
+				requireNoError(t, c.PushBack(fmt.Sprint("list:", i), "v"), "PushBack() failed")
+			}
+			requireNoError(t, c.SetWithTTL("string:expired", "v", time.Millisecond), "SetWithTTL() failed")
+			time.Sleep(5 * time.Millisecond)
+
+			tests := []struct {
+				name string
+				opts ScanOptions
+				want int
+			}{
+				{name: "all", opts: ScanOptions{}, want: 600},
+				{name: "large count", opts: ScanOptions{Count: 1000}, want: 600},
+				{name: "max count", opts: ScanOptions{Count: math.MaxInt}, want: 600},
+				{name: "count 1", opts: ScanOptions{Count: 1}, want: 600},
+				{name: "match", opts: ScanOptions{Match: "string:1?"}, want: 10},
+				{name: "type", opts: ScanOptions{Type: "list"}, want: 100},
+				{name: "match and type", opts: ScanOptions{Match: "string:*", Type: "list"}, want: 0},
+				{name: "unknown type", opts: ScanOptions{Type: "stream"}, want: 0},
+			}
+			for _, tc := range tests {
+				seen := scanAll(t, c, tc.opts, nil)
+				require(t, len(seen) == tc.want, "%s: scan returned %d keys, want %d", tc.name, len(seen), tc.want)
+				for key, n := range seen {
+					require(t, n == 1, "%s: key %q returned %d times without concurrent writes", tc.name, key, n)
+				}
+				require(t, seen["string:expired"] == 0, "%s: expired key returned", tc.name)
+			}
+		})
+	}
+}
+
+func TestScan_ConcurrentWrites(t *testing.T) {
+	t.Parallel()
+
+	caches := []struct {
+		name string
+		c    Cache
+	}{
+		{"memory", NewMemoryCache(0)},
+		{"sharded", NewShardedCache(4, 0)},
+	}
+	for _, tt := range caches {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := tt.c
+			for i := range 200 {
+				requireNoError(t, c.Set(fmt.Sprint("stable:", i), "v"), "Set() failed")
+			}
+
+			// Keys are added, then removed, between the steps of the scan,
+			// growing and shrinking the index under it.
+			seen := scanAll(t, c, ScanOptions{Count: 5}, func(step int) {
+				if step < 100 {
+					for i := range 20 {
+						requireNoError(t, c.Set(fmt.Sprint("churn:", step, ":", i), "v"), "Set() failed")
+					}
+				} else if step < 200 {
+					for i := range 20 {
+						_ = c.Remove(fmt.Sprint("churn:", step-100, ":", i))
+					}
+				}
+			})
+
+			for i := range 200 {
+				key := fmt.Sprint("stable:", i)
+				require(t, seen[key] > 0, "key %q present during the whole scan was not returned", key)
+			}
+		})
+	}
+}
+
+func TestScanIndex_Resize(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+	for i := range 1000 {
+		requireNoError(t, c.Set(fmt.Sprint("key:", i), "v"), "Set() failed")
+	}
+	require(t, len(c.scan.buckets) >= 1000, "index has %d buckets for 1000 keys", len(c.scan.buckets))
+
+	for i := range 990 {
+		requireNoError(t, c.Remove(fmt.Sprint("key:", i)), "Remove() failed")
+	}
+	require(t, len(c.scan.buckets) <= 128, "index still has %d buckets for 10 keys", len(c.scan.buckets))
+	require(t, c.scan.count == 10, "index count = %d, want 10", c.scan.count)
+
+	requireNoError(t, c.Clear(), "Clear() failed")
+	seen := scanAll(t, c, ScanOptions{}, nil)
+	require(t, len(seen) == 0, "scan after Clear returned %v", seen)
+}
+
+func TestKeys(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []Cache{NewMemoryCache(0), NewShardedCache(4, 0)} {
+		for _, key := range []string{"user:2", "user:1", "order:1", "user:10"} {
+			requireNoError(t, c.Set(key, "v"), "Set() failed")
+		}
+
+		got := c.Keys("user:?")
+		want := []string{"user:1", "user:2"}
+		require(t, slices.Equal(got, want), "Keys(user:?) = %v, want %v", got, want)
+
+		got = c.Keys("")
+		want = []string{"order:1", "user:1", "user:10", "user:2"}
+		require(t, slices.Equal(got, want), "Keys() = %v, want %v", got, want)
+
+		got = c.Keys("missing*")
+		require(t, got != nil && len(got) == 0, "Keys(missing*) = %#v, want an empty slice", got)
+	}
+}
@@ -0,0 +1,315 @@
+package cache
+
+import (
+	"slices"
+)
+
+// memberSet is the value of a SetType item.
+type memberSet map[string]struct{}
+
+// members returns the members of s in sorted order.
+func (s memberSet) members() []string {
+	members := make([]string, 0, len(s))
+	for member := range s {
+		members = append(members, member)
+	}
+	slices.Sort(members)
+	return members
+}
+
+// SAdd adds members to the set stored at key, creating the set if needed.
+// It returns the number of members that were added.
+func (c *MemoryCache) SAdd(key string, members ...string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reserve(); err != nil {
+		return 0, err
+	}
+
+	item := c.lookupItem(key)
+	if item != nil && item.dataType != SetType {
+		return 0, ErrTypeMismatch
+	}
+	if len(members) == 0 {
+		return 0, nil
+	}
+
+	if item == nil {
+		item = &cacheItem{
+			dataType: SetType,
+			value:    make(memberSet, len(members)),
+		}
+		c.storeItem(key, item)
+	}
+
+	s := item.value.(memberSet)
+	added := 0
+	for _, member := range members {
+		if _, exists := s[member]; !exists {
+			s[member] = struct{}{}
+			c.resize(item, setMemberSize(member))
+			added++
+		}
+	}
+	if added > 0 {
+		c.notify(EventsSet, "sadd", key)
+	}
+
+	return added, nil
+}
+
+// SRem removes members from the set stored at key and returns the number of
+// members that were removed. The key is deleted once the set is empty.
+func (c *MemoryCache) SRem(key string, members ...string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := c.lookupItem(key)
+	if item == nil {
+		return 0, ErrKeyNotFound
+	}
+	if item.dataType != SetType {
+		return 0, ErrTypeMismatch
+	}
+
+	s := item.value.(memberSet)
+	removed := 0
+	for _, member := range members {
+		if _, exists := s[member]; exists {
+			delete(s, member)
+			c.resize(item, -setMemberSize(member))
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		c.notify(EventsSet, "srem", key)
+	}
+	if len(s) == 0 {
+		c.deleteItem(key)
+		c.notify(EventsGeneric, "del", key)
+	}
+
+	return removed, nil
+}
+
+// SIsMember reports whether member belongs to the set stored at key. A
+// missing key is treated as an empty set.
+func (c *MemoryCache) SIsMember(key string, member string) (bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s, err := c.readableSet(key)
+	if err != nil {
+		return false, err
+	}
+
+	_, exists := s[member]
+	return exists, nil
+}
+
+// SMembers returns the members of the set stored at key in sorted order.
+func (c *MemoryCache) SMembers(key string) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	item := c.peekItem(key)
+	if item == nil {
+		return nil, ErrKeyNotFound
+	}
+	if item.dataType != SetType {
+		return nil, ErrTypeMismatch
+	}
+
+	return item.value.(memberSet).members(), nil
+}
+
+// SCard returns the number of members of the set stored at key.
+func (c *MemoryCache) SCard(key string) (int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s, err := c.readableSet(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(s), nil
+}
+
+// SInter returns the members of the intersection of the sets stored at keys
+// in sorted order. Missing keys are treated as empty sets.
+func (c *MemoryCache) SInter(keys ...string) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result, err := c.combineSets(keys, intersect)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.members(), nil
+}
+
+// SUnion returns the members of the union of the sets stored at keys in
+// sorted order. Missing keys are treated as empty sets.
+func (c *MemoryCache) SUnion(keys ...string) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result, err := c.combineSets(keys, union)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.members(), nil
+}
+
+// SDiff returns the members of the first set that are not in any of the
+// following sets, in sorted order. Missing keys are treated as empty sets.
+func (c *MemoryCache) SDiff(keys ...string) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result, err := c.combineSets(keys, difference)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.members(), nil
+}
+
+// SInterStore stores the intersection of the sets stored at keys in
+// destination and returns its size.
+func (c *MemoryCache) SInterStore(destination string, keys ...string) (int, error) {
+	return c.storeSet("sinterstore", destination, keys, intersect)
+}
+
+// SUnionStore stores the union of the sets stored at keys in destination and
+// returns its size.
+func (c *MemoryCache) SUnionStore(destination string, keys ...string) (int, error) {
+	return c.storeSet("sunionstore", destination, keys, union)
+}
+
+// SDiffStore stores the difference of the sets stored at keys in destination
+// and returns its size.
+func (c *MemoryCache) SDiffStore(destination string, keys ...string) (int, error) {
+	return c.storeSet("sdiffstore", destination, keys, difference)
+}
+
+// storeSet combines the sets stored at keys and overwrites destination with
+// the result, removing any TTL. An empty result deletes destination. event
+// is published on success.
+func (c *MemoryCache) storeSet(event, destination string, keys []string, op func([]memberSet) memberSet) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reserve(); err != nil {
+		return 0, err
+	}
+
+	result, err := c.combineSets(keys, op)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(result) == 0 {
+		if _, found := c.items[destination]; found {
+			c.deleteItem(destination)
+			c.notify(EventsGeneric, "del", destination)
+		}
+		return 0, nil
+	}
+
+	c.storeItem(destination, &cacheItem{
+		dataType: SetType,
+		value:    result,
+	})
+	c.notify(EventsSet, event, destination)
+
+	return len(result), nil
+}
+
+// combineSets applies op to the sets stored at keys. The result never
+// aliases a stored set. The caller must hold at least the read lock.
+func (c *MemoryCache) combineSets(keys []string, op func([]memberSet) memberSet) (memberSet, error) {
+	sets := make([]memberSet, 0, len(keys))
+	for _, key := range keys {
+		s, err := c.readableSet(key)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, s)
+	}
+
+	return op(sets), nil
+}
+
+// readableSet returns the set stored at key, or nil if the key is missing.
+// The caller must hold at least the read lock.
+func (c *MemoryCache) readableSet(key string) (memberSet, error) {
+	item := c.peekItem(key)
+	if item == nil {
+		return nil, nil
+	}
+	if item.dataType != SetType {
+		return nil, ErrTypeMismatch
+	}
+
+	return item.value.(memberSet), nil
+}
+
+// intersect returns the members present in every set.
+func intersect(sets []memberSet) memberSet {
+	result := make(memberSet)
+	if len(sets) == 0 {
+		return result
+	}
+
+	// Iterate over the smallest set to minimise lookups.
+	smallest := slices.MinFunc(sets, func(a, b memberSet) int { return len(a) - len(b) })
+
+outer:
+	for member := range smallest {
+		for _, s := range sets {
+			if _, exists := s[member]; !exists {
+				continue outer
+			}
+		}
+		result[member] = struct{}{}
+	}
+
+	return result
+}
+
+// union returns the members present in any set.
+func union(sets []memberSet) memberSet {
+	result := make(memberSet)
+	for _, s := range sets {
+		for member := range s {
+			result[member] = struct{}{}
+		}
+	}
+	return result
+}
+
+// difference returns the members of the first set absent from the others.
+func difference(sets []memberSet) memberSet {
+	result := make(memberSet)
+	if len(sets) == 0 {
+		return result
+	}
+
+outer:
+	for member := range sets[0] {
+		for _, s := range sets[1:] {
+			if _, exists := s[member]; exists {
+				continue outer
+			}
+		}
+		result[member] = struct{}{}
+	}
+
+	return result
+}
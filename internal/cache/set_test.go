@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_Set(t *testing.T) {
+	t.Parallel()
+
+	setup := func(c *MemoryCache) {
+		_, err := c.SAdd("a", "1", "2", "3", "4")
+		requireNoError(t, err, "Setup failed: %v", err)
+		_, err = c.SAdd("b", "3", "4", "5")
+		requireNoError(t, err, "Setup failed: %v", err)
+		_, err = c.SAdd("c", "4", "6")
+		requireNoError(t, err, "Setup failed: %v", err)
+		err = c.Set("str", "value")
+		requireNoError(t, err, "Setup failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		operation func(c *MemoryCache) (any, error)
+		want      any
+		wantErr   error
+	}{
+		{
+			name: "SAdd skips existing members",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.SAdd("a", "1", "5", "5")
+			},
+			want: 1,
+		},
+		{
+			name: "SAdd on string key",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.SAdd("str", "1")
+			},
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name: "SRem removes members",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.SRem("a", "1", "9")
+			},
+			want: 1,
+		},
+		{
+			name: "SRem deletes empty set",
+			operation: func(c *MemoryCache) (any, error) {
+				if _, err := c.SRem("c", "4", "6"); err != nil {
+					return nil, err
+				}
+				return c.Exists("c"), nil
+			},
+			want: false,
+		},
+		{
+			name: "SIsMember on missing key",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.SIsMember("missing", "1")
+			},
+			want: false,
+		},
+		{
+			name: "SIsMember existing member",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.SIsMember("b", "5")
+			},
+			want: true,
+		},
+		{
+			name: "SCard",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.SCard("a")
+			},
+			want: 4,
+		},
+		{
+			name: "SMembers missing key",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.SMembers("missing")
+			},
+			wantErr: ErrKeyNotFound,
+		},
+		{
+			name: "SMembers sorted",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.SMembers("b")
+			},
+			want: []string{"3", "4", "5"},
+		},
+		{
+			name: "SInter",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.SInter("a", "b", "c")
+			},
+			want: []string{"4"},
+		},
+		{
+			name: "SInter with missing key",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.SInter("a", "missing")
+			},
+			want: []string{},
+		},
+		{
+			name: "SUnion",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.SUnion("b", "c", "missing")
+			},
+			want: []string{"3", "4", "5", "6"},
+		},
+		{
+			name: "SDiff",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.SDiff("a", "b")
+			},
+			want: []string{"1", "2"},
+		},
+		{
+			name: "SDiff with wrong type",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.SDiff("a", "str")
+			},
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name: "SInterStore overwrites destination and TTL",
+			operation: func(c *MemoryCache) (any, error) {
+				if err := c.SetTTL("str", time.Minute); err != nil {
+					return nil, err
+				}
+				if _, err := c.SInterStore("str", "a", "b"); err != nil {
+					return nil, err
+				}
+				ttl, _ := c.GetTTL("str")
+				members, err := c.SMembers("str")
+				return []any{members, ttl}, err
+			},
+			want: []any{[]string{"3", "4"}, time.Duration(-1)},
+		},
+		{
+			name: "SUnionStore into source key",
+			operation: func(c *MemoryCache) (any, error) {
+				if _, err := c.SUnionStore("c", "c", "b"); err != nil {
+					return nil, err
+				}
+				return c.SMembers("c")
+			},
+			want: []string{"3", "4", "5", "6"},
+		},
+		{
+			name: "SDiffStore with empty result deletes destination",
+			operation: func(c *MemoryCache) (any, error) {
+				n, err := c.SDiffStore("b", "c", "a", "c")
+				return []any{n, c.Exists("b")}, err
+			},
+			want: []any{0, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMemoryCache(100 * time.Millisecond)
+			defer c.Stop()
+
+			setup(c)
+
+			got, err := tt.operation(c)
+			require(t, errors.Is(err, tt.wantErr), "error = %v, want %v", err, tt.wantErr)
+			if tt.wantErr == nil {
+				require(t, equal(got, tt.want), "got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,669 @@
+package cache
+
+import (
+	"context"
+	"hash/maphash"
+	"slices"
+	"time"
+
+	"github.com/dsha256/gredis/internal/pubsub"
+)
+
+// DefaultShardCount is the number of shards used when none is configured.
+const DefaultShardCount = 16
+
+// ShardedCache implements the Cache interface by spreading keys over
+// independent MemoryCache shards chosen by key hash, so that operations on
+// different keys rarely contend for the same lock.
+//
+// Single-key operations only lock the shard of their key. Multi-key
+// operations, Clear, Dump and Restore lock every shard they touch, always
+// in shard order, so they see and leave a consistent state.
+type ShardedCache struct {
+	shards []*MemoryCache
+	seed   maphash.Seed
+	// broker delivers published messages for the whole cache.
+	broker *pubsub.Broker
+	// For TTL cleanup
+	cleanupInterval time.Duration
+	stopCleanup     chan struct{}
+}
+
+// NewShardedCache creates a cache split into shardCount shards, or
+// DefaultShardCount if shardCount is not positive. The options apply to
+// every shard; a memory limit set with WithMaxMemory is shared equally
+// between them.
+func NewShardedCache(shardCount int, cleanupInterval time.Duration, opts ...Option) *ShardedCache {
+	if shardCount <= 0 {
+		shardCount = DefaultShardCount
+	}
+
+	s := &ShardedCache{
+		shards:          make([]*MemoryCache, shardCount),
+		seed:            maphash.MakeSeed(),
+		broker:          pubsub.NewBroker(),
+		cleanupInterval: cleanupInterval,
+		stopCleanup:     make(chan struct{}),
+	}
+	for i := range s.shards {
+		// The shards are cleaned up together, see startCleanup.
+		shard := NewMemoryCache(0, opts...)
+		// Messages published by the shards, including keyspace
+		// notifications, reach the subscribers of the cache.
+		shard.broker = s.broker
+		if shard.maxMemory > 0 {
+			shard.maxMemory = max(shard.maxMemory/int64(shardCount), 1)
+		}
+		s.shards[i] = shard
+	}
+
+	if cleanupInterval > 0 {
+		go s.startCleanup()
+	}
+
+	return s
+}
+
+// startCleanup periodically removes expired items, one shard at a time.
+func (s *ShardedCache) startCleanup() {
+	ticker := time.NewTicker(s.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, shard := range s.shards {
+				shard.cleanup()
+			}
+		case <-s.stopCleanup:
+			return
+		}
+	}
+}
+
+// Stop stops the cleanup goroutine
+func (s *ShardedCache) Stop() {
+	if s.cleanupInterval > 0 {
+		s.stopCleanup <- struct{}{}
+	}
+}
+
+// shardIndex returns the index of the shard holding key.
+func (s *ShardedCache) shardIndex(key string) int {
+	return int(maphash.String(s.seed, key) % uint64(len(s.shards)))
+}
+
+// ShardCount returns the number of shards of the cache.
+func (s *ShardedCache) ShardCount() int {
+	return len(s.shards)
+}
+
+// ShardIndex returns the index of the shard holding key.
+func (s *ShardedCache) ShardIndex(key string) int {
+	return s.shardIndex(key)
+}
+
+// shard returns the shard holding key.
+func (s *ShardedCache) shard(key string) *MemoryCache {
+	return s.shards[s.shardIndex(key)]
+}
+
+// lockShards locks the shards holding keys, in shard order, for reading or
+// writing, and returns a function that unlocks them.
+func (s *ShardedCache) lockShards(keys []string, write bool) (unlock func()) {
+	indexes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		indexes = append(indexes, s.shardIndex(key))
+	}
+	slices.Sort(indexes)
+
+	return s.lockIndexes(slices.Compact(indexes), write)
+}
+
+// lockAll locks every shard, in shard order, and returns a function that
+// unlocks them.
+func (s *ShardedCache) lockAll(write bool) (unlock func()) {
+	indexes := make([]int, len(s.shards))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	return s.lockIndexes(indexes, write)
+}
+
+// lockAllContext locks every shard like lockAll, but gives up with the
+// error of ctx, leaving every shard unlocked, if ctx is done before they
+// are all locked.
+func (s *ShardedCache) lockAllContext(ctx context.Context, write bool) (unlock func(), err error) {
+	locked := make([]int, 0, len(s.shards))
+	for i := range s.shards {
+		if err := ctx.Err(); err != nil {
+			s.unlockIndexes(locked, write)
+			return nil, err
+		}
+		s.lockIndexes([]int{i}, write)
+		locked = append(locked, i)
+	}
+	if err := ctx.Err(); err != nil {
+		s.unlockIndexes(locked, write)
+		return nil, err
+	}
+
+	return func() { s.unlockIndexes(locked, write) }, nil
+}
+
+// lockIndexes locks the shards at the sorted indexes.
+func (s *ShardedCache) lockIndexes(indexes []int, write bool) (unlock func()) {
+	for _, i := range indexes {
+		if write {
+			s.shards[i].mu.Lock()
+		} else {
+			s.shards[i].mu.RLock()
+		}
+	}
+
+	return func() { s.unlockIndexes(indexes, write) }
+}
+
+// unlockIndexes unlocks the shards at the sorted indexes, in reverse order.
+func (s *ShardedCache) unlockIndexes(indexes []int, write bool) {
+	for _, i := range slices.Backward(indexes) {
+		if write {
+			s.shards[i].mu.Unlock()
+		} else {
+			s.shards[i].mu.RUnlock()
+		}
+	}
+}
+
+// String operations.
+
+// Get retrieves a string value from the cache
+func (s *ShardedCache) Get(key string) (string, bool) {
+	return s.shard(key).Get(key)
+}
+
+// Set stores a string value in the cache
+func (s *ShardedCache) Set(key string, value string) error {
+	return s.shard(key).Set(key, value)
+}
+
+// SetWithTTL stores a string value in the cache with a TTL
+func (s *ShardedCache) SetWithTTL(key string, value string, ttl time.Duration) error {
+	return s.shard(key).SetWithTTL(key, value, ttl)
+}
+
+// SetWithOptions stores a string value under the conditions given by opts.
+func (s *ShardedCache) SetWithOptions(key string, value string, opts SetOptions) (SetResult, error) {
+	return s.shard(key).SetWithOptions(key, value, opts)
+}
+
+// Update updates an existing string value in the cache
+func (s *ShardedCache) Update(key string, value string) error {
+	return s.shard(key).Update(key, value)
+}
+
+// Incr increments the integer stored at key by one.
+func (s *ShardedCache) Incr(key string) (int64, error) {
+	return s.shard(key).Incr(key)
+}
+
+// IncrBy increments the integer stored at key.
+func (s *ShardedCache) IncrBy(key string, increment int64) (int64, error) {
+	return s.shard(key).IncrBy(key, increment)
+}
+
+// DecrBy decrements the integer stored at key.
+func (s *ShardedCache) DecrBy(key string, decrement int64) (int64, error) {
+	return s.shard(key).DecrBy(key, decrement)
+}
+
+// IncrByFloat increments the number stored at key.
+func (s *ShardedCache) IncrByFloat(key string, increment float64) (float64, error) {
+	return s.shard(key).IncrByFloat(key, increment)
+}
+
+// List operations.
+
+// PushFront adds a value to the front of a list.
+func (s *ShardedCache) PushFront(key string, value string) error {
+	return s.shard(key).PushFront(key, value)
+}
+
+// PushBack adds a value to the back of a list.
+func (s *ShardedCache) PushBack(key string, value string) error {
+	return s.shard(key).PushBack(key, value)
+}
+
+// ListPush adds values to an end of the list stored at key.
+func (s *ShardedCache) ListPush(key string, end ListEnd, values ...string) (int, error) {
+	return s.shard(key).ListPush(key, end, values...)
+}
+
+// PopFront removes and returns the first element of a list.
+func (s *ShardedCache) PopFront(key string) (string, bool) {
+	return s.shard(key).PopFront(key)
+}
+
+// PopBack removes and returns the last element of a list.
+func (s *ShardedCache) PopBack(key string) (string, bool) {
+	return s.shard(key).PopBack(key)
+}
+
+// ListRange returns a range of elements from a list.
+func (s *ShardedCache) ListRange(key string, start, end int) ([]string, error) {
+	return s.shard(key).ListRange(key, start, end)
+}
+
+// ListLen returns the number of elements of the list stored at key.
+func (s *ShardedCache) ListLen(key string) (int, error) {
+	return s.shard(key).ListLen(key)
+}
+
+// ListIndex returns the element at index in the list stored at key.
+func (s *ShardedCache) ListIndex(key string, index int) (string, error) {
+	return s.shard(key).ListIndex(key, index)
+}
+
+// ListSet replaces the element at index in the list stored at key.
+func (s *ShardedCache) ListSet(key string, index int, value string) error {
+	return s.shard(key).ListSet(key, index, value)
+}
+
+// ListInsert inserts value before or after pivot in the list stored at key.
+func (s *ShardedCache) ListInsert(key string, before bool, pivot, value string) (int, error) {
+	return s.shard(key).ListInsert(key, before, pivot, value)
+}
+
+// ListRemove removes occurrences of value from the list stored at key.
+func (s *ShardedCache) ListRemove(key string, count int, value string) (int, error) {
+	return s.shard(key).ListRemove(key, count, value)
+}
+
+// ListTrim keeps only a range of elements of the list stored at key.
+func (s *ShardedCache) ListTrim(key string, start, end int) error {
+	return s.shard(key).ListTrim(key, start, end)
+}
+
+// ListPos returns the indexes of the elements equal to value in the list
+// stored at key.
+func (s *ShardedCache) ListPos(key string, value string, opts ListPosOptions) ([]int, error) {
+	return s.shard(key).ListPos(key, value, opts)
+}
+
+// Hash operations.
+
+// HSet sets fields in the hash stored at key.
+func (s *ShardedCache) HSet(key string, fields map[string]string) (int, error) {
+	return s.shard(key).HSet(key, fields)
+}
+
+// HGet returns the value of a field in the hash stored at key.
+func (s *ShardedCache) HGet(key string, field string) (string, error) {
+	return s.shard(key).HGet(key, field)
+}
+
+// HDel removes fields from the hash stored at key.
+func (s *ShardedCache) HDel(key string, fields ...string) (int, error) {
+	return s.shard(key).HDel(key, fields...)
+}
+
+// HGetAll returns a copy of all fields and values of the hash stored at key.
+func (s *ShardedCache) HGetAll(key string) (map[string]string, error) {
+	return s.shard(key).HGetAll(key)
+}
+
+// HIncrBy increments the integer value of a field in the hash stored at key.
+func (s *ShardedCache) HIncrBy(key string, field string, increment int64) (int64, error) {
+	return s.shard(key).HIncrBy(key, field, increment)
+}
+
+// Set operations.
+
+// SAdd adds members to the set stored at key.
+func (s *ShardedCache) SAdd(key string, members ...string) (int, error) {
+	return s.shard(key).SAdd(key, members...)
+}
+
+// SRem removes members from the set stored at key.
+func (s *ShardedCache) SRem(key string, members ...string) (int, error) {
+	return s.shard(key).SRem(key, members...)
+}
+
+// SIsMember reports whether member belongs to the set stored at key.
+func (s *ShardedCache) SIsMember(key string, member string) (bool, error) {
+	return s.shard(key).SIsMember(key, member)
+}
+
+// SMembers returns the members of the set stored at key in sorted order.
+func (s *ShardedCache) SMembers(key string) ([]string, error) {
+	return s.shard(key).SMembers(key)
+}
+
+// SCard returns the number of members of the set stored at key.
+func (s *ShardedCache) SCard(key string) (int, error) {
+	return s.shard(key).SCard(key)
+}
+
+// SInter returns the members of the intersection of the sets stored at keys
+// in sorted order.
+func (s *ShardedCache) SInter(keys ...string) ([]string, error) {
+	return s.combine(keys, intersect)
+}
+
+// SUnion returns the members of the union of the sets stored at keys in
+// sorted order.
+func (s *ShardedCache) SUnion(keys ...string) ([]string, error) {
+	return s.combine(keys, union)
+}
+
+// SDiff returns the members of the first set that are not in any of the
+// following sets, in sorted order.
+func (s *ShardedCache) SDiff(keys ...string) ([]string, error) {
+	return s.combine(keys, difference)
+}
+
+// SInterStore stores the intersection of the sets stored at keys in
+// destination and returns its size.
+func (s *ShardedCache) SInterStore(destination string, keys ...string) (int, error) {
+	return s.storeSet("sinterstore", destination, keys, intersect)
+}
+
+// SUnionStore stores the union of the sets stored at keys in destination and
+// returns its size.
+func (s *ShardedCache) SUnionStore(destination string, keys ...string) (int, error) {
+	return s.storeSet("sunionstore", destination, keys, union)
+}
+
+// SDiffStore stores the difference of the sets stored at keys in destination
+// and returns its size.
+func (s *ShardedCache) SDiffStore(destination string, keys ...string) (int, error) {
+	return s.storeSet("sdiffstore", destination, keys, difference)
+}
+
+// combine applies op to the sets stored at keys and returns the members of
+// the result in sorted order.
+func (s *ShardedCache) combine(keys []string, op func([]memberSet) memberSet) ([]string, error) {
+	unlock := s.lockShards(keys, false)
+	defer unlock()
+
+	result, err := s.combineSets(keys, op)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.members(), nil
+}
+
+// storeSet combines the sets stored at keys and overwrites destination with
+// the result, removing any TTL. An empty result deletes destination. event
+// is published on success.
+func (s *ShardedCache) storeSet(event, destination string, keys []string, op func([]memberSet) memberSet) (int, error) {
+	unlock := s.lockShards(append([]string{destination}, keys...), true)
+	defer unlock()
+
+	dst := s.shard(destination)
+	if err := dst.reserve(); err != nil {
+		return 0, err
+	}
+
+	result, err := s.combineSets(keys, op)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(result) == 0 {
+		if _, found := dst.items[destination]; found {
+			dst.deleteItem(destination)
+			dst.notify(EventsGeneric, "del", destination)
+		}
+		return 0, nil
+	}
+
+	dst.storeItem(destination, &cacheItem{
+		dataType: SetType,
+		value:    result,
+	})
+	dst.notify(EventsSet, event, destination)
+
+	return len(result), nil
+}
+
+// combineSets applies op to the sets stored at keys. The caller must hold at
+// least the read lock of their shards.
+func (s *ShardedCache) combineSets(keys []string, op func([]memberSet) memberSet) (memberSet, error) {
+	sets := make([]memberSet, 0, len(keys))
+	for _, key := range keys {
+		set, err := s.shard(key).readableSet(key)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+
+	return op(sets), nil
+}
+
+// Sorted set operations.
+
+// ZAdd adds members to the sorted set stored at key, or updates their
+// scores, according to opts.
+func (s *ShardedCache) ZAdd(key string, opts ZAddOptions, members ...Z) (int, error) {
+	return s.shard(key).ZAdd(key, opts, members...)
+}
+
+// ZIncrBy increments the score of member in the sorted set stored at key.
+func (s *ShardedCache) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	return s.shard(key).ZIncrBy(key, increment, member)
+}
+
+// ZScore returns the score of member in the sorted set stored at key.
+func (s *ShardedCache) ZScore(key string, member string) (float64, error) {
+	return s.shard(key).ZScore(key, member)
+}
+
+// ZRank returns the rank of member in the sorted set stored at key.
+func (s *ShardedCache) ZRank(key string, member string, rev bool) (int, error) {
+	return s.shard(key).ZRank(key, member, rev)
+}
+
+// ZRange returns the members of the sorted set stored at key between the
+// ranks start and stop.
+func (s *ShardedCache) ZRange(key string, start, stop int, rev bool) ([]Z, error) {
+	return s.shard(key).ZRange(key, start, stop, rev)
+}
+
+// ZRangeByScore returns the members of the sorted set stored at key with a
+// score between min and max.
+func (s *ShardedCache) ZRangeByScore(key string, min, max ScoreBound, opts ZRangeOptions) ([]Z, error) {
+	return s.shard(key).ZRangeByScore(key, min, max, opts)
+}
+
+// ZRangeByLex returns the members of the sorted set stored at key between
+// min and max in lexicographical order.
+func (s *ShardedCache) ZRangeByLex(key string, min, max LexBound, opts ZRangeOptions) ([]string, error) {
+	return s.shard(key).ZRangeByLex(key, min, max, opts)
+}
+
+// ZCount returns the number of members of the sorted set stored at key with
+// a score between min and max.
+func (s *ShardedCache) ZCount(key string, min, max ScoreBound) (int, error) {
+	return s.shard(key).ZCount(key, min, max)
+}
+
+// ZCard returns the number of members of the sorted set stored at key.
+func (s *ShardedCache) ZCard(key string) (int, error) {
+	return s.shard(key).ZCard(key)
+}
+
+// ZRem removes members from the sorted set stored at key.
+func (s *ShardedCache) ZRem(key string, members ...string) (int, error) {
+	return s.shard(key).ZRem(key, members...)
+}
+
+// ZPopMin removes and returns up to count members with the lowest scores
+// from the sorted set stored at key.
+func (s *ShardedCache) ZPopMin(key string, count int) ([]Z, error) {
+	return s.shard(key).ZPopMin(key, count)
+}
+
+// ZPopMax removes and returns up to count members with the highest scores
+// from the sorted set stored at key.
+func (s *ShardedCache) ZPopMax(key string, count int) ([]Z, error) {
+	return s.shard(key).ZPopMax(key, count)
+}
+
+// TTL operations.
+
+// SetTTL sets the TTL for a key.
+func (s *ShardedCache) SetTTL(key string, ttl time.Duration) error {
+	return s.shard(key).SetTTL(key, ttl)
+}
+
+// ExpireAt sets the absolute expiration time of a key.
+func (s *ShardedCache) ExpireAt(key string, at time.Time) error {
+	return s.shard(key).ExpireAt(key, at)
+}
+
+// GetTTL returns the remaining TTL for a key.
+func (s *ShardedCache) GetTTL(key string) (time.Duration, bool) {
+	return s.shard(key).GetTTL(key)
+}
+
+// RemoveTTL removes the TTL for a key.
+func (s *ShardedCache) RemoveTTL(key string) error {
+	return s.shard(key).RemoveTTL(key)
+}
+
+// General operations.
+
+// Remove removes a key from the cache.
+func (s *ShardedCache) Remove(key string) error {
+	return s.shard(key).Remove(key)
+}
+
+// Exists checks if a key exists in the cache.
+func (s *ShardedCache) Exists(key string) bool {
+	return s.shard(key).Exists(key)
+}
+
+// Type returns the type of a key.
+func (s *ShardedCache) Type(key string) (DataType, bool) {
+	return s.shard(key).Type(key)
+}
+
+// Clear removes all items from every shard at once.
+func (s *ShardedCache) Clear() error {
+	return s.ClearContext(context.Background())
+}
+
+// ClearContext is Clear, failing with the error of ctx, having removed
+// nothing, if ctx is done before every shard is locked.
+func (s *ShardedCache) ClearContext(ctx context.Context) error {
+	unlock, err := s.lockAllContext(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, shard := range s.shards {
+		shard.clear()
+	}
+	return nil
+}
+
+// Publish/subscribe operations.
+
+// Publish posts a message to a channel and returns the number of
+// subscribers that received it.
+func (s *ShardedCache) Publish(channel string, message string) int {
+	return s.broker.Publish(channel, message)
+}
+
+// Subscribe creates a subscription to the given channels. The caller must
+// close the subscription once it is no longer used.
+func (s *ShardedCache) Subscribe(channels ...string) *pubsub.Subscription {
+	return s.broker.Subscribe(channels...)
+}
+
+// PSubscribe creates a subscription to the channels matching the given glob
+// patterns. The caller must close the subscription once it is no longer
+// used.
+func (s *ShardedCache) PSubscribe(patterns ...string) *pubsub.Subscription {
+	return s.broker.PSubscribe(patterns...)
+}
+
+// Dump returns a copy of every live key in the cache, ordered by key. All
+// shards are dumped at the same point in time.
+func (s *ShardedCache) Dump() []Entry {
+	unlock := s.lockAll(false)
+	defer unlock()
+
+	var entries []Entry
+	for _, shard := range s.shards {
+		entries = shard.appendEntries(entries)
+	}
+	sortEntries(entries)
+
+	return entries
+}
+
+// Restore stores the given entries, replacing existing keys with the same
+// name. Entries that have already expired are skipped. No entry is stored
+// if any of them is invalid.
+func (s *ShardedCache) Restore(entries []Entry) error {
+	items, err := newItems(entries)
+	if err != nil {
+		return err
+	}
+
+	unlock := s.lockAll(true)
+	defer unlock()
+
+	for i, item := range items {
+		key := entries[i].Key
+		s.shard(key).restoreItem(key, item)
+	}
+
+	return nil
+}
+
+// BeginLoad suspends key expiration in every shard until EndLoad is called.
+func (s *ShardedCache) BeginLoad() {
+	for _, shard := range s.shards {
+		shard.BeginLoad()
+	}
+}
+
+// EndLoad resumes key expiration and removes the keys that expired while
+// the cache was loading.
+func (s *ShardedCache) EndLoad() {
+	for _, shard := range s.shards {
+		shard.EndLoad()
+	}
+}
+
+// MemoryStats returns the memory use and eviction counters of all shards
+// combined.
+func (s *ShardedCache) MemoryStats() MemoryStats {
+	var stats MemoryStats
+	for _, shard := range s.shards {
+		shardStats := shard.MemoryStats()
+		stats.UsedMemory += shardStats.UsedMemory
+		stats.MaxMemory += shardStats.MaxMemory
+		stats.Policy = shardStats.Policy
+		stats.Keys += shardStats.Keys
+		stats.EvictedKeys += shardStats.EvictedKeys
+		stats.RejectedWrites += shardStats.RejectedWrites
+	}
+	return stats
+}
+
+// OnEvict registers fn to be called with every key evicted from any shard.
+// It is called while the shard is locked, so it must not call back into the
+// cache.
+func (s *ShardedCache) OnEvict(fn func(key string)) {
+	for _, shard := range s.shards {
+		shard.OnEvict(fn)
+	}
+}
@@ -0,0 +1,225 @@
+package cache
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// keysOnShards returns n keys of the form prefix+i that are spread over at
+// least two shards of s.
+func keysOnShards(t *testing.T, s *ShardedCache, prefix string, n int) []string {
+	t.Helper()
+
+	keys := make([]string, n)
+	shards := make(map[int]bool)
+	for i := range keys {
+		keys[i] = prefix + strconv.Itoa(i)
+		shards[s.shardIndex(keys[i])] = true
+	}
+	require(t, len(shards) > 1, "keys %v all map to the same shard", keys)
+	return keys
+}
+
+func TestShardedCache_MultiKey(t *testing.T) {
+	t.Parallel()
+
+	s := NewShardedCache(8, 0)
+	keys := keysOnShards(t, s, "set", 4)
+	for i, key := range keys {
+		_, err := s.SAdd(key, "common", fmt.Sprint("only", i))
+		requireNoError(t, err, "SAdd() failed: %v", err)
+	}
+
+	inter, err := s.SInter(keys...)
+	requireNoError(t, err, "SInter() failed: %v", err)
+	require(t, slices.Equal(inter, []string{"common"}), "SInter() = %v", inter)
+
+	union, err := s.SUnion(keys...)
+	requireNoError(t, err, "SUnion() failed: %v", err)
+	require(t, len(union) == len(keys)+1, "SUnion() = %v", union)
+
+	diff, err := s.SDiff(keys...)
+	requireNoError(t, err, "SDiff() failed: %v", err)
+	require(t, slices.Equal(diff, []string{"only0"}), "SDiff() = %v", diff)
+
+	n, err := s.SUnionStore("destination", keys...)
+	requireNoError(t, err, "SUnionStore() failed: %v", err)
+	members, err := s.SMembers("destination")
+	requireNoError(t, err, "SMembers() failed: %v", err)
+	require(t, n == len(union) && slices.Equal(members, union), "SUnionStore() = %d, stored %v, want %v", n, members, union)
+
+	n, err = s.SInterStore("destination", keys[0], "missing")
+	requireNoError(t, err, "SInterStore() failed: %v", err)
+	require(t, n == 0 && !s.Exists("destination"), "SInterStore() with an empty result = %d, kept destination", n)
+
+	requireNoError(t, s.Set(keys[1], "string"), "Set() failed")
+	_, err = s.SUnion(keys...)
+	require(t, err == ErrTypeMismatch, "SUnion() error = %v, want ErrTypeMismatch", err)
+}
+
+func TestShardedCache_Keyspace(t *testing.T) {
+	t.Parallel()
+
+	s := NewShardedCache(4, 0)
+	keys := keysOnShards(t, s, "key", 20)
+	for _, key := range keys {
+		requireNoError(t, s.SetWithTTL(key, "value", time.Hour), "SetWithTTL() failed")
+	}
+	requireNoError(t, s.PushBack("list", "a"), "PushBack() failed")
+	_, err := s.ZAdd("zset", ZAddOptions{}, Z{Member: "a", Score: 1})
+	requireNoError(t, err, "ZAdd() failed: %v", err)
+
+	entries := s.Dump()
+	require(t, len(entries) == len(keys)+2, "Dump() returned %d entries, want %d", len(entries), len(keys)+2)
+	require(t, slices.IsSortedFunc(entries, func(a, b Entry) int { return strings.Compare(a.Key, b.Key) }), "Dump() is not sorted by key")
+
+	restored := NewShardedCache(3, 0)
+	requireNoError(t, restored.Restore(entries), "Restore() failed")
+	require(t, reflect.DeepEqual(restored.Dump(), entries), "Restore() did not reproduce the dump")
+
+	stats := s.MemoryStats()
+	require(t, stats.Keys == len(keys)+2 && stats.UsedMemory > 0, "MemoryStats() = %+v", stats)
+
+	requireNoError(t, s.Clear(), "Clear() failed")
+	require(t, len(s.Dump()) == 0, "Dump() after Clear() = %v", s.Dump())
+	require(t, s.MemoryStats().UsedMemory == 0, "used memory = %d after Clear()", s.MemoryStats().UsedMemory)
+}
+
+func TestShardedCache_MaxMemory(t *testing.T) {
+	t.Parallel()
+
+	s := NewShardedCache(4, 0, WithMaxMemory(4096, AllKeysLRU))
+	var mu sync.Mutex
+	evicted := 0
+	s.OnEvict(func(string) {
+		mu.Lock()
+		evicted++
+		mu.Unlock()
+	})
+
+	for i := range 1000 {
+		requireNoError(t, s.Set(strconv.Itoa(i), "value"), "Set() failed")
+	}
+
+	stats := s.MemoryStats()
+	require(t, stats.MaxMemory == 4096 && stats.Policy == AllKeysLRU, "MemoryStats() = %+v", stats)
+	require(t, stats.EvictedKeys > 0 && stats.EvictedKeys == int64(evicted), "EvictedKeys = %d, OnEvict calls = %d", stats.EvictedKeys, evicted)
+	require(t, stats.Keys == 1000-evicted, "Keys = %d, want %d", stats.Keys, 1000-evicted)
+	// Every shard may exceed its share by the size of its last write.
+	require(t, stats.UsedMemory <= 4096+4*itemSize("999", &cacheItem{value: "value"}), "UsedMemory = %d", stats.UsedMemory)
+}
+
+func TestShardedCache_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	s := NewShardedCache(8, time.Millisecond)
+	defer s.Stop()
+	keys := keysOnShards(t, s, "set", 8)
+
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				key := keys[(w+i)%len(keys)]
+				_, _ = s.SAdd(key, strconv.Itoa(i))
+				_ = s.SetWithTTL(fmt.Sprint("ttl", w, i), "value", time.Millisecond)
+				_, _ = s.SUnionStore(keys[w], keys...)
+				_, _ = s.SInter(keys...)
+				if i%50 == 0 {
+					_ = s.Clear()
+					_ = s.Dump()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// benchmarkKeys is the number of keys the benchmarks spread their load over.
+const benchmarkKeys = 10000
+
+// benchmarkCaches returns the caches compared by the benchmarks.
+func benchmarkCaches() []struct {
+	name  string
+	cache Cache
+} {
+	return []struct {
+		name  string
+		cache Cache
+	}{
+		{"MemoryCache", NewMemoryCache(0)},
+		{"ShardedCache/4", NewShardedCache(4, 0)},
+		{"ShardedCache/16", NewShardedCache(16, 0)},
+		{"ShardedCache/64", NewShardedCache(64, 0)},
+	}
+}
+
+// runParallelBenchmark runs op from GOMAXPROCS goroutines against each
+// cache, preloaded with benchmarkKeys string keys.
+func runParallelBenchmark(b *testing.B, op func(c Cache, key string, i int)) {
+	keys := make([]string, benchmarkKeys)
+	for i := range keys {
+		keys[i] = "key:" + strconv.Itoa(i)
+	}
+
+	for _, bc := range benchmarkCaches() {
+		b.Run(bc.name, func(b *testing.B) {
+			for _, key := range keys {
+				_ = bc.cache.Set(key, "value")
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					op(bc.cache, keys[i%len(keys)], i)
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkCache_Get(b *testing.B) {
+	runParallelBenchmark(b, func(c Cache, key string, _ int) {
+		c.Get(key)
+	})
+}
+
+func BenchmarkCache_Set(b *testing.B) {
+	runParallelBenchmark(b, func(c Cache, key string, _ int) {
+		_ = c.Set(key, "value")
+	})
+}
+
+// BenchmarkCache_Mixed issues one write for every nine reads.
+func BenchmarkCache_Mixed(b *testing.B) {
+	runParallelBenchmark(b, func(c Cache, key string, i int) {
+		if i%10 == 0 {
+			_ = c.Set(key, "value")
+		} else {
+			c.Get(key)
+		}
+	})
+}
+
+// BenchmarkCache_ExpiredGet reads keys that have expired, which MemoryCache
+// deletes under its write lock.
+func BenchmarkCache_ExpiredGet(b *testing.B) {
+	runParallelBenchmark(b, func(c Cache, key string, i int) {
+		if i%2 == 0 {
+			_ = c.SetWithTTL(key, "value", time.Nanosecond)
+		} else {
+			c.Get(key)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+// Approximate memory overheads, in bytes, of the structures holding the
+// data. They only need to be good enough to compare against a memory limit.
+const (
+	// itemOverhead covers the map entry, the cacheItem and the key header.
+	itemOverhead = 96
+	// stringOverhead covers a string header.
+	stringOverhead = 16
+	// elementOverhead covers a list element or a hash or set map entry.
+	elementOverhead = 64
+	// zsetMemberOverhead covers the map entry and skip list node of a
+	// sorted set member.
+	zsetMemberOverhead = 128
+)
+
+// stringSize returns the size of a string value.
+func stringSize(s string) int64 {
+	return stringOverhead + int64(len(s))
+}
+
+// listElementSize returns the size of a list element holding value.
+func listElementSize(value string) int64 {
+	return elementOverhead + stringSize(value)
+}
+
+// hashFieldSize returns the size of a hash field holding value.
+func hashFieldSize(field, value string) int64 {
+	return elementOverhead + stringSize(field) + stringSize(value)
+}
+
+// setMemberSize returns the size of a set member.
+func setMemberSize(member string) int64 {
+	return elementOverhead + stringSize(member)
+}
+
+// zsetMemberSize returns the size of a sorted set member.
+func zsetMemberSize(member string) int64 {
+	return zsetMemberOverhead + stringSize(member)
+}
+
+// itemSize returns the size of item stored under key. It walks the whole
+// value, so writes to existing collections adjust the size with resize
+// instead.
+func itemSize(key string, item *cacheItem) int64 {
+	size := itemOverhead + int64(len(key))
+
+	switch item.dataType {
+	case StringType:
+		size += stringSize(item.value.(string))
+	case ListType:
+		for e := item.value.(*list.List).Front(); e != nil; e = e.Next() {
+			size += listElementSize(e.Value.(string))
+		}
+	case HashType:
+		for field, value := range item.value.(map[string]string) {
+			size += hashFieldSize(field, value)
+		}
+	case SetType:
+		for member := range item.value.(memberSet) {
+			size += setMemberSize(member)
+		}
+	case SortedSetType:
+		for member := range item.value.(*sortedSet).scores {
+			size += zsetMemberSize(member)
+		}
+	}
+
+	return size
+}
+
+// storeItem stores item under key, replacing any existing item, and
+// accounts for its size. The caller must hold the write lock.
+func (c *MemoryCache) storeItem(key string, item *cacheItem) {
+	if old, found := c.items[key]; found {
+		c.used -= old.size
+		c.unindexExpiry(old)
+	} else {
+		c.scan.add(key)
+	}
+
+	item.size = itemSize(key, item)
+	c.modified(item)
+	item.lastAccess.Store(time.Now().UnixNano())
+	item.freq.Store(lfuInitValue)
+
+	c.used += item.size
+	c.items[key] = item
+	c.indexExpiry(key, item)
+}
+
+// deleteItem removes the item stored under key, if any. The caller must
+// hold the write lock.
+func (c *MemoryCache) deleteItem(key string) {
+	if item, found := c.items[key]; found {
+		c.used -= item.size
+		delete(c.items, key)
+		c.scan.remove(key)
+		c.unindexExpiry(item)
+		c.removed(key)
+	}
+}
+
+// resize adjusts the accounted size of a stored item by delta bytes after
+// its value was changed in place. The caller must hold the write lock.
+func (c *MemoryCache) resize(item *cacheItem, delta int64) {
+	item.size += delta
+	c.used += delta
+	c.modified(item)
+}
@@ -0,0 +1,292 @@
+package cache
+
+import (
+	"math/rand/v2"
+)
+
+const (
+	skipListMaxLevel = 32
+	skipListP        = 0.25
+)
+
+// skipListNode is an element of a skipList.
+type skipListNode struct {
+	member   string
+	score    float64
+	backward *skipListNode
+	levels   []skipListLevel
+}
+
+// skipListLevel is a forward link of a node together with the number of
+// nodes it skips, which is what makes rank lookups logarithmic.
+type skipListLevel struct {
+	forward *skipListNode
+	span    int
+}
+
+// before reports whether the node sorts strictly before (score, member).
+func (n *skipListNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+// notAfter reports whether the node sorts before or at (score, member).
+func (n *skipListNode) notAfter(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member <= member)
+}
+
+// skipList keeps members ordered by score and then lexicographically by
+// member, as Redis sorted sets do. Ranks are 1-based; rank 0 means "absent".
+type skipList struct {
+	header *skipListNode
+	tail   *skipListNode
+	length int
+	level  int
+}
+
+// newSkipList creates an empty skip list.
+func newSkipList() *skipList {
+	return &skipList{
+		header: &skipListNode{levels: make([]skipListLevel, skipListMaxLevel)},
+		level:  1,
+	}
+}
+
+// randomLevel returns a level for a new node with a power law distribution.
+func randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && rand.Float64() < skipListP {
+		level++
+	}
+	return level
+}
+
+// first returns the lowest ranked node, or nil if the list is empty.
+func (sl *skipList) first() *skipListNode {
+	return sl.header.levels[0].forward
+}
+
+// insert adds (score, member). The caller must ensure the member is absent.
+func (sl *skipList) insert(score float64, member string) *skipListNode {
+	var update [skipListMaxLevel]*skipListNode
+	var rank [skipListMaxLevel]int
+
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = sl.header
+			update[i].levels[i].span = sl.length
+		}
+		sl.level = level
+	}
+
+	x = &skipListNode{
+		member: member,
+		score:  score,
+		levels: make([]skipListLevel, level),
+	}
+	for i := range level {
+		x.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = x
+
+		x.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < sl.level; i++ {
+		update[i].levels[i].span++
+	}
+
+	if update[0] != sl.header {
+		x.backward = update[0]
+	}
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x
+	} else {
+		sl.tail = x
+	}
+	sl.length++
+
+	return x
+}
+
+// delete removes (score, member) and reports whether it was present.
+func (sl *skipList) delete(score float64, member string) bool {
+	var update [skipListMaxLevel]*skipListNode
+
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.levels[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+
+	for i := range sl.level {
+		if update[i].levels[i].forward == x {
+			update[i].levels[i].span += x.levels[i].span - 1
+			update[i].levels[i].forward = x.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x.backward
+	} else {
+		sl.tail = x.backward
+	}
+	for sl.level > 1 && sl.header.levels[sl.level-1].forward == nil {
+		sl.level--
+	}
+	sl.length--
+
+	return true
+}
+
+// rank returns the 1-based rank of (score, member), or 0 if it is absent.
+func (sl *skipList) rank(score float64, member string) int {
+	rank := 0
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.notAfter(score, member) {
+			rank += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if x != sl.header && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank returns the node with the given 1-based rank, or nil.
+func (sl *skipList) byRank(rank int) *skipListNode {
+	traversed := 0
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= rank {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if traversed == rank && x != sl.header {
+			return x
+		}
+	}
+	return nil
+}
+
+// firstInScoreRange returns the first node with a score within [min, max].
+func (sl *skipList) firstInScoreRange(min, max ScoreBound) *skipListNode {
+	if !sl.intersectsScoreRange(min, max) {
+		return nil
+	}
+
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !min.below(x.levels[i].forward.score) {
+			x = x.levels[i].forward
+		}
+	}
+
+	x = x.levels[0].forward
+	if x == nil || !max.above(x.score) {
+		return nil
+	}
+	return x
+}
+
+// lastInScoreRange returns the last node with a score within [min, max].
+func (sl *skipList) lastInScoreRange(min, max ScoreBound) *skipListNode {
+	if !sl.intersectsScoreRange(min, max) {
+		return nil
+	}
+
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && max.above(x.levels[i].forward.score) {
+			x = x.levels[i].forward
+		}
+	}
+
+	if x == sl.header || !min.below(x.score) {
+		return nil
+	}
+	return x
+}
+
+// intersectsScoreRange reports whether any node could be within [min, max].
+func (sl *skipList) intersectsScoreRange(min, max ScoreBound) bool {
+	if min.Score > max.Score || (min.Score == max.Score && (min.Exclusive || max.Exclusive)) {
+		return false
+	}
+	if sl.tail == nil || !min.below(sl.tail.score) {
+		return false
+	}
+	return max.above(sl.first().score)
+}
+
+// firstInLexRange returns the first node with a member within [min, max].
+func (sl *skipList) firstInLexRange(min, max LexBound) *skipListNode {
+	if !sl.intersectsLexRange(min, max) {
+		return nil
+	}
+
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !min.below(x.levels[i].forward.member) {
+			x = x.levels[i].forward
+		}
+	}
+
+	x = x.levels[0].forward
+	if x == nil || !max.above(x.member) {
+		return nil
+	}
+	return x
+}
+
+// lastInLexRange returns the last node with a member within [min, max].
+func (sl *skipList) lastInLexRange(min, max LexBound) *skipListNode {
+	if !sl.intersectsLexRange(min, max) {
+		return nil
+	}
+
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && max.above(x.levels[i].forward.member) {
+			x = x.levels[i].forward
+		}
+	}
+
+	if x == sl.header || !min.below(x.member) {
+		return nil
+	}
+	return x
+}
+
+// intersectsLexRange reports whether any node could be within [min, max].
+func (sl *skipList) intersectsLexRange(min, max LexBound) bool {
+	if min.emptyWith(max) {
+		return false
+	}
+	if sl.tail == nil || !min.below(sl.tail.member) {
+		return false
+	}
+	return max.above(sl.first().member)
+}
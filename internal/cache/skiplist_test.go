@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestSkipList(t *testing.T) {
+	t.Parallel()
+
+	sl := newSkipList()
+	scores := make(map[string]float64)
+
+	// Apply random inserts, updates and deletes, mirroring them in a map.
+	for i := range 2000 {
+		member := strconv.Itoa(rand.IntN(300))
+		score := float64(rand.IntN(50))
+
+		if current, exists := scores[member]; exists {
+			require(t, sl.delete(current, member), "delete(%v, %q) = false on iteration %d", current, member, i)
+			delete(scores, member)
+			if rand.IntN(2) == 0 {
+				continue
+			}
+		}
+		sl.insert(score, member)
+		scores[member] = score
+	}
+
+	want := make([]Z, 0, len(scores))
+	for member, score := range scores {
+		want = append(want, Z{Member: member, Score: score})
+	}
+	slices.SortFunc(want, func(a, b Z) int {
+		return cmp.Or(cmp.Compare(a.Score, b.Score), cmp.Compare(a.Member, b.Member))
+	})
+
+	require(t, sl.length == len(want), "length = %d, want %d", sl.length, len(want))
+
+	var prev *skipListNode
+	x := sl.first()
+	for i, z := range want {
+		require(t, x != nil && x.member == z.Member && x.score == z.Score, "node %d = %v, want %v", i, x, z)
+		require(t, x.backward == prev, "node %d has a wrong backward link", i)
+		require(t, sl.rank(z.Score, z.Member) == i+1, "rank(%v) = %d, want %d", z, sl.rank(z.Score, z.Member), i+1)
+		require(t, sl.byRank(i+1) == x, "byRank(%d) returned the wrong node", i+1)
+		prev, x = x, x.levels[0].forward
+	}
+	require(t, x == nil && sl.tail == prev, "list is not terminated at the tail")
+
+	require(t, !sl.delete(-1, "missing"), "delete() of a missing member = true")
+	require(t, sl.rank(-1, "missing") == 0, "rank() of a missing member != 0")
+	require(t, sl.byRank(len(want)+1) == nil, "byRank() past the end != nil")
+}
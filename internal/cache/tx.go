@@ -0,0 +1,224 @@
+package cache
+
+import "hash/maphash"
+
+// rwLocker is the lock of a MemoryCache.
+type rwLocker interface {
+	Lock()
+	Unlock()
+	RLock()
+	RUnlock()
+}
+
+// noLock is the lock of the view of a cache a transaction runs against. The
+// transaction holds the real lock for as long as the view is used.
+type noLock struct{}
+
+func (noLock) Lock()    {}
+func (noLock) Unlock()  {}
+func (noLock) RLock()   {}
+func (noLock) RUnlock() {}
+
+// TxResult is the outcome of a single operation of a transaction.
+type TxResult struct {
+	Value any
+	Err   error
+}
+
+// Tx queues operations on a cache and applies them together with Exec, so
+// that no other operation on the cache is applied in between. As in Redis,
+// an operation that fails does not roll back the others.
+//
+// Keys can be watched for optimistic locking: Exec then applies nothing if
+// one of them was written, removed or expired since it was watched.
+type Tx struct {
+	c       Transactor
+	ops     []func(c Cache) (any, error)
+	watched map[string]uint64
+}
+
+// NewTx creates an empty transaction on c.
+func NewTx(c Transactor) *Tx {
+	return &Tx{c: c}
+}
+
+// Queue adds op to the transaction. op must only use the cache it is
+// given, which is valid until it returns.
+func (tx *Tx) Queue(op func(c Cache) (any, error)) {
+	tx.ops = append(tx.ops, op)
+}
+
+// Len returns the number of queued operations.
+func (tx *Tx) Len() int {
+	return len(tx.ops)
+}
+
+// Watch makes Exec abort if one of keys changes from now on. Watching a
+// key again keeps the version it had when it was first watched.
+func (tx *Tx) Watch(keys ...string) {
+	for _, key := range keys {
+		tx.WatchVersion(key, tx.c.Version(key))
+	}
+}
+
+// WatchVersion makes Exec abort unless key still has the given version,
+// as returned by Transactor.Version.
+func (tx *Tx) WatchVersion(key string, version uint64) {
+	if tx.watched == nil {
+		tx.watched = make(map[string]uint64)
+	}
+	if _, found := tx.watched[key]; !found {
+		tx.watched[key] = version
+	}
+}
+
+// Unwatch forgets the watched keys.
+func (tx *Tx) Unwatch() {
+	tx.watched = nil
+}
+
+// Discard drops the queued operations and forgets the watched keys.
+func (tx *Tx) Discard() {
+	tx.ops = nil
+	tx.watched = nil
+}
+
+// Exec applies the queued operations atomically, in order, and returns
+// their results. If a watched key has changed, nothing is applied and ok is
+// false. The transaction is empty afterwards and can be reused.
+func (tx *Tx) Exec() (results []TxResult, ok bool) {
+	ops, watched := tx.ops, tx.watched
+	tx.ops, tx.watched = nil, nil
+
+	tx.c.Atomically(func(c Store) {
+		for key, version := range watched {
+			if c.Version(key) != version {
+				return
+			}
+		}
+
+		results = make([]TxResult, len(ops))
+		for i, op := range ops {
+			results[i].Value, results[i].Err = op(c)
+		}
+		ok = true
+	})
+
+	return results, ok
+}
+
+// Atomically calls fn with a view of the cache while holding its write
+// lock, so that the operations fn applies to the view are atomic. fn must
+// not use the cache itself, which would deadlock, nor retain the view.
+func (c *MemoryCache) Atomically(fn func(c Store)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fn(c.view())
+}
+
+// expiredVersion is set in the version of an item that expired but is
+// not removed yet. Versions are counted from 0 and never reach it.
+const expiredVersion = 1 << 63
+
+// tombstoneSlots is the number of slots of the tombstones of a keyspace.
+const tombstoneSlots = 1024
+
+// tombstones records when keys were last removed, so that a missing key
+// has a version that changes when it is created and removed again. The
+// keys are hashed to a fixed number of slots, so the removal of another
+// key in the same slot changes the version too; a watching transaction is
+// then aborted needlessly, which is safe.
+type tombstones struct {
+	seed  maphash.Seed
+	slots [tombstoneSlots]uint64
+	// cleared is the version of the last Clear, which removed every key.
+	cleared uint64
+}
+
+// newTombstones creates tombstones with no removal recorded.
+func newTombstones() *tombstones {
+	return &tombstones{seed: maphash.MakeSeed()}
+}
+
+// slot returns the slot of key.
+func (t *tombstones) slot(key string) *uint64 {
+	return &t.slots[maphash.String(t.seed, key)%tombstoneSlots]
+}
+
+// Version returns the version of key, which changes whenever the key is
+// written, removed or expires. A missing key has the version of the last
+// removal of a key hashed like it, or of the last Clear, and 0 if there
+// was none, so that creating and removing it again changes its version.
+func (c *MemoryCache) Version(key string) uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	item, found := c.items[key]
+	switch {
+	case !found:
+		return max(*c.tombstones.slot(key), c.tombstones.cleared)
+	case c.expired(item):
+		// The item is not removed yet, but its version must change all
+		// the same, and differ from the version the key had before it
+		// was written.
+		return item.version | expiredVersion
+	default:
+		return item.version
+	}
+}
+
+// modified gives item a new version after it was written. The caller must
+// hold the write lock.
+func (c *MemoryCache) modified(item *cacheItem) {
+	c.version++
+	item.version = c.version
+}
+
+// removed gives the missing key a new version after it was removed. The
+// caller must hold the write lock.
+func (c *MemoryCache) removed(key string) {
+	c.version++
+	*c.tombstones.slot(key) = c.version
+}
+
+// cleared gives every missing key a new version after every key was
+// removed. The caller must hold the write lock.
+func (c *MemoryCache) cleared() {
+	c.version++
+	c.tombstones.cleared = c.version
+}
+
+// view returns a cache sharing the keyspace of c without locking it. The
+// caller must hold the write lock for as long as the view is used.
+func (c *MemoryCache) view() *MemoryCache {
+	return &MemoryCache{mu: noLock{}, keyspace: c.keyspace}
+}
+
+// Version returns the version of key in its shard, which changes whenever
+// the key is written, removed or expires. As in MemoryCache.Version, a
+// missing key has the version of the last removal of a key hashed like it
+// in the shard, or of the last Clear, and 0 if there was none.
+func (s *ShardedCache) Version(key string) uint64 {
+	return s.shard(key).Version(key)
+}
+
+// Atomically calls fn with a view of the cache while holding the write
+// lock of every shard, so that the operations fn applies to the view are
+// atomic. fn must not use the cache itself, which would deadlock, nor
+// retain the view.
+func (s *ShardedCache) Atomically(fn func(c Store)) {
+	unlock := s.lockAll(true)
+	defer unlock()
+
+	view := &ShardedCache{
+		shards: make([]*MemoryCache, len(s.shards)),
+		seed:   s.seed,
+		broker: s.broker,
+	}
+	for i, shard := range s.shards {
+		view.shards[i] = shard.view()
+	}
+
+	fn(view)
+}
@@ -0,0 +1,245 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTx(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name  string
+		cache Store
+	}{
+		{"MemoryCache", NewMemoryCache(0)},
+		{"ShardedCache", NewShardedCache(4, 0)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := tc.cache
+			requireNoError(t, c.PushBack("source", "a"), "PushBack() failed")
+			requireNoError(t, c.Set("string", "value"), "Set() failed")
+
+			tx := NewTx(c)
+			tx.Queue(func(c Cache) (any, error) {
+				value, ok := c.PopFront("source")
+				if !ok {
+					return nil, ErrKeyNotFound
+				}
+				return value, c.PushBack("destination", value)
+			})
+			tx.Queue(func(c Cache) (any, error) { return nil, c.PushBack("string", "b") })
+			tx.Queue(func(c Cache) (any, error) { return c.ListRange("destination", 0, -1) })
+			require(t, tx.Len() == 3, "Len() = %d, want 3", tx.Len())
+
+			results, ok := tx.Exec()
+			require(t, ok && len(results) == 3 && tx.Len() == 0, "Exec() returned %d results, %v, left %d queued", len(results), ok, tx.Len())
+			require(t, results[0].Value == "a" && results[0].Err == nil, "result 0 = %+v", results[0])
+			// A failing operation does not roll back the others.
+			require(t, errors.Is(results[1].Err, ErrTypeMismatch), "result 1 = %+v, want ErrTypeMismatch", results[1])
+			values, _ := results[2].Value.([]string)
+			require(t, len(values) == 1 && values[0] == "a", "result 2 = %+v", results[2])
+
+			tx.Queue(func(c Cache) (any, error) { return nil, c.Set("discarded", "value") })
+			tx.Discard()
+			results, _ = tx.Exec()
+			require(t, len(results) == 0 && !c.Exists("discarded"), "Discard() kept the queued operation")
+		})
+	}
+}
+
+func TestTx_Atomic(t *testing.T) {
+	t.Parallel()
+
+	// Concurrent transactions move a token between two keys; a reader
+	// never sees it in both or neither, even with the keys on different
+	// shards.
+	c := NewShardedCache(8, 0)
+	keys := keysOnShards(t, c, "key", 8)
+	for i, key := range keys {
+		if c.shardIndex(key) != c.shardIndex(keys[0]) {
+			keys = []string{keys[0], keys[i]}
+			break
+		}
+	}
+	requireNoError(t, c.Set(keys[0], "token"), "Set() failed")
+
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tx := NewTx(c)
+			for i := range 200 {
+				tx.Queue(func(c Cache) (any, error) {
+					from, to := keys[0], keys[1]
+					if !c.Exists(from) {
+						from, to = to, from
+					}
+					if err := c.Remove(from); err != nil {
+						return nil, err
+					}
+					return nil, c.Set(to, strconv.Itoa(w*1000+i))
+				})
+				_, _ = tx.Exec()
+			}
+		}()
+	}
+
+	for range 200 {
+		var count int
+		c.Atomically(func(c Store) {
+			for _, key := range keys {
+				if c.Exists(key) {
+					count++
+				}
+			}
+		})
+		require(t, count == 1, "token found under %d keys", count)
+	}
+	wg.Wait()
+}
+
+func TestMemoryCache_Version(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+	requireNoError(t, c.Set("other", "value"), "Set() failed")
+
+	steps := []struct {
+		name    string
+		run     func() error
+		changes bool
+	}{
+		{"Set", func() error { return c.Set("key", "value") }, true},
+		{"Get", func() error { c.Get("key"); return nil }, false},
+		{"Update", func() error { return c.Update("key", "other") }, true},
+		{"SetTTL", func() error { return c.SetTTL("key", time.Hour) }, true},
+		{"GetTTL", func() error { c.GetTTL("key"); return nil }, false},
+		{"RemoveTTL", func() error { return c.RemoveTTL("key") }, true},
+		{"write to another key", func() error { return c.Set("other", "changed") }, false},
+		{"Remove", func() error { return c.Remove("key") }, true},
+		{"ZAdd", func() error { _, err := c.ZAdd("key", ZAddOptions{}, Z{Member: "a", Score: 1}); return err }, true},
+		{"ZAdd with a new score", func() error { _, err := c.ZAdd("key", ZAddOptions{}, Z{Member: "a", Score: 2}); return err }, true},
+		{"ZAdd with the same score", func() error { _, err := c.ZAdd("key", ZAddOptions{}, Z{Member: "a", Score: 2}); return err }, false},
+		{"expiry", func() error {
+			if err := c.SetWithTTL("key", "value", time.Millisecond); err != nil {
+				return err
+			}
+			time.Sleep(2 * time.Millisecond)
+			return nil
+		}, true},
+		{"Set again", func() error { return c.Set("key", "value") }, true},
+		{"Clear", c.Clear, true},
+	}
+
+	version := c.Version("key")
+	require(t, version == 0, "Version() of a missing key = %d, want 0", version)
+	for _, step := range steps {
+		requireNoError(t, step.run(), "%s failed", step.name)
+		got := c.Version("key")
+		require(t, (got != version) == step.changes, "%s: version %d -> %d, want changed = %v", step.name, version, got, step.changes)
+		version = got
+	}
+}
+
+func TestTx_WatchMissing(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		change func(c Cache) error
+	}{
+		{"Set and Remove", func(c Cache) error {
+			if err := c.Set("key", "value"); err != nil {
+				return err
+			}
+			return c.Remove("key")
+		}},
+		{"Set and Clear", func(c Cache) error {
+			if err := c.Set("key", "value"); err != nil {
+				return err
+			}
+			return c.Clear()
+		}},
+		{"Clear", func(c Cache) error { return c.Clear() }},
+		{"Set with an expiration that passes", func(c Cache) error {
+			if err := c.SetWithTTL("key", "value", time.Millisecond); err != nil {
+				return err
+			}
+			time.Sleep(2 * time.Millisecond)
+			return nil
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, c := range []interface {
+				Cache
+				Transactor
+			}{NewMemoryCache(0), NewShardedCache(4, 0)} {
+				tx := NewTx(c)
+				tx.Watch("key")
+				requireNoError(t, tt.change(c), "%s failed", tt.name)
+				tx.Queue(func(c Cache) (any, error) { return nil, c.Set("key", "tx") })
+				_, ok := tx.Exec()
+				require(t, !ok && !c.Exists("key"), "Exec() was applied after %s of a watched missing key", tt.name)
+			}
+		})
+	}
+
+	// The version of an existing key changes when the cache is cleared
+	// and the key written again.
+	c := NewMemoryCache(0)
+	requireNoError(t, c.Set("key", "value"), "Set() failed")
+	tx := NewTx(c)
+	tx.Watch("key")
+	requireNoError(t, c.Clear(), "Clear() failed")
+	requireNoError(t, c.Set("key", "value"), "Set() failed")
+	tx.Queue(func(c Cache) (any, error) { return nil, c.Set("key", "tx") })
+	_, ok := tx.Exec()
+	require(t, !ok, "Exec() was applied after the cache was cleared")
+}
+
+func TestTx_Watch(t *testing.T) {
+	t.Parallel()
+
+	c := NewMemoryCache(0)
+	requireNoError(t, c.Set("balance", "10"), "Set() failed")
+
+	tx := NewTx(c)
+	tx.Watch("balance", "missing")
+	tx.Queue(func(c Cache) (any, error) { return nil, c.Set("balance", "5") })
+	results, ok := tx.Exec()
+	require(t, ok && len(results) == 1 && results[0].Err == nil, "Exec() with unchanged keys = %+v, %v", results, ok)
+
+	// A write between Watch and Exec aborts the transaction, even if it
+	// stores the same value.
+	tx.Watch("balance")
+	requireNoError(t, c.Set("balance", "5"), "Set() failed")
+	tx.Queue(func(c Cache) (any, error) { return nil, c.Set("balance", "0") })
+	results, ok = tx.Exec()
+	require(t, !ok && results == nil, "Exec() after a write to a watched key = %+v, %v", results, ok)
+	value, _ := c.Get("balance")
+	require(t, value == "5", "aborted transaction was applied: balance = %q", value)
+
+	// So does creating a key that was missing when it was watched.
+	tx.Watch("missing")
+	requireNoError(t, c.Set("missing", "value"), "Set() failed")
+	tx.Queue(func(c Cache) (any, error) { return nil, c.Remove("missing") })
+	_, ok = tx.Exec()
+	require(t, !ok && c.Exists("missing"), "Exec() after creating a watched key was applied")
+
+	// Exec forgets the watched keys.
+	requireNoError(t, c.Set("balance", "1"), "Set() failed")
+	tx.Queue(func(c Cache) (any, error) { return nil, c.Set("balance", "2") })
+	_, ok = tx.Exec()
+	require(t, ok, "Exec() was aborted by a key watched by an earlier transaction")
+}
@@ -0,0 +1,654 @@
+package cache
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sorted set errors.
+var (
+	ErrMemberNotFound = errors.New("member not found")
+	ErrInvalidScore   = errors.New("score is not a valid float")
+	ErrInvalidBound   = errors.New("min or max is not a valid range bound")
+	ErrInvalidOptions = errors.New("invalid option combination")
+)
+
+// Z is a sorted set member together with its score.
+type Z struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// ZAddOptions controls how ZAdd treats existing and new members.
+type ZAddOptions struct {
+	// NX only adds new members and never updates existing ones.
+	NX bool
+	// XX only updates existing members and never adds new ones.
+	XX bool
+	// GT only updates existing members if the new score is greater.
+	GT bool
+	// LT only updates existing members if the new score is lower.
+	LT bool
+	// CH makes ZAdd return the number of added and updated members
+	// instead of only the added ones.
+	CH bool
+}
+
+// validate reports whether the options can be combined.
+func (o ZAddOptions) validate() error {
+	if (o.NX && o.XX) || (o.GT && o.LT) || (o.NX && (o.GT || o.LT)) {
+		return ErrInvalidOptions
+	}
+	return nil
+}
+
+// ZRangeOptions controls the order and paging of score and lex ranges.
+type ZRangeOptions struct {
+	// Rev returns elements from the highest to the lowest.
+	Rev bool
+	// Offset is the number of matching elements to skip.
+	Offset int
+	// Count limits the number of returned elements; zero or negative means
+	// no limit.
+	Count int
+}
+
+// ScoreBound is one end of a score interval.
+type ScoreBound struct {
+	Score     float64
+	Exclusive bool
+}
+
+// Inclusive score bounds covering every score.
+var (
+	MinScore = ScoreBound{Score: math.Inf(-1)}
+	MaxScore = ScoreBound{Score: math.Inf(1)}
+)
+
+// ParseScoreBound parses a Redis-style score bound such as "1.5", "(1.5",
+// "-inf" or "+inf".
+func ParseScoreBound(s string) (ScoreBound, error) {
+	var bound ScoreBound
+	if strings.HasPrefix(s, "(") {
+		bound.Exclusive = true
+		s = s[1:]
+	}
+
+	score, err := ParseScore(s)
+	if err != nil {
+		return ScoreBound{}, ErrInvalidBound
+	}
+	bound.Score = score
+
+	return bound, nil
+}
+
+// ParseScore parses a score, accepting "inf", "+inf" and "-inf".
+func ParseScore(s string) (float64, error) {
+	score, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, ErrInvalidScore
+	}
+	return score, nil
+}
+
+// below reports whether score lies on the inner side of b used as a minimum.
+func (b ScoreBound) below(score float64) bool {
+	if b.Exclusive {
+		return score > b.Score
+	}
+	return score >= b.Score
+}
+
+// above reports whether score lies on the inner side of b used as a maximum.
+func (b ScoreBound) above(score float64) bool {
+	if b.Exclusive {
+		return score < b.Score
+	}
+	return score <= b.Score
+}
+
+// LexBound is one end of a lexicographical interval over members.
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	// Infinite is -1 for the "-" bound, 1 for the "+" bound and 0 for a
+	// bound on Value.
+	Infinite int
+}
+
+// Lex bounds covering every member.
+var (
+	MinLex = LexBound{Infinite: -1}
+	MaxLex = LexBound{Infinite: 1}
+)
+
+// ParseLexBound parses a Redis-style lex bound: "-", "+", "[value" or
+// "(value".
+func ParseLexBound(s string) (LexBound, error) {
+	switch {
+	case s == "-":
+		return MinLex, nil
+	case s == "+":
+		return MaxLex, nil
+	case strings.HasPrefix(s, "["):
+		return LexBound{Value: s[1:]}, nil
+	case strings.HasPrefix(s, "("):
+		return LexBound{Value: s[1:], Exclusive: true}, nil
+	default:
+		return LexBound{}, ErrInvalidBound
+	}
+}
+
+// below reports whether member lies on the inner side of b used as a
+// minimum.
+func (b LexBound) below(member string) bool {
+	switch {
+	case b.Infinite < 0:
+		return true
+	case b.Infinite > 0:
+		return false
+	case b.Exclusive:
+		return member > b.Value
+	default:
+		return member >= b.Value
+	}
+}
+
+// above reports whether member lies on the inner side of b used as a
+// maximum.
+func (b LexBound) above(member string) bool {
+	switch {
+	case b.Infinite > 0:
+		return true
+	case b.Infinite < 0:
+		return false
+	case b.Exclusive:
+		return member < b.Value
+	default:
+		return member <= b.Value
+	}
+}
+
+// emptyWith reports whether no member can lie within [b, max].
+func (b LexBound) emptyWith(max LexBound) bool {
+	if b.Infinite > 0 || max.Infinite < 0 {
+		return true
+	}
+	if b.Infinite < 0 || max.Infinite > 0 {
+		return false
+	}
+	return b.Value > max.Value || (b.Value == max.Value && (b.Exclusive || max.Exclusive))
+}
+
+// sortedSet is the value of a SortedSetType item: a member to score map for
+// O(1) score lookups plus a skip list for ordered and ranked access.
+type sortedSet struct {
+	scores map[string]float64
+	list   *skipList
+}
+
+// newSortedSet creates an empty sorted set.
+func newSortedSet() *sortedSet {
+	return &sortedSet{
+		scores: make(map[string]float64),
+		list:   newSkipList(),
+	}
+}
+
+// set inserts member or moves it to a new score.
+func (z *sortedSet) set(member string, score float64) {
+	if current, exists := z.scores[member]; exists {
+		if current == score {
+			return
+		}
+		z.list.delete(current, member)
+	}
+	z.scores[member] = score
+	z.list.insert(score, member)
+}
+
+// remove deletes member and reports whether it was present.
+func (z *sortedSet) remove(member string) bool {
+	score, exists := z.scores[member]
+	if !exists {
+		return false
+	}
+	delete(z.scores, member)
+	z.list.delete(score, member)
+	return true
+}
+
+// ZAdd adds members to the sorted set stored at key, or updates their
+// scores, according to opts. It returns the number of added members, or
+// of added and updated members if opts.CH is set.
+func (c *MemoryCache) ZAdd(key string, opts ZAddOptions, members ...Z) (int, error) {
+	if err := opts.validate(); err != nil {
+		return 0, err
+	}
+	for _, m := range members {
+		if math.IsNaN(m.Score) {
+			return 0, ErrInvalidScore
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reserve(); err != nil {
+		return 0, err
+	}
+
+	item := c.lookupItem(key)
+	if item != nil && item.dataType != SortedSetType {
+		return 0, ErrTypeMismatch
+	}
+
+	added, updated := 0, 0
+	for _, m := range members {
+		current, exists := 0.0, false
+		if item != nil {
+			current, exists = item.value.(*sortedSet).scores[m.Member]
+		}
+
+		switch {
+		case exists:
+			if opts.NX || (opts.GT && m.Score <= current) || (opts.LT && m.Score >= current) || m.Score == current {
+				continue
+			}
+			updated++
+		case opts.XX:
+			continue
+		default:
+			added++
+		}
+
+		if item == nil {
+			item = &cacheItem{
+				dataType: SortedSetType,
+				value:    newSortedSet(),
+			}
+			c.storeItem(key, item)
+		}
+		item.value.(*sortedSet).set(m.Member, m.Score)
+		if exists {
+			c.modified(item)
+		} else {
+			c.resize(item, zsetMemberSize(m.Member))
+		}
+	}
+	if added+updated > 0 {
+		c.notify(EventsSortedSet, "zadd", key)
+	}
+
+	if opts.CH {
+		return added + updated, nil
+	}
+	return added, nil
+}
+
+// ZIncrBy increments the score of member in the sorted set stored at key and
+// returns the new score. A missing member is added with the increment as
+// its score.
+func (c *MemoryCache) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reserve(); err != nil {
+		return 0, err
+	}
+
+	item := c.lookupItem(key)
+	if item != nil && item.dataType != SortedSetType {
+		return 0, ErrTypeMismatch
+	}
+
+	score, exists := 0.0, false
+	if item != nil {
+		score, exists = item.value.(*sortedSet).scores[member]
+	}
+	score += increment
+	if math.IsNaN(score) {
+		return 0, ErrInvalidScore
+	}
+
+	if item == nil {
+		item = &cacheItem{
+			dataType: SortedSetType,
+			value:    newSortedSet(),
+		}
+		c.storeItem(key, item)
+	}
+	item.value.(*sortedSet).set(member, score)
+	if exists {
+		c.modified(item)
+	} else {
+		c.resize(item, zsetMemberSize(member))
+	}
+	c.notify(EventsSortedSet, "zincr", key)
+
+	return score, nil
+}
+
+// ZScore returns the score of member in the sorted set stored at key.
+func (c *MemoryCache) ZScore(key string, member string) (float64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	z, err := c.readableSortedSet(key)
+	if err != nil {
+		return 0, err
+	}
+
+	score, exists := z.scores[member]
+	if !exists {
+		return 0, ErrMemberNotFound
+	}
+
+	return score, nil
+}
+
+// ZRank returns the 0-based rank of member in the sorted set stored at key,
+// ordered from the lowest score, or from the highest if rev is set.
+func (c *MemoryCache) ZRank(key string, member string, rev bool) (int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	z, err := c.readableSortedSet(key)
+	if err != nil {
+		return 0, err
+	}
+
+	score, exists := z.scores[member]
+	if !exists {
+		return 0, ErrMemberNotFound
+	}
+
+	rank := z.list.rank(score, member)
+	if rev {
+		return z.list.length - rank, nil
+	}
+	return rank - 1, nil
+}
+
+// ZRange returns the members of the sorted set stored at key between the
+// 0-based ranks start and stop, inclusive. Negative ranks count from the
+// end, as in ListRange. If rev is set, ranks are counted from the highest
+// score.
+func (c *MemoryCache) ZRange(key string, start, stop int, rev bool) ([]Z, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	z, err := c.readableSortedSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	length := z.list.length
+
+	// Handle negative indices..
+	if start < 0 {
+		start = length + start
+	}
+	if stop < 0 {
+		stop = length + stop
+	}
+
+	// Validate indices..
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop || start >= length {
+		return []Z{}, nil
+	}
+
+	result := make([]Z, 0, stop-start+1)
+	if rev {
+		for x := z.list.byRank(length - start); len(result) < cap(result); x = x.backward {
+			result = append(result, Z{Member: x.member, Score: x.score})
+		}
+	} else {
+		for x := z.list.byRank(start + 1); len(result) < cap(result); x = x.levels[0].forward {
+			result = append(result, Z{Member: x.member, Score: x.score})
+		}
+	}
+
+	return result, nil
+}
+
+// ZRangeByScore returns the members of the sorted set stored at key with a
+// score between min and max.
+func (c *MemoryCache) ZRangeByScore(key string, min, max ScoreBound, opts ZRangeOptions) ([]Z, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	z, err := c.readableSortedSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var x *skipListNode
+	if opts.Rev {
+		x = z.list.lastInScoreRange(min, max)
+	} else {
+		x = z.list.firstInScoreRange(min, max)
+	}
+
+	inRange := func(x *skipListNode) bool {
+		return min.below(x.score) && max.above(x.score)
+	}
+
+	result := []Z{}
+	for x = skipNodes(x, opts, inRange); x != nil && inRange(x); x = nextNode(x, opts.Rev) {
+		if opts.Count > 0 && len(result) == opts.Count {
+			break
+		}
+		result = append(result, Z{Member: x.member, Score: x.score})
+	}
+
+	return result, nil
+}
+
+// ZRangeByLex returns the members of the sorted set stored at key that lie
+// between min and max lexicographically. Like in Redis, the result is only
+// meaningful if all members share the same score.
+func (c *MemoryCache) ZRangeByLex(key string, min, max LexBound, opts ZRangeOptions) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	z, err := c.readableSortedSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var x *skipListNode
+	if opts.Rev {
+		x = z.list.lastInLexRange(min, max)
+	} else {
+		x = z.list.firstInLexRange(min, max)
+	}
+
+	inRange := func(x *skipListNode) bool {
+		return min.below(x.member) && max.above(x.member)
+	}
+
+	result := []string{}
+	for x = skipNodes(x, opts, inRange); x != nil && inRange(x); x = nextNode(x, opts.Rev) {
+		if opts.Count > 0 && len(result) == opts.Count {
+			break
+		}
+		result = append(result, x.member)
+	}
+
+	return result, nil
+}
+
+// ZCount returns the number of members of the sorted set stored at key with
+// a score between min and max. A missing key counts as an empty set.
+func (c *MemoryCache) ZCount(key string, min, max ScoreBound) (int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	z, err := c.readableSortedSet(key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	first := z.list.firstInScoreRange(min, max)
+	if first == nil {
+		return 0, nil
+	}
+	last := z.list.lastInScoreRange(min, max)
+
+	return z.list.rank(last.score, last.member) - z.list.rank(first.score, first.member) + 1, nil
+}
+
+// ZCard returns the number of members of the sorted set stored at key. A
+// missing key counts as an empty set.
+func (c *MemoryCache) ZCard(key string) (int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	z, err := c.readableSortedSet(key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return z.list.length, nil
+}
+
+// ZRem removes members from the sorted set stored at key and returns the
+// number of members that were removed. The key is deleted once the sorted
+// set is empty.
+func (c *MemoryCache) ZRem(key string, members ...string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, err := c.writableSortedSet(key)
+	if err != nil {
+		return 0, err
+	}
+
+	z := item.value.(*sortedSet)
+	removed := 0
+	for _, member := range members {
+		if z.remove(member) {
+			c.resize(item, -zsetMemberSize(member))
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		c.notify(EventsSortedSet, "zrem", key)
+	}
+	if z.list.length == 0 {
+		c.deleteItem(key)
+		c.notify(EventsGeneric, "del", key)
+	}
+
+	return removed, nil
+}
+
+// ZPopMin removes and returns up to count members with the lowest scores
+// from the sorted set stored at key.
+func (c *MemoryCache) ZPopMin(key string, count int) ([]Z, error) {
+	return c.zpop(key, count, false)
+}
+
+// ZPopMax removes and returns up to count members with the highest scores
+// from the sorted set stored at key.
+func (c *MemoryCache) ZPopMax(key string, count int) ([]Z, error) {
+	return c.zpop(key, count, true)
+}
+
+// zpop is a helper function for ZPopMin and ZPopMax
+func (c *MemoryCache) zpop(key string, count int, highest bool) ([]Z, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, err := c.writableSortedSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	z := item.value.(*sortedSet)
+	result := make([]Z, 0, min(max(count, 0), z.list.length))
+	for len(result) < cap(result) {
+		x := z.list.first()
+		if highest {
+			x = z.list.tail
+		}
+		result = append(result, Z{Member: x.member, Score: x.score})
+		z.remove(x.member)
+		c.resize(item, -zsetMemberSize(x.member))
+	}
+
+	if len(result) > 0 {
+		event := "zpopmin"
+		if highest {
+			event = "zpopmax"
+		}
+		c.notify(EventsSortedSet, event, key)
+	}
+	if z.list.length == 0 {
+		c.deleteItem(key)
+		c.notify(EventsGeneric, "del", key)
+	}
+
+	return result, nil
+}
+
+// readableSortedSet returns the sorted set stored at key. The caller must
+// hold at least the read lock.
+func (c *MemoryCache) readableSortedSet(key string) (*sortedSet, error) {
+	item := c.peekItem(key)
+	if item == nil {
+		return nil, ErrKeyNotFound
+	}
+	if item.dataType != SortedSetType {
+		return nil, ErrTypeMismatch
+	}
+
+	return item.value.(*sortedSet), nil
+}
+
+// writableSortedSet returns the item holding the sorted set stored at key,
+// deleting it first if it has expired. The caller must hold the write lock.
+func (c *MemoryCache) writableSortedSet(key string) (*cacheItem, error) {
+	item := c.lookupItem(key)
+	if item == nil {
+		return nil, ErrKeyNotFound
+	}
+	if item.dataType != SortedSetType {
+		return nil, ErrTypeMismatch
+	}
+
+	return item, nil
+}
+
+// skipNodes advances from x past opts.Offset nodes that satisfy inRange.
+func skipNodes(x *skipListNode, opts ZRangeOptions, inRange func(*skipListNode) bool) *skipListNode {
+	for i := 0; i < opts.Offset && x != nil && inRange(x); i++ {
+		x = nextNode(x, opts.Rev)
+	}
+	return x
+}
+
+// nextNode returns the node following x in the iteration direction.
+func nextNode(x *skipListNode, rev bool) *skipListNode {
+	if rev {
+		return x.backward
+	}
+	return x.levels[0].forward
+}
@@ -0,0 +1,326 @@
+package cache
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_SortedSet(t *testing.T) {
+	t.Parallel()
+
+	setup := func(c *MemoryCache) {
+		_, err := c.ZAdd("board", ZAddOptions{},
+			Z{Member: "alice", Score: 10},
+			Z{Member: "bob", Score: 20},
+			Z{Member: "carol", Score: 20},
+			Z{Member: "dave", Score: 30},
+		)
+		requireNoError(t, err, "Setup failed: %v", err)
+		_, err = c.ZAdd("lex", ZAddOptions{},
+			Z{Member: "a"}, Z{Member: "b"}, Z{Member: "c"}, Z{Member: "d"}, Z{Member: "e"},
+		)
+		requireNoError(t, err, "Setup failed: %v", err)
+		err = c.Set("str", "value")
+		requireNoError(t, err, "Setup failed: %v", err)
+	}
+
+	bound := func(s string) ScoreBound {
+		b, err := ParseScoreBound(s)
+		requireNoError(t, err, "ParseScoreBound(%q) failed: %v", s, err)
+		return b
+	}
+	lex := func(s string) LexBound {
+		b, err := ParseLexBound(s)
+		requireNoError(t, err, "ParseLexBound(%q) failed: %v", s, err)
+		return b
+	}
+
+	tests := []struct {
+		name      string
+		operation func(c *MemoryCache) (any, error)
+		want      any
+		wantErr   error
+	}{
+		{
+			name: "ZAdd counts only new members",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.ZAdd("board", ZAddOptions{}, Z{Member: "alice", Score: 11}, Z{Member: "erin", Score: 5})
+			},
+			want: 1,
+		},
+		{
+			name: "ZAdd CH counts updates",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.ZAdd("board", ZAddOptions{CH: true}, Z{Member: "alice", Score: 11}, Z{Member: "bob", Score: 20})
+			},
+			want: 1,
+		},
+		{
+			name: "ZAdd NX skips existing members",
+			operation: func(c *MemoryCache) (any, error) {
+				if _, err := c.ZAdd("board", ZAddOptions{NX: true}, Z{Member: "alice", Score: 99}); err != nil {
+					return nil, err
+				}
+				return c.ZScore("board", "alice")
+			},
+			want: 10.0,
+		},
+		{
+			name: "ZAdd XX skips new members",
+			operation: func(c *MemoryCache) (any, error) {
+				if _, err := c.ZAdd("board", ZAddOptions{XX: true}, Z{Member: "zed", Score: 1}); err != nil {
+					return nil, err
+				}
+				return c.ZCard("board")
+			},
+			want: 4,
+		},
+		{
+			name: "ZAdd GT only raises scores",
+			operation: func(c *MemoryCache) (any, error) {
+				n, err := c.ZAdd("board", ZAddOptions{GT: true, CH: true}, Z{Member: "alice", Score: 5}, Z{Member: "bob", Score: 25})
+				if err != nil {
+					return nil, err
+				}
+				alice, _ := c.ZScore("board", "alice")
+				bob, _ := c.ZScore("board", "bob")
+				return []any{n, alice, bob}, nil
+			},
+			want: []any{1, 10.0, 25.0},
+		},
+		{
+			name: "ZAdd LT only lowers scores",
+			operation: func(c *MemoryCache) (any, error) {
+				if _, err := c.ZAdd("board", ZAddOptions{LT: true}, Z{Member: "dave", Score: 40}); err != nil {
+					return nil, err
+				}
+				return c.ZScore("board", "dave")
+			},
+			want: 30.0,
+		},
+		{
+			name: "ZAdd NX with GT",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.ZAdd("board", ZAddOptions{NX: true, GT: true}, Z{Member: "alice", Score: 1})
+			},
+			wantErr: ErrInvalidOptions,
+		},
+		{
+			name: "ZAdd NaN score",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.ZAdd("board", ZAddOptions{}, Z{Member: "alice", Score: math.NaN()})
+			},
+			wantErr: ErrInvalidScore,
+		},
+		{
+			name: "ZAdd on string key",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.ZAdd("str", ZAddOptions{}, Z{Member: "alice", Score: 1})
+			},
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name: "ZIncrBy moves member",
+			operation: func(c *MemoryCache) (any, error) {
+				if _, err := c.ZIncrBy("board", 25, "alice"); err != nil {
+					return nil, err
+				}
+				return c.ZRank("board", "alice", false)
+			},
+			want: 3,
+		},
+		{
+			name: "ZIncrBy infinity minus infinity",
+			operation: func(c *MemoryCache) (any, error) {
+				if _, err := c.ZIncrBy("inf", math.Inf(1), "m"); err != nil {
+					return nil, err
+				}
+				return c.ZIncrBy("inf", math.Inf(-1), "m")
+			},
+			wantErr: ErrInvalidScore,
+		},
+		{
+			name: "ZRank ties ordered by member",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.ZRank("board", "carol", false)
+			},
+			want: 2,
+		},
+		{
+			name: "ZRank reversed",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.ZRank("board", "dave", true)
+			},
+			want: 0,
+		},
+		{
+			name: "ZRank missing member",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.ZRank("board", "zed", false)
+			},
+			wantErr: ErrMemberNotFound,
+		},
+		{
+			name: "ZRange negative ranks",
+			operation: func(c *MemoryCache) (any, error) {
+				return zMembers(c.ZRange("board", -3, -2, false))
+			},
+			want: []string{"bob", "carol"},
+		},
+		{
+			name: "ZRange reversed",
+			operation: func(c *MemoryCache) (any, error) {
+				return zMembers(c.ZRange("board", 0, 1, true))
+			},
+			want: []string{"dave", "carol"},
+		},
+		{
+			name: "ZRange missing key",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.ZRange("missing", 0, -1, false)
+			},
+			wantErr: ErrKeyNotFound,
+		},
+		{
+			name: "ZRangeByScore exclusive",
+			operation: func(c *MemoryCache) (any, error) {
+				return zMembers(c.ZRangeByScore("board", bound("(10"), bound("+inf"), ZRangeOptions{}))
+			},
+			want: []string{"bob", "carol", "dave"},
+		},
+		{
+			name: "ZRangeByScore with limit",
+			operation: func(c *MemoryCache) (any, error) {
+				return zMembers(c.ZRangeByScore("board", bound("-inf"), bound("30"), ZRangeOptions{Offset: 1, Count: 2}))
+			},
+			want: []string{"bob", "carol"},
+		},
+		{
+			name: "ZRangeByScore reversed with limit",
+			operation: func(c *MemoryCache) (any, error) {
+				return zMembers(c.ZRangeByScore("board", bound("10"), bound("(30"), ZRangeOptions{Rev: true, Offset: 1}))
+			},
+			want: []string{"bob", "alice"},
+		},
+		{
+			name: "ZRangeByScore empty range",
+			operation: func(c *MemoryCache) (any, error) {
+				return zMembers(c.ZRangeByScore("board", bound("(20"), bound("20"), ZRangeOptions{}))
+			},
+			want: []string{},
+		},
+		{
+			name: "ZRangeByLex",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.ZRangeByLex("lex", lex("(a"), lex("[c"), ZRangeOptions{})
+			},
+			want: []string{"b", "c"},
+		},
+		{
+			name: "ZRangeByLex reversed with limit",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.ZRangeByLex("lex", lex("-"), lex("+"), ZRangeOptions{Rev: true, Offset: 1, Count: 2})
+			},
+			want: []string{"d", "c"},
+		},
+		{
+			name: "ZCount",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.ZCount("board", bound("20"), bound("+inf"))
+			},
+			want: 3,
+		},
+		{
+			name: "ZCount missing key",
+			operation: func(c *MemoryCache) (any, error) {
+				return c.ZCount("missing", MinScore, MaxScore)
+			},
+			want: 0,
+		},
+		{
+			name: "ZRem deletes empty sorted set",
+			operation: func(c *MemoryCache) (any, error) {
+				n, err := c.ZRem("lex", "a", "b", "c", "d", "e", "f")
+				return []any{n, c.Exists("lex")}, err
+			},
+			want: []any{5, false},
+		},
+		{
+			name: "ZPopMin",
+			operation: func(c *MemoryCache) (any, error) {
+				return zMembers(c.ZPopMin("board", 2))
+			},
+			want: []string{"alice", "bob"},
+		},
+		{
+			name: "ZPopMax more than available",
+			operation: func(c *MemoryCache) (any, error) {
+				members, err := zMembers(c.ZPopMax("board", 10))
+				return []any{members, c.Exists("board")}, err
+			},
+			want: []any{[]string{"dave", "carol", "bob", "alice"}, false},
+		},
+		{
+			name: "ZPopMin keeps TTL",
+			operation: func(c *MemoryCache) (any, error) {
+				if err := c.SetTTL("board", time.Minute); err != nil {
+					return nil, err
+				}
+				if _, err := c.ZPopMin("board", 1); err != nil {
+					return nil, err
+				}
+				ttl, _ := c.GetTTL("board")
+				return ttl > 0, nil
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMemoryCache(100 * time.Millisecond)
+			defer c.Stop()
+
+			setup(c)
+
+			got, err := tt.operation(c)
+			require(t, errors.Is(err, tt.wantErr), "error = %v, want %v", err, tt.wantErr)
+			if tt.wantErr == nil {
+				require(t, equal(got, tt.want), "got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBounds(t *testing.T) {
+	t.Parallel()
+
+	score, err := ParseScoreBound("(1.5")
+	require(t, err == nil && score == ScoreBound{Score: 1.5, Exclusive: true}, "ParseScoreBound((1.5) = %v, %v", score, err)
+
+	score, err = ParseScoreBound("-inf")
+	require(t, err == nil && score == MinScore, "ParseScoreBound(-inf) = %v, %v", score, err)
+
+	_, err = ParseScoreBound("abc")
+	require(t, errors.Is(err, ErrInvalidBound), "ParseScoreBound(abc) error = %v", err)
+
+	lex, err := ParseLexBound("[abc")
+	require(t, err == nil && lex == LexBound{Value: "abc"}, "ParseLexBound([abc) = %v, %v", lex, err)
+
+	_, err = ParseLexBound("abc")
+	require(t, errors.Is(err, ErrInvalidBound), "ParseLexBound(abc) error = %v", err)
+}
+
+// zMembers extracts the members of a sorted set range result.
+func zMembers(zs []Z, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	members := make([]string, 0, len(zs))
+	for _, z := range zs {
+		members = append(members, z.Member)
+	}
+	return members, nil
+}
@@ -0,0 +1,217 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// Common errors.
+var (
+	ErrUnknownCommand = errors.New("unknown command")
+	ErrWrongArgs      = errors.New("wrong number of arguments")
+	ErrSyntax         = errors.New("syntax error")
+	ErrNotInteger     = errors.New("value is not an integer or out of range")
+	ErrInvalidCursor  = errors.New("invalid cursor")
+)
+
+// Command is a single cache command in its wire form: an upper-case name
+// followed by its arguments, e.g. SET key value.
+type Command struct {
+	Name string
+	Args []string
+}
+
+// New creates a command from a name and its arguments.
+func New(name string, args ...string) Command {
+	return Command{
+		Name: strings.ToUpper(name),
+		Args: args,
+	}
+}
+
+// Status is a simple status reply such as "OK" or "string".
+type Status string
+
+// StatusOK is the reply of commands that only report success.
+const StatusOK Status = "OK"
+
+// spec describes how a command is validated and executed.
+type spec struct {
+	// minArgs and maxArgs bound the number of arguments after the command
+	// name. A negative maxArgs means there is no upper bound.
+	minArgs int
+	maxArgs int
+	write   bool
+	run     func(c cache.Cache, args []string) (any, error)
+}
+
+// commands is the dispatch table of every supported command.
+var commands = map[string]spec{
+	// String operations
+	"GET":         {minArgs: 1, maxArgs: 1, run: get},
+	"SET":         {minArgs: 2, maxArgs: -1, write: true, run: set},
+	"MGET":        {minArgs: 1, maxArgs: -1, run: mget},
+	"MSET":        {minArgs: 2, maxArgs: -1, write: true, run: mset},
+	"MSETNX":      {minArgs: 2, maxArgs: -1, write: true, run: msetnx},
+	"INCR":        {minArgs: 1, maxArgs: 1, write: true, run: incr},
+	"DECR":        {minArgs: 1, maxArgs: 1, write: true, run: decr},
+	"INCRBY":      {minArgs: 2, maxArgs: 2, write: true, run: incrby},
+	"DECRBY":      {minArgs: 2, maxArgs: 2, write: true, run: decrby},
+	"INCRBYFLOAT": {minArgs: 2, maxArgs: 2, write: true, run: incrbyfloat},
+
+	// List operations
+	"LPUSH":     {minArgs: 2, maxArgs: -1, write: true, run: lpush},
+	"RPUSH":     {minArgs: 2, maxArgs: -1, write: true, run: rpush},
+	"LPOP":      {minArgs: 1, maxArgs: 1, write: true, run: lpop},
+	"RPOP":      {minArgs: 1, maxArgs: 1, write: true, run: rpop},
+	"LRANGE":    {minArgs: 3, maxArgs: 3, run: lrange},
+	"LLEN":      {minArgs: 1, maxArgs: 1, run: llen},
+	"LINDEX":    {minArgs: 2, maxArgs: 2, run: lindex},
+	"LSET":      {minArgs: 3, maxArgs: 3, write: true, run: lset},
+	"LINSERT":   {minArgs: 4, maxArgs: 4, write: true, run: linsert},
+	"LREM":      {minArgs: 3, maxArgs: 3, write: true, run: lrem},
+	"LTRIM":     {minArgs: 3, maxArgs: 3, write: true, run: ltrim},
+	"LPOS":      {minArgs: 2, maxArgs: 8, run: lpos},
+	"LMOVE":     {minArgs: 4, maxArgs: 4, write: true, run: lmove},
+	"RPOPLPUSH": {minArgs: 2, maxArgs: 2, write: true, run: rpoplpush},
+
+	// Hash operations
+	"HSET":    {minArgs: 3, maxArgs: -1, write: true, run: hset},
+	"HGET":    {minArgs: 2, maxArgs: 2, run: hget},
+	"HDEL":    {minArgs: 2, maxArgs: -1, write: true, run: hdel},
+	"HGETALL": {minArgs: 1, maxArgs: 1, run: hgetall},
+	"HINCRBY": {minArgs: 3, maxArgs: 3, write: true, run: hincrby},
+
+	// Set operations
+	"SADD":        {minArgs: 2, maxArgs: -1, write: true, run: sadd},
+	"SREM":        {minArgs: 2, maxArgs: -1, write: true, run: srem},
+	"SISMEMBER":   {minArgs: 2, maxArgs: 2, run: sismember},
+	"SMEMBERS":    {minArgs: 1, maxArgs: 1, run: smembers},
+	"SCARD":       {minArgs: 1, maxArgs: 1, run: scard},
+	"SINTER":      {minArgs: 1, maxArgs: -1, run: sinter},
+	"SUNION":      {minArgs: 1, maxArgs: -1, run: sunion},
+	"SDIFF":       {minArgs: 1, maxArgs: -1, run: sdiff},
+	"SINTERSTORE": {minArgs: 2, maxArgs: -1, write: true, run: sinterstore},
+	"SUNIONSTORE": {minArgs: 2, maxArgs: -1, write: true, run: sunionstore},
+	"SDIFFSTORE":  {minArgs: 2, maxArgs: -1, write: true, run: sdiffstore},
+
+	// Sorted set operations
+	"ZADD":     {minArgs: 3, maxArgs: -1, write: true, run: zadd},
+	"ZINCRBY":  {minArgs: 3, maxArgs: 3, write: true, run: zincrby},
+	"ZSCORE":   {minArgs: 2, maxArgs: 2, run: zscore},
+	"ZRANK":    {minArgs: 2, maxArgs: 2, run: zrank},
+	"ZREVRANK": {minArgs: 2, maxArgs: 2, run: zrevrank},
+	"ZRANGE":   {minArgs: 3, maxArgs: -1, run: zrange},
+	"ZCOUNT":   {minArgs: 3, maxArgs: 3, run: zcount},
+	"ZCARD":    {minArgs: 1, maxArgs: 1, run: zcard},
+	"ZREM":     {minArgs: 2, maxArgs: -1, write: true, run: zrem},
+	"ZPOPMIN":  {minArgs: 1, maxArgs: 2, write: true, run: zpopmin},
+	"ZPOPMAX":  {minArgs: 1, maxArgs: 2, write: true, run: zpopmax},
+
+	// TTL operations
+	"EXPIRE":      {minArgs: 2, maxArgs: -1, write: true, run: expire},
+	"PEXPIRE":     {minArgs: 2, maxArgs: -1, write: true, run: pexpire},
+	"EXPIREAT":    {minArgs: 2, maxArgs: -1, write: true, run: expireat},
+	"PEXPIREAT":   {minArgs: 2, maxArgs: -1, write: true, run: pexpireat},
+	"TTL":         {minArgs: 1, maxArgs: 1, run: ttl},
+	"PTTL":        {minArgs: 1, maxArgs: 1, run: pttl},
+	"EXPIRETIME":  {minArgs: 1, maxArgs: 1, run: expiretime},
+	"PEXPIRETIME": {minArgs: 1, maxArgs: 1, run: pexpiretime},
+	"PERSIST":     {minArgs: 1, maxArgs: 1, write: true, run: persist},
+
+	// Pub/sub operations
+	"PUBLISH": {minArgs: 2, maxArgs: 2, run: publish},
+
+	// General operations
+	"DEL":      {minArgs: 1, maxArgs: -1, write: true, run: del},
+	"EXISTS":   {minArgs: 1, maxArgs: -1, run: exists},
+	"TYPE":     {minArgs: 1, maxArgs: 1, run: typeOf},
+	"RENAME":   {minArgs: 2, maxArgs: 2, write: true, run: rename},
+	"RENAMENX": {minArgs: 2, maxArgs: 2, write: true, run: renamenx},
+	"COPY":     {minArgs: 2, maxArgs: 3, write: true, run: copyKey},
+	"SCAN":     {minArgs: 1, maxArgs: 7, run: scan},
+	"KEYS":     {minArgs: 1, maxArgs: 1, run: keys},
+	"FLUSHDB":  {minArgs: 0, maxArgs: 1, write: true, run: flush},
+	"FLUSHALL": {minArgs: 0, maxArgs: 1, write: true, run: flush},
+}
+
+// Execute runs cmd against c and returns its reply.
+//
+// Replies are nil (a missing value), string, int64, Status or []string.
+func Execute(c cache.Cache, cmd Command) (any, error) {
+	s, err := lookup(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.run(c, cmd.Args)
+}
+
+// Validate reports whether cmd is a supported command with an acceptable
+// number of arguments, without running it.
+func Validate(cmd Command) error {
+	_, err := lookup(cmd)
+	return err
+}
+
+// lookup returns the spec of cmd after checking its number of arguments.
+func lookup(cmd Command) (spec, error) {
+	name := strings.ToUpper(cmd.Name)
+
+	s, ok := commands[name]
+	if !ok {
+		return spec{}, fmt.Errorf("%w '%s'", ErrUnknownCommand, cmd.Name)
+	}
+
+	if len(cmd.Args) < s.minArgs || (s.maxArgs >= 0 && len(cmd.Args) > s.maxArgs) {
+		return spec{}, wrongArgs(name)
+	}
+
+	return s, nil
+}
+
+// Exists reports whether name is a supported command.
+func Exists(name string) bool {
+	_, ok := commands[strings.ToUpper(name)]
+	return ok
+}
+
+// IsWrite reports whether name is a command that modifies the cache.
+func IsWrite(name string) bool {
+	return commands[strings.ToUpper(name)].write
+}
+
+// wrongArgs returns ErrWrongArgs annotated with the command name.
+func wrongArgs(name string) error {
+	return fmt.Errorf("%w for '%s' command", ErrWrongArgs, strings.ToLower(name))
+}
+
+// checkType returns cache.ErrTypeMismatch if key holds a value of a type
+// other than want.
+func checkType(c cache.Cache, key string, want cache.DataType) error {
+	if dataType, found := c.Type(key); found && dataType != want {
+		return cache.ErrTypeMismatch
+	}
+	return nil
+}
+
+// parseInt parses a decimal integer argument.
+func parseInt(arg string) (int64, error) {
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, ErrNotInteger
+	}
+	return n, nil
+}
+
+// boolToInt converts a boolean into the 1/0 integer reply used by Redis.
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,140 @@
+package command
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// del implements DEL key [key ...].
+func del(c cache.Cache, args []string) (any, error) {
+	var removed int64
+	for _, key := range args {
+		err := c.Remove(key)
+		if errors.Is(err, cache.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// exists implements EXISTS key [key ...].
+func exists(c cache.Cache, args []string) (any, error) {
+	var count int64
+	for _, key := range args {
+		count += boolToInt(c.Exists(key))
+	}
+	return count, nil
+}
+
+// typeOf implements TYPE key.
+func typeOf(c cache.Cache, args []string) (any, error) {
+	dataType, found := c.Type(args[0])
+	if !found {
+		return Status("none"), nil
+	}
+	return Status(dataType.String()), nil
+}
+
+// rename implements RENAME key newkey.
+func rename(c cache.Cache, args []string) (any, error) {
+	if err := c.Rename(args[0], args[1], true); err != nil {
+		return nil, err
+	}
+	return StatusOK, nil
+}
+
+// renamenx implements RENAMENX key newkey.
+func renamenx(c cache.Cache, args []string) (any, error) {
+	err := c.Rename(args[0], args[1], false)
+	if errors.Is(err, cache.ErrKeyExists) {
+		return int64(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return int64(1), nil
+}
+
+// copyKey implements COPY source destination [REPLACE].
+func copyKey(c cache.Cache, args []string) (any, error) {
+	replace := false
+	if len(args) == 3 {
+		if !strings.EqualFold(args[2], "REPLACE") {
+			return nil, ErrSyntax
+		}
+		replace = true
+	}
+
+	err := c.Copy(args[0], args[1], replace)
+	if errors.Is(err, cache.ErrKeyNotFound) || errors.Is(err, cache.ErrKeyExists) {
+		return int64(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return int64(1), nil
+}
+
+// scan implements SCAN cursor [MATCH pattern] [COUNT count] [TYPE type].
+// The reply is the next cursor followed by the array of keys.
+func scan(c cache.Cache, args []string) (any, error) {
+	cursor, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var opts cache.ScanOptions
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, ErrSyntax
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			opts.Match = args[i+1]
+		case "COUNT":
+			count, err := parseInt(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if count < 1 {
+				return nil, ErrSyntax
+			}
+			opts.Count = int(count)
+		case "TYPE":
+			opts.Type = strings.ToLower(args[i+1])
+		default:
+			return nil, ErrSyntax
+		}
+	}
+
+	keys, next := c.Scan(cursor, opts)
+	return []any{strconv.FormatUint(next, 10), keys}, nil
+}
+
+// keys implements KEYS pattern.
+func keys(c cache.Cache, args []string) (any, error) {
+	return c.Keys(args[0]), nil
+}
+
+// flush implements FLUSHDB [ASYNC | SYNC] and its FLUSHALL alias.
+func flush(c cache.Cache, args []string) (any, error) {
+	if len(args) == 1 {
+		switch strings.ToUpper(args[0]) {
+		case "ASYNC", "SYNC":
+		default:
+			return nil, ErrSyntax
+		}
+	}
+
+	if err := c.Clear(); err != nil {
+		return nil, err
+	}
+	return StatusOK, nil
+}
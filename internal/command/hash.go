@@ -0,0 +1,82 @@
+package command
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// hset implements HSET key field value [field value ...].
+func hset(c cache.Cache, args []string) (any, error) {
+	if len(args)%2 != 1 {
+		return nil, wrongArgs("hset")
+	}
+
+	fields := make(map[string]string, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		fields[args[i]] = args[i+1]
+	}
+
+	added, err := c.HSet(args[0], fields)
+	if err != nil {
+		return nil, err
+	}
+	return int64(added), nil
+}
+
+// hget implements HGET key field.
+func hget(c cache.Cache, args []string) (any, error) {
+	value, err := c.HGet(args[0], args[1])
+	if errors.Is(err, cache.ErrKeyNotFound) || errors.Is(err, cache.ErrFieldNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// hdel implements HDEL key field [field ...].
+func hdel(c cache.Cache, args []string) (any, error) {
+	removed, err := c.HDel(args[0], args[1:]...)
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return int64(removed), nil
+}
+
+// hgetall implements HGETALL key. Fields are returned in sorted order.
+func hgetall(c cache.Cache, args []string) (any, error) {
+	fields, err := c.HGetAll(args[0])
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	reply := make([]string, 0, 2*len(fields))
+	for _, name := range names {
+		reply = append(reply, name, fields[name])
+	}
+	return reply, nil
+}
+
+// hincrby implements HINCRBY key field increment.
+func hincrby(c cache.Cache, args []string) (any, error) {
+	increment, err := parseInt(args[2])
+	if err != nil {
+		return nil, err
+	}
+	return c.HIncrBy(args[0], args[1], increment)
+}
@@ -0,0 +1,280 @@
+package command
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// ErrZeroRank is returned by LPOS for a RANK of zero.
+var ErrZeroRank = errors.New("RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+
+// lpush implements LPUSH key value [value ...].
+func lpush(c cache.Cache, args []string) (any, error) {
+	return push(c, args, cache.ListFront)
+}
+
+// rpush implements RPUSH key value [value ...].
+func rpush(c cache.Cache, args []string) (any, error) {
+	return push(c, args, cache.ListBack)
+}
+
+// push atomically pushes the values of LPUSH or RPUSH to the given end of
+// the list and returns its new length.
+func push(c cache.Cache, args []string, end cache.ListEnd) (any, error) {
+	length, err := c.ListPush(args[0], end, args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return int64(length), nil
+}
+
+// lpop implements LPOP key.
+func lpop(c cache.Cache, args []string) (any, error) {
+	value, ok := c.PopFront(args[0])
+	if !ok {
+		return nil, checkType(c, args[0], cache.ListType)
+	}
+	return value, nil
+}
+
+// rpop implements RPOP key.
+func rpop(c cache.Cache, args []string) (any, error) {
+	value, ok := c.PopBack(args[0])
+	if !ok {
+		return nil, checkType(c, args[0], cache.ListType)
+	}
+	return value, nil
+}
+
+// lrange implements LRANGE key start stop.
+func lrange(c cache.Cache, args []string) (any, error) {
+	start, end, err := parseRange(args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := c.ListRange(args[0], start, end)
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// llen implements LLEN key.
+func llen(c cache.Cache, args []string) (any, error) {
+	return listLen(c, args[0])
+}
+
+// lindex implements LINDEX key index.
+func lindex(c cache.Cache, args []string) (any, error) {
+	index, err := parseIndex(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := c.ListIndex(args[0], index)
+	if errors.Is(err, cache.ErrKeyNotFound) || errors.Is(err, cache.ErrIndexOutOfRange) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// lset implements LSET key index value.
+func lset(c cache.Cache, args []string) (any, error) {
+	index, err := parseIndex(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.ListSet(args[0], index, args[2]); err != nil {
+		return nil, err
+	}
+	return StatusOK, nil
+}
+
+// linsert implements LINSERT key BEFORE | AFTER pivot value.
+func linsert(c cache.Cache, args []string) (any, error) {
+	var before bool
+	switch strings.ToUpper(args[1]) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+	default:
+		return nil, ErrSyntax
+	}
+
+	length, err := c.ListInsert(args[0], before, args[2], args[3])
+	switch {
+	case errors.Is(err, cache.ErrKeyNotFound):
+		return int64(0), nil
+	case errors.Is(err, cache.ErrPivotNotFound):
+		return int64(-1), nil
+	case err != nil:
+		return nil, err
+	}
+	return int64(length), nil
+}
+
+// lrem implements LREM key count value.
+func lrem(c cache.Cache, args []string) (any, error) {
+	count, err := parseIndex(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := c.ListRemove(args[0], count, args[2])
+	if err != nil {
+		return nil, err
+	}
+	return int64(removed), nil
+}
+
+// ltrim implements LTRIM key start stop.
+func ltrim(c cache.Cache, args []string) (any, error) {
+	start, end, err := parseRange(args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.ListTrim(args[0], start, end); err != nil {
+		return nil, err
+	}
+	return StatusOK, nil
+}
+
+// lpos implements LPOS key element [RANK rank] [COUNT num-matches]
+// [MAXLEN len]. Without COUNT the reply is the first position, or nil.
+func lpos(c cache.Cache, args []string) (any, error) {
+	var opts cache.ListPosOptions
+	withCount := false
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, ErrSyntax
+		}
+		n, err := parseIndex(args[i+1])
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToUpper(args[i]) {
+		case "RANK":
+			if n == 0 {
+				return nil, ErrZeroRank
+			}
+			opts.Rank = n
+		case "COUNT":
+			opts.Count, withCount = n, true
+		case "MAXLEN":
+			opts.MaxLen = n
+		default:
+			return nil, ErrSyntax
+		}
+	}
+	if !withCount {
+		opts.Count = 1
+	}
+
+	positions, err := c.ListPos(args[0], args[1], opts)
+	if err != nil {
+		return nil, err
+	}
+	if !withCount {
+		if len(positions) == 0 {
+			return nil, nil
+		}
+		return int64(positions[0]), nil
+	}
+
+	reply := make([]any, len(positions))
+	for i, position := range positions {
+		reply[i] = int64(position)
+	}
+	return reply, nil
+}
+
+// listLen returns the length of the list stored at key.
+func listLen(c cache.Cache, key string) (any, error) {
+	length, err := c.ListLen(key)
+	if err != nil {
+		return nil, err
+	}
+	return int64(length), nil
+}
+
+// parseIndex parses a list index or count argument.
+func parseIndex(arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, ErrNotInteger
+	}
+	return n, nil
+}
+
+// parseRange parses the start and stop arguments of a list range.
+func parseRange(start, stop string) (int, int, error) {
+	s, err := parseIndex(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	e, err := parseIndex(stop)
+	if err != nil {
+		return 0, 0, err
+	}
+	return s, e, nil
+}
+
+// lmove implements LMOVE source destination LEFT | RIGHT LEFT | RIGHT.
+func lmove(c cache.Cache, args []string) (any, error) {
+	from, err := parseListEnd(args[2])
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseListEnd(args[3])
+	if err != nil {
+		return nil, err
+	}
+	return listMove(c, args[0], args[1], from, to)
+}
+
+// rpoplpush implements RPOPLPUSH source destination.
+func rpoplpush(c cache.Cache, args []string) (any, error) {
+	return listMove(c, args[0], args[1], cache.ListBack, cache.ListFront)
+}
+
+// listMove moves an element between lists, replying with the element or
+// nil if source is empty.
+func listMove(c cache.Cache, source, destination string, from, to cache.ListEnd) (any, error) {
+	value, moved, err := c.ListMove(source, destination, from, to)
+	if err != nil || !moved {
+		return nil, err
+	}
+	return value, nil
+}
+
+// parseListEnd parses LEFT or RIGHT.
+func parseListEnd(arg string) (cache.ListEnd, error) {
+	switch strings.ToUpper(arg) {
+	case "LEFT":
+		return cache.ListFront, nil
+	case "RIGHT":
+		return cache.ListBack, nil
+	default:
+		return 0, ErrSyntax
+	}
+}
+
+// listEndName returns the Redis name of a list end, LEFT or RIGHT.
+func listEndName(end cache.ListEnd) string {
+	if end == cache.ListBack {
+		return "RIGHT"
+	}
+	return "LEFT"
+}
@@ -0,0 +1,10 @@
+package command
+
+import (
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// publish implements PUBLISH channel message.
+func publish(c cache.Cache, args []string) (any, error) {
+	return int64(c.Publish(args[0], args[1])), nil
+}
@@ -0,0 +1,819 @@
+package command
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// Sink receives the writes applied to a Recorder, in the order they were
+// applied to each shard of its store. Writes to different shards may be
+// appended concurrently.
+type Sink interface {
+	Append(cmd Command)
+}
+
+// Recorder is a cache.Store that applies every call to an underlying store
+// and reports each effective write to its sinks as a command. Replaying
+// the recorded commands with Execute against the same starting state
+// reproduces the store.
+//
+// Writes are recorded by their effect rather than verbatim: expirations
+// are absolute, increments become assignments and pops become removals, so
+// that the commands do not depend on when they are replayed.
+//
+// Writes are serialized with their recording per shard of the store, see
+// cache.Partitioner, so that the recorded order of the writes to a shard
+// is their applied order. Writes to different shards involve different
+// keys, so they commute and are applied and recorded concurrently. Reads
+// go straight to the underlying store.
+type Recorder struct {
+	cache.Store
+
+	locks *shardLocks
+	sinks []Sink
+}
+
+// NewRecorder creates a recorder over store reporting to sinks. If store
+// evicts keys, the evictions are recorded as DEL; they happen during the
+// write that caused them, so every write to store must then go through the
+// recorder.
+func NewRecorder(store cache.Store, sinks ...Sink) *Recorder {
+	r := &Recorder{
+		Store: store,
+		locks: newShardLocks(store),
+		sinks: sinks,
+	}
+	if e, ok := store.(cache.Evictor); ok {
+		e.OnEvict(r.evicted)
+	}
+	return r
+}
+
+// shardLocks serializes the writes to each shard of a store with their
+// recording. Without any lock, as in the view of a transaction, which
+// already holds them all, it locks nothing.
+type shardLocks struct {
+	mus []sync.Mutex
+	// index returns the shard of a key, nil for a single shard.
+	index func(key string) int
+}
+
+// newShardLocks creates the locks of the shards of store, a single one if
+// it is not a cache.Partitioner.
+func newShardLocks(store cache.Store) *shardLocks {
+	if p, ok := store.(cache.Partitioner); ok && p.ShardCount() > 1 {
+		return &shardLocks{mus: make([]sync.Mutex, p.ShardCount()), index: p.ShardIndex}
+	}
+	return &shardLocks{mus: make([]sync.Mutex, 1)}
+}
+
+// lock locks the shards of keys, in shard order, and returns a function
+// that unlocks them.
+func (l *shardLocks) lock(keys ...string) (unlock func()) {
+	switch {
+	case len(l.mus) == 0:
+		return func() {}
+	case l.index == nil:
+		l.mus[0].Lock()
+		return l.mus[0].Unlock
+	case len(keys) == 1:
+		mu := &l.mus[l.index(keys[0])]
+		mu.Lock()
+		return mu.Unlock
+	}
+
+	indexes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		indexes = append(indexes, l.index(key))
+	}
+	slices.Sort(indexes)
+	return l.lockIndexes(slices.Compact(indexes))
+}
+
+// lockAll locks every shard and returns a function that unlocks them.
+func (l *shardLocks) lockAll() (unlock func()) {
+	indexes := make([]int, len(l.mus))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return l.lockIndexes(indexes)
+}
+
+// lockIndexes locks the shards at indexes, which are sorted, and returns a
+// function that unlocks them.
+func (l *shardLocks) lockIndexes(indexes []int) (unlock func()) {
+	for _, i := range indexes {
+		l.mus[i].Lock()
+	}
+	return func() {
+		for _, i := range slices.Backward(indexes) {
+			l.mus[i].Unlock()
+		}
+	}
+}
+
+// AddSink adds a sink that receives the writes applied from now on.
+func (r *Recorder) AddSink(sink Sink) {
+	defer r.locks.lockAll()()
+
+	r.sinks = append(r.sinks, sink)
+}
+
+// RemoveSink removes a sink added with NewRecorder or AddSink.
+func (r *Recorder) RemoveSink(sink Sink) {
+	defer r.locks.lockAll()()
+
+	r.sinks = slices.DeleteFunc(r.sinks, func(s Sink) bool { return s == sink })
+}
+
+// Exclusive runs fn while no write can be applied or recorded. A dump taken
+// by fn is therefore consistent with the commands recorded after it.
+func (r *Recorder) Exclusive(fn func()) {
+	defer r.locks.lockAll()()
+
+	fn()
+}
+
+// record reports cmds to every sink. The caller must hold the locks of
+// the shards written.
+func (r *Recorder) record(cmds ...Command) {
+	for _, cmd := range cmds {
+		for _, sink := range r.sinks {
+			sink.Append(cmd)
+		}
+	}
+}
+
+// evicted records the eviction of key. It is called by the store during a
+// write, so the lock of the shard of key is already held.
+func (r *Recorder) evicted(key string) {
+	r.record(New("DEL", key))
+}
+
+// String operations.
+
+// Set stores a string value and records SET.
+func (r *Recorder) Set(key string, value string) error {
+	defer r.locks.lock(key)()
+
+	if err := r.Store.Set(key, value); err != nil {
+		return err
+	}
+	r.record(New("SET", key, value))
+	return nil
+}
+
+// SetWithTTL stores a string value with a TTL and records SET with the
+// absolute expiration the value was given.
+func (r *Recorder) SetWithTTL(key string, value string, ttl time.Duration) error {
+	defer r.locks.lock(key)()
+
+	opts := cache.SetOptions{TTL: max(ttl, 0)}
+	result, err := r.Store.SetWithOptions(key, value, opts)
+	if err != nil {
+		return err
+	}
+	r.recordSet(key, value, opts, result)
+	return nil
+}
+
+// SetWithOptions stores a string value under the conditions given by opts
+// and, if it was stored, records SET with the resulting expiration.
+func (r *Recorder) SetWithOptions(key string, value string, opts cache.SetOptions) (cache.SetResult, error) {
+	defer r.locks.lock(key)()
+
+	result, err := r.Store.SetWithOptions(key, value, opts)
+	if err == nil && result.Stored {
+		r.recordSet(key, value, opts, result)
+	}
+	return result, err
+}
+
+// recordSet records SET for a value stored with opts, with the absolute
+// expiration given in result.
+func (r *Recorder) recordSet(key string, value string, opts cache.SetOptions, result cache.SetResult) {
+	switch {
+	case opts.KeepTTL:
+		r.record(New("SET", key, value, "KEEPTTL"))
+	case !result.ExpireAt.IsZero():
+		r.record(New("SET", key, value, "PXAT", unixMilli(result.ExpireAt)))
+	default:
+		r.record(New("SET", key, value))
+	}
+}
+
+// Update updates an existing string value and records SET with XX and
+// KEEPTTL.
+func (r *Recorder) Update(key string, value string) error {
+	defer r.locks.lock(key)()
+
+	if err := r.Store.Update(key, value); err != nil {
+		return err
+	}
+	r.record(New("SET", key, value, "XX", "KEEPTTL"))
+	return nil
+}
+
+// MSet stores several string values and records MSET.
+func (r *Recorder) MSet(values map[string]string) error {
+	defer r.locks.lock(slices.Collect(maps.Keys(values))...)()
+
+	if err := r.Store.MSet(values); err != nil {
+		return err
+	}
+	r.recordMSet(values)
+	return nil
+}
+
+// MSetNX stores several string values if none of their keys exists and, if
+// it did, records MSET.
+func (r *Recorder) MSetNX(values map[string]string) (bool, error) {
+	defer r.locks.lock(slices.Collect(maps.Keys(values))...)()
+
+	set, err := r.Store.MSetNX(values)
+	if err == nil && set {
+		r.recordMSet(values)
+	}
+	return set, err
+}
+
+// recordMSet records MSET of values, in key order.
+func (r *Recorder) recordMSet(values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+	args := make([]string, 0, 2*len(values))
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		args = append(args, key, values[key])
+	}
+	r.record(New("MSET", args...))
+}
+
+// Incr increments a counter and records SET of the result.
+func (r *Recorder) Incr(key string) (int64, error) {
+	return r.IncrBy(key, 1)
+}
+
+// IncrBy increments a counter and records SET of the result with KEEPTTL,
+// which keeps the expiration of the key as the cache does.
+func (r *Recorder) IncrBy(key string, increment int64) (int64, error) {
+	defer r.locks.lock(key)()
+
+	value, err := r.Store.IncrBy(key, increment)
+	if err == nil {
+		r.record(New("SET", key, strconv.FormatInt(value, 10), "KEEPTTL"))
+	}
+	return value, err
+}
+
+// DecrBy decrements a counter and records SET of the result with KEEPTTL.
+func (r *Recorder) DecrBy(key string, decrement int64) (int64, error) {
+	defer r.locks.lock(key)()
+
+	value, err := r.Store.DecrBy(key, decrement)
+	if err == nil {
+		r.record(New("SET", key, strconv.FormatInt(value, 10), "KEEPTTL"))
+	}
+	return value, err
+}
+
+// IncrByFloat increments a counter and records SET of the result with
+// KEEPTTL. Recording the stored result rather than the increment spares the
+// replay from rounding the sum differently.
+func (r *Recorder) IncrByFloat(key string, increment float64) (float64, error) {
+	defer r.locks.lock(key)()
+
+	value, err := r.Store.IncrByFloat(key, increment)
+	if err == nil {
+		r.record(New("SET", key, cache.FormatFloat(value), "KEEPTTL"))
+	}
+	return value, err
+}
+
+// List operations.
+
+// PushFront adds a value to the front of a list and records LPUSH.
+func (r *Recorder) PushFront(key string, value string) error {
+	defer r.locks.lock(key)()
+
+	if err := r.Store.PushFront(key, value); err != nil {
+		return err
+	}
+	r.record(New("LPUSH", key, value))
+	return nil
+}
+
+// PushBack adds a value to the back of a list and records RPUSH.
+func (r *Recorder) PushBack(key string, value string) error {
+	defer r.locks.lock(key)()
+
+	if err := r.Store.PushBack(key, value); err != nil {
+		return err
+	}
+	r.record(New("RPUSH", key, value))
+	return nil
+}
+
+// ListPush adds values to an end of a list and records LPUSH or RPUSH.
+func (r *Recorder) ListPush(key string, end cache.ListEnd, values ...string) (int, error) {
+	defer r.locks.lock(key)()
+
+	length, err := r.Store.ListPush(key, end, values...)
+	if err == nil && len(values) > 0 {
+		name := "LPUSH"
+		if end == cache.ListBack {
+			name = "RPUSH"
+		}
+		r.record(New(name, append([]string{key}, values...)...))
+	}
+	return length, err
+}
+
+// PopFront removes the first element of a list and records LPOP.
+func (r *Recorder) PopFront(key string) (string, bool) {
+	defer r.locks.lock(key)()
+
+	value, ok := r.Store.PopFront(key)
+	if ok {
+		r.record(New("LPOP", key))
+	}
+	return value, ok
+}
+
+// PopBack removes the last element of a list and records RPOP.
+func (r *Recorder) PopBack(key string) (string, bool) {
+	defer r.locks.lock(key)()
+
+	value, ok := r.Store.PopBack(key)
+	if ok {
+		r.record(New("RPOP", key))
+	}
+	return value, ok
+}
+
+// ListSet replaces a list element and records LSET.
+func (r *Recorder) ListSet(key string, index int, value string) error {
+	defer r.locks.lock(key)()
+
+	if err := r.Store.ListSet(key, index, value); err != nil {
+		return err
+	}
+	r.record(New("LSET", key, strconv.Itoa(index), value))
+	return nil
+}
+
+// ListInsert inserts a list element next to a pivot and records LINSERT.
+func (r *Recorder) ListInsert(key string, before bool, pivot, value string) (int, error) {
+	defer r.locks.lock(key)()
+
+	length, err := r.Store.ListInsert(key, before, pivot, value)
+	if err == nil {
+		where := "AFTER"
+		if before {
+			where = "BEFORE"
+		}
+		r.record(New("LINSERT", key, where, pivot, value))
+	}
+	return length, err
+}
+
+// ListRemove removes list elements and records LREM.
+func (r *Recorder) ListRemove(key string, count int, value string) (int, error) {
+	defer r.locks.lock(key)()
+
+	removed, err := r.Store.ListRemove(key, count, value)
+	if err == nil && removed > 0 {
+		r.record(New("LREM", key, strconv.Itoa(count), value))
+	}
+	return removed, err
+}
+
+// ListTrim trims a list and records LTRIM.
+func (r *Recorder) ListTrim(key string, start, end int) error {
+	defer r.locks.lock(key)()
+
+	if err := r.Store.ListTrim(key, start, end); err != nil {
+		return err
+	}
+	r.record(New("LTRIM", key, strconv.Itoa(start), strconv.Itoa(end)))
+	return nil
+}
+
+// ListMove moves an element between lists and records LMOVE.
+func (r *Recorder) ListMove(source, destination string, from, to cache.ListEnd) (string, bool, error) {
+	defer r.locks.lock(source, destination)()
+
+	value, moved, err := r.Store.ListMove(source, destination, from, to)
+	if err == nil && moved {
+		r.record(New("LMOVE", source, destination, listEndName(from), listEndName(to)))
+	}
+	return value, moved, err
+}
+
+// Hash operations.
+
+// HSet sets hash fields and records HSET.
+func (r *Recorder) HSet(key string, fields map[string]string) (int, error) {
+	defer r.locks.lock(key)()
+
+	added, err := r.Store.HSet(key, fields)
+	if err != nil || len(fields) == 0 {
+		return added, err
+	}
+
+	args := []string{key}
+	for _, field := range slices.Sorted(maps.Keys(fields)) {
+		args = append(args, field, fields[field])
+	}
+	r.record(New("HSET", args...))
+	return added, nil
+}
+
+// HDel removes hash fields and records HDEL.
+func (r *Recorder) HDel(key string, fields ...string) (int, error) {
+	defer r.locks.lock(key)()
+
+	removed, err := r.Store.HDel(key, fields...)
+	if err == nil && removed > 0 {
+		r.record(New("HDEL", append([]string{key}, fields...)...))
+	}
+	return removed, err
+}
+
+// HIncrBy increments a hash field and records HSET of the result.
+func (r *Recorder) HIncrBy(key string, field string, increment int64) (int64, error) {
+	defer r.locks.lock(key)()
+
+	value, err := r.Store.HIncrBy(key, field, increment)
+	if err == nil {
+		r.record(New("HSET", key, field, strconv.FormatInt(value, 10)))
+	}
+	return value, err
+}
+
+// Set operations.
+
+// SAdd adds set members and records SADD.
+func (r *Recorder) SAdd(key string, members ...string) (int, error) {
+	defer r.locks.lock(key)()
+
+	added, err := r.Store.SAdd(key, members...)
+	if err == nil && added > 0 {
+		r.record(New("SADD", append([]string{key}, members...)...))
+	}
+	return added, err
+}
+
+// SRem removes set members and records SREM.
+func (r *Recorder) SRem(key string, members ...string) (int, error) {
+	defer r.locks.lock(key)()
+
+	removed, err := r.Store.SRem(key, members...)
+	if err == nil && removed > 0 {
+		r.record(New("SREM", append([]string{key}, members...)...))
+	}
+	return removed, err
+}
+
+// SInterStore stores an intersection and records the resulting set.
+func (r *Recorder) SInterStore(destination string, keys ...string) (int, error) {
+	return r.storeSet(destination, keys, func() (int, error) {
+		return r.Store.SInterStore(destination, keys...)
+	})
+}
+
+// SUnionStore stores a union and records the resulting set.
+func (r *Recorder) SUnionStore(destination string, keys ...string) (int, error) {
+	return r.storeSet(destination, keys, func() (int, error) {
+		return r.Store.SUnionStore(destination, keys...)
+	})
+}
+
+// SDiffStore stores a difference and records the resulting set.
+func (r *Recorder) SDiffStore(destination string, keys ...string) (int, error) {
+	return r.storeSet(destination, keys, func() (int, error) {
+		return r.Store.SDiffStore(destination, keys...)
+	})
+}
+
+// storeSet runs a set store operation and records its result as DEL
+// followed by SADD, so that replay does not depend on the source keys.
+func (r *Recorder) storeSet(destination string, keys []string, store func() (int, error)) (int, error) {
+	defer r.locks.lock(append([]string{destination}, keys...)...)()
+
+	count, err := store()
+	if err != nil {
+		return count, err
+	}
+
+	r.record(New("DEL", destination))
+	if count > 0 {
+		members, err := r.Store.SMembers(destination)
+		if err != nil {
+			return count, err
+		}
+		r.record(New("SADD", append([]string{destination}, members...)...))
+	}
+	return count, nil
+}
+
+// Sorted set operations.
+
+// ZAdd adds sorted set members and records ZADD with the same options.
+func (r *Recorder) ZAdd(key string, opts cache.ZAddOptions, members ...cache.Z) (int, error) {
+	defer r.locks.lock(key)()
+
+	count, err := r.Store.ZAdd(key, opts, members...)
+	if err != nil || len(members) == 0 {
+		return count, err
+	}
+
+	args := []string{key}
+	for _, flag := range []struct {
+		name string
+		set  bool
+	}{{"NX", opts.NX}, {"XX", opts.XX}, {"GT", opts.GT}, {"LT", opts.LT}} {
+		if flag.set {
+			args = append(args, flag.name)
+		}
+	}
+	for _, m := range members {
+		args = append(args, formatScore(m.Score), m.Member)
+	}
+	r.record(New("ZADD", args...))
+	return count, nil
+}
+
+// ZIncrBy increments a member score and records ZADD of the result.
+func (r *Recorder) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	defer r.locks.lock(key)()
+
+	score, err := r.Store.ZIncrBy(key, increment, member)
+	if err == nil {
+		r.record(New("ZADD", key, formatScore(score), member))
+	}
+	return score, err
+}
+
+// ZRem removes sorted set members and records ZREM.
+func (r *Recorder) ZRem(key string, members ...string) (int, error) {
+	defer r.locks.lock(key)()
+
+	removed, err := r.Store.ZRem(key, members...)
+	if err == nil && removed > 0 {
+		r.record(New("ZREM", append([]string{key}, members...)...))
+	}
+	return removed, err
+}
+
+// ZPopMin pops the lowest scored members and records ZREM of them.
+func (r *Recorder) ZPopMin(key string, count int) ([]cache.Z, error) {
+	defer r.locks.lock(key)()
+
+	return r.recordPopped(key)(r.Store.ZPopMin(key, count))
+}
+
+// ZPopMax pops the highest scored members and records ZREM of them.
+func (r *Recorder) ZPopMax(key string, count int) ([]cache.Z, error) {
+	defer r.locks.lock(key)()
+
+	return r.recordPopped(key)(r.Store.ZPopMax(key, count))
+}
+
+// recordPopped returns a function recording the removal of popped members.
+// The caller must hold the lock of the shard of key.
+func (r *Recorder) recordPopped(key string) func([]cache.Z, error) ([]cache.Z, error) {
+	return func(popped []cache.Z, err error) ([]cache.Z, error) {
+		if err == nil && len(popped) > 0 {
+			args := []string{key}
+			for _, m := range popped {
+				args = append(args, m.Member)
+			}
+			r.record(New("ZREM", args...))
+		}
+		return popped, err
+	}
+}
+
+// TTL operations.
+
+// SetTTL sets the TTL of a key and records PEXPIREAT, or PERSIST for a
+// non-positive TTL.
+func (r *Recorder) SetTTL(key string, ttl time.Duration) error {
+	defer r.locks.lock(key)()
+
+	if ttl <= 0 {
+		if err := r.Store.SetTTL(key, ttl); err != nil {
+			return err
+		}
+		r.record(New("PERSIST", key))
+		return nil
+	}
+
+	// The key is given the recorded expiration.
+	at := time.Now().Add(ttl)
+	if err := r.Store.ExpireAt(key, at); err != nil {
+		return err
+	}
+	r.record(New("PEXPIREAT", key, unixMilli(at)))
+	return nil
+}
+
+// ExpireAt sets the expiration time of a key and records PEXPIREAT.
+func (r *Recorder) ExpireAt(key string, at time.Time) error {
+	defer r.locks.lock(key)()
+
+	if err := r.Store.ExpireAt(key, at); err != nil {
+		return err
+	}
+	r.record(New("PEXPIREAT", key, unixMilli(at)))
+	return nil
+}
+
+// ExpireWithOptions expires a key after ttl if opts allow it and records
+// PEXPIREAT with the resulting time.
+func (r *Recorder) ExpireWithOptions(key string, ttl time.Duration, opts cache.ExpireOptions) (bool, error) {
+	return r.ExpireAtWithOptions(key, time.Now().Add(ttl), opts)
+}
+
+// ExpireAtWithOptions sets the expiration time of a key if opts allow it
+// and records PEXPIREAT.
+func (r *Recorder) ExpireAtWithOptions(key string, at time.Time, opts cache.ExpireOptions) (bool, error) {
+	defer r.locks.lock(key)()
+
+	set, err := r.Store.ExpireAtWithOptions(key, at, opts)
+	if set {
+		r.record(New("PEXPIREAT", key, unixMilli(at)))
+	}
+	return set, err
+}
+
+// RemoveTTL removes the TTL of a key and records PERSIST.
+func (r *Recorder) RemoveTTL(key string) error {
+	defer r.locks.lock(key)()
+
+	if err := r.Store.RemoveTTL(key); err != nil {
+		return err
+	}
+	r.record(New("PERSIST", key))
+	return nil
+}
+
+// General operations.
+
+// Remove removes a key and records DEL.
+func (r *Recorder) Remove(key string) error {
+	defer r.locks.lock(key)()
+
+	if err := r.Store.Remove(key); err != nil {
+		return err
+	}
+	r.record(New("DEL", key))
+	return nil
+}
+
+// Rename renames a key and records RENAME, or RENAMENX without replace.
+func (r *Recorder) Rename(key, newKey string, replace bool) error {
+	defer r.locks.lock(key, newKey)()
+
+	if err := r.Store.Rename(key, newKey, replace); err != nil {
+		return err
+	}
+	if replace {
+		r.record(New("RENAME", key, newKey))
+	} else {
+		r.record(New("RENAMENX", key, newKey))
+	}
+	return nil
+}
+
+// Copy copies a key and records COPY.
+func (r *Recorder) Copy(source, destination string, replace bool) error {
+	defer r.locks.lock(source, destination)()
+
+	if err := r.Store.Copy(source, destination, replace); err != nil {
+		return err
+	}
+	if replace {
+		r.record(New("COPY", source, destination, "REPLACE"))
+	} else {
+		r.record(New("COPY", source, destination))
+	}
+	return nil
+}
+
+// Clear removes all keys and records FLUSHALL.
+func (r *Recorder) Clear() error {
+	defer r.locks.lockAll()()
+
+	if err := r.Store.Clear(); err != nil {
+		return err
+	}
+	r.record(New("FLUSHALL"))
+	return nil
+}
+
+// ClearContext removes all keys unless ctx is done first, see
+// cache.GeneralCmdableContext, and records FLUSHALL if it did.
+func (r *Recorder) ClearContext(ctx context.Context) error {
+	defer r.locks.lockAll()()
+
+	if err := cache.WithContext(r.Store).ClearContext(ctx); err != nil {
+		return err
+	}
+	r.record(New("FLUSHALL"))
+	return nil
+}
+
+// ScanContext scans the underlying store, stopping once ctx is done.
+func (r *Recorder) ScanContext(ctx context.Context, cursor uint64, opts cache.ScanOptions) ([]string, uint64, error) {
+	return cache.WithContext(r.Store).ScanContext(ctx, cursor, opts)
+}
+
+// KeysContext lists the keys of the underlying store, stopping once ctx is
+// done.
+func (r *Recorder) KeysContext(ctx context.Context, pattern string) ([]string, error) {
+	return cache.WithContext(r.Store).KeysContext(ctx, pattern)
+}
+
+// Restore stores the given entries and records the commands that rebuild
+// them.
+func (r *Recorder) Restore(entries []cache.Entry) error {
+	defer r.locks.lockAll()()
+
+	if err := r.Store.Restore(entries); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		r.record(New("DEL", entry.Key))
+		r.record(EntryCommands(entry)...)
+	}
+	return nil
+}
+
+// entryBatchSize is the number of elements EntryCommands puts in a single
+// command, which keeps the commands for large collections reasonably small.
+const entryBatchSize = 64
+
+// EntryCommands returns the commands that rebuild entry on a cache where
+// its key does not exist.
+func EntryCommands(entry cache.Entry) []Command {
+	var cmds []Command
+	batch := func(name string, elems []string) {
+		for len(elems) > 0 {
+			n := min(len(elems), entryBatchSize)
+			cmds = append(cmds, New(name, append([]string{entry.Key}, elems[:n]...)...))
+			elems = elems[n:]
+		}
+	}
+
+	switch value := entry.Value.(type) {
+	case string:
+		if !entry.ExpireAt.IsZero() {
+			return []Command{New("SET", entry.Key, value, "PXAT", unixMilli(entry.ExpireAt))}
+		}
+		return []Command{New("SET", entry.Key, value)}
+	case []string:
+		if entry.Type == cache.SetType {
+			batch("SADD", value)
+		} else {
+			batch("RPUSH", value)
+		}
+	case map[string]string:
+		pairs := make([]string, 0, 2*len(value))
+		for _, field := range slices.Sorted(maps.Keys(value)) {
+			pairs = append(pairs, field, value[field])
+		}
+		for len(pairs) > 0 {
+			n := min(len(pairs), 2*entryBatchSize)
+			cmds = append(cmds, New("HSET", append([]string{entry.Key}, pairs[:n]...)...))
+			pairs = pairs[n:]
+		}
+	case []cache.Z:
+		pairs := make([]string, 0, 2*len(value))
+		for _, m := range value {
+			pairs = append(pairs, formatScore(m.Score), m.Member)
+		}
+		for len(pairs) > 0 {
+			n := min(len(pairs), 2*entryBatchSize)
+			cmds = append(cmds, New("ZADD", append([]string{entry.Key}, pairs[:n]...)...))
+			pairs = pairs[n:]
+		}
+	}
+
+	if len(cmds) > 0 && !entry.ExpireAt.IsZero() {
+		cmds = append(cmds, New("PEXPIREAT", entry.Key, unixMilli(entry.ExpireAt)))
+	}
+	return cmds
+}
+
+// unixMilli formats t as a Unix timestamp in milliseconds.
+func unixMilli(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
@@ -0,0 +1,354 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// collector is a Sink collecting the recorded commands; it may be
+// appended to concurrently.
+type collector struct {
+	mu   sync.Mutex
+	cmds []Command
+}
+
+func (c *collector) Append(cmd Command) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cmds = append(c.cmds, cmd)
+}
+
+// replay applies the collected commands to a new cache, those between
+// MULTI and EXEC as a transaction, and returns it.
+func (c *collector) replay(t *testing.T) *cache.MemoryCache {
+	t.Helper()
+
+	replayed := cache.NewMemoryCache(0)
+	var tx []Command
+	inTx := false
+	for _, cmd := range c.cmds {
+		switch {
+		case cmd.Name == Multi:
+			inTx = true
+		case cmd.Name == Exec:
+			_, err := ExecTx(replayed, tx)
+			requireNoError(t, err, "ExecTx(%v) failed: %v", tx, err)
+			tx, inTx = nil, false
+		case inTx:
+			tx = append(tx, cmd)
+		default:
+			_, err := Execute(replayed, cmd)
+			requireNoError(t, err, "Execute(%v) failed: %v", cmd, err)
+		}
+	}
+	require(t, !inTx, "unterminated transaction %v", tx)
+	return replayed
+}
+
+func TestRecorder_Records(t *testing.T) {
+	t.Parallel()
+
+	at := time.Now().Add(time.Hour)
+	ms := strconv.FormatInt(at.UnixMilli(), 10)
+
+	tests := []struct {
+		name string
+		// setup prepares the cache directly, without recording anything.
+		setup func(c *cache.MemoryCache)
+		write func(r *Recorder)
+		want  []Command
+	}{
+		{
+			name:  "set",
+			write: func(r *Recorder) { _ = r.Set("k", "v") },
+			want:  []Command{New("SET", "k", "v")},
+		},
+		{
+			name: "set at absolute expiration",
+			write: func(r *Recorder) {
+				_, _ = r.SetWithOptions("k", "v", cache.SetOptions{ExpireAt: at})
+			},
+			want: []Command{New("SET", "k", "v", "PXAT", ms)},
+		},
+		{
+			name:  "set not stored",
+			setup: func(c *cache.MemoryCache) { _ = c.Set("k", "v") },
+			write: func(r *Recorder) {
+				_, _ = r.SetWithOptions("k", "w", cache.SetOptions{NX: true})
+			},
+		},
+		{
+			name:  "expire at",
+			setup: func(c *cache.MemoryCache) { _ = c.Set("k", "v") },
+			write: func(r *Recorder) { _ = r.ExpireAt("k", at) },
+			want:  []Command{New("PEXPIREAT", "k", ms)},
+		},
+		{
+			name:  "persist",
+			setup: func(c *cache.MemoryCache) { _ = c.SetWithTTL("k", "v", time.Hour) },
+			write: func(r *Recorder) { _ = r.SetTTL("k", 0) },
+			want:  []Command{New("PERSIST", "k")},
+		},
+		{
+			name:  "incr",
+			write: func(r *Recorder) { _, _ = r.Incr("n") },
+			want:  []Command{New("SET", "n", "1", "KEEPTTL")},
+		},
+		{
+			name:  "decrby",
+			setup: func(c *cache.MemoryCache) { _ = c.Set("n", "5") },
+			write: func(r *Recorder) { _, _ = r.DecrBy("n", 7) },
+			want:  []Command{New("SET", "n", "-2", "KEEPTTL")},
+		},
+		{
+			name:  "incrbyfloat",
+			setup: func(c *cache.MemoryCache) { _ = c.Set("f", "0.1") },
+			write: func(r *Recorder) { _, _ = r.IncrByFloat("f", 0.2) },
+			want:  []Command{New("SET", "f", "0.30000000000000004", "KEEPTTL")},
+		},
+		{
+			name:  "incr of a non-integer",
+			setup: func(c *cache.MemoryCache) { _ = c.Set("n", "x") },
+			write: func(r *Recorder) { _, _ = r.Incr("n") },
+		},
+		{
+			name:  "hincrby",
+			setup: func(c *cache.MemoryCache) { _, _ = c.HSet("h", map[string]string{"f": "1"}) },
+			write: func(r *Recorder) { _, _ = r.HIncrBy("h", "f", 2) },
+			want:  []Command{New("HSET", "h", "f", "3")},
+		},
+		{
+			name:  "lpop",
+			setup: func(c *cache.MemoryCache) { _, _ = c.ListPush("l", cache.ListBack, "a", "b") },
+			write: func(r *Recorder) { _, _ = r.PopFront("l") },
+			want:  []Command{New("LPOP", "l")},
+		},
+		{
+			name:  "lpop of a missing list",
+			write: func(r *Recorder) { _, _ = r.PopFront("l") },
+		},
+		{
+			name:  "blocking pop",
+			setup: func(c *cache.MemoryCache) { _, _ = c.ListPush("l", cache.ListBack, "a", "b") },
+			write: func(r *Recorder) {
+				_, _, _ = cache.BPop(context.Background(), r, []string{"missing", "l"}, cache.ListBack, time.Second)
+			},
+			want: []Command{New("RPOP", "l")},
+		},
+		{
+			name: "zpopmin",
+			setup: func(c *cache.MemoryCache) {
+				_, _ = c.ZAdd("z", cache.ZAddOptions{}, cache.Z{Member: "a", Score: 1}, cache.Z{Member: "b", Score: 2})
+			},
+			write: func(r *Recorder) { _, _ = r.ZPopMin("z", 1) },
+			want:  []Command{New("ZREM", "z", "a")},
+		},
+		{
+			name:  "remove of a missing key",
+			write: func(r *Recorder) { _ = r.Remove("k") },
+		},
+		{
+			name:  "renamenx",
+			setup: func(c *cache.MemoryCache) { _ = c.Set("k", "v") },
+			write: func(r *Recorder) { _ = r.Rename("k", "n", false) },
+			want:  []Command{New("RENAMENX", "k", "n")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := cache.NewMemoryCache(0)
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+			var sink collector
+			tt.write(NewRecorder(c, &sink))
+			require(t, reflect.DeepEqual(sink.cmds, tt.want), "recorded %v, want %v", sink.cmds, tt.want)
+		})
+	}
+}
+
+func TestRecorder_AbsoluteExpirations(t *testing.T) {
+	t.Parallel()
+
+	c := cache.NewMemoryCache(0)
+	var sink collector
+	r := NewRecorder(c, &sink)
+
+	requireNoError(t, r.SetWithTTL("setex", "v", time.Hour), "SetWithTTL() failed")
+	requireNoError(t, r.Set("expire", "v"), "Set() failed")
+	requireNoError(t, r.SetTTL("expire", time.Hour), "SetTTL() failed")
+	requireNoError(t, r.Set("counter", "1"), "Set() failed")
+	_, err := r.ExpireWithOptions("counter", time.Hour, cache.ExpireOptions{})
+	requireNoError(t, err, "ExpireWithOptions() failed: %v", err)
+	_, err = r.IncrByFloat("counter", 0.5)
+	requireNoError(t, err, "IncrByFloat() failed: %v", err)
+
+	// The recorded expirations are the ones the keys were given, however
+	// late they are replayed.
+	time.Sleep(10 * time.Millisecond)
+	replayed := sink.replay(t)
+	for _, key := range []string{"setex", "expire", "counter"} {
+		want, _ := c.ExpireTime(key)
+		got, ok := replayed.ExpireTime(key)
+		require(t, ok && got.UnixMilli() == want.UnixMilli(), "%q expires at %v after replay, want %v", key, got, want)
+	}
+	value, _ := replayed.Get("counter")
+	require(t, value == "1.5", "replayed Get(counter) = %q, want %q", value, "1.5")
+}
+
+func TestRecorder_Atomically(t *testing.T) {
+	t.Parallel()
+
+	c := cache.NewMemoryCache(0, cache.WithMaxMemory(1024, cache.AllKeysRandom))
+	var sink collector
+	r := NewRecorder(c, &sink)
+
+	// The writes of the transaction, including the evictions they cause,
+	// only reach the sink once it is over, framed by MULTI and EXEC.
+	r.Atomically(func(store cache.Store) {
+		for i := range 100 {
+			_ = store.Set(fmt.Sprint("key", i), "value")
+		}
+		require(t, len(sink.cmds) == 0, "recorded %v during the transaction", sink.cmds)
+	})
+	require(t, c.MemoryStats().EvictedKeys > 0, "no key was evicted")
+
+	var sets, dels int
+	for _, cmd := range sink.cmds[1 : len(sink.cmds)-1] {
+		switch cmd.Name {
+		case "SET":
+			sets++
+		case "DEL":
+			dels++
+		default:
+			t.Fatalf("recorded %v in the transaction", cmd)
+		}
+	}
+	first, last := sink.cmds[0], sink.cmds[len(sink.cmds)-1]
+	require(t, first.Name == Multi && last.Name == Exec, "transaction recorded between %v and %v", first, last)
+	require(t, sets == 100 && dels > 0, "recorded %d SET and %d DEL, want 100 SET and some DEL", sets, dels)
+	got, want := sink.replay(t).Dump(), c.Dump()
+	require(t, reflect.DeepEqual(got, want), "replayed contents = %v, want %v", got, want)
+
+	// The sinks are restored afterwards and a single write is recorded on
+	// its own.
+	sink.cmds = nil
+	r.Atomically(func(store cache.Store) { _ = store.Remove("key99") })
+	requireNoError(t, r.Set("after", "v"), "Set() failed")
+	wantAfter := []Command{New("DEL", "key99"), New("SET", "after", "v")}
+	require(t, reflect.DeepEqual(sink.cmds, wantAfter), "recorded %v, want %v", sink.cmds, wantAfter)
+}
+
+func TestRecorder_ExecTx(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cmds []Command
+		want []Command
+	}{
+		{
+			name: "several writes",
+			cmds: []Command{
+				New("SET", "s", "1"),
+				New("INCRBYFLOAT", "f", "0.1"),
+				New("LPUSH", "s", "x"),
+				New("GET", "s"),
+				New("INCRBY", "s", "2"),
+			},
+			want: []Command{
+				New(Multi),
+				New("SET", "s", "1"),
+				New("SET", "f", "0.1", "KEEPTTL"),
+				New("SET", "s", "3", "KEEPTTL"),
+				New(Exec),
+			},
+		},
+		{
+			name: "single write",
+			cmds: []Command{New("GET", "s"), New("SET", "s", "1")},
+			want: []Command{New("SET", "s", "1")},
+		},
+		{
+			name: "reads only",
+			cmds: []Command{New("GET", "s")},
+		},
+		{
+			name: "unknown command",
+			cmds: []Command{New("SET", "s", "1"), New("NOPE")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var sink collector
+			_, _ = ExecTx(NewRecorder(cache.NewMemoryCache(0), &sink), tt.cmds)
+			require(t, reflect.DeepEqual(sink.cmds, tt.want), "recorded %v, want %v", sink.cmds, tt.want)
+		})
+	}
+}
+
+func TestRecorder_ConcurrentShards(t *testing.T) {
+	t.Parallel()
+
+	const workers, writes = 8, 200
+	c := cache.NewShardedCache(8, 0)
+	var sink collector
+	r := NewRecorder(c, &sink)
+
+	// The workers write to overlapping keys, some of them spread over
+	// several shards, so that writes to different shards are recorded
+	// concurrently.
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range writes {
+				key := "key:" + strconv.Itoa((w+i)%16)
+				switch i % 5 {
+				case 0:
+					_ = r.Set(key, strconv.Itoa(w))
+				case 1:
+					_, _ = r.ListPush("list:"+key, cache.ListBack, strconv.Itoa(i))
+				case 2:
+					_, _ = r.SAdd("set:"+key, strconv.Itoa(w))
+				case 3:
+					_ = r.MSet(map[string]string{key: "m", "key:" + strconv.Itoa(i%16): "n"})
+				case 4:
+					_ = r.Remove(key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, want := sink.replay(t).Dump(), c.Dump()
+	require(t, reflect.DeepEqual(got, want), "replayed contents = %v, want %v", got, want)
+}
+
+func requireNoError(t *testing.T, err error, format string, args ...any) {
+	t.Helper()
+	require(t, errors.Is(err, nil), format, args...)
+}
+
+func require(t *testing.T, condition bool, format string, args ...any) {
+	t.Helper()
+	if !condition {
+		t.Fatalf(format, args...)
+	}
+}
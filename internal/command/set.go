@@ -0,0 +1,100 @@
+package command
+
+import (
+	"errors"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// sadd implements SADD key member [member ...].
+func sadd(c cache.Cache, args []string) (any, error) {
+	added, err := c.SAdd(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return int64(added), nil
+}
+
+// srem implements SREM key member [member ...].
+func srem(c cache.Cache, args []string) (any, error) {
+	removed, err := c.SRem(args[0], args[1:]...)
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return int64(removed), nil
+}
+
+// sismember implements SISMEMBER key member.
+func sismember(c cache.Cache, args []string) (any, error) {
+	isMember, err := c.SIsMember(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return boolToInt(isMember), nil
+}
+
+// smembers implements SMEMBERS key.
+func smembers(c cache.Cache, args []string) (any, error) {
+	members, err := c.SMembers(args[0])
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// scard implements SCARD key.
+func scard(c cache.Cache, args []string) (any, error) {
+	count, err := c.SCard(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return int64(count), nil
+}
+
+// sinter implements SINTER key [key ...].
+func sinter(c cache.Cache, args []string) (any, error) {
+	return c.SInter(args...)
+}
+
+// sunion implements SUNION key [key ...].
+func sunion(c cache.Cache, args []string) (any, error) {
+	return c.SUnion(args...)
+}
+
+// sdiff implements SDIFF key [key ...].
+func sdiff(c cache.Cache, args []string) (any, error) {
+	return c.SDiff(args...)
+}
+
+// sinterstore implements SINTERSTORE destination key [key ...].
+func sinterstore(c cache.Cache, args []string) (any, error) {
+	count, err := c.SInterStore(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return int64(count), nil
+}
+
+// sunionstore implements SUNIONSTORE destination key [key ...].
+func sunionstore(c cache.Cache, args []string) (any, error) {
+	count, err := c.SUnionStore(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return int64(count), nil
+}
+
+// sdiffstore implements SDIFFSTORE destination key [key ...].
+func sdiffstore(c cache.Cache, args []string) (any, error) {
+	count, err := c.SDiffStore(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return int64(count), nil
+}
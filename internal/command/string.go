@@ -0,0 +1,186 @@
+package command
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// ErrInvalidExpire is returned for non-positive expiry arguments.
+var ErrInvalidExpire = errors.New("invalid expire time")
+
+// get implements GET key.
+func get(c cache.Cache, args []string) (any, error) {
+	value, ok := c.Get(args[0])
+	if !ok {
+		return nil, checkType(c, args[0], cache.StringType)
+	}
+	return value, nil
+}
+
+// set implements SET key value [NX | XX] [GET] [EX seconds |
+// PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds |
+// KEEPTTL].
+//
+// The reply is OK, or nil if NX or XX prevented the write; with GET it is
+// the previous value instead.
+func set(c cache.Cache, args []string) (any, error) {
+	key, value := args[0], args[1]
+
+	var (
+		opts      cache.SetOptions
+		hasExpiry bool
+	)
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); opt {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "GET":
+			opts.Get = true
+		case "KEEPTTL":
+			if hasExpiry {
+				return nil, ErrSyntax
+			}
+			hasExpiry = true
+			opts.KeepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if hasExpiry || i+1 >= len(args) {
+				return nil, ErrSyntax
+			}
+			i++
+
+			n, err := parseInt(args[i])
+			if err != nil {
+				return nil, err
+			}
+			if n <= 0 {
+				return nil, ErrInvalidExpire
+			}
+			hasExpiry = true
+
+			switch opt {
+			case "EX":
+				opts.TTL = time.Duration(n) * time.Second
+			case "PX":
+				opts.TTL = time.Duration(n) * time.Millisecond
+			case "EXAT":
+				opts.ExpireAt = time.Unix(n, 0)
+			case "PXAT":
+				opts.ExpireAt = time.UnixMilli(n)
+			}
+		default:
+			return nil, ErrSyntax
+		}
+	}
+	if opts.NX && opts.XX {
+		return nil, ErrSyntax
+	}
+
+	result, err := c.SetWithOptions(key, value, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case opts.Get && result.HadPrevious:
+		return result.Previous, nil
+	case opts.Get || !result.Stored:
+		return nil, nil
+	default:
+		return StatusOK, nil
+	}
+}
+
+// mget implements MGET key [key ...]. The reply has the value of each key
+// in order, nil for keys that are missing or hold another type.
+func mget(c cache.Cache, args []string) (any, error) {
+	values := c.MGet(args...)
+
+	reply := make([]any, len(args))
+	for i, key := range args {
+		if value, found := values[key]; found {
+			reply[i] = value
+		}
+	}
+	return reply, nil
+}
+
+// mset implements MSET key value [key value ...].
+func mset(c cache.Cache, args []string) (any, error) {
+	if len(args)%2 != 0 {
+		return nil, wrongArgs("mset")
+	}
+	if err := c.MSet(pairs(args)); err != nil {
+		return nil, err
+	}
+	return StatusOK, nil
+}
+
+// msetnx implements MSETNX key value [key value ...].
+func msetnx(c cache.Cache, args []string) (any, error) {
+	if len(args)%2 != 0 {
+		return nil, wrongArgs("msetnx")
+	}
+	set, err := c.MSetNX(pairs(args))
+	if err != nil {
+		return nil, err
+	}
+	return boolToInt(set), nil
+}
+
+// pairs converts an even number of key value arguments into a map; a
+// later value of a key replaces an earlier one.
+func pairs(args []string) map[string]string {
+	values := make(map[string]string, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		values[args[i]] = args[i+1]
+	}
+	return values
+}
+
+// incr implements INCR key.
+func incr(c cache.Cache, args []string) (any, error) {
+	return c.Incr(args[0])
+}
+
+// decr implements DECR key.
+func decr(c cache.Cache, args []string) (any, error) {
+	return c.DecrBy(args[0], 1)
+}
+
+// incrby implements INCRBY key increment.
+func incrby(c cache.Cache, args []string) (any, error) {
+	increment, err := parseInt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return c.IncrBy(args[0], increment)
+}
+
+// decrby implements DECRBY key decrement.
+func decrby(c cache.Cache, args []string) (any, error) {
+	decrement, err := parseInt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return c.DecrBy(args[0], decrement)
+}
+
+// incrbyfloat implements INCRBYFLOAT key increment. The reply is the new
+// value as a bulk string.
+func incrbyfloat(c cache.Cache, args []string) (any, error) {
+	increment, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		return nil, cache.ErrNotFloat
+	}
+	value, err := c.IncrByFloat(args[0], increment)
+	if err != nil {
+		return nil, err
+	}
+	return cache.FormatFloat(value), nil
+}
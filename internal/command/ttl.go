@@ -0,0 +1,161 @@
+package command
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// expire implements EXPIRE key seconds [NX | XX | GT | LT]. A non-positive
+// timeout deletes the key, as in Redis.
+func expire(c cache.Cache, args []string) (any, error) {
+	return expireKey(c, args, time.Second, false)
+}
+
+// pexpire implements PEXPIRE key milliseconds [NX | XX | GT | LT].
+func pexpire(c cache.Cache, args []string) (any, error) {
+	return expireKey(c, args, time.Millisecond, false)
+}
+
+// expireat implements EXPIREAT key unix-time-seconds [NX | XX | GT | LT].
+// A time in the past deletes the key, as in Redis.
+func expireat(c cache.Cache, args []string) (any, error) {
+	return expireKey(c, args, time.Second, true)
+}
+
+// pexpireat implements PEXPIREAT key unix-time-milliseconds
+// [NX | XX | GT | LT].
+func pexpireat(c cache.Cache, args []string) (any, error) {
+	return expireKey(c, args, time.Millisecond, true)
+}
+
+// expireKey sets the expiration of a key to a time given in unit, either
+// from now or, if absolute, since the Unix epoch. The reply is 1 if the
+// expiration was set and 0 if the key is missing or the options prevented
+// it.
+func expireKey(c cache.Cache, args []string, unit time.Duration, absolute bool) (any, error) {
+	n, err := parseInt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	opts, err := parseExpireOptions(args[2:])
+	if err != nil {
+		return nil, err
+	}
+
+	var at time.Time
+	switch {
+	case absolute && unit == time.Second:
+		at = time.Unix(n, 0)
+	case absolute:
+		at = time.UnixMilli(n)
+	case n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit):
+		return nil, ErrInvalidExpire
+	default:
+		at = time.Now().Add(time.Duration(n) * unit)
+	}
+
+	set, err := c.ExpireAtWithOptions(args[0], at, opts)
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return boolToInt(set), nil
+}
+
+// parseExpireOptions parses the NX, XX, GT and LT options of the EXPIRE
+// family of commands.
+func parseExpireOptions(args []string) (cache.ExpireOptions, error) {
+	var opts cache.ExpireOptions
+	for _, arg := range args {
+		switch strings.ToUpper(arg) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "GT":
+			opts.GT = true
+		case "LT":
+			opts.LT = true
+		default:
+			return cache.ExpireOptions{}, ErrSyntax
+		}
+	}
+	return opts, nil
+}
+
+// ttl implements TTL key. It returns -2 for a missing key and -1 for a key
+// without an expiration.
+func ttl(c cache.Cache, args []string) (any, error) {
+	remaining, found := c.GetTTL(args[0])
+	if !found {
+		return int64(-2), nil
+	}
+	if remaining < 0 {
+		return int64(-1), nil
+	}
+	return int64((remaining + time.Second/2) / time.Second), nil
+}
+
+// pttl implements PTTL key, which is TTL in milliseconds.
+func pttl(c cache.Cache, args []string) (any, error) {
+	remaining, found := c.GetTTL(args[0])
+	if !found {
+		return int64(-2), nil
+	}
+	if remaining < 0 {
+		return int64(-1), nil
+	}
+	return remaining.Milliseconds(), nil
+}
+
+// expiretime implements EXPIRETIME key. It returns the Unix time in
+// seconds at which the key expires, -2 for a missing key and -1 for a key
+// without an expiration.
+func expiretime(c cache.Cache, args []string) (any, error) {
+	at, found := c.ExpireTime(args[0])
+	switch {
+	case !found:
+		return int64(-2), nil
+	case at.IsZero():
+		return int64(-1), nil
+	default:
+		return at.Unix(), nil
+	}
+}
+
+// pexpiretime implements PEXPIRETIME key, which is EXPIRETIME in
+// milliseconds.
+func pexpiretime(c cache.Cache, args []string) (any, error) {
+	at, found := c.ExpireTime(args[0])
+	switch {
+	case !found:
+		return int64(-2), nil
+	case at.IsZero():
+		return int64(-1), nil
+	default:
+		return at.UnixMilli(), nil
+	}
+}
+
+// persist implements PERSIST key.
+func persist(c cache.Cache, args []string) (any, error) {
+	remaining, found := c.GetTTL(args[0])
+	if !found || remaining < 0 {
+		return int64(0), nil
+	}
+
+	err := c.RemoveTTL(args[0])
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return int64(1), nil
+}
@@ -0,0 +1,81 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// Names of the commands that delimit a transaction. They are handled by the
+// servers rather than by Execute, and a Recorder writes the commands of a
+// transaction between MULTI and EXEC.
+const (
+	Multi   = "MULTI"
+	Exec    = "EXEC"
+	Discard = "DISCARD"
+)
+
+// ExecTx executes cmds atomically against c and returns the reply and error
+// of each. As with EXEC in Redis, nothing is executed if one of the commands
+// is unknown or has the wrong number of arguments, while a command failing
+// when it runs does not prevent the others from running.
+func ExecTx(c cache.Transactor, cmds []Command) ([]cache.TxResult, error) {
+	tx := cache.NewTx(c)
+	if err := QueueTx(tx, cmds); err != nil {
+		return nil, err
+	}
+	results, _ := tx.Exec()
+	return results, nil
+}
+
+// QueueTx queues cmds on tx. Nothing is queued if one of the commands is
+// unknown or has the wrong number of arguments.
+func QueueTx(tx *cache.Tx, cmds []Command) error {
+	for i, cmd := range cmds {
+		if err := Validate(cmd); err != nil {
+			return fmt.Errorf("command %d: %w", i+1, err)
+		}
+	}
+
+	for _, cmd := range cmds {
+		tx.Queue(func(c cache.Cache) (any, error) {
+			return Execute(c, cmd)
+		})
+	}
+	return nil
+}
+
+// Atomically applies the writes fn makes to the view it is given atomically
+// and records them between MULTI and EXEC, so that they are replayed
+// atomically too. A single write is recorded on its own.
+func (r *Recorder) Atomically(fn func(c cache.Store)) {
+	defer r.locks.lockAll()()
+
+	// Writes, including the evictions they cause, are collected in batch
+	// while the transaction runs.
+	var batch batch
+	sinks := r.sinks
+	r.sinks = []Sink{&batch}
+	defer func() {
+		r.sinks = sinks
+		if len(batch) > 1 {
+			r.record(New(Multi))
+			r.record(batch...)
+			r.record(New(Exec))
+		} else {
+			r.record(batch...)
+		}
+	}()
+
+	r.Store.Atomically(func(store cache.Store) {
+		fn(&Recorder{Store: store, locks: new(shardLocks), sinks: r.sinks})
+	})
+}
+
+// batch is a Sink collecting the writes of a transaction.
+type batch []Command
+
+// Append adds cmd to the batch.
+func (b *batch) Append(cmd Command) {
+	*b = append(*b, cmd)
+}
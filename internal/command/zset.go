@@ -0,0 +1,293 @@
+package command
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// zadd implements ZADD key [NX | XX] [GT | LT] [CH] score member
+// [score member ...].
+func zadd(c cache.Cache, args []string) (any, error) {
+	var opts cache.ZAddOptions
+
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "GT":
+			opts.GT = true
+		case "LT":
+			opts.LT = true
+		case "CH":
+			opts.CH = true
+		default:
+			break options
+		}
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return nil, ErrSyntax
+	}
+
+	members := make([]cache.Z, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, err := cache.ParseScore(pairs[j])
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, cache.Z{Member: pairs[j+1], Score: score})
+	}
+
+	count, err := c.ZAdd(args[0], opts, members...)
+	if err != nil {
+		return nil, err
+	}
+	return int64(count), nil
+}
+
+// zincrby implements ZINCRBY key increment member.
+func zincrby(c cache.Cache, args []string) (any, error) {
+	increment, err := cache.ParseScore(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	score, err := c.ZIncrBy(args[0], increment, args[2])
+	if err != nil {
+		return nil, err
+	}
+	return formatScore(score), nil
+}
+
+// zscore implements ZSCORE key member.
+func zscore(c cache.Cache, args []string) (any, error) {
+	score, err := c.ZScore(args[0], args[1])
+	if errors.Is(err, cache.ErrKeyNotFound) || errors.Is(err, cache.ErrMemberNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return formatScore(score), nil
+}
+
+// zrank implements ZRANK key member.
+func zrank(c cache.Cache, args []string) (any, error) {
+	return zrankReply(c, args, false)
+}
+
+// zrevrank implements ZREVRANK key member.
+func zrevrank(c cache.Cache, args []string) (any, error) {
+	return zrankReply(c, args, true)
+}
+
+// zrankReply returns the rank of a member, or nil if it is missing.
+func zrankReply(c cache.Cache, args []string, rev bool) (any, error) {
+	rank, err := c.ZRank(args[0], args[1], rev)
+	if errors.Is(err, cache.ErrKeyNotFound) || errors.Is(err, cache.ErrMemberNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return int64(rank), nil
+}
+
+// zrange implements ZRANGE key start stop [BYSCORE | BYLEX] [REV]
+// [LIMIT offset count] [WITHSCORES].
+func zrange(c cache.Cache, args []string) (any, error) {
+	key, start, stop := args[0], args[1], args[2]
+
+	var byScore, byLex, withScores, limit bool
+	var opts cache.ZRangeOptions
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "BYSCORE":
+			byScore = true
+		case "BYLEX":
+			byLex = true
+		case "REV":
+			opts.Rev = true
+		case "WITHSCORES":
+			withScores = true
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return nil, ErrSyntax
+			}
+			offset, err := parseInt(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			count, err := parseInt(args[i+2])
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return []string{}, nil
+			}
+			opts.Offset, opts.Count = int(offset), int(count)
+			limit = true
+			i += 2
+		default:
+			return nil, ErrSyntax
+		}
+	}
+	if (byScore && byLex) || (limit && !byScore && !byLex) || (withScores && byLex) {
+		return nil, ErrSyntax
+	}
+
+	// Like Redis, reversed score and lex ranges take the maximum first.
+	if opts.Rev && (byScore || byLex) {
+		start, stop = stop, start
+	}
+
+	var zs []cache.Z
+	var err error
+	switch {
+	case byScore:
+		var min, max cache.ScoreBound
+		if min, err = cache.ParseScoreBound(start); err != nil {
+			return nil, err
+		}
+		if max, err = cache.ParseScoreBound(stop); err != nil {
+			return nil, err
+		}
+		zs, err = c.ZRangeByScore(key, min, max, opts)
+	case byLex:
+		var min, max cache.LexBound
+		if min, err = cache.ParseLexBound(start); err != nil {
+			return nil, err
+		}
+		if max, err = cache.ParseLexBound(stop); err != nil {
+			return nil, err
+		}
+		members, err := c.ZRangeByLex(key, min, max, opts)
+		if errors.Is(err, cache.ErrKeyNotFound) {
+			return []string{}, nil
+		}
+		return members, err
+	default:
+		startRank, err := strconv.Atoi(start)
+		if err != nil {
+			return nil, ErrNotInteger
+		}
+		stopRank, err := strconv.Atoi(stop)
+		if err != nil {
+			return nil, ErrNotInteger
+		}
+		zs, err = c.ZRange(key, startRank, stopRank, opts.Rev)
+	}
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return zReply(zs, withScores), nil
+}
+
+// zcount implements ZCOUNT key min max.
+func zcount(c cache.Cache, args []string) (any, error) {
+	min, err := cache.ParseScoreBound(args[1])
+	if err != nil {
+		return nil, err
+	}
+	max, err := cache.ParseScoreBound(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := c.ZCount(args[0], min, max)
+	if err != nil {
+		return nil, err
+	}
+	return int64(count), nil
+}
+
+// zcard implements ZCARD key.
+func zcard(c cache.Cache, args []string) (any, error) {
+	count, err := c.ZCard(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return int64(count), nil
+}
+
+// zrem implements ZREM key member [member ...].
+func zrem(c cache.Cache, args []string) (any, error) {
+	removed, err := c.ZRem(args[0], args[1:]...)
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return int64(removed), nil
+}
+
+// zpopmin implements ZPOPMIN key [count].
+func zpopmin(c cache.Cache, args []string) (any, error) {
+	return zpop(args, c.ZPopMin)
+}
+
+// zpopmax implements ZPOPMAX key [count].
+func zpopmax(c cache.Cache, args []string) (any, error) {
+	return zpop(args, c.ZPopMax)
+}
+
+// zpop pops members with pop and replies with members and scores.
+func zpop(args []string, pop func(string, int) ([]cache.Z, error)) (any, error) {
+	count := int64(1)
+	if len(args) == 2 {
+		var err error
+		if count, err = parseInt(args[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	zs, err := pop(args[0], int(count))
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return zReply(zs, true), nil
+}
+
+// zReply flattens sorted set members, optionally interleaved with scores.
+func zReply(zs []cache.Z, withScores bool) []string {
+	reply := make([]string, 0, len(zs)*2)
+	for _, z := range zs {
+		reply = append(reply, z.Member)
+		if withScores {
+			reply = append(reply, formatScore(z.Score))
+		}
+	}
+	return reply
+}
+
+// formatScore formats a score like Redis does, using plain integers where
+// possible.
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	case score == math.Trunc(score) && math.Abs(score) < 1<<53:
+		return strconv.FormatInt(int64(score), 10)
+	default:
+		return strconv.FormatFloat(score, 'g', -1, 64)
+	}
+}
@@ -8,7 +8,13 @@ import (
 )
 
 type Config struct {
-	Server Server `json:"server" yaml:"server"`
+	Server        Server        `json:"server"        yaml:"server"`
+	Cache         Cache         `json:"cache"         yaml:"cache"`
+	RESP          RESP          `json:"resp"          yaml:"resp"`
+	Persistence   Persistence   `json:"persistence"   yaml:"persistence"`
+	Replication   Replication   `json:"replication"   yaml:"replication"`
+	Memory        Memory        `json:"memory"        yaml:"memory"`
+	Notifications Notifications `json:"notifications" yaml:"notifications"`
 }
 
 type Server struct {
@@ -18,6 +24,67 @@ type Server struct {
 	WriteTimeout      time.Duration `json:"write_timeout"       yaml:"write_timeout"`
 }
 
+type Cache struct {
+	// Shards splits the keyspace into independently locked shards; 0 or 1
+	// keeps a single shard.
+	Shards int `json:"shards" yaml:"shards"`
+}
+
+type RESP struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	Port    int  `json:"port"    yaml:"port"`
+}
+
+type Persistence struct {
+	Snapshot Snapshot `json:"snapshot" yaml:"snapshot"`
+	AOF      AOF      `json:"aof"      yaml:"aof"`
+}
+
+type Snapshot struct {
+	Enabled bool   `json:"enabled"  yaml:"enabled"`
+	Path    string `json:"path"     yaml:"path"`
+	// Interval between periodic saves; zero only saves on demand and at
+	// shutdown.
+	Interval time.Duration `json:"interval" yaml:"interval"`
+}
+
+type AOF struct {
+	Enabled bool   `json:"enabled" yaml:"enabled"`
+	Path    string `json:"path"    yaml:"path"`
+	// Fsync is one of "always", "everysec" (the default) or "no".
+	Fsync string `json:"fsync" yaml:"fsync"`
+	// RewritePercentage triggers a rewrite when the file has grown by this
+	// percentage since the last one; zero disables automatic rewrites.
+	RewritePercentage int   `json:"rewrite_percentage" yaml:"rewrite_percentage"`
+	RewriteMinSize    int64 `json:"rewrite_min_size"   yaml:"rewrite_min_size"`
+}
+
+type Replication struct {
+	// Role is "leader" (the default) or "follower".
+	Role string `json:"role" yaml:"role"`
+	// LeaderAddr is the RESP address of the leader a follower replicates.
+	LeaderAddr string `json:"leader_addr" yaml:"leader_addr"`
+	// BacklogSize is the number of bytes of the replication stream a leader
+	// keeps for followers resuming after a disconnect.
+	BacklogSize int `json:"backlog_size" yaml:"backlog_size"`
+}
+
+type Memory struct {
+	// MaxMemory limits the approximate memory used by the keys, in bytes;
+	// zero means no limit.
+	MaxMemory int64 `json:"max_memory" yaml:"max_memory"`
+	// EvictionPolicy is one of "noeviction" (the default), "allkeys-lru",
+	// "allkeys-lfu", "volatile-lru", "volatile-ttl" or "allkeys-random".
+	EvictionPolicy string `json:"eviction_policy" yaml:"eviction_policy"`
+}
+
+type Notifications struct {
+	// KeyspaceEvents selects the keyspace notifications to publish, with the
+	// flags of notify-keyspace-events in Redis, e.g. "KEA"; empty disables
+	// them.
+	KeyspaceEvents string `json:"keyspace_events" yaml:"keyspace_events"`
+}
+
 func GetConfigFromFile(path string) (*Config, error) {
 	yamlFile, err := os.ReadFile(path)
 	if err != nil {
@@ -0,0 +1,120 @@
+// Package glob implements the glob-style patterns used by Redis for channel
+// and key matching.
+//
+// The supported syntax is:
+//
+//	h?llo     matches hello and hallo: ? matches exactly one character
+//	h*llo     matches hllo and heeeello: * matches any sequence, including none
+//	h[ae]llo  matches hello and hallo, but not hillo
+//	h[^e]llo  matches hallo and hbllo, but not hello
+//	h[a-b]llo matches hallo and hbllo
+//	h\*llo    matches h*llo only: \ escapes the next character
+package glob
+
+// Match reports whether s matches pattern. A malformed pattern, such as an
+// unterminated class, is matched as literally as possible instead of
+// failing, as in Redis.
+func Match(pattern, s string) bool {
+	p := 0
+	i := 0
+	// Position of the most recent star in pattern and of the character in s
+	// it is currently assumed to extend to, used for backtracking.
+	star, mark := -1, 0
+
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				for p < len(pattern) && pattern[p] == '*' {
+					p++
+				}
+				if p == len(pattern) {
+					return true
+				}
+				star, mark = p, i
+				continue
+			case '?':
+				p++
+				i++
+				continue
+			case '[':
+				if next, ok := matchClass(pattern, p, s[i]); ok {
+					p = next
+					i++
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) {
+					if pattern[p+1] == s[i] {
+						p += 2
+						i++
+						continue
+					}
+					break
+				}
+				fallthrough
+			default:
+				if pattern[p] == s[i] {
+					p++
+					i++
+					continue
+				}
+			}
+		}
+
+		if star < 0 {
+			return false
+		}
+		mark++
+		p, i = star, mark
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass matches c against the character class starting at pattern[p],
+// which must be '['. It returns the position after the class and whether c
+// is a member of it.
+func matchClass(pattern string, p int, c byte) (int, bool) {
+	p++ // skip '['
+
+	negate := false
+	if p < len(pattern) && pattern[p] == '^' {
+		negate = true
+		p++
+	}
+
+	matched := false
+	for p < len(pattern) && pattern[p] != ']' {
+		switch {
+		case pattern[p] == '\\' && p+1 < len(pattern):
+			p++
+			if pattern[p] == c {
+				matched = true
+			}
+			p++
+		case p+2 < len(pattern) && pattern[p+1] == '-' && pattern[p+2] != ']':
+			lo, hi := pattern[p], pattern[p+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if c >= lo && c <= hi {
+				matched = true
+			}
+			p += 3
+		default:
+			if pattern[p] == c {
+				matched = true
+			}
+			p++
+		}
+	}
+	if p < len(pattern) {
+		p++ // skip ']'
+	}
+
+	return p, matched != negate
+}
@@ -0,0 +1,47 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{pattern: "", s: "", want: true},
+		{pattern: "", s: "a", want: false},
+		{pattern: "news", s: "news", want: true},
+		{pattern: "news", s: "new", want: false},
+		{pattern: "*", s: "", want: true},
+		{pattern: "*", s: "anything", want: true},
+		{pattern: "news.*", s: "news.tech", want: true},
+		{pattern: "news.*", s: "news", want: false},
+		{pattern: "*.tech", s: "news.tech", want: true},
+		{pattern: "a*b*c", s: "aXXbYYc", want: true},
+		{pattern: "a*b*c", s: "aXXbYY", want: false},
+		{pattern: "a**c", s: "abc", want: true},
+		{pattern: "*ab", s: "aab", want: true},
+		{pattern: "h?llo", s: "hello", want: true},
+		{pattern: "h?llo", s: "hllo", want: false},
+		{pattern: "h[ae]llo", s: "hallo", want: true},
+		{pattern: "h[ae]llo", s: "hillo", want: false},
+		{pattern: "h[^e]llo", s: "hallo", want: true},
+		{pattern: "h[^e]llo", s: "hello", want: false},
+		{pattern: "h[a-b]llo", s: "hbllo", want: true},
+		{pattern: "h[b-a]llo", s: "hallo", want: true},
+		{pattern: "h[a-b]llo", s: "hcllo", want: false},
+		{pattern: `h\*llo`, s: "h*llo", want: true},
+		{pattern: `h\*llo`, s: "hello", want: false},
+		{pattern: `[\]]`, s: "]", want: true},
+		{pattern: "*[0-9]", s: "user:42", want: true},
+		{pattern: "[abc", s: "a", want: true},
+		{pattern: `a\`, s: `a\`, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
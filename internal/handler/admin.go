@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dsha256/gredis/internal/persistence"
+	"github.com/dsha256/gredis/internal/responder"
+)
+
+// Errors returned by admin endpoints that need a backend when none is
+// configured.
+var (
+	errPersistenceDisabled = errors.New("snapshot persistence is disabled")
+	errAOFDisabled         = errors.New("append-only file persistence is disabled")
+	errMemoryUntracked     = errors.New("memory use is not tracked")
+)
+
+// Save handles POST /api/v1/admin/save
+func (h *Handler) Save(w http.ResponseWriter, _ *http.Request) {
+	if h.Snapshotter == nil {
+		responder.WriteError(w, http.StatusNotImplemented, errPersistenceDisabled)
+		return
+	}
+
+	info, err := h.Snapshotter.Save()
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Snapshot saved successfully", map[string]any{
+		"path":     info.Path,
+		"keys":     info.Keys,
+		"size":     info.Size,
+		"saved_at": info.SavedAt.UTC().Format(time.RFC3339),
+		"duration": info.Duration.String(),
+	})
+}
+
+// RewriteAOF handles POST /api/v1/admin/rewrite-aof
+func (h *Handler) RewriteAOF(w http.ResponseWriter, _ *http.Request) {
+	if h.AOF == nil {
+		responder.WriteError(w, http.StatusNotImplemented, errAOFDisabled)
+		return
+	}
+
+	info, err := h.AOF.Rewrite()
+	if errors.Is(err, persistence.ErrRewriteInProgress) {
+		responder.WriteError(w, http.StatusConflict, err)
+		return
+	}
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Append-only file rewritten successfully", map[string]any{
+		"path":     info.Path,
+		"keys":     info.Keys,
+		"size":     info.Size,
+		"duration": info.Duration.String(),
+	})
+}
+
+// MemoryStats handles GET /api/v1/admin/memory
+func (h *Handler) MemoryStats(w http.ResponseWriter, _ *http.Request) {
+	if h.Memory == nil {
+		responder.WriteError(w, http.StatusNotImplemented, errMemoryUntracked)
+		return
+	}
+
+	stats := h.Memory.MemoryStats()
+	responder.WriteSuccess(w, http.StatusOK, "Memory statistics retrieved successfully", map[string]any{
+		"used_memory":     stats.UsedMemory,
+		"max_memory":      stats.MaxMemory,
+		"eviction_policy": stats.Policy,
+		"keys":            stats.Keys,
+		"evicted_keys":    stats.EvictedKeys,
+		"rejected_writes": stats.RejectedWrites,
+	})
+}
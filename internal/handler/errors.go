@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -9,6 +10,23 @@ import (
 	"github.com/dsha256/gredis/internal/responder"
 )
 
+// statusClientClosedRequest is the non-standard status, introduced by
+// nginx, of a request whose client went away before it was answered.
+const statusClientClosedRequest = 499
+
+// errReadOnly is returned for write operations on a read-only server.
+var errReadOnly = errors.New("write operations are not allowed on a read-only replica")
+
+// rejectWrite writes a 403 Forbidden response and returns true if the server
+// is read-only.
+func (h *Handler) rejectWrite(w http.ResponseWriter) bool {
+	if !h.ReadOnly {
+		return false
+	}
+	responder.WriteError(w, http.StatusForbidden, errReadOnly)
+	return true
+}
+
 func (h *Handler) HandleError(w http.ResponseWriter, err error) bool {
 	if err == nil {
 		return false
@@ -18,10 +36,30 @@ func (h *Handler) HandleError(w http.ResponseWriter, err error) bool {
 	var unmarshalTypeErr *json.UnmarshalTypeError
 
 	switch {
-	case errors.Is(err, cache.ErrKeyNotFound):
+	case errors.Is(err, cache.ErrKeyNotFound),
+		errors.Is(err, cache.ErrFieldNotFound),
+		errors.Is(err, cache.ErrMemberNotFound),
+		errors.Is(err, cache.ErrIndexOutOfRange),
+		errors.Is(err, cache.ErrPivotNotFound),
+		errors.Is(err, cache.ErrTimeout):
 		responder.WriteError(w, http.StatusNotFound, err)
-	case errors.Is(err, cache.ErrTypeMismatch):
+	case errors.Is(err, cache.ErrTypeMismatch),
+		errors.Is(err, cache.ErrNotInteger),
+		errors.Is(err, cache.ErrNotFloat),
+		errors.Is(err, cache.ErrOverflow),
+		errors.Is(err, cache.ErrInvalidScore),
+		errors.Is(err, cache.ErrInvalidOptions),
+		errors.Is(err, cache.ErrInvalidListEnd),
+		errors.Is(err, cache.ErrSameKey):
 		responder.WriteError(w, http.StatusBadRequest, err)
+	case errors.Is(err, cache.ErrKeyExists):
+		responder.WriteError(w, http.StatusConflict, err)
+	case errors.Is(err, cache.ErrOutOfMemory):
+		responder.WriteError(w, http.StatusInsufficientStorage, err)
+	case errors.Is(err, context.DeadlineExceeded):
+		responder.WriteError(w, http.StatusGatewayTimeout, err)
+	case errors.Is(err, context.Canceled):
+		responder.WriteError(w, statusClientClosedRequest, err)
 	case errors.As(err, &syntaxErr) || errors.As(err, &unmarshalTypeErr):
 		responder.WriteError(w, http.StatusBadRequest, errors.New("invalid request format"))
 	default:
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dsha256/gredis/internal/cache"
+	"github.com/dsha256/gredis/internal/glob"
+	"github.com/dsha256/gredis/internal/pubsub"
+	"github.com/dsha256/gredis/internal/responder"
+)
+
+// Events handles GET /api/v1/events
+//
+// Keyspace notifications are streamed as Server-Sent Events until the
+// client disconnects. They are read from the keyevent channels, so the
+// server must publish them with the E flag. events is a comma separated
+// list of event types, e.g. "set,del", and match a glob pattern the keys
+// must match; both default to everything. Each notification is an event
+// named after its type whose data is the JSON encoded cache.Event.
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var sub *pubsub.Subscription
+	if events := query.Get("events"); events != "" {
+		var channels []string
+		for _, event := range strings.Split(events, ",") {
+			event = strings.TrimSpace(event)
+			if event == "" {
+				responder.WriteError(w, http.StatusBadRequest, errors.New("events must not contain empty event types"))
+				return
+			}
+			channels = append(channels, cache.KeyeventPrefix+event)
+		}
+		sub = h.Cache.Subscribe(channels...)
+	} else {
+		sub = h.Cache.PSubscribe(cache.KeyeventPrefix + "*")
+	}
+	defer sub.Close()
+
+	match := query.Get("match")
+
+	h.stream(w, r, sub, func(msg pubsub.Message) (string, any, bool) {
+		event, ok := cache.ParseEvent(msg)
+		if !ok || (match != "" && !glob.Match(match, event.Key)) {
+			return "", nil, false
+		}
+		return event.Type, event, true
+	})
+}
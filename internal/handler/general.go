@@ -2,7 +2,9 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/dsha256/gredis/internal/cache"
@@ -13,7 +15,7 @@ import (
 func (h *Handler) Remove(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/api/v1/key/")
 
-	if err := h.Cache.Remove(key); err != nil {
+	if err := h.store().RemoveContext(r.Context(), key); err != nil {
 		h.HandleError(w, err)
 		return
 	}
@@ -28,7 +30,10 @@ func (h *Handler) Exists(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/api/v1/key/")
 	key = strings.TrimSuffix(key, "/exists")
 
-	exists := h.Cache.Exists(key)
+	exists, err := h.store().ExistsContext(r.Context(), key)
+	if h.HandleError(w, err) {
+		return
+	}
 
 	responder.WriteSuccess(w, http.StatusOK, "Key existence checked", map[string]any{
 		"key":    key,
@@ -41,33 +46,134 @@ func (h *Handler) Type(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/api/v1/key/")
 	key = strings.TrimSuffix(key, "/type")
 
-	dataType, found := h.Cache.Type(key)
+	dataType, found, err := h.store().TypeContext(r.Context(), key)
+	if h.HandleError(w, err) {
+		return
+	}
 	if !found {
 		responder.WriteError(w, http.StatusNotFound, cache.ErrKeyNotFound)
 		return
 	}
 
-	var typeStr string
-	switch dataType {
-	case cache.StringType:
-		typeStr = "string"
-	case cache.ListType:
-		typeStr = "list"
-	default:
-		typeStr = "unknown"
-	}
-
 	responder.WriteSuccess(w, http.StatusOK, "Key type retrieved successfully", map[string]string{
 		"key":  key,
-		"type": typeStr,
+		"type": dataType.String(),
+	})
+}
+
+// RenameRequest represents a request to rename a key
+type RenameRequest struct {
+	Destination string `json:"destination"`
+	// NX fails the request if destination exists instead of replacing it.
+	NX bool `json:"nx,omitempty"`
+}
+
+// CopyRequest represents a request to copy a key
+type CopyRequest struct {
+	Destination string `json:"destination"`
+	// Replace replaces destination if it exists.
+	Replace bool `json:"replace,omitempty"`
+}
+
+// Rename handles POST /api/v1/key/{key}/rename
+//
+// The key keeps its value, type and TTL. An existing destination is
+// replaced, like RENAME, or with nx the request fails with 409, like
+// RENAMENX.
+func (h *Handler) Rename(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	var req RenameRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Destination == "" {
+		responder.WriteError(w, http.StatusBadRequest, errors.New("destination is required"))
+		return
+	}
+
+	if h.HandleError(w, h.store().RenameContext(r.Context(), key, req.Destination, !req.NX)) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Key renamed successfully", map[string]string{
+		"key":         key,
+		"destination": req.Destination,
+	})
+}
+
+// Copy handles POST /api/v1/key/{key}/copy
+//
+// The copy has the value, type and TTL of the key. An existing destination
+// makes the request fail with 409 unless replace is set, like COPY.
+func (h *Handler) Copy(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	var req CopyRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Destination == "" {
+		responder.WriteError(w, http.StatusBadRequest, errors.New("destination is required"))
+		return
+	}
+
+	if h.HandleError(w, h.store().CopyContext(r.Context(), key, req.Destination, req.Replace)) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusCreated, "Key copied successfully", map[string]string{
+		"key":         key,
+		"destination": req.Destination,
+	})
+}
+
+// Scan handles GET /api/v1/keys
+//
+// It returns a page of the keys matching the optional match glob pattern
+// and type, starting at cursor (0 by default), and the cursor of the next
+// page, which is 0 once every key was returned. count is the number of
+// keys to examine, a hint rather than a limit.
+func (h *Handler) Scan(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var cursor uint64
+	if s := query.Get("cursor"); s != "" {
+		var err error
+		if cursor, err = strconv.ParseUint(s, 10, 64); err != nil {
+			responder.WriteError(w, http.StatusBadRequest, errors.New("cursor must be a non-negative integer"))
+			return
+		}
+	}
+
+	count, err := parseOptionalInt(query.Get("count"), cache.DefaultScanCount)
+	if err != nil || count < 1 {
+		responder.WriteError(w, http.StatusBadRequest, errors.New("count must be a positive integer"))
+		return
+	}
+
+	opts := cache.ScanOptions{Match: query.Get("match"), Count: count, Type: query.Get("type")}
+	if opts.Type != "" {
+		if _, err = cache.ParseDataType(opts.Type); err != nil {
+			responder.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	keys, next, err := h.store().ScanContext(r.Context(), cursor, opts)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Keys scanned successfully", map[string]any{
+		"cursor": next,
+		"keys":   keys,
 	})
 }
 
 // Clear handles DELETE /api/v1/keys
-func (h *Handler) Clear(w http.ResponseWriter, _ *http.Request) {
-	err := h.Cache.Clear()
-	if err != nil {
-		responder.WriteError(w, http.StatusInternalServerError, err)
+func (h *Handler) Clear(w http.ResponseWriter, r *http.Request) {
+	if h.HandleError(w, h.store().ClearContext(r.Context())) {
 		return
 	}
 
@@ -6,46 +6,165 @@ import (
 
 	"github.com/dsha256/gredis/internal/cache"
 	"github.com/dsha256/gredis/internal/middleware"
+	"github.com/dsha256/gredis/internal/persistence"
 )
 
 // Handler contains the dependencies for all handlers
 type Handler struct {
 	Cache  cache.Cache
 	Logger *slog.Logger
+	// Snapshotter saves snapshots on demand; nil if persistence is disabled.
+	Snapshotter *persistence.Snapshotter
+	// AOF rewrites the append-only file on demand; nil if it is disabled.
+	AOF *persistence.AOF
+	// ReadOnly rejects every write, as on a replication follower.
+	ReadOnly bool
+	// Memory reports memory use and evictions; nil if the cache does not
+	// track them.
+	Memory cache.MemoryReporter
+}
+
+// Option configures optional Handler dependencies
+type Option func(*Handler)
+
+// WithSnapshotter enables the on-demand snapshot endpoint
+func WithSnapshotter(s *persistence.Snapshotter) Option {
+	return func(h *Handler) {
+		h.Snapshotter = s
+	}
+}
+
+// WithAOF enables the on-demand append-only file rewrite endpoint
+func WithAOF(aof *persistence.AOF) Option {
+	return func(h *Handler) {
+		h.AOF = aof
+	}
+}
+
+// WithMemoryReporter enables the memory statistics endpoint
+func WithMemoryReporter(m cache.MemoryReporter) Option {
+	return func(h *Handler) {
+		h.Memory = m
+	}
+}
+
+// WithReadOnly rejects write operations with 403 Forbidden
+func WithReadOnly() Option {
+	return func(h *Handler) {
+		h.ReadOnly = true
+	}
 }
 
 // New creates a new Handler with the given dependencies
-func New(cache cache.Cache, logger *slog.Logger) *Handler {
-	return &Handler{
+func New(cache cache.Cache, logger *slog.Logger, opts ...Option) *Handler {
+	h := &Handler{
 		Cache:  cache,
 		Logger: logger,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// store returns the cache with the variants of its operations that take a
+// context, through which handlers pass on the context of their request.
+func (h *Handler) store() cache.ContextCache {
+	return cache.WithContext(h.Cache)
 }
 
 // RegisterRoutes registers all the routes for the cache API
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	// String operations
 	mux.Handle("GET /api/v1/string/{key}", h.wrapHandler(h.GetString))
-	mux.Handle("POST /api/v1/string/{key}", h.wrapHandler(h.SetString))
-	mux.Handle("PUT /api/v1/string/{key}", h.wrapHandler(h.UpdateString))
+	mux.Handle("POST /api/v1/string/{key}", h.wrapWriteHandler(h.SetString))
+	mux.Handle("PUT /api/v1/string/{key}", h.wrapWriteHandler(h.UpdateString))
+	mux.Handle("POST /api/v1/string/{key}/incr", h.wrapWriteHandler(h.IncrString))
+	mux.Handle("POST /api/v1/string/{key}/decr", h.wrapWriteHandler(h.DecrString))
+	mux.Handle("POST /api/v1/string/{key}/incrbyfloat", h.wrapWriteHandler(h.IncrFloatString))
+	mux.Handle("POST /api/v1/strings/mget", h.wrapHandler(h.MGet))
+	mux.Handle("POST /api/v1/strings/mset", h.wrapWriteHandler(h.MSet))
 
 	// List operations
-	mux.Handle("POST /api/v1/list/{key}/front", h.wrapHandler(h.PushFront))
-	mux.Handle("POST /api/v1/list/{key}/back", h.wrapHandler(h.PushBack))
-	mux.Handle("DELETE /api/v1/list/{key}/front", h.wrapHandler(h.PopFront))
-	mux.Handle("DELETE /api/v1/list/{key}/back", h.wrapHandler(h.PopBack))
+	mux.Handle("POST /api/v1/list/{key}/front", h.wrapWriteHandler(h.PushFront))
+	mux.Handle("POST /api/v1/list/{key}/back", h.wrapWriteHandler(h.PushBack))
+	mux.Handle("DELETE /api/v1/list/{key}/front", h.wrapWriteHandler(h.PopFront))
+	mux.Handle("DELETE /api/v1/list/{key}/back", h.wrapWriteHandler(h.PopBack))
+	mux.Handle("POST /api/v1/list/{key}/move", h.wrapWriteHandler(h.Move))
 	mux.Handle("GET /api/v1/list/{key}/range", h.wrapHandler(h.ListRange))
+	mux.Handle("GET /api/v1/list/{key}/len", h.wrapHandler(h.ListLen))
+	mux.Handle("GET /api/v1/list/{key}/index/{index}", h.wrapHandler(h.ListIndex))
+	mux.Handle("PUT /api/v1/list/{key}/index/{index}", h.wrapWriteHandler(h.ListSet))
+	mux.Handle("POST /api/v1/list/{key}/insert", h.wrapWriteHandler(h.ListInsert))
+	mux.Handle("DELETE /api/v1/list/{key}/value/{value}", h.wrapWriteHandler(h.ListRemove))
+	mux.Handle("POST /api/v1/list/{key}/trim", h.wrapWriteHandler(h.ListTrim))
+	mux.Handle("GET /api/v1/list/{key}/pos", h.wrapHandler(h.ListPos))
+
+	// Hash operations
+	mux.Handle("GET /api/v1/hash/{key}", h.wrapHandler(h.HGetAll))
+	mux.Handle("POST /api/v1/hash/{key}", h.wrapWriteHandler(h.HSet))
+	mux.Handle("GET /api/v1/hash/{key}/{field}", h.wrapHandler(h.HGet))
+	mux.Handle("DELETE /api/v1/hash/{key}/{field}", h.wrapWriteHandler(h.HDel))
+	mux.Handle("POST /api/v1/hash/{key}/{field}/incr", h.wrapWriteHandler(h.HIncrBy))
+
+	// Set operations
+	mux.Handle("GET /api/v1/set/{key}", h.wrapHandler(h.SMembers))
+	mux.Handle("POST /api/v1/set/{key}", h.wrapWriteHandler(h.SAdd))
+	mux.Handle("GET /api/v1/set/{key}/{member}", h.wrapHandler(h.SIsMember))
+	mux.Handle("DELETE /api/v1/set/{key}/{member}", h.wrapWriteHandler(h.SRem))
+	mux.Handle("POST /api/v1/sets/inter", h.wrapHandler(h.SInter))
+	mux.Handle("POST /api/v1/sets/union", h.wrapHandler(h.SUnion))
+	mux.Handle("POST /api/v1/sets/diff", h.wrapHandler(h.SDiff))
+
+	// Sorted set operations
+	mux.Handle("POST /api/v1/zset/{key}", h.wrapWriteHandler(h.ZAdd))
+	mux.Handle("POST /api/v1/zset/{key}/incr", h.wrapWriteHandler(h.ZIncrBy))
+	mux.Handle("GET /api/v1/zset/{key}/range", h.wrapHandler(h.ZRange))
+	mux.Handle("GET /api/v1/zset/{key}/count", h.wrapHandler(h.ZCount))
+	mux.Handle("GET /api/v1/zset/{key}/rank/{member}", h.wrapHandler(h.ZRank))
+	mux.Handle("GET /api/v1/zset/{key}/score/{member}", h.wrapHandler(h.ZScore))
+	mux.Handle("DELETE /api/v1/zset/{key}/member/{member}", h.wrapWriteHandler(h.ZRem))
+	mux.Handle("DELETE /api/v1/zset/{key}/min", h.wrapWriteHandler(h.ZPopMin))
+	mux.Handle("DELETE /api/v1/zset/{key}/max", h.wrapWriteHandler(h.ZPopMax))
 
 	// TTL operations
-	mux.Handle("PUT /api/v1/ttl/{key}", h.wrapHandler(h.SetTTL))
+	mux.Handle("PUT /api/v1/ttl/{key}", h.wrapWriteHandler(h.SetTTL))
 	mux.Handle("GET /api/v1/ttl/{key}", h.wrapHandler(h.GetTTL))
-	mux.Handle("DELETE /api/v1/ttl/{key}", h.wrapHandler(h.RemoveTTL))
+	mux.Handle("DELETE /api/v1/ttl/{key}", h.wrapWriteHandler(h.RemoveTTL))
+
+	// Pub/sub operations
+	mux.Handle("GET /api/v1/pubsub/{channel}", h.wrapHandler(h.Subscribe))
+	mux.Handle("POST /api/v1/pubsub/{channel}", h.wrapHandler(h.Publish))
+	mux.Handle("GET /api/v1/events", h.wrapHandler(h.Events))
 
 	// General operations
-	mux.Handle("DELETE /api/v1/key/{key}", h.wrapHandler(h.Remove))
+	mux.Handle("DELETE /api/v1/key/{key}", h.wrapWriteHandler(h.Remove))
 	mux.Handle("GET /api/v1/key/{key}/exists", h.wrapHandler(h.Exists))
 	mux.Handle("GET /api/v1/key/{key}/type", h.wrapHandler(h.Type))
-	mux.Handle("DELETE /api/v1/keys", h.wrapHandler(h.Clear))
+	mux.Handle("POST /api/v1/key/{key}/rename", h.wrapWriteHandler(h.Rename))
+	mux.Handle("POST /api/v1/key/{key}/copy", h.wrapWriteHandler(h.Copy))
+	mux.Handle("GET /api/v1/keys", h.wrapHandler(h.Scan))
+	mux.Handle("DELETE /api/v1/keys", h.wrapWriteHandler(h.Clear))
+
+	// Transactions
+	mux.Handle("POST /api/v1/tx", h.wrapHandler(h.Exec))
+	mux.Handle("POST /api/v1/tx/watch", h.wrapHandler(h.Watch))
+
+	// Admin operations
+	mux.Handle("POST /api/v1/admin/save", h.wrapHandler(h.Save))
+	mux.Handle("POST /api/v1/admin/rewrite-aof", h.wrapHandler(h.RewriteAOF))
+	mux.Handle("GET /api/v1/admin/memory", h.wrapHandler(h.MemoryStats))
+}
+
+// wrapWriteHandler wraps a handler that modifies the cache, rejecting it on
+// read-only servers
+func (h *Handler) wrapWriteHandler(handler http.HandlerFunc) http.Handler {
+	return h.wrapHandler(func(w http.ResponseWriter, r *http.Request) {
+		if h.rejectWrite(w) {
+			return
+		}
+		handler(w, r)
+	})
 }
 
 func (h *Handler) wrapHandler(handler http.HandlerFunc) http.Handler {
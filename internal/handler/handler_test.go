@@ -1,16 +1,26 @@
 package handler
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/dsha256/gredis/internal/cache"
+	"github.com/dsha256/gredis/internal/command"
+	"github.com/dsha256/gredis/internal/persistence"
+	"github.com/dsha256/gredis/internal/pubsub"
 	"github.com/dsha256/gredis/internal/types"
 )
 
@@ -136,6 +146,165 @@ func TestStringOperations(t *testing.T) {
 	}
 }
 
+// TestConditionalSet tests the conditional options of the set endpoint
+func TestConditionalSet(t *testing.T) {
+	h, server := setupTest(t)
+	defer server.Close()
+
+	tests := []struct {
+		name             string
+		key              string
+		body             StringRequest
+		expectedStatus   int
+		expectedPrevious string
+	}{
+		{"NX missing", "lock", StringRequest{Value: "a", NX: true, TTL: 60}, http.StatusCreated, ""},
+		{"NX existing", "lock", StringRequest{Value: "b", NX: true}, http.StatusConflict, ""},
+		{"XX with GET", "lock", StringRequest{Value: "b", XX: true, Get: true, KeepTTL: true}, http.StatusCreated, "a"},
+		{"XX missing", "missing", StringRequest{Value: "b", XX: true}, http.StatusNotFound, ""},
+		{"NX and XX", "lock", StringRequest{Value: "c", NX: true, XX: true}, http.StatusBadRequest, ""},
+		{"TTL and EXAT", "lock", StringRequest{Value: "c", TTL: 60, ExAt: 32503680000}, http.StatusBadRequest, ""},
+		{"EXAT and PXAT", "lock", StringRequest{Value: "c", ExAt: 32503680000, PxAt: 32503680000000}, http.StatusBadRequest, ""},
+		{"TTL and PX", "lock", StringRequest{Value: "c", TTL: 60, PX: 60000}, http.StatusBadRequest, ""},
+		{"PX", "px", StringRequest{Value: "c", PX: 1500}, http.StatusCreated, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := doRequest(t, server, http.MethodPost, "/api/v1/string/"+tc.key, tc.body)
+			if resp.StatusCode != tc.expectedStatus {
+				resp.Body.Close()
+				t.Fatalf("Expected status code %d, got %d", tc.expectedStatus, resp.StatusCode)
+			}
+
+			var response types.Response[map[string]string]
+			parseResponse(t, resp, &response)
+			if response.Data["previous"] != tc.expectedPrevious {
+				t.Errorf("Expected previous value %q, got %q", tc.expectedPrevious, response.Data["previous"])
+			}
+		})
+	}
+
+	if ttl, _ := h.Cache.GetTTL("lock"); ttl <= 0 {
+		t.Errorf("Expected KEEPTTL to keep the TTL, got %v", ttl)
+	}
+
+	resp := doRequest(t, server, http.MethodPost, "/api/v1/string/at", StringRequest{Value: "v", PxAt: time.Now().Add(time.Hour).UnixMilli()})
+	resp.Body.Close()
+	if ttl, _ := h.Cache.GetTTL("at"); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("Expected a TTL of about an hour from PXAT, got %v", ttl)
+	}
+	if ttl, _ := h.Cache.GetTTL("px"); ttl <= time.Second || ttl > 1500*time.Millisecond {
+		t.Errorf("Expected a TTL of about 1.5s from PX, got %v", ttl)
+	}
+}
+
+// TestBatchStringOperations tests the MGET and MSET endpoints
+func TestBatchStringOperations(t *testing.T) {
+	h, server := setupTest(t)
+	defer server.Close()
+
+	resp := doRequest(t, server, http.MethodPost, "/api/v1/strings/mset", MSetRequest{Values: map[string]string{"a": "1", "b": "2"}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+	if err := h.Cache.PushBack("list", "x"); err != nil {
+		t.Fatalf("PushBack failed: %v", err)
+	}
+
+	type mgetResult struct {
+		Values  map[string]string `json:"values"`
+		Missing []string          `json:"missing"`
+	}
+	resp = doRequest(t, server, http.MethodPost, "/api/v1/strings/mget", MGetRequest{Keys: []string{"a", "missing", "b", "list", "missing"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	var response types.Response[mgetResult]
+	parseResponse(t, resp, &response)
+	if len(response.Data.Values) != 2 || response.Data.Values["a"] != "1" || response.Data.Values["b"] != "2" {
+		t.Errorf("Unexpected values: %v", response.Data.Values)
+	}
+	if !slices.Equal(response.Data.Missing, []string{"missing", "list"}) {
+		t.Errorf("Expected missing [missing list], got %v", response.Data.Missing)
+	}
+
+	resp = doRequest(t, server, http.MethodPost, "/api/v1/strings/mset", MSetRequest{Values: map[string]string{"a": "x", "c": "3"}, NX: true})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status code %d, got %d", http.StatusConflict, resp.StatusCode)
+	}
+	if _, found := h.Cache.Get("c"); found {
+		t.Error("Expected MSETNX to set nothing")
+	}
+
+	for _, tc := range []struct {
+		path string
+		body any
+	}{
+		{"/api/v1/strings/mget", MGetRequest{}},
+		{"/api/v1/strings/mset", MSetRequest{}},
+	} {
+		resp := doRequest(t, server, http.MethodPost, tc.path, tc.body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", tc.path, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
+
+// TestCounterOperations tests the atomic counter endpoints
+func TestCounterOperations(t *testing.T) {
+	h, server := setupTest(t)
+	defer server.Close()
+
+	if err := h.Cache.SetWithTTL("ttl-counter", "10", time.Hour); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+
+	increment := int64(5)
+	tests := []struct {
+		name           string
+		path           string
+		body           any
+		expectedStatus int
+		expectedValue  float64
+	}{
+		{"Incr missing key", "/api/v1/string/counter/incr", nil, http.StatusOK, 1},
+		{"IncrBy", "/api/v1/string/counter/incr", CounterRequest{Increment: &increment}, http.StatusOK, 6},
+		{"Decr", "/api/v1/string/counter/decr", CounterRequest{}, http.StatusOK, 5},
+		{"DecrBy", "/api/v1/string/counter/decr", CounterRequest{Increment: &increment}, http.StatusOK, 0},
+		{"IncrByFloat", "/api/v1/string/ttl-counter/incrbyfloat", FloatCounterRequest{Increment: 2.5}, http.StatusOK, 12.5},
+		{"Incr float value", "/api/v1/string/ttl-counter/incr", nil, http.StatusBadRequest, 0},
+		{"Fractional increment", "/api/v1/string/counter/incr", map[string]float64{"increment": 1.5}, http.StatusBadRequest, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := doRequest(t, server, http.MethodPost, tc.path, tc.body)
+			if resp.StatusCode != tc.expectedStatus {
+				resp.Body.Close()
+				t.Fatalf("Expected status code %d, got %d", tc.expectedStatus, resp.StatusCode)
+			}
+			if tc.expectedStatus != http.StatusOK {
+				resp.Body.Close()
+				return
+			}
+
+			var response types.Response[map[string]any]
+			parseResponse(t, resp, &response)
+			if response.Data["value"] != tc.expectedValue {
+				t.Errorf("Expected value %v, got %v", tc.expectedValue, response.Data["value"])
+			}
+		})
+	}
+
+	if ttl, _ := h.Cache.GetTTL("ttl-counter"); ttl <= 0 {
+		t.Errorf("Expected the TTL to be kept, got %v", ttl)
+	}
+}
+
 // TestListOperations tests the list operations (PushFront, PushBack, PopFront, PopBack, ListRange)
 func TestListOperations(t *testing.T) {
 	_, server := setupTest(t)
@@ -272,6 +441,65 @@ func TestListOperations(t *testing.T) {
 	}
 }
 
+// TestListCommands tests the list endpoints beyond push, pop and range
+func TestListCommands(t *testing.T) {
+	h, server := setupTest(t)
+	defer server.Close()
+
+	for _, v := range []string{"a", "b", "c", "a"} {
+		if err := h.Cache.PushBack("list", v); err != nil {
+			t.Fatalf("PushBack failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           any
+		expectedStatus int
+		// expectedData holds fields expected in the response data.
+		expectedData map[string]any
+	}{
+		{"ListLen", http.MethodGet, "/api/v1/list/list/len", nil, http.StatusOK, map[string]any{"length": 4.0}},
+		{"ListLen missing key", http.MethodGet, "/api/v1/list/missing/len", nil, http.StatusOK, map[string]any{"length": 0.0}},
+		{"ListIndex", http.MethodGet, "/api/v1/list/list/index/-2", nil, http.StatusOK, map[string]any{"value": "c"}},
+		{"ListIndex out of range", http.MethodGet, "/api/v1/list/list/index/4", nil, http.StatusNotFound, nil},
+		{"ListIndex invalid index", http.MethodGet, "/api/v1/list/list/index/first", nil, http.StatusBadRequest, nil},
+		{"ListSet", http.MethodPut, "/api/v1/list/list/index/1", ListRequest{Value: "B"}, http.StatusOK, map[string]any{"value": "B"}},
+		{"ListSet missing key", http.MethodPut, "/api/v1/list/missing/index/0", ListRequest{Value: "x"}, http.StatusNotFound, nil},
+		{"ListInsert", http.MethodPost, "/api/v1/list/list/insert", ListInsertRequest{Pivot: "c", Value: "x", Position: "after"}, http.StatusCreated, map[string]any{"length": 5.0}},
+		{"ListInsert missing pivot", http.MethodPost, "/api/v1/list/list/insert", ListInsertRequest{Pivot: "z", Value: "x"}, http.StatusNotFound, nil},
+		{"ListInsert invalid position", http.MethodPost, "/api/v1/list/list/insert", ListInsertRequest{Pivot: "c", Value: "x", Position: "middle"}, http.StatusBadRequest, nil},
+		{"ListPos", http.MethodGet, "/api/v1/list/list/pos?value=a&rank=-1", nil, http.StatusOK, map[string]any{"positions": []any{4.0, 0.0}}},
+		{"ListPos without value", http.MethodGet, "/api/v1/list/list/pos", nil, http.StatusBadRequest, nil},
+		{"ListRemove", http.MethodDelete, "/api/v1/list/list/value/a?count=-1", nil, http.StatusOK, map[string]any{"removed": 1.0}},
+		{"ListTrim", http.MethodPost, "/api/v1/list/list/trim", ListRangeRequest{Start: 1, End: -1}, http.StatusOK, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := doRequest(t, server, tc.method, tc.path, tc.body)
+			if resp.StatusCode != tc.expectedStatus {
+				resp.Body.Close()
+				t.Fatalf("Expected status code %d, got %d", tc.expectedStatus, resp.StatusCode)
+			}
+
+			var response types.Response[map[string]any]
+			parseResponse(t, resp, &response)
+			for field, want := range tc.expectedData {
+				if got := response.Data[field]; !reflect.DeepEqual(got, want) {
+					t.Errorf("Expected %s %v, got %v", field, want, got)
+				}
+			}
+		})
+	}
+
+	if values, _ := h.Cache.ListRange("list", 0, -1); !slices.Equal(values, []string{"B", "c", "x"}) {
+		t.Errorf("Expected list to be [B c x], got %v", values)
+	}
+}
+
 // TestTTLOperations tests the TTL operations (SetTTL, GetTTL, RemoveTTL)
 func TestTTLOperations(t *testing.T) {
 	_, server := setupTest(t)
@@ -309,7 +537,7 @@ func TestTTLOperations(t *testing.T) {
 			setup:          setupKey,
 			method:         http.MethodPut,
 			path:           "/api/v1/ttl/ttl-test-key",
-			body:           TTLRequest{TTL: 60 * time.Second}, // 60 seconds
+			body:           TTLRequest{TTL: 60},
 			expectedStatus: http.StatusOK,
 			validateFunc: func(t *testing.T, resp *http.Response) {
 				var response types.Response[map[string]interface{}]
@@ -437,6 +665,72 @@ func TestTTLOperations(t *testing.T) {
 	}
 }
 
+// TestTTLOptions tests the units and conditions of SetTTL
+func TestTTLOptions(t *testing.T) {
+	h, server := setupTest(t)
+	defer server.Close()
+
+	if err := h.Cache.Set("session", "v"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	at := time.Now().Add(2 * time.Hour).UnixMilli()
+
+	tests := []struct {
+		name           string
+		body           TTLRequest
+		expectedStatus int
+		expectedPTTL   int64 // checked if positive
+	}{
+		{"XX without TTL", TTLRequest{TTL: 60, XX: true}, http.StatusConflict, 0},
+		{"PX", TTLRequest{PX: 1500}, http.StatusOK, 1500},
+		{"NX with TTL", TTLRequest{TTL: 60, NX: true}, http.StatusConflict, 0},
+		{"GT", TTLRequest{TTL: 60, GT: true}, http.StatusOK, 60000},
+		{"LT later", TTLRequest{TTL: 120, LT: true}, http.StatusConflict, 0},
+		{"PXAT", TTLRequest{PxAt: at, XX: true}, http.StatusOK, 0},
+		{"No expiration", TTLRequest{}, http.StatusBadRequest, 0},
+		{"TTL and PX", TTLRequest{TTL: 60, PX: 60000}, http.StatusBadRequest, 0},
+		{"Negative TTL", TTLRequest{TTL: -1}, http.StatusBadRequest, 0},
+		{"NX and GT", TTLRequest{TTL: 60, NX: true, GT: true}, http.StatusBadRequest, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := doRequest(t, server, http.MethodPut, "/api/v1/ttl/session", tc.body)
+			if resp.StatusCode != tc.expectedStatus {
+				resp.Body.Close()
+				t.Fatalf("Expected status code %d, got %d", tc.expectedStatus, resp.StatusCode)
+			}
+			if tc.expectedPTTL <= 0 {
+				resp.Body.Close()
+				return
+			}
+
+			var response types.Response[map[string]any]
+			parseResponse(t, resp, &response)
+			if pttl, _ := response.Data["pttl"].(float64); pttl != float64(tc.expectedPTTL) {
+				t.Errorf("Expected pttl to be %d, got %v", tc.expectedPTTL, response.Data["pttl"])
+			}
+		})
+	}
+
+	resp := doRequest(t, server, http.MethodGet, "/api/v1/ttl/session", nil)
+	var response types.Response[map[string]any]
+	parseResponse(t, resp, &response)
+	if pxat, _ := response.Data["pxat"].(float64); int64(pxat) != at {
+		t.Errorf("Expected pxat to be %d, got %v", at, response.Data["pxat"])
+	}
+	if pttl, _ := response.Data["pttl"].(float64); pttl <= float64(119*time.Minute/time.Millisecond) {
+		t.Errorf("Expected pttl of about two hours, got %v", response.Data["pttl"])
+	}
+
+	// An absolute time in the past deletes the key.
+	resp = doRequest(t, server, http.MethodPut, "/api/v1/ttl/session", TTLRequest{ExAt: 1})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || h.Cache.Exists("session") {
+		t.Errorf("Expected EXAT in the past to delete the key, got status %d", resp.StatusCode)
+	}
+}
+
 // TestGeneralOperations tests the general operations (Remove, Exists, Type, Clear)
 func TestGeneralOperations(t *testing.T) {
 	_, server := setupTest(t)
@@ -653,35 +947,1132 @@ func TestGeneralOperations(t *testing.T) {
 	}
 }
 
-// setupTest creates a new test server with the given handler
-func setupTest(t *testing.T) (*Handler, *httptest.Server) {
-	t.Helper()
-
-	// Create a new in-memory cache with a short cleanup interval
-	memCache := cache.NewMemoryCache(100 * time.Millisecond)
+// TestRenameAndCopy tests the rename and copy endpoints
+func TestRenameAndCopy(t *testing.T) {
+	h, server := setupTest(t)
+	defer server.Close()
 
-	// Create a test logger that discards all output
-	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	if err := h.Cache.PushBack("queue", "job"); err != nil {
+		t.Fatalf("PushBack failed: %v", err)
+	}
+	if err := h.Cache.SetTTL("queue", time.Hour); err != nil {
+		t.Fatalf("SetTTL failed: %v", err)
+	}
+	if err := h.Cache.Set("other", "v"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
 
-	// Create a new handler with the cache and logger
-	h := New(memCache, logger)
+	tests := []struct {
+		name           string
+		path           string
+		body           any
+		expectedStatus int
+	}{
+		{"Copy", "/api/v1/key/queue/copy", CopyRequest{Destination: "backup"}, http.StatusCreated},
+		{"Copy existing destination", "/api/v1/key/queue/copy", CopyRequest{Destination: "other"}, http.StatusConflict},
+		{"Copy replace", "/api/v1/key/queue/copy", CopyRequest{Destination: "other", Replace: true}, http.StatusCreated},
+		{"Copy to itself", "/api/v1/key/queue/copy", CopyRequest{Destination: "queue", Replace: true}, http.StatusBadRequest},
+		{"Rename NX existing destination", "/api/v1/key/queue/rename", RenameRequest{Destination: "other", NX: true}, http.StatusConflict},
+		{"Rename", "/api/v1/key/queue/rename", RenameRequest{Destination: "live"}, http.StatusOK},
+		{"Rename missing key", "/api/v1/key/queue/rename", RenameRequest{Destination: "live"}, http.StatusNotFound},
+		{"Rename without destination", "/api/v1/key/live/rename", RenameRequest{}, http.StatusBadRequest},
+	}
 
-	// Create a new test server with the handler
-	mux := http.NewServeMux()
-	h.RegisterRoutes(mux)
-	server := httptest.NewServer(mux)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := doRequest(t, server, http.MethodPost, tc.path, tc.body)
+			resp.Body.Close()
+			if resp.StatusCode != tc.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tc.expectedStatus, resp.StatusCode)
+			}
+		})
+	}
 
-	// Return the handler and server for use in tests
-	return h, server
+	for _, key := range []string{"live", "backup", "other"} {
+		if values, _ := h.Cache.ListRange(key, 0, -1); !slices.Equal(values, []string{"job"}) {
+			t.Errorf("Expected %s to be [job], got %v", key, values)
+		}
+		if ttl, _ := h.Cache.GetTTL(key); ttl <= 0 {
+			t.Errorf("Expected %s to keep the TTL, got %v", key, ttl)
+		}
+	}
 }
 
-// parseResponse parses the response body into the given struct
-func parseResponse[T any](t *testing.T, resp *http.Response, v *types.Response[T]) {
-	t.Helper()
+// TestHashOperations tests the hash operations (HSet, HGet, HGetAll, HIncrBy, HDel)
+func TestHashOperations(t *testing.T) {
+	_, server := setupTest(t)
+	defer server.Close()
 
-	defer resp.Body.Close()
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           interface{}
+		expectedStatus int
+		validateFunc   func(*testing.T, *http.Response)
+	}{
+		{
+			name:           "HSet",
+			method:         http.MethodPost,
+			path:           "/api/v1/hash/test-hash",
+			body:           HashRequest{Fields: map[string]string{"name": "gredis", "visits": "1"}},
+			expectedStatus: http.StatusCreated,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[map[string]any]
+				parseResponse(t, resp, &response)
+				if response.Data["added"] != float64(2) {
+					t.Errorf("Unexpected response data: %v", response.Data)
+				}
+			},
+		},
+		{
+			name:           "HGet",
+			method:         http.MethodGet,
+			path:           "/api/v1/hash/test-hash/name",
+			expectedStatus: http.StatusOK,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[map[string]string]
+				parseResponse(t, resp, &response)
+				if response.Data["field"] != "name" || response.Data["value"] != "gredis" {
+					t.Errorf("Unexpected response data: %v", response.Data)
+				}
+			},
+		},
+		{
+			name:           "HIncrBy",
+			method:         http.MethodPost,
+			path:           "/api/v1/hash/test-hash/visits/incr",
+			body:           HashIncrRequest{Increment: 41},
+			expectedStatus: http.StatusOK,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[map[string]any]
+				parseResponse(t, resp, &response)
+				if response.Data["value"] != float64(42) {
+					t.Errorf("Unexpected response data: %v", response.Data)
+				}
+			},
+		},
+		{
+			name:           "HIncrBy_NotInteger",
+			method:         http.MethodPost,
+			path:           "/api/v1/hash/test-hash/name/incr",
+			body:           HashIncrRequest{Increment: 1},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "HGetAll",
+			method:         http.MethodGet,
+			path:           "/api/v1/hash/test-hash",
+			expectedStatus: http.StatusOK,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[struct {
+					Fields map[string]string `json:"fields"`
+				}]
+				parseResponse(t, resp, &response)
+				if len(response.Data.Fields) != 2 || response.Data.Fields["visits"] != "42" {
+					t.Errorf("Unexpected response data: %v", response.Data)
+				}
+			},
+		},
+		{
+			name:           "HDel",
+			method:         http.MethodDelete,
+			path:           "/api/v1/hash/test-hash/name",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "HGet_FieldNotFound",
+			method:         http.MethodGet,
+			path:           "/api/v1/hash/test-hash/name",
+			expectedStatus: http.StatusNotFound,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[map[string]string]
+				parseResponse(t, resp, &response)
+				if response.Err != cache.ErrFieldNotFound.Error() {
+					t.Errorf("Expected error message %q, got %q", cache.ErrFieldNotFound.Error(), response.Err)
+				}
+			},
+		},
+		{
+			name:           "HGetAll_NotFound",
+			method:         http.MethodGet,
+			path:           "/api/v1/hash/missing-hash",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
 
-	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
-		t.Fatalf("Failed to decode response: %v", err)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := doRequest(t, server, tc.method, tc.path, tc.body)
+
+			// Check the response status code
+			if resp.StatusCode != tc.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tc.expectedStatus, resp.StatusCode)
+			}
+
+			// Run validation function if provided
+			if tc.validateFunc != nil {
+				tc.validateFunc(t, resp)
+			} else {
+				resp.Body.Close()
+			}
+		})
 	}
 }
+
+// TestSetOperations tests the set operations (SAdd, SMembers, SIsMember, SRem and set algebra)
+func TestSetOperations(t *testing.T) {
+	_, server := setupTest(t)
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           interface{}
+		expectedStatus int
+		validateFunc   func(*testing.T, *http.Response)
+	}{
+		{
+			name:           "SAdd_First",
+			method:         http.MethodPost,
+			path:           "/api/v1/set/tags-a",
+			body:           SetRequest{Members: []string{"go", "redis", "cache"}},
+			expectedStatus: http.StatusCreated,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[map[string]any]
+				parseResponse(t, resp, &response)
+				if response.Data["added"] != float64(3) {
+					t.Errorf("Unexpected response data: %v", response.Data)
+				}
+			},
+		},
+		{
+			name:           "SAdd_Second",
+			method:         http.MethodPost,
+			path:           "/api/v1/set/tags-b",
+			body:           SetRequest{Members: []string{"go", "http"}},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "SMembers",
+			method:         http.MethodGet,
+			path:           "/api/v1/set/tags-a",
+			expectedStatus: http.StatusOK,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[struct {
+					Members []string `json:"members"`
+				}]
+				parseResponse(t, resp, &response)
+				if strings.Join(response.Data.Members, ",") != "cache,go,redis" {
+					t.Errorf("Unexpected members: %v", response.Data.Members)
+				}
+			},
+		},
+		{
+			name:           "SIsMember",
+			method:         http.MethodGet,
+			path:           "/api/v1/set/tags-b/http",
+			expectedStatus: http.StatusOK,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[map[string]any]
+				parseResponse(t, resp, &response)
+				if response.Data["is_member"] != true {
+					t.Errorf("Unexpected response data: %v", response.Data)
+				}
+			},
+		},
+		{
+			name:           "SInter",
+			method:         http.MethodPost,
+			path:           "/api/v1/sets/inter",
+			body:           SetAlgebraRequest{Keys: []string{"tags-a", "tags-b"}},
+			expectedStatus: http.StatusOK,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[struct {
+					Members []string `json:"members"`
+				}]
+				parseResponse(t, resp, &response)
+				if strings.Join(response.Data.Members, ",") != "go" {
+					t.Errorf("Unexpected members: %v", response.Data.Members)
+				}
+			},
+		},
+		{
+			name:           "SUnionStore",
+			method:         http.MethodPost,
+			path:           "/api/v1/sets/union",
+			body:           SetAlgebraRequest{Keys: []string{"tags-a", "tags-b"}, Destination: "tags-all"},
+			expectedStatus: http.StatusOK,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[map[string]any]
+				parseResponse(t, resp, &response)
+				if response.Data["count"] != float64(4) {
+					t.Errorf("Unexpected response data: %v", response.Data)
+				}
+			},
+		},
+		{
+			name:           "SRem",
+			method:         http.MethodDelete,
+			path:           "/api/v1/set/tags-all/go",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "SDiff",
+			method:         http.MethodPost,
+			path:           "/api/v1/sets/diff",
+			body:           SetAlgebraRequest{Keys: []string{"tags-all", "tags-a"}},
+			expectedStatus: http.StatusOK,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[struct {
+					Members []string `json:"members"`
+				}]
+				parseResponse(t, resp, &response)
+				if strings.Join(response.Data.Members, ",") != "http" {
+					t.Errorf("Unexpected members: %v", response.Data.Members)
+				}
+			},
+		},
+		{
+			name:           "SMembers_NotFound",
+			method:         http.MethodGet,
+			path:           "/api/v1/set/missing-set",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := doRequest(t, server, tc.method, tc.path, tc.body)
+
+			// Check the response status code
+			if resp.StatusCode != tc.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tc.expectedStatus, resp.StatusCode)
+			}
+
+			// Run validation function if provided
+			if tc.validateFunc != nil {
+				tc.validateFunc(t, resp)
+			} else {
+				resp.Body.Close()
+			}
+		})
+	}
+}
+
+// TestSortedSetOperations tests the sorted set operations
+func TestSortedSetOperations(t *testing.T) {
+	_, server := setupTest(t)
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           interface{}
+		expectedStatus int
+		validateFunc   func(*testing.T, *http.Response)
+	}{
+		{
+			name:   "ZAdd",
+			method: http.MethodPost,
+			path:   "/api/v1/zset/board",
+			body: ZAddRequest{Members: []cache.Z{
+				{Member: "alice", Score: 10},
+				{Member: "bob", Score: 20},
+				{Member: "carol", Score: 30},
+			}},
+			expectedStatus: http.StatusCreated,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[map[string]any]
+				parseResponse(t, resp, &response)
+				if response.Data["count"] != float64(3) {
+					t.Errorf("Unexpected response data: %v", response.Data)
+				}
+			},
+		},
+		{
+			name:           "ZAdd_InvalidOptions",
+			method:         http.MethodPost,
+			path:           "/api/v1/zset/board",
+			body:           ZAddRequest{Members: []cache.Z{{Member: "alice", Score: 1}}, NX: true, XX: true},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "ZIncrBy",
+			method:         http.MethodPost,
+			path:           "/api/v1/zset/board/incr",
+			body:           ZIncrByRequest{Member: "alice", Increment: 15},
+			expectedStatus: http.StatusOK,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[map[string]any]
+				parseResponse(t, resp, &response)
+				if response.Data["score"] != float64(25) {
+					t.Errorf("Unexpected response data: %v", response.Data)
+				}
+			},
+		},
+		{
+			name:           "ZRange_ByRank",
+			method:         http.MethodGet,
+			path:           "/api/v1/zset/board/range?rev=true",
+			expectedStatus: http.StatusOK,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[struct {
+					Values []cache.Z `json:"values"`
+				}]
+				parseResponse(t, resp, &response)
+				if len(response.Data.Values) != 3 || response.Data.Values[0].Member != "carol" || response.Data.Values[1].Member != "alice" {
+					t.Errorf("Unexpected values: %v", response.Data.Values)
+				}
+			},
+		},
+		{
+			name:           "ZRange_ByScore",
+			method:         http.MethodGet,
+			path:           "/api/v1/zset/board/range?by=score&start=(20&stop=%2Binf&count=1",
+			expectedStatus: http.StatusOK,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[struct {
+					Values []cache.Z `json:"values"`
+				}]
+				parseResponse(t, resp, &response)
+				if len(response.Data.Values) != 1 || response.Data.Values[0].Member != "alice" {
+					t.Errorf("Unexpected values: %v", response.Data.Values)
+				}
+			},
+		},
+		{
+			name:           "ZRange_InvalidBound",
+			method:         http.MethodGet,
+			path:           "/api/v1/zset/board/range?by=score&start=abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "ZRank",
+			method:         http.MethodGet,
+			path:           "/api/v1/zset/board/rank/bob",
+			expectedStatus: http.StatusOK,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[map[string]any]
+				parseResponse(t, resp, &response)
+				if response.Data["rank"] != float64(0) {
+					t.Errorf("Unexpected response data: %v", response.Data)
+				}
+			},
+		},
+		{
+			name:           "ZScore_MemberNotFound",
+			method:         http.MethodGet,
+			path:           "/api/v1/zset/board/score/zed",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "ZCount",
+			method:         http.MethodGet,
+			path:           "/api/v1/zset/board/count?min=20&max=30",
+			expectedStatus: http.StatusOK,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[map[string]any]
+				parseResponse(t, resp, &response)
+				if response.Data["count"] != float64(3) {
+					t.Errorf("Unexpected response data: %v", response.Data)
+				}
+			},
+		},
+		{
+			name:           "ZPopMax",
+			method:         http.MethodDelete,
+			path:           "/api/v1/zset/board/max?count=2",
+			expectedStatus: http.StatusOK,
+			validateFunc: func(t *testing.T, resp *http.Response) {
+				var response types.Response[struct {
+					Values []cache.Z `json:"values"`
+				}]
+				parseResponse(t, resp, &response)
+				if len(response.Data.Values) != 2 || response.Data.Values[0].Member != "carol" {
+					t.Errorf("Unexpected values: %v", response.Data.Values)
+				}
+			},
+		},
+		{
+			name:           "ZRem",
+			method:         http.MethodDelete,
+			path:           "/api/v1/zset/board/member/bob",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "ZRange_NotFound",
+			method:         http.MethodGet,
+			path:           "/api/v1/zset/board/range",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := doRequest(t, server, tc.method, tc.path, tc.body)
+
+			// Check the response status code
+			if resp.StatusCode != tc.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tc.expectedStatus, resp.StatusCode)
+			}
+
+			// Run validation function if provided
+			if tc.validateFunc != nil {
+				tc.validateFunc(t, resp)
+			} else {
+				resp.Body.Close()
+			}
+		})
+	}
+}
+
+// TestPubSubOperations tests publishing messages to an event stream subscriber
+func TestPubSubOperations(t *testing.T) {
+	_, server := setupTest(t)
+	defer server.Close()
+
+	// Nobody is listening yet
+	resp := doRequest(t, server, http.MethodPost, "/api/v1/pubsub/news", PublishRequest{Message: "early"})
+	var published types.Response[map[string]any]
+	parseResponse(t, resp, &published)
+	if published.Data["receivers"] != float64(0) {
+		t.Fatalf("Unexpected response data: %v", published.Data)
+	}
+
+	// The subscription exists once the stream headers have been received
+	stream := doRequest(t, server, http.MethodGet, "/api/v1/pubsub/news.*?pattern=true", nil)
+	defer stream.Body.Close()
+	if stream.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, stream.StatusCode)
+	}
+	if ct := stream.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected content type text/event-stream, got %q", ct)
+	}
+
+	resp = doRequest(t, server, http.MethodPost, "/api/v1/pubsub/news.tech", PublishRequest{Message: "hello"})
+	parseResponse(t, resp, &published)
+	if published.Data["receivers"] != float64(1) {
+		t.Fatalf("Unexpected response data: %v", published.Data)
+	}
+
+	lines := make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(stream.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	var event []string
+	for len(event) < 2 {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("Event stream closed unexpectedly")
+			}
+			if line != "" {
+				event = append(event, line)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for event")
+		}
+	}
+
+	if event[0] != "event: message" {
+		t.Errorf("Unexpected event line: %q", event[0])
+	}
+	var msg pubsub.Message
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(event[1], "data: ")), &msg); err != nil {
+		t.Fatalf("Failed to decode event data %q: %v", event[1], err)
+	}
+	if want := (pubsub.Message{Channel: "news.tech", Pattern: "news.*", Payload: "hello"}); msg != want {
+		t.Errorf("Expected message %v, got %v", want, msg)
+	}
+
+	resp = doRequest(t, server, http.MethodGet, "/api/v1/pubsub/news?pattern=maybe", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestKeyspaceEvents(t *testing.T) {
+	memCache := cache.NewMemoryCache(0, cache.WithNotifications(cache.EventsKeyevent|cache.EventsAll))
+	h := New(memCache, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	stream := doRequest(t, server, http.MethodGet, "/api/v1/events?events=set,del&match=user:*", nil)
+	defer stream.Body.Close()
+	if stream.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, stream.StatusCode)
+	}
+
+	// Only the set and del events of the matching keys are streamed.
+	if err := memCache.Set("other", "value"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := memCache.Set("user:1", "value"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := memCache.PushBack("user:2", "value"); err != nil {
+		t.Fatalf("PushBack failed: %v", err)
+	}
+	if err := memCache.Remove("user:1"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	lines := make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(stream.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	var got []string
+	for len(got) < 4 {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("Event stream closed unexpectedly")
+			}
+			if line != "" {
+				got = append(got, line)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for events")
+		}
+	}
+
+	want := []string{
+		"event: set",
+		`data: {"event":"set","key":"user:1"}`,
+		"event: del",
+		`data: {"event":"del","key":"user:1"}`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected events %q, got %q", want, got)
+	}
+
+	resp := doRequest(t, server, http.MethodGet, "/api/v1/events?events=set,,del", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestScanKeys(t *testing.T) {
+	h, server := setupTest(t)
+	defer server.Close()
+
+	for i := range 30 {
+		if err := h.Cache.Set(fmt.Sprintf("user:%d", i), "value"); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+	if err := h.Cache.PushBack("user:list", "value"); err != nil {
+		t.Fatalf("PushBack failed: %v", err)
+	}
+	if err := h.Cache.Set("other", "value"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	type page struct {
+		Cursor uint64   `json:"cursor"`
+		Keys   []string `json:"keys"`
+	}
+	scan := func(query string) map[string]bool {
+		seen := make(map[string]bool)
+		cursor := uint64(0)
+		for pages := 0; ; pages++ {
+			if pages > 100 {
+				t.Fatal("Scan did not complete")
+			}
+			resp := doRequest(t, server, http.MethodGet, fmt.Sprintf("/api/v1/keys?cursor=%d&%s", cursor, query), nil)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+			}
+			var response types.Response[page]
+			parseResponse(t, resp, &response)
+			for _, key := range response.Data.Keys {
+				seen[key] = true
+			}
+			if cursor = response.Data.Cursor; cursor == 0 {
+				return seen
+			}
+		}
+	}
+
+	if seen := scan("match=user:*&count=3"); len(seen) != 31 || seen["other"] {
+		t.Errorf("Expected the 31 user keys, got %v", seen)
+	}
+	if seen := scan("type=list"); len(seen) != 1 || !seen["user:list"] {
+		t.Errorf("Expected only the list key, got %v", seen)
+	}
+
+	for _, query := range []string{"cursor=-1", "count=0", "count=many", "type=stream"} {
+		resp := doRequest(t, server, http.MethodGet, "/api/v1/keys?"+query, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", query, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
+
+// TestBlockingListOperations tests the long-poll list endpoints
+func TestBlockingListOperations(t *testing.T) {
+	h, server := setupTest(t)
+	defer server.Close()
+
+	type popped struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+
+	// A blocked pop is answered once another request pushes an element.
+	result := make(chan *http.Response)
+	go func() {
+		result <- doRequest(t, server, http.MethodDelete, "/api/v1/list/jobs:high/front?block=5s&key=jobs:low", nil)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	resp := doRequest(t, server, http.MethodPost, "/api/v1/list/jobs:low/back", ListRequest{Value: "job1"})
+	resp.Body.Close()
+
+	resp = <-result
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	var response types.Response[popped]
+	parseResponse(t, resp, &response)
+	if response.Data.Key != "jobs:low" || response.Data.Value != "job1" {
+		t.Errorf("Expected job1 from jobs:low, got %+v", response.Data)
+	}
+
+	resp = doRequest(t, server, http.MethodDelete, "/api/v1/list/jobs:high/back?block=0.05", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d on timeout, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+	if err := h.Cache.PushBack("jobs:high", "job2"); err != nil {
+		t.Fatalf("PushBack failed: %v", err)
+	}
+	resp = doRequest(t, server, http.MethodPost, "/api/v1/list/jobs:high/move?block=1s", ListMoveRequest{Destination: "jobs:done", To: "front"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	resp.Body.Close()
+	if values, _ := h.Cache.ListRange("jobs:done", 0, -1); !slices.Equal(values, []string{"job2"}) {
+		t.Errorf("Expected jobs:done to be [job2], got %v", values)
+	}
+
+	// Without block an empty source is not waited for.
+	resp = doRequest(t, server, http.MethodPost, "/api/v1/list/jobs:high/move", ListMoveRequest{Destination: "jobs:done"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d for an empty source, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+	resp = doRequest(t, server, http.MethodPost, "/api/v1/list/jobs:done/move", ListMoveRequest{Destination: "jobs:high"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	var moved types.Response[map[string]string]
+	parseResponse(t, resp, &moved)
+	if moved.Data["value"] != "job2" {
+		t.Errorf("Expected job2 to be moved, got %v", moved.Data)
+	}
+	if h.Cache.Exists("jobs:done") {
+		t.Error("Expected the emptied source list to be deleted")
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		body         any
+	}{
+		{http.MethodDelete, "/api/v1/list/jobs:high/front?block=soon", nil},
+		{http.MethodDelete, "/api/v1/list/jobs:high/front?block=-1s", nil},
+		{http.MethodPost, "/api/v1/list/jobs:high/move?block=1s", ListMoveRequest{}},
+		{http.MethodPost, "/api/v1/list/jobs:high/move?block=1s", ListMoveRequest{Destination: "jobs:done", From: "middle"}},
+	} {
+		resp := doRequest(t, server, tc.method, tc.path, tc.body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s %s: expected status code %d, got %d", tc.method, tc.path, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
+
+// TestAdminOperations tests the on-demand snapshot endpoint
+func TestAdminOperations(t *testing.T) {
+	_, server := setupTest(t)
+	defer server.Close()
+
+	resp := doRequest(t, server, http.MethodPost, "/api/v1/admin/save", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("Expected status code %d without persistence, got %d", http.StatusNotImplemented, resp.StatusCode)
+	}
+
+	memCache := cache.NewMemoryCache(0)
+	if err := memCache.Set("key", "value"); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "dump.gredis")
+	h := New(memCache, logger, WithSnapshotter(persistence.NewSnapshotter(memCache, path, 0, logger)))
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	persistent := httptest.NewServer(mux)
+	defer persistent.Close()
+
+	resp = doRequest(t, persistent, http.MethodPost, "/api/v1/admin/save", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	var response types.Response[map[string]any]
+	parseResponse(t, resp, &response)
+	if response.Data["keys"] != float64(1) || response.Data["path"] != path {
+		t.Errorf("Unexpected response data: %v", response.Data)
+	}
+
+	entries, err := persistence.LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Key != "key" {
+		t.Errorf("Unexpected snapshot entries: %v", entries)
+	}
+
+	resp = doRequest(t, server, http.MethodPost, "/api/v1/admin/rewrite-aof", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("Expected status code %d without an append-only file, got %d", http.StatusNotImplemented, resp.StatusCode)
+	}
+
+	recorder := command.NewRecorder(memCache)
+	aofPath := filepath.Join(t.TempDir(), "appendonly.aof")
+	aof, err := persistence.OpenAOF(aofPath, recorder, persistence.AOFOptions{Fsync: persistence.FsyncAlways}, logger)
+	if err != nil {
+		t.Fatalf("Failed to open append-only file: %v", err)
+	}
+	recorder.AddSink(aof)
+
+	mux = http.NewServeMux()
+	New(recorder, logger, WithAOF(aof)).RegisterRoutes(mux)
+	logged := httptest.NewServer(mux)
+	defer logged.Close()
+
+	resp = doRequest(t, logged, http.MethodPost, "/api/v1/string/other", map[string]string{"value": "value"})
+	resp.Body.Close()
+	resp = doRequest(t, logged, http.MethodPost, "/api/v1/admin/rewrite-aof", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	parseResponse(t, resp, &response)
+	if response.Data["keys"] != float64(2) || response.Data["path"] != aofPath {
+		t.Errorf("Unexpected response data: %v", response.Data)
+	}
+
+	if err = aof.Close(); err != nil {
+		t.Fatalf("Failed to close append-only file: %v", err)
+	}
+	replayed := cache.NewMemoryCache(0)
+	if _, err = persistence.ReplayAOF(aofPath, replayed, logger); err != nil {
+		t.Fatalf("Failed to replay append-only file: %v", err)
+	}
+	if !replayed.Exists("key") || !replayed.Exists("other") {
+		t.Errorf("Unexpected replayed keys: %v", replayed.Dump())
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	_, server := setupTest(t)
+	defer server.Close()
+
+	resp := doRequest(t, server, http.MethodGet, "/api/v1/admin/memory", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("Expected status code %d without memory tracking, got %d", http.StatusNotImplemented, resp.StatusCode)
+	}
+
+	memCache := cache.NewMemoryCache(0, cache.WithMaxMemory(1, cache.NoEviction))
+	h := New(memCache, slog.New(slog.NewJSONHandler(io.Discard, nil)), WithMemoryReporter(memCache))
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	limited := httptest.NewServer(mux)
+	defer limited.Close()
+
+	// The first write fits, the cache is then over the limit.
+	for _, want := range []int{http.StatusCreated, http.StatusInsufficientStorage} {
+		resp = doRequest(t, limited, http.MethodPost, "/api/v1/string/key", map[string]string{"value": "value"})
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("Expected status code %d, got %d", want, resp.StatusCode)
+		}
+	}
+
+	resp = doRequest(t, limited, http.MethodGet, "/api/v1/admin/memory", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	var response types.Response[map[string]any]
+	parseResponse(t, resp, &response)
+	if response.Data["max_memory"] != float64(1) || response.Data["eviction_policy"] != "noeviction" ||
+		response.Data["keys"] != float64(1) || response.Data["rejected_writes"] != float64(1) ||
+		response.Data["used_memory"].(float64) <= 1 {
+		t.Errorf("Unexpected response data: %v", response.Data)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	h, server := setupTest(t)
+	defer server.Close()
+
+	if err := h.Cache.PushBack("source", "a"); err != nil {
+		t.Fatalf("Failed to push: %v", err)
+	}
+
+	resp := doRequest(t, server, http.MethodPost, "/api/v1/tx", []TxCommand{
+		{Command: "LPOP", Args: []string{"source"}},
+		{Command: "RPUSH", Args: []string{"destination", "a"}},
+		{Command: "GET", Args: []string{"destination"}},
+		{Command: "LRANGE", Args: []string{"destination", "0", "-1"}},
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	var response types.Response[struct {
+		Aborted bool       `json:"aborted"`
+		Results []TxResult `json:"results"`
+	}]
+	parseResponse(t, resp, &response)
+	results := response.Data.Results
+	if response.Data.Aborted || len(results) != 4 || results[0].Result != "a" || results[1].Result != float64(1) ||
+		results[2].Error != cache.ErrTypeMismatch.Error() || len(results[3].Result.([]any)) != 1 {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+
+	// A malformed command aborts the whole transaction.
+	resp = doRequest(t, server, http.MethodPost, "/api/v1/tx", []TxCommand{
+		{Command: "SET", Args: []string{"aborted", "value"}},
+		{Command: "NOPE"},
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	if h.Cache.Exists("aborted") {
+		t.Errorf("Aborted transaction was applied")
+	}
+
+	// Check-and-set: the transaction is aborted once the watched key has
+	// changed.
+	resp = doRequest(t, server, http.MethodPost, "/api/v1/tx/watch", WatchRequest{Keys: []string{"destination", "missing"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	var watched types.Response[map[string]map[string]uint64]
+	parseResponse(t, resp, &watched)
+	versions := watched.Data["versions"]
+	if versions["destination"] == 0 || versions["missing"] != 0 {
+		t.Fatalf("Unexpected versions: %v", versions)
+	}
+
+	tx := TxRequest{
+		Watch:    versions,
+		Commands: []TxCommand{{Command: "RPUSH", Args: []string{"destination", "b"}}},
+	}
+	for _, wantAborted := range []bool{false, true} {
+		resp = doRequest(t, server, http.MethodPost, "/api/v1/tx", tx)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+		var response types.Response[map[string]any]
+		parseResponse(t, resp, &response)
+		if response.Data["aborted"] != wantAborted || (response.Data["results"] == nil) != wantAborted {
+			t.Errorf("Unexpected response data with aborted = %v: %v", wantAborted, response.Data)
+		}
+	}
+	if values, _ := h.Cache.ListRange("destination", 0, -1); len(values) != 2 {
+		t.Errorf("Expected the transaction to be applied once, got %v", values)
+	}
+
+	h.ReadOnly = true
+	for _, tt := range []struct {
+		cmd        TxCommand
+		wantStatus int
+	}{
+		{TxCommand{Command: "SET", Args: []string{"key", "value"}}, http.StatusForbidden},
+		{TxCommand{Command: "LRANGE", Args: []string{"destination", "0", "-1"}}, http.StatusOK},
+	} {
+		resp = doRequest(t, server, http.MethodPost, "/api/v1/tx", []TxCommand{tt.cmd})
+		resp.Body.Close()
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s on a read-only server: expected status code %d, got %d", tt.cmd.Command, tt.wantStatus, resp.StatusCode)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	memCache := cache.NewMemoryCache(0)
+	if err := memCache.Set("key", "value"); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+
+	h := New(memCache, slog.New(slog.NewJSONHandler(io.Discard, nil)), WithReadOnly())
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       interface{}
+		wantStatus int
+	}{
+		{"Set string", http.MethodPost, "/api/v1/string/other", map[string]string{"value": "value"}, http.StatusForbidden},
+		{"Pop list", http.MethodDelete, "/api/v1/list/list/front", nil, http.StatusForbidden},
+		{"Remove key", http.MethodDelete, "/api/v1/key/key", nil, http.StatusForbidden},
+		{"Store intersection", http.MethodPost, "/api/v1/sets/inter", map[string]any{"keys": []string{"a"}, "destination": "d"}, http.StatusForbidden},
+		{"Get string", http.MethodGet, "/api/v1/string/key", nil, http.StatusOK},
+		{"Compute intersection", http.MethodPost, "/api/v1/sets/inter", map[string]any{"keys": []string{"a"}}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := doRequest(t, server, tt.method, tt.path, tt.body)
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+		})
+	}
+
+	if value, _ := memCache.Get("key"); value != "value" || memCache.Exists("other") {
+		t.Errorf("Read-only handler modified the cache")
+	}
+}
+
+func TestRequestContext(t *testing.T) {
+	memCache := cache.NewMemoryCache(0)
+	h := New(command.NewRecorder(memCache), slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	if err := h.Cache.Set("key", "value"); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"Get cancelled", cancelled, http.MethodGet, "/api/v1/string/key", "", statusClientClosedRequest},
+		{"Set past deadline", expired, http.MethodPost, "/api/v1/string/other", `{"value":"v"}`, http.StatusGatewayTimeout},
+		{"Pop cancelled", cancelled, http.MethodDelete, "/api/v1/list/list/front", "", statusClientClosedRequest},
+		{"Expire past deadline", expired, http.MethodPut, "/api/v1/ttl/key", `{"ttl":60}`, http.StatusGatewayTimeout},
+		{"Scan cancelled", cancelled, http.MethodGet, "/api/v1/keys", "", statusClientClosedRequest},
+		{"Clear past deadline", expired, http.MethodDelete, "/api/v1/keys", "", http.StatusGatewayTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequestWithContext(tt.ctx, tt.method, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d: %s", tt.wantStatus, w.Code, w.Body)
+			}
+		})
+	}
+
+	if value, _ := h.Cache.Get("key"); value != "value" || h.Cache.Exists("other") {
+		t.Errorf("A request with a done context modified the cache")
+	}
+	if at, _ := h.Cache.ExpireTime("key"); !at.IsZero() {
+		t.Errorf("A request with a done context set the key to expire at %v", at)
+	}
+}
+
+// setupTest creates a new test server with the given handler
+func setupTest(t *testing.T) (*Handler, *httptest.Server) {
+	t.Helper()
+
+	// Create a new in-memory cache with a short cleanup interval
+	memCache := cache.NewMemoryCache(100 * time.Millisecond)
+
+	// Create a test logger that discards all output
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	// Create a new handler with the cache and logger
+	h := New(memCache, logger)
+
+	// Create a new test server with the handler
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+
+	// Return the handler and server for use in tests
+	return h, server
+}
+
+// parseResponse parses the response body into the given struct
+func parseResponse[T any](t *testing.T, resp *http.Response, v *types.Response[T]) {
+	t.Helper()
+
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+}
+
+// doRequest sends a request with an optional JSON body to the test server
+func doRequest(t *testing.T, server *httptest.Server, method, path string, body interface{}) *http.Response {
+	t.Helper()
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Failed to encode request body: %v", err)
+		}
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	req, err := http.NewRequest(method, server.URL+path, reqBody)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+
+	return resp
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dsha256/gredis/internal/responder"
+)
+
+// HashRequest represents a request to set fields of a hash
+type HashRequest struct {
+	Fields map[string]string `json:"fields"`
+}
+
+// HashIncrRequest represents a request to increment a hash field
+type HashIncrRequest struct {
+	Increment int64 `json:"increment"`
+}
+
+// HGetAll handles GET /api/v1/hash/{key}
+func (h *Handler) HGetAll(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/api/v1/hash/")
+
+	fields, err := h.Cache.HGetAll(key)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Hash retrieved successfully", map[string]any{
+		"key":    key,
+		"fields": fields,
+	})
+}
+
+// HSet handles POST /api/v1/hash/{key}
+func (h *Handler) HSet(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/api/v1/hash/")
+
+	var req HashRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	added, err := h.Cache.HSet(key, req.Fields)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusCreated, "Hash fields set successfully", map[string]any{
+		"key":   key,
+		"added": added,
+	})
+}
+
+// HGet handles GET /api/v1/hash/{key}/{field}
+func (h *Handler) HGet(w http.ResponseWriter, r *http.Request) {
+	key, field := r.PathValue("key"), r.PathValue("field")
+
+	value, err := h.Cache.HGet(key, field)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Hash field retrieved successfully", map[string]string{
+		"key":   key,
+		"field": field,
+		"value": value,
+	})
+}
+
+// HDel handles DELETE /api/v1/hash/{key}/{field}
+func (h *Handler) HDel(w http.ResponseWriter, r *http.Request) {
+	key, field := r.PathValue("key"), r.PathValue("field")
+
+	removed, err := h.Cache.HDel(key, field)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Hash field removed successfully", map[string]any{
+		"key":     key,
+		"field":   field,
+		"removed": removed,
+	})
+}
+
+// HIncrBy handles POST /api/v1/hash/{key}/{field}/incr
+func (h *Handler) HIncrBy(w http.ResponseWriter, r *http.Request) {
+	key, field := r.PathValue("key"), r.PathValue("field")
+
+	var req HashIncrRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	value, err := h.Cache.HIncrBy(key, field, req.Increment)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Hash field incremented successfully", map[string]any{
+		"key":   key,
+		"field": field,
+		"value": value,
+	})
+}
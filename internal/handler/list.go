@@ -2,9 +2,13 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dsha256/gredis/internal/cache"
 	"github.com/dsha256/gredis/internal/responder"
@@ -15,12 +19,22 @@ type ListRequest struct {
 	Value string `json:"value"`
 }
 
-// ListRangeRequest represents a request to get a range of values from a list
+// ListRangeRequest represents a request to get a range of values from a
+// list, or to trim it to the range
 type ListRangeRequest struct {
 	Start int `json:"start"`
 	End   int `json:"end"`
 }
 
+// ListInsertRequest represents a request to insert a value next to a pivot
+// element of a list
+type ListInsertRequest struct {
+	Pivot string `json:"pivot"`
+	Value string `json:"value"`
+	// Position is "before" or "after" the pivot, "before" if empty.
+	Position string `json:"position,omitempty"`
+}
+
 // PushFront handles POST /api/v1/list/{key}/front
 func (h *Handler) PushFront(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/api/v1/list/")
@@ -31,7 +45,7 @@ func (h *Handler) PushFront(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.Cache.PushFront(key, req.Value)
+	err := h.store().PushFrontContext(r.Context(), key, req.Value)
 	if h.HandleError(w, err) {
 		return
 	}
@@ -53,7 +67,7 @@ func (h *Handler) PushBack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Cache.PushBack(key, req.Value); err != nil {
+	if err := h.store().PushBackContext(r.Context(), key, req.Value); err != nil {
 		h.HandleError(w, err)
 		return
 	}
@@ -65,39 +79,173 @@ func (h *Handler) PushBack(w http.ResponseWriter, r *http.Request) {
 }
 
 // PopFront handles DELETE /api/v1/list/{key}/front
+//
+// With block, a duration such as "5s" or a number of seconds, the request
+// waits up to that long for an element if the list is empty, like BLPOP;
+// 0 waits until the client disconnects. More lists can be given with
+// repeated key parameters; the element is popped from the first non-empty
+// one.
 func (h *Handler) PopFront(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/api/v1/list/")
 	key = strings.TrimSuffix(key, "/front")
 
-	value, found := h.Cache.PopFront(key)
-	if !found {
-		responder.WriteError(w, http.StatusNotFound, cache.ErrKeyNotFound)
+	h.pop(w, r, key, cache.ListFront, "Value popped from front of list successfully")
+}
+
+// PopBack handles DELETE /api/v1/list/{key}/back
+//
+// It accepts the block and key parameters of PopFront, like BRPOP.
+func (h *Handler) PopBack(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/api/v1/list/")
+	key = strings.TrimSuffix(key, "/back")
+
+	h.pop(w, r, key, cache.ListBack, "Value popped from back of list successfully")
+}
+
+// pop pops an element from the given end of the list at key, blocking if
+// the request asks to.
+func (h *Handler) pop(w http.ResponseWriter, r *http.Request, key string, end cache.ListEnd, msg string) {
+	query := r.URL.Query()
+	timeout, blocking, err := parseBlock(query.Get("block"))
+	if err != nil {
+		responder.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var value string
+	if blocking {
+		h.longPoll(w, timeout)
+		keys := append([]string{key}, query["key"]...)
+		key, value, err = cache.BPop(r.Context(), h.Cache, keys, end, timeout)
+		if r.Context().Err() != nil {
+			// The client is gone.
+			return
+		}
+	} else {
+		var found bool
+		if end == cache.ListBack {
+			value, found, err = h.store().PopBackContext(r.Context(), key)
+		} else {
+			value, found, err = h.store().PopFrontContext(r.Context(), key)
+		}
+		if err == nil && !found {
+			err = cache.ErrKeyNotFound
+		}
+	}
+	if h.HandleError(w, err) {
 		return
 	}
 
-	responder.WriteSuccess(w, http.StatusOK, "Value popped from front of list successfully", map[string]string{
+	responder.WriteSuccess(w, http.StatusOK, msg, map[string]string{
 		"key":   key,
 		"value": value,
 	})
 }
 
-// PopBack handles DELETE /api/v1/list/{key}/back
-func (h *Handler) PopBack(w http.ResponseWriter, r *http.Request) {
+// ListMoveRequest represents a request to move an element between lists
+type ListMoveRequest struct {
+	Destination string `json:"destination"`
+	// From and To are "front" or "back"; they default to "front" and
+	// "back".
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Move handles POST /api/v1/list/{key}/move
+//
+// An element is moved atomically from the list at key to the destination
+// list, like LMOVE. With block, which has the meaning it has for PopFront,
+// the request waits for an element like BLMOVE.
+func (h *Handler) Move(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/api/v1/list/")
-	key = strings.TrimSuffix(key, "/back")
+	key = strings.TrimSuffix(key, "/move")
 
-	value, found := h.Cache.PopBack(key)
-	if !found {
-		responder.WriteError(w, http.StatusNotFound, cache.ErrKeyNotFound)
+	timeout, blocking, err := parseBlock(r.URL.Query().Get("block"))
+	if err != nil {
+		responder.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
+	var req ListMoveRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Destination == "" {
+		responder.WriteError(w, http.StatusBadRequest, errors.New("destination is required"))
+		return
+	}
+	from, to := cache.ListFront, cache.ListBack
+	if req.From != "" {
+		if from, err = cache.ParseListEnd(req.From); h.HandleError(w, err) {
+			return
+		}
+	}
+	if req.To != "" {
+		if to, err = cache.ParseListEnd(req.To); h.HandleError(w, err) {
+			return
+		}
+	}
 
-	responder.WriteSuccess(w, http.StatusOK, "Value popped from back of list successfully", map[string]string{
-		"key":   key,
-		"value": value,
+	var value string
+	if blocking {
+		h.longPoll(w, timeout)
+		value, err = cache.BMove(r.Context(), h.Cache, key, req.Destination, from, to, timeout)
+		if r.Context().Err() != nil {
+			return
+		}
+	} else {
+		var moved bool
+		value, moved, err = h.store().ListMoveContext(r.Context(), key, req.Destination, from, to)
+		if err == nil && !moved {
+			err = cache.ErrKeyNotFound
+		}
+	}
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Value moved successfully", map[string]string{
+		"source":      key,
+		"destination": req.Destination,
+		"value":       value,
 	})
 }
 
+// longPoll extends the write deadline of a request that may wait for
+// timeout, or clears it if timeout is zero.
+func (h *Handler) longPoll(w http.ResponseWriter, timeout time.Duration) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout + longPollMargin)
+	}
+	if err := http.NewResponseController(w).SetWriteDeadline(deadline); err != nil {
+		h.Logger.Debug("Failed to extend write deadline", "error", err)
+	}
+}
+
+// longPollMargin is the time a long poll is given to write its response
+// after its timeout.
+const longPollMargin = 5 * time.Second
+
+// parseBlock parses the block parameter of a blocking request, a duration
+// or a number of seconds, and reports whether it was given.
+func parseBlock(s string) (time.Duration, bool, error) {
+	if s == "" {
+		return 0, false, nil
+	}
+	timeout, err := time.ParseDuration(s)
+	if err != nil {
+		seconds, serr := strconv.ParseFloat(s, 64)
+		if serr != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+			return 0, false, fmt.Errorf("invalid block duration %q", s)
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	if timeout < 0 {
+		return 0, false, errors.New("block must not be negative")
+	}
+	return timeout, true, nil
+}
+
 // ListRange handles GET /api/v1/list/{key}/range
 func (h *Handler) ListRange(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/api/v1/list/")
@@ -118,7 +266,7 @@ func (h *Handler) ListRange(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	values, err := h.Cache.ListRange(key, start, end)
+	values, err := h.store().ListRangeContext(r.Context(), key, start, end)
 	if h.HandleError(w, err) {
 		return
 	}
@@ -130,3 +278,183 @@ func (h *Handler) ListRange(w http.ResponseWriter, r *http.Request) {
 		"values": values,
 	})
 }
+
+// ListLen handles GET /api/v1/list/{key}/len
+func (h *Handler) ListLen(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	length, err := h.store().ListLenContext(r.Context(), key)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "List length retrieved successfully", map[string]any{
+		"key":    key,
+		"length": length,
+	})
+}
+
+// ListIndex handles GET /api/v1/list/{key}/index/{index}
+func (h *Handler) ListIndex(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil {
+		responder.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	value, err := h.store().ListIndexContext(r.Context(), key, index)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "List element retrieved successfully", map[string]any{
+		"key":   key,
+		"index": index,
+		"value": value,
+	})
+}
+
+// ListSet handles PUT /api/v1/list/{key}/index/{index}
+func (h *Handler) ListSet(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil {
+		responder.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req ListRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	if h.HandleError(w, h.store().ListSetContext(r.Context(), key, index, req.Value)) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "List element updated successfully", map[string]any{
+		"key":   key,
+		"index": index,
+		"value": req.Value,
+	})
+}
+
+// ListInsert handles POST /api/v1/list/{key}/insert
+func (h *Handler) ListInsert(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	var req ListInsertRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	var before bool
+	switch strings.ToLower(req.Position) {
+	case "", "before":
+		before = true
+	case "after":
+	default:
+		responder.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid position %q", req.Position))
+		return
+	}
+
+	length, err := h.store().ListInsertContext(r.Context(), key, before, req.Pivot, req.Value)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusCreated, "Value inserted into list successfully", map[string]any{
+		"key":    key,
+		"value":  req.Value,
+		"length": length,
+	})
+}
+
+// ListRemove handles DELETE /api/v1/list/{key}/value/{value}
+//
+// count limits the number of removed occurrences, counted from the back of
+// the list if negative; without it every occurrence is removed.
+func (h *Handler) ListRemove(w http.ResponseWriter, r *http.Request) {
+	key, value := r.PathValue("key"), r.PathValue("value")
+
+	count, err := parseOptionalInt(r.URL.Query().Get("count"), 0)
+	if err != nil {
+		responder.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	removed, err := h.store().ListRemoveContext(r.Context(), key, count, value)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Values removed from list successfully", map[string]any{
+		"key":     key,
+		"value":   value,
+		"removed": removed,
+	})
+}
+
+// ListTrim handles POST /api/v1/list/{key}/trim
+func (h *Handler) ListTrim(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	var req ListRangeRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	if h.HandleError(w, h.store().ListTrimContext(r.Context(), key, req.Start, req.End)) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "List trimmed successfully", map[string]any{
+		"key":   key,
+		"start": req.Start,
+		"end":   req.End,
+	})
+}
+
+// ListPos handles GET /api/v1/list/{key}/pos
+//
+// It returns the positions of value, with rank, count and maxlen as in
+// cache.ListPosOptions.
+func (h *Handler) ListPos(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	query := r.URL.Query()
+
+	if !query.Has("value") {
+		responder.WriteError(w, http.StatusBadRequest, errors.New("value is required"))
+		return
+	}
+	value := query.Get("value")
+
+	var opts cache.ListPosOptions
+	var err error
+	if opts.Rank, err = parseOptionalInt(query.Get("rank"), 0); err != nil {
+		responder.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if opts.Count, err = parseOptionalInt(query.Get("count"), 0); err != nil {
+		responder.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if opts.MaxLen, err = parseOptionalInt(query.Get("maxlen"), 0); err != nil {
+		responder.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	positions, err := h.store().ListPosContext(r.Context(), key, value, opts)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "List positions retrieved successfully", map[string]any{
+		"key":       key,
+		"value":     value,
+		"positions": positions,
+	})
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dsha256/gredis/internal/pubsub"
+	"github.com/dsha256/gredis/internal/responder"
+)
+
+// sseKeepAlive is how often an idle event stream receives a comment, so
+// that proxies keep the connection open and dead clients are detected.
+const sseKeepAlive = 15 * time.Second
+
+// PublishRequest represents a request to publish a message
+type PublishRequest struct {
+	Message string `json:"message"`
+}
+
+// Publish handles POST /api/v1/pubsub/{channel}
+func (h *Handler) Publish(w http.ResponseWriter, r *http.Request) {
+	channel := r.PathValue("channel")
+
+	var req PublishRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	receivers := h.Cache.Publish(channel, req.Message)
+
+	responder.WriteSuccess(w, http.StatusOK, "Message published successfully", map[string]any{
+		"channel":   channel,
+		"receivers": receivers,
+	})
+}
+
+// Subscribe handles GET /api/v1/pubsub/{channel}
+//
+// Messages are streamed as Server-Sent Events until the client disconnects.
+// With pattern=true the channel is a glob pattern, e.g. "news.*". Each
+// message is a "message" event whose data is the JSON encoded message.
+func (h *Handler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	channel := r.PathValue("channel")
+
+	pattern, err := parseOptionalBool(r.URL.Query().Get("pattern"))
+	if err != nil {
+		responder.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var sub *pubsub.Subscription
+	if pattern {
+		sub = h.Cache.PSubscribe(channel)
+	} else {
+		sub = h.Cache.Subscribe(channel)
+	}
+	defer sub.Close()
+
+	h.stream(w, r, sub, func(msg pubsub.Message) (string, any, bool) {
+		return "message", msg, true
+	})
+}
+
+// stream writes the messages received by sub as Server-Sent Events until
+// the client disconnects. event converts a message to the name and data of
+// its event, or reports false to skip it. The data is JSON encoded.
+func (h *Handler) stream(w http.ResponseWriter, r *http.Request, sub *pubsub.Subscription, event func(msg pubsub.Message) (string, any, bool)) {
+	// The stream outlives the server write timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.Logger.Debug("Failed to clear write deadline", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		h.Logger.Error("Streaming is not supported", "error", err)
+		return
+	}
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case msg, ok := <-sub.C():
+			if !ok {
+				// The broker dropped a subscriber that fell behind.
+				data, _ := json.Marshal(map[string]string{"error": sub.Err().Error()})
+				_, _ = fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+				_ = rc.Flush()
+				return
+			}
+			name, v, ok := event(msg)
+			if !ok {
+				continue
+			}
+			data, _ := json.Marshal(v)
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
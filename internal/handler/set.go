@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dsha256/gredis/internal/responder"
+)
+
+// SetRequest represents a request to add members to a set
+type SetRequest struct {
+	Members []string `json:"members"`
+}
+
+// SetAlgebraRequest represents a request to combine several sets. If
+// Destination is set, the result is stored there instead of returned.
+type SetAlgebraRequest struct {
+	Keys        []string `json:"keys"`
+	Destination string   `json:"destination,omitempty"`
+}
+
+// SMembers handles GET /api/v1/set/{key}
+func (h *Handler) SMembers(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/api/v1/set/")
+
+	members, err := h.Cache.SMembers(key)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Set members retrieved successfully", map[string]any{
+		"key":     key,
+		"members": members,
+		"count":   len(members),
+	})
+}
+
+// SAdd handles POST /api/v1/set/{key}
+func (h *Handler) SAdd(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/api/v1/set/")
+
+	var req SetRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	added, err := h.Cache.SAdd(key, req.Members...)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusCreated, "Members added to set successfully", map[string]any{
+		"key":   key,
+		"added": added,
+	})
+}
+
+// SIsMember handles GET /api/v1/set/{key}/{member}
+func (h *Handler) SIsMember(w http.ResponseWriter, r *http.Request) {
+	key, member := r.PathValue("key"), r.PathValue("member")
+
+	isMember, err := h.Cache.SIsMember(key, member)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Set membership checked", map[string]any{
+		"key":       key,
+		"member":    member,
+		"is_member": isMember,
+	})
+}
+
+// SRem handles DELETE /api/v1/set/{key}/{member}
+func (h *Handler) SRem(w http.ResponseWriter, r *http.Request) {
+	key, member := r.PathValue("key"), r.PathValue("member")
+
+	removed, err := h.Cache.SRem(key, member)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Member removed from set successfully", map[string]any{
+		"key":     key,
+		"member":  member,
+		"removed": removed,
+	})
+}
+
+// SInter handles POST /api/v1/sets/inter
+func (h *Handler) SInter(w http.ResponseWriter, r *http.Request) {
+	h.setAlgebra(w, r, "intersection", h.Cache.SInter, h.Cache.SInterStore)
+}
+
+// SUnion handles POST /api/v1/sets/union
+func (h *Handler) SUnion(w http.ResponseWriter, r *http.Request) {
+	h.setAlgebra(w, r, "union", h.Cache.SUnion, h.Cache.SUnionStore)
+}
+
+// SDiff handles POST /api/v1/sets/diff
+func (h *Handler) SDiff(w http.ResponseWriter, r *http.Request) {
+	h.setAlgebra(w, r, "difference", h.Cache.SDiff, h.Cache.SDiffStore)
+}
+
+// setAlgebra computes a set operation and either returns or stores the result
+func (h *Handler) setAlgebra(
+	w http.ResponseWriter,
+	r *http.Request,
+	name string,
+	compute func(keys ...string) ([]string, error),
+	store func(destination string, keys ...string) (int, error),
+) {
+	var req SetAlgebraRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Destination != "" {
+		if h.rejectWrite(w) {
+			return
+		}
+
+		count, err := store(req.Destination, req.Keys...)
+		if h.HandleError(w, err) {
+			return
+		}
+
+		responder.WriteSuccess(w, http.StatusOK, "Set "+name+" stored successfully", map[string]any{
+			"keys":        req.Keys,
+			"destination": req.Destination,
+			"count":       count,
+		})
+		return
+	}
+
+	members, err := compute(req.Keys...)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Set "+name+" computed successfully", map[string]any{
+		"keys":    req.Keys,
+		"members": members,
+		"count":   len(members),
+	})
+}
@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"slices"
 	"strings"
-	"time"
 
 	"github.com/dsha256/gredis/internal/cache"
 	"github.com/dsha256/gredis/internal/responder"
@@ -11,15 +14,71 @@ import (
 
 // StringRequest represents a request to set a string value
 type StringRequest struct {
-	Value string        `json:"value"`
-	TTL   time.Duration `json:"ttl,omitempty"` // in seconds
+	Value string `json:"value"`
+	// TTL and PX expire the key after this many seconds or milliseconds.
+	TTL int64 `json:"ttl,omitempty"`
+	PX  int64 `json:"px,omitempty"`
+	// NX only sets the value if the key does not exist, XX only if it
+	// does.
+	NX bool `json:"nx,omitempty"`
+	XX bool `json:"xx,omitempty"`
+	// Get returns the previous value of the key as "previous".
+	Get bool `json:"get,omitempty"`
+	// KeepTTL keeps the TTL of an existing key.
+	KeepTTL bool `json:"keepttl,omitempty"`
+	// ExAt and PxAt expire the key at a Unix time in seconds or
+	// milliseconds.
+	ExAt int64 `json:"exat,omitempty"`
+	PxAt int64 `json:"pxat,omitempty"`
+}
+
+// options converts the request into cache.SetOptions.
+func (req StringRequest) options() (cache.SetOptions, error) {
+	at, _, err := expiration(req.TTL, req.PX, req.ExAt, req.PxAt)
+	if err != nil {
+		return cache.SetOptions{}, err
+	}
+	return cache.SetOptions{
+		NX:       req.NX,
+		XX:       req.XX,
+		Get:      req.Get,
+		KeepTTL:  req.KeepTTL,
+		ExpireAt: at,
+	}, nil
+}
+
+// CounterRequest represents a request to increment or decrement an
+// integer string value. Increment defaults to 1.
+type CounterRequest struct {
+	Increment *int64 `json:"increment,omitempty"`
+}
+
+// FloatCounterRequest represents a request to increment a numeric string
+// value by a float
+type FloatCounterRequest struct {
+	Increment float64 `json:"increment"`
+}
+
+// MGetRequest represents a request to get several string values
+type MGetRequest struct {
+	Keys []string `json:"keys"`
+}
+
+// MSetRequest represents a request to set several string values. With NX
+// nothing is set if any of the keys exists.
+type MSetRequest struct {
+	Values map[string]string `json:"values"`
+	NX     bool              `json:"nx,omitempty"`
 }
 
 // GetString handles GET /api/v1/string/{key}
 func (h *Handler) GetString(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/api/v1/string/")
 
-	value, found := h.Cache.Get(key)
+	value, found, err := h.store().GetContext(r.Context(), key)
+	if h.HandleError(w, err) {
+		return
+	}
 	if !found {
 		h.HandleError(w, cache.ErrKeyNotFound)
 		return
@@ -32,6 +91,9 @@ func (h *Handler) GetString(w http.ResponseWriter, r *http.Request) {
 }
 
 // SetString handles POST /api/v1/string/{key}
+//
+// A value that NX or XX prevents from being set is answered with 409
+// Conflict or 404 Not Found.
 func (h *Handler) SetString(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/api/v1/string/")
 
@@ -40,21 +102,32 @@ func (h *Handler) SetString(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var err error
-	if req.TTL > 0 {
-		err = h.Cache.SetWithTTL(key, req.Value, req.TTL*time.Second)
-	} else {
-		err = h.Cache.Set(key, req.Value)
+	opts, err := req.options()
+	if h.HandleError(w, err) {
+		return
 	}
 
+	result, err := h.store().SetWithOptionsContext(r.Context(), key, req.Value, opts)
 	if h.HandleError(w, err) {
 		return
 	}
+	if !result.Stored {
+		if req.NX {
+			h.HandleError(w, cache.ErrKeyExists)
+		} else {
+			h.HandleError(w, cache.ErrKeyNotFound)
+		}
+		return
+	}
 
-	responder.WriteSuccess(w, http.StatusCreated, "Value set successfully", map[string]string{
+	data := map[string]string{
 		"key":   key,
 		"value": req.Value,
-	})
+	}
+	if result.HadPrevious {
+		data["previous"] = result.Previous
+	}
+	responder.WriteSuccess(w, http.StatusCreated, "Value set successfully", data)
 }
 
 // UpdateString handles PUT /api/v1/string/{key}
@@ -66,7 +139,7 @@ func (h *Handler) UpdateString(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Cache.Update(key, req.Value); err != nil {
+	if err := h.store().UpdateContext(r.Context(), key, req.Value); err != nil {
 		h.HandleError(w, err)
 		return
 	}
@@ -76,3 +149,141 @@ func (h *Handler) UpdateString(w http.ResponseWriter, r *http.Request) {
 		"value": req.Value,
 	})
 }
+
+// IncrString handles POST /api/v1/string/{key}/incr
+func (h *Handler) IncrString(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	increment, ok := h.decodeCounter(w, r)
+	if !ok {
+		return
+	}
+
+	value, err := h.store().IncrByContext(r.Context(), key, increment)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Value incremented successfully", map[string]any{
+		"key":   key,
+		"value": value,
+	})
+}
+
+// DecrString handles POST /api/v1/string/{key}/decr
+func (h *Handler) DecrString(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	decrement, ok := h.decodeCounter(w, r)
+	if !ok {
+		return
+	}
+
+	value, err := h.store().DecrByContext(r.Context(), key, decrement)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Value decremented successfully", map[string]any{
+		"key":   key,
+		"value": value,
+	})
+}
+
+// IncrFloatString handles POST /api/v1/string/{key}/incrbyfloat
+func (h *Handler) IncrFloatString(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	var req FloatCounterRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	value, err := h.store().IncrByFloatContext(r.Context(), key, req.Increment)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Value incremented successfully", map[string]any{
+		"key":   key,
+		"value": value,
+	})
+}
+
+// decodeCounter decodes an optional CounterRequest body and returns its
+// increment, 1 if the body is empty or omits it.
+func (h *Handler) decodeCounter(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	var req CounterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.HandleError(w, err)
+		return 0, false
+	}
+	if req.Increment == nil {
+		return 1, true
+	}
+	return *req.Increment, true
+}
+
+// MGet handles POST /api/v1/strings/mget
+//
+// The values are read atomically. Keys that are missing or do not hold a
+// string are listed in missing, in request order.
+func (h *Handler) MGet(w http.ResponseWriter, r *http.Request) {
+	var req MGetRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.Keys) == 0 {
+		responder.WriteError(w, http.StatusBadRequest, errors.New("keys must not be empty"))
+		return
+	}
+
+	values, err := h.store().MGetContext(r.Context(), req.Keys...)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	missing := []string{}
+	for _, key := range req.Keys {
+		if _, found := values[key]; !found && !slices.Contains(missing, key) {
+			missing = append(missing, key)
+		}
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Values retrieved successfully", map[string]any{
+		"values":  values,
+		"missing": missing,
+	})
+}
+
+// MSet handles POST /api/v1/strings/mset
+//
+// The values are set atomically. With nx, a request for which any of the
+// keys exists sets nothing and is answered with 409 Conflict.
+func (h *Handler) MSet(w http.ResponseWriter, r *http.Request) {
+	var req MSetRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.Values) == 0 {
+		responder.WriteError(w, http.StatusBadRequest, errors.New("values must not be empty"))
+		return
+	}
+
+	if req.NX {
+		set, err := h.store().MSetNXContext(r.Context(), req.Values)
+		if h.HandleError(w, err) {
+			return
+		}
+		if !set {
+			h.HandleError(w, cache.ErrKeyExists)
+			return
+		}
+	} else if err := h.store().MSetContext(r.Context(), req.Values); h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusCreated, "Values set successfully", map[string]any{
+		"count": len(req.Values),
+	})
+}
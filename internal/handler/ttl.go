@@ -1,7 +1,8 @@
 package handler
 
 import (
-	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
 	"strings"
 	"time"
@@ -10,60 +11,139 @@ import (
 	"github.com/dsha256/gredis/internal/responder"
 )
 
-// TTLRequest represents a request to set a TTL for a key
+var (
+	errExpirationRequired = errors.New("one of ttl, px, exat and pxat is required")
+	errExpirationNotSet   = errors.New("expiration not set: condition not met")
+)
+
+// TTLRequest represents a request to set the expiration of a key. Exactly
+// one of TTL, PX, ExAt and PxAt must be given.
 type TTLRequest struct {
-	TTL time.Duration `json:"ttl"` // in seconds
+	// TTL and PX expire the key after this many seconds or milliseconds.
+	TTL int64 `json:"ttl,omitempty"`
+	PX  int64 `json:"px,omitempty"`
+	// ExAt and PxAt expire the key at a Unix time in seconds or
+	// milliseconds. A time in the past deletes the key.
+	ExAt int64 `json:"exat,omitempty"`
+	PxAt int64 `json:"pxat,omitempty"`
+	// NX only sets the expiration if the key has none, XX only if it has
+	// one, GT only if it is later than the current one and LT only if it
+	// is earlier.
+	NX bool `json:"nx,omitempty"`
+	XX bool `json:"xx,omitempty"`
+	GT bool `json:"gt,omitempty"`
+	LT bool `json:"lt,omitempty"`
+}
+
+// expiration returns the expiration time given by whichever of the relative
+// ttl (seconds) and px (milliseconds) and the absolute exAt (Unix seconds)
+// and pxAt (Unix milliseconds) is set, and false if none is. It fails with
+// cache.ErrInvalidOptions if more than one is set, or one is negative or
+// out of range.
+func expiration(ttl, px, exAt, pxAt int64) (time.Time, bool, error) {
+	set := 0
+	for _, n := range []int64{ttl, px, exAt, pxAt} {
+		if n < 0 {
+			return time.Time{}, false, cache.ErrInvalidOptions
+		}
+		if n > 0 {
+			set++
+		}
+	}
+
+	switch {
+	case set > 1, ttl > math.MaxInt64/int64(time.Second), px > math.MaxInt64/int64(time.Millisecond):
+		return time.Time{}, false, cache.ErrInvalidOptions
+	case ttl > 0:
+		return time.Now().Add(time.Duration(ttl) * time.Second), true, nil
+	case px > 0:
+		return time.Now().Add(time.Duration(px) * time.Millisecond), true, nil
+	case exAt > 0:
+		return time.Unix(exAt, 0), true, nil
+	case pxAt > 0:
+		return time.UnixMilli(pxAt), true, nil
+	}
+	return time.Time{}, false, nil
+}
+
+// expirationData describes the expiration time at of key, zero for none,
+// as the remaining TTL in seconds ("ttl") and milliseconds ("pttl"), to the
+// nearest millisecond, and the Unix time in milliseconds ("pxat"), each -1
+// if the key does not expire.
+func expirationData(key string, at time.Time) map[string]any {
+	if at.IsZero() {
+		return map[string]any{
+			"key":  key,
+			"ttl":  -1,
+			"pttl": -1,
+			"pxat": -1,
+		}
+	}
+
+	remaining := max(time.Until(at), 0).Round(time.Millisecond)
+	return map[string]any{
+		"key":  key,
+		"ttl":  remaining.Seconds(),
+		"pttl": remaining.Milliseconds(),
+		"pxat": at.UnixMilli(),
+	}
 }
 
 // SetTTL handles PUT /api/v1/ttl/{key}
+//
+// An expiration that NX, XX, GT or LT prevents from being set is answered
+// with 409 Conflict.
 func (h *Handler) SetTTL(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/api/v1/ttl/")
 
 	var req TTLRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		responder.WriteError(w, http.StatusBadRequest, err)
+	if !h.DecodeJSON(w, r, &req) {
 		return
 	}
 
-	if err := h.Cache.SetTTL(key, req.TTL); err != nil {
-		h.HandleError(w, err)
+	at, ok, err := expiration(req.TTL, req.PX, req.ExAt, req.PxAt)
+	if h.HandleError(w, err) {
+		return
+	}
+	if !ok {
+		responder.WriteError(w, http.StatusBadRequest, errExpirationRequired)
 		return
 	}
 
-	responder.WriteSuccess(w, http.StatusOK, "TTL set successfully", map[string]any{
-		"key": key,
-		"ttl": req.TTL.Seconds(),
-	})
+	opts := cache.ExpireOptions{NX: req.NX, XX: req.XX, GT: req.GT, LT: req.LT}
+	set, err := h.store().ExpireAtWithOptionsContext(r.Context(), key, at, opts)
+	if h.HandleError(w, err) {
+		return
+	}
+	if !set {
+		responder.WriteError(w, http.StatusConflict, errExpirationNotSet)
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "TTL set successfully", expirationData(key, at))
 }
 
 // GetTTL handles GET /api/v1/ttl/{key}
 func (h *Handler) GetTTL(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/api/v1/ttl/")
 
-	ttl, found := h.Cache.GetTTL(key)
+	at, found, err := h.store().ExpireTimeContext(r.Context(), key)
+	if h.HandleError(w, err) {
+		return
+	}
 	if !found {
 		responder.WriteError(w, http.StatusNotFound, cache.ErrKeyNotFound)
 		return
 	}
 
-	var ttlSeconds float64
-	if ttl < 0 {
-		ttlSeconds = -1 // No expiration
-	} else {
-		ttlSeconds = ttl.Seconds()
-	}
-
-	responder.WriteSuccess(w, http.StatusOK, "TTL retrieved successfully", map[string]any{
-		"key": key,
-		"ttl": ttlSeconds,
-	})
+	responder.WriteSuccess(w, http.StatusOK, "TTL retrieved successfully", expirationData(key, at))
 }
 
 // RemoveTTL handles DELETE /api/v1/ttl/{key}
 func (h *Handler) RemoveTTL(w http.ResponseWriter, r *http.Request) {
 	key := strings.TrimPrefix(r.URL.Path, "/api/v1/ttl/")
 
-	if err := h.Cache.RemoveTTL(key); err != nil {
+	if err := h.store().RemoveTTLContext(r.Context(), key); err != nil {
 		h.HandleError(w, err)
 		return
 	}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/dsha256/gredis/internal/cache"
+	"github.com/dsha256/gredis/internal/command"
+	"github.com/dsha256/gredis/internal/responder"
+)
+
+// errTxUnsupported is returned when the cache cannot apply transactions.
+var errTxUnsupported = errors.New("transactions are not supported by the cache")
+
+// TxCommand is a command of a transaction in its wire form, e.g.
+// {"command": "SET", "args": ["key", "value"]}
+type TxCommand struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+}
+
+// TxRequest represents a transaction. Watch maps keys to the versions
+// returned by POST /api/v1/tx/watch; the transaction is aborted if one of
+// them has changed since.
+type TxRequest struct {
+	Watch    map[string]uint64 `json:"watch,omitempty"`
+	Commands []TxCommand       `json:"commands"`
+}
+
+// UnmarshalJSON accepts either a transaction object or a bare array of
+// commands.
+func (r *TxRequest) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		r.Watch = nil
+		return json.Unmarshal(data, &r.Commands)
+	}
+
+	type txRequest TxRequest
+	return json.Unmarshal(data, (*txRequest)(r))
+}
+
+// TxResult is the outcome of a command of a transaction. Error is set
+// instead of Result if the command failed.
+type TxResult struct {
+	Result any    `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// WatchRequest represents a request for the versions of keys
+type WatchRequest struct {
+	Keys []string `json:"keys"`
+}
+
+// Exec handles POST /api/v1/tx
+//
+// The commands of the transaction are applied atomically. If a command is
+// unknown or has the wrong number of arguments, none is applied. If a
+// watched key has changed, none is applied either and the response reports
+// the transaction as aborted. Otherwise every command is applied and
+// reports its own result or error.
+func (h *Handler) Exec(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.Cache.(cache.Transactor)
+	if !ok {
+		responder.WriteError(w, http.StatusNotImplemented, errTxUnsupported)
+		return
+	}
+
+	var req TxRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	cmds := make([]command.Command, len(req.Commands))
+	write := false
+	for i, c := range req.Commands {
+		cmds[i] = command.New(c.Command, c.Args...)
+		write = write || command.IsWrite(c.Command)
+	}
+	if write && h.rejectWrite(w) {
+		return
+	}
+
+	tx := cache.NewTx(t)
+	for key, version := range req.Watch {
+		tx.WatchVersion(key, version)
+	}
+	if err := command.QueueTx(tx, cmds); err != nil {
+		responder.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	results, ok := tx.Exec()
+	if !ok {
+		responder.WriteSuccess(w, http.StatusOK, "Transaction aborted: a watched key changed", map[string]any{
+			"aborted": true,
+			"results": nil,
+		})
+		return
+	}
+
+	resp := make([]TxResult, len(results))
+	for i, result := range results {
+		if result.Err != nil {
+			resp[i].Error = result.Err.Error()
+		} else {
+			resp[i].Result = result.Value
+		}
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Transaction executed successfully", map[string]any{
+		"aborted": false,
+		"results": resp,
+	})
+}
+
+// Watch handles POST /api/v1/tx/watch
+//
+// It returns the current version of each key, including missing keys, to
+// be passed back in the watch field of a transaction.
+func (h *Handler) Watch(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.Cache.(cache.Transactor)
+	if !ok {
+		responder.WriteError(w, http.StatusNotImplemented, errTxUnsupported)
+		return
+	}
+
+	var req WatchRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	versions := make(map[string]uint64, len(req.Keys))
+	for _, key := range req.Keys {
+		versions[key] = t.Version(key)
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Key versions retrieved successfully", map[string]any{
+		"versions": versions,
+	})
+}
@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dsha256/gredis/internal/cache"
+	"github.com/dsha256/gredis/internal/responder"
+)
+
+// ZAddRequest represents a request to add members to a sorted set
+type ZAddRequest struct {
+	Members []cache.Z `json:"members"`
+	NX      bool      `json:"nx,omitempty"`
+	XX      bool      `json:"xx,omitempty"`
+	GT      bool      `json:"gt,omitempty"`
+	LT      bool      `json:"lt,omitempty"`
+	CH      bool      `json:"ch,omitempty"`
+}
+
+// ZIncrByRequest represents a request to increment the score of a member
+type ZIncrByRequest struct {
+	Member    string  `json:"member"`
+	Increment float64 `json:"increment"`
+}
+
+// ZAdd handles POST /api/v1/zset/{key}
+func (h *Handler) ZAdd(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/api/v1/zset/")
+
+	var req ZAddRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	opts := cache.ZAddOptions{NX: req.NX, XX: req.XX, GT: req.GT, LT: req.LT, CH: req.CH}
+	count, err := h.Cache.ZAdd(key, opts, req.Members...)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusCreated, "Members added to sorted set successfully", map[string]any{
+		"key":   key,
+		"count": count,
+	})
+}
+
+// ZIncrBy handles POST /api/v1/zset/{key}/incr
+func (h *Handler) ZIncrBy(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/api/v1/zset/")
+	key = strings.TrimSuffix(key, "/incr")
+
+	var req ZIncrByRequest
+	if !h.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	score, err := h.Cache.ZIncrBy(key, req.Increment, req.Member)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Member score incremented successfully", map[string]any{
+		"key":    key,
+		"member": req.Member,
+		"score":  score,
+	})
+}
+
+// ZRange handles GET /api/v1/zset/{key}/range
+//
+// The "by" query parameter selects a range by "rank" (default), "score" or
+// "lex". Score and lex bounds use the Redis syntax, e.g. "(1", "-inf", "[a"
+// or "+". "offset" and "count" page score and lex ranges and "rev" reverses
+// the order.
+func (h *Handler) ZRange(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/api/v1/zset/")
+	key = strings.TrimSuffix(key, "/range")
+
+	query := r.URL.Query()
+	start, stop := query.Get("start"), query.Get("stop")
+
+	rev, err := parseOptionalBool(query.Get("rev"))
+	if err != nil {
+		responder.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	opts := cache.ZRangeOptions{Rev: rev}
+	if opts.Offset, err = parseOptionalInt(query.Get("offset"), 0); err != nil {
+		responder.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if opts.Count, err = parseOptionalInt(query.Get("count"), 0); err != nil {
+		responder.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var values any
+	switch by := query.Get("by"); by {
+	case "", "rank":
+		var startRank, stopRank int
+		if startRank, err = parseOptionalInt(start, 0); err != nil {
+			responder.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		if stopRank, err = parseOptionalInt(stop, -1); err != nil {
+			responder.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		values, err = h.Cache.ZRange(key, startRank, stopRank, rev)
+	case "score":
+		min, max := cache.MinScore, cache.MaxScore
+		if start != "" {
+			if min, err = cache.ParseScoreBound(start); err != nil {
+				responder.WriteError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		if stop != "" {
+			if max, err = cache.ParseScoreBound(stop); err != nil {
+				responder.WriteError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		values, err = h.Cache.ZRangeByScore(key, min, max, opts)
+	case "lex":
+		min, max := cache.MinLex, cache.MaxLex
+		if start != "" {
+			if min, err = cache.ParseLexBound(start); err != nil {
+				responder.WriteError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		if stop != "" {
+			if max, err = cache.ParseLexBound(stop); err != nil {
+				responder.WriteError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		values, err = h.Cache.ZRangeByLex(key, min, max, opts)
+	default:
+		responder.WriteError(w, http.StatusBadRequest, errors.New("by must be one of rank, score or lex"))
+		return
+	}
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Sorted set range retrieved successfully", map[string]any{
+		"key":    key,
+		"values": values,
+	})
+}
+
+// ZRank handles GET /api/v1/zset/{key}/rank/{member}
+func (h *Handler) ZRank(w http.ResponseWriter, r *http.Request) {
+	key, member := r.PathValue("key"), r.PathValue("member")
+
+	rev, err := parseOptionalBool(r.URL.Query().Get("rev"))
+	if err != nil {
+		responder.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rank, err := h.Cache.ZRank(key, member, rev)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Member rank retrieved successfully", map[string]any{
+		"key":    key,
+		"member": member,
+		"rank":   rank,
+	})
+}
+
+// ZScore handles GET /api/v1/zset/{key}/score/{member}
+func (h *Handler) ZScore(w http.ResponseWriter, r *http.Request) {
+	key, member := r.PathValue("key"), r.PathValue("member")
+
+	score, err := h.Cache.ZScore(key, member)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Member score retrieved successfully", map[string]any{
+		"key":    key,
+		"member": member,
+		"score":  score,
+	})
+}
+
+// ZCount handles GET /api/v1/zset/{key}/count
+func (h *Handler) ZCount(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/api/v1/zset/")
+	key = strings.TrimSuffix(key, "/count")
+
+	var err error
+	min, max := cache.MinScore, cache.MaxScore
+	if s := r.URL.Query().Get("min"); s != "" {
+		if min, err = cache.ParseScoreBound(s); err != nil {
+			responder.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if s := r.URL.Query().Get("max"); s != "" {
+		if max, err = cache.ParseScoreBound(s); err != nil {
+			responder.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	count, err := h.Cache.ZCount(key, min, max)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Sorted set members counted", map[string]any{
+		"key":   key,
+		"count": count,
+	})
+}
+
+// ZRem handles DELETE /api/v1/zset/{key}/member/{member}
+func (h *Handler) ZRem(w http.ResponseWriter, r *http.Request) {
+	key, member := r.PathValue("key"), r.PathValue("member")
+
+	removed, err := h.Cache.ZRem(key, member)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Member removed from sorted set successfully", map[string]any{
+		"key":     key,
+		"member":  member,
+		"removed": removed,
+	})
+}
+
+// ZPopMin handles DELETE /api/v1/zset/{key}/min
+func (h *Handler) ZPopMin(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/api/v1/zset/")
+	key = strings.TrimSuffix(key, "/min")
+
+	h.zpop(w, r, key, h.Cache.ZPopMin)
+}
+
+// ZPopMax handles DELETE /api/v1/zset/{key}/max
+func (h *Handler) ZPopMax(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/api/v1/zset/")
+	key = strings.TrimSuffix(key, "/max")
+
+	h.zpop(w, r, key, h.Cache.ZPopMax)
+}
+
+// zpop pops up to the "count" query parameter (default 1) members
+func (h *Handler) zpop(w http.ResponseWriter, r *http.Request, key string, pop func(string, int) ([]cache.Z, error)) {
+	count, err := parseOptionalInt(r.URL.Query().Get("count"), 1)
+	if err != nil {
+		responder.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	values, err := pop(key, count)
+	if h.HandleError(w, err) {
+		return
+	}
+
+	responder.WriteSuccess(w, http.StatusOK, "Members popped from sorted set successfully", map[string]any{
+		"key":    key,
+		"values": values,
+	})
+}
+
+// parseOptionalInt parses s as an integer, returning def if s is empty
+func parseOptionalInt(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// parseOptionalBool parses s as a boolean, returning false if s is empty
+func parseOptionalBool(s string) (bool, error) {
+	if s == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
@@ -0,0 +1,532 @@
+package persistence
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+	"github.com/dsha256/gredis/internal/command"
+	"github.com/dsha256/gredis/internal/resp"
+)
+
+// AOF errors.
+var (
+	ErrCorruptAOF          = errors.New("corrupt append-only file")
+	ErrRewriteInProgress   = errors.New("append-only file rewrite already in progress")
+	ErrAOFClosed           = errors.New("append-only file is closed")
+	ErrInvalidFsyncPolicy  = errors.New("invalid fsync policy")
+	errUnexpectedAOFRecord = fmt.Errorf("%w: unexpected record", ErrCorruptAOF)
+)
+
+// FsyncPolicy controls how often the append-only file is flushed to disk.
+type FsyncPolicy string
+
+const (
+	// FsyncAlways syncs after every record. No acknowledged write is lost,
+	// at the cost of one disk flush per write.
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncEverySec syncs once per second, losing at most about a second of
+	// writes on a crash.
+	FsyncEverySec FsyncPolicy = "everysec"
+	// FsyncNo leaves flushing to the operating system.
+	FsyncNo FsyncPolicy = "no"
+)
+
+// ParseFsyncPolicy parses an fsync policy name. An empty name selects
+// FsyncEverySec.
+func ParseFsyncPolicy(s string) (FsyncPolicy, error) {
+	switch p := FsyncPolicy(s); p {
+	case "":
+		return FsyncEverySec, nil
+	case FsyncAlways, FsyncEverySec, FsyncNo:
+		return p, nil
+	default:
+		return "", fmt.Errorf("%w %q", ErrInvalidFsyncPolicy, s)
+	}
+}
+
+// AOFOptions configures an append-only file.
+type AOFOptions struct {
+	Fsync FsyncPolicy
+	// RewritePercentage triggers a background rewrite when the file has
+	// grown by this percentage since the last rewrite; zero disables
+	// automatic rewrites.
+	RewritePercentage int
+	// RewriteMinSize is the size below which the file is never rewritten
+	// automatically.
+	RewriteMinSize int64
+}
+
+// RewriteSource provides the contents an append-only file is rewritten
+// from. Exclusive must hold off writes to the cache while fn runs, so that
+// a dump taken by fn is consistent with the records appended after it.
+// command.Recorder implements it.
+type RewriteSource interface {
+	Dump() []cache.Entry
+	Exclusive(fn func())
+}
+
+// RewriteInfo describes a completed rewrite.
+type RewriteInfo struct {
+	Path     string
+	Keys     int
+	Size     int64
+	Duration time.Duration
+}
+
+// AOF is an append-only file of the commands applied to a cache. It
+// implements command.Sink, so that a command.Recorder can feed it every
+// write.
+//
+// The file grows with every write. Rewrite compacts it by replacing it with
+// the commands that rebuild the current contents of the cache, while writes
+// keep being appended.
+type AOF struct {
+	path   string
+	source RewriteSource
+	opts   AOFOptions
+	logger *slog.Logger
+
+	// mu guards the fields below.
+	mu       sync.Mutex
+	f        appendFile
+	enc      *resp.Writer
+	buf      bytes.Buffer
+	size     int64
+	baseSize int64
+	dirty    bool
+	closed   bool
+	// rewriteBuf collects the records appended while a rewrite runs; nil
+	// otherwise.
+	rewriteBuf *bytes.Buffer
+
+	rewriting atomic.Bool
+	// wg tracks background goroutines.
+	wg   sync.WaitGroup
+	stop chan struct{}
+}
+
+// appendFile is the file records are appended to. *os.File implements it.
+type appendFile interface {
+	io.Writer
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+// OpenAOF opens the append-only file at path for appending, creating it if
+// needed. Replay it with ReplayAOF first to restore its contents.
+func OpenAOF(path string, source RewriteSource, opts AOFOptions, logger *slog.Logger) (*AOF, error) {
+	if opts.Fsync == "" {
+		opts.Fsync = FsyncEverySec
+	}
+	if _, err := ParseFsyncPolicy(string(opts.Fsync)); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	a := &AOF{
+		path:     path,
+		source:   source,
+		opts:     opts,
+		logger:   logger,
+		f:        f,
+		size:     stat.Size(),
+		baseSize: stat.Size(),
+		stop:     make(chan struct{}),
+	}
+	a.enc = resp.NewWriter(&a.buf)
+
+	if opts.Fsync == FsyncEverySec {
+		a.wg.Add(1)
+		go a.syncLoop()
+	}
+
+	return a, nil
+}
+
+// Path returns the path of the file.
+func (a *AOF) Path() string {
+	return a.path
+}
+
+// Size returns the current size of the file.
+func (a *AOF) Size() int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.size
+}
+
+// Append writes cmd to the end of the file. Write errors are logged: the
+// write has already been applied to the cache and cannot be refused. A
+// record cut short by a failed write is truncated away, so that the file
+// stays replayable.
+func (a *AOF) Append(cmd command.Command) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		a.logger.Error("Dropped append-only file record", "command", cmd.Name, "error", ErrAOFClosed)
+		return
+	}
+
+	a.buf.Reset()
+	_ = a.enc.WriteCommand(append([]string{cmd.Name}, cmd.Args...)...)
+	_ = a.enc.Flush()
+	record := a.buf.Bytes()
+
+	n, err := a.f.Write(record)
+	a.dirty = true
+	if err != nil {
+		a.logger.Error("Failed to write append-only file", "path", a.path, "error", err)
+		// Cut off the part of the record that made it to the file, so that
+		// the next record does not follow a partial one. The file is opened
+		// with O_APPEND, so the next write lands at the new end.
+		if n > 0 {
+			if err = a.f.Truncate(a.size); err != nil {
+				a.size += int64(n)
+				a.logger.Error("Failed to truncate append-only file", "path", a.path, "error", err)
+			}
+		}
+		return
+	}
+	a.size += int64(n)
+	if a.rewriteBuf != nil {
+		a.rewriteBuf.Write(record)
+	}
+
+	if a.opts.Fsync == FsyncAlways {
+		if err = a.f.Sync(); err != nil {
+			a.logger.Error("Failed to sync append-only file", "path", a.path, "error", err)
+		}
+		a.dirty = false
+	}
+
+	if a.shouldRewrite() && a.rewriting.CompareAndSwap(false, true) {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			defer a.rewriting.Store(false)
+
+			info, err := a.rewrite()
+			if err != nil {
+				a.logger.Error("Failed to rewrite append-only file", "path", a.path, "error", err)
+				return
+			}
+			a.logger.Info("Append-only file rewritten", "path", info.Path, "keys", info.Keys, "size", info.Size, "duration", info.Duration.String())
+		}()
+	}
+}
+
+// shouldRewrite reports whether the file has grown enough to be rewritten
+// automatically. The caller must hold a.mu.
+func (a *AOF) shouldRewrite() bool {
+	if a.opts.RewritePercentage <= 0 || a.size < a.opts.RewriteMinSize {
+		return false
+	}
+	return a.size >= a.baseSize+a.baseSize*int64(a.opts.RewritePercentage)/100
+}
+
+// Rewrite replaces the file with the commands that rebuild the current
+// contents of the cache. Writes are appended as usual while it runs and
+// carried over to the new file. It returns ErrRewriteInProgress if another
+// rewrite is running.
+func (a *AOF) Rewrite() (RewriteInfo, error) {
+	if !a.rewriting.CompareAndSwap(false, true) {
+		return RewriteInfo{}, ErrRewriteInProgress
+	}
+	defer a.rewriting.Store(false)
+
+	return a.rewrite()
+}
+
+func (a *AOF) rewrite() (info RewriteInfo, err error) {
+	start := time.Now()
+
+	var entries []cache.Entry
+	a.source.Exclusive(func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		if a.closed {
+			err = ErrAOFClosed
+			return
+		}
+		entries = a.source.Dump()
+		a.rewriteBuf = new(bytes.Buffer)
+	})
+	if err != nil {
+		return RewriteInfo{}, err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(a.path), filepath.Base(a.path)+".tmp-*")
+	if err != nil {
+		a.abortRewrite()
+		return RewriteInfo{}, err
+	}
+	defer func() {
+		if err != nil {
+			a.abortRewrite()
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if err = writeEntries(f, entries); err != nil {
+		return RewriteInfo{}, err
+	}
+	// Sync the bulk of the file before taking the lock, so that writers are
+	// only held off while the records appended in the meantime are copied.
+	if err = f.Sync(); err != nil {
+		return RewriteInfo{}, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return RewriteInfo{}, ErrAOFClosed
+	}
+	if _, err = f.Write(a.rewriteBuf.Bytes()); err != nil {
+		return RewriteInfo{}, err
+	}
+	if err = f.Sync(); err != nil {
+		return RewriteInfo{}, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return RewriteInfo{}, err
+	}
+	if err = os.Rename(f.Name(), a.path); err != nil {
+		return RewriteInfo{}, err
+	}
+	if err = syncDir(filepath.Dir(a.path)); err != nil {
+		return RewriteInfo{}, err
+	}
+
+	_ = a.f.Close()
+	a.f = f
+	a.size = stat.Size()
+	a.baseSize = stat.Size()
+	a.dirty = false
+	a.rewriteBuf = nil
+
+	return RewriteInfo{
+		Path:     a.path,
+		Keys:     len(entries),
+		Size:     stat.Size(),
+		Duration: time.Since(start),
+	}, nil
+}
+
+// abortRewrite stops collecting records for a failed rewrite.
+func (a *AOF) abortRewrite() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.rewriteBuf = nil
+}
+
+// writeEntries writes the commands that rebuild entries to w.
+func writeEntries(w io.Writer, entries []cache.Entry) error {
+	enc := resp.NewWriter(w)
+	for _, entry := range entries {
+		for _, cmd := range command.EntryCommands(entry) {
+			if err := enc.WriteCommand(append([]string{cmd.Name}, cmd.Args...)...); err != nil {
+				return err
+			}
+		}
+	}
+	return enc.Flush()
+}
+
+// Close stops background work, syncs the file and closes it. Records
+// appended after Close are dropped.
+func (a *AOF) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.stop)
+	a.mu.Unlock()
+
+	a.wg.Wait()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.f.Sync(); err != nil {
+		_ = a.f.Close()
+		return err
+	}
+	return a.f.Close()
+}
+
+// syncLoop syncs the file once per second if it has been written to.
+func (a *AOF) syncLoop() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.mu.Lock()
+			f, dirty := a.f, a.dirty
+			a.dirty = false
+			a.mu.Unlock()
+
+			if !dirty {
+				continue
+			}
+			// The file may be replaced and closed by a rewrite meanwhile,
+			// in which case the rewrite has synced it.
+			if err := f.Sync(); err != nil && !errors.Is(err, os.ErrClosed) {
+				a.logger.Error("Failed to sync append-only file", "path", a.path, "error", err)
+			}
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// ReplayAOF applies the commands of the append-only file at path to c and
+// returns the number of commands applied. If the file does not exist, the
+// returned error satisfies errors.Is(err, fs.ErrNotExist).
+//
+// A final record cut short, e.g. by a crash in the middle of a write, is
+// logged and truncated away, as is a final transaction without EXEC. Any
+// other malformed record fails the replay with ErrCorruptAOF. Records that
+// are well formed but fail against the cache are logged and skipped.
+//
+// The commands of a transaction are applied atomically. If c implements
+// cache.Loader, keys do not expire while the file is replayed, so that
+// expirations recorded in the past take effect only once the whole file has
+// been applied.
+func ReplayAOF(path string, c cache.Store, logger *slog.Logger) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if loader, ok := c.(cache.Loader); ok {
+		loader.BeginLoad()
+		defer loader.EndLoad()
+	}
+
+	cr := &countingReader{r: f}
+	rd := resp.NewReader(cr)
+
+	var (
+		applied int
+		// tx holds the commands of an open transaction, which starts at
+		// txOffset.
+		tx       []command.Command
+		txOffset int64
+	)
+
+	// check counts a command as applied, logs it if it failed against the
+	// cache, or returns an error if it is malformed.
+	check := func(cmd command.Command, offset int64, err error) error {
+		switch {
+		case errors.Is(err, command.ErrUnknownCommand), errors.Is(err, command.ErrWrongArgs), errors.Is(err, command.ErrSyntax):
+			return fmt.Errorf("replay %s at offset %d: %w: %w", path, offset, ErrCorruptAOF, err)
+		case err != nil:
+			logger.Warn("Skipped failing append-only file record", "path", path, "offset", offset, "command", cmd.Name, "error", err)
+		default:
+			applied++
+		}
+		return nil
+	}
+
+	// truncate cuts the file at offset, dropping what a crash cut short.
+	truncate := func(offset int64) (int, error) {
+		logger.Warn("Truncating incomplete record at the end of the append-only file", "path", path, "offset", offset)
+		return applied, os.Truncate(path, offset)
+	}
+
+	for {
+		offset := cr.n - int64(rd.Buffered())
+
+		args, err := rd.ReadCommand()
+		if errors.Is(err, io.EOF) {
+			if tx != nil {
+				return truncate(txOffset)
+			}
+			return applied, nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			if tx != nil {
+				return truncate(txOffset)
+			}
+			return truncate(offset)
+		}
+		if err != nil {
+			return applied, fmt.Errorf("replay %s at offset %d: %w: %w", path, offset, ErrCorruptAOF, err)
+		}
+		if len(args) == 0 {
+			return applied, fmt.Errorf("replay %s at offset %d: %w", path, offset, errUnexpectedAOFRecord)
+		}
+
+		cmd := command.New(args[0], args[1:]...)
+		switch {
+		case cmd.Name == command.Multi && tx == nil:
+			tx, txOffset = []command.Command{}, offset
+		case cmd.Name == command.Exec && tx != nil:
+			results, err := command.ExecTx(c, tx)
+			if err != nil {
+				return applied, fmt.Errorf("replay %s at offset %d: %w: %w", path, txOffset, ErrCorruptAOF, err)
+			}
+			for i, result := range results {
+				if err = check(tx[i], txOffset, result.Err); err != nil {
+					return applied, err
+				}
+			}
+			tx = nil
+		case tx != nil:
+			tx = append(tx, cmd)
+		default:
+			_, err = command.Execute(c, cmd)
+			if err = check(cmd, offset, err); err != nil {
+				return applied, err
+			}
+		}
+	}
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,458 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+	"github.com/dsha256/gredis/internal/command"
+)
+
+func openTestAOF(t *testing.T, path string, opts AOFOptions) (*cache.MemoryCache, *command.Recorder, *AOF) {
+	t.Helper()
+
+	c := cache.NewMemoryCache(0)
+	rec := command.NewRecorder(c)
+	aof, err := OpenAOF(path, rec, opts, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	requireNoError(t, err, "OpenAOF() failed: %v", err)
+	rec.AddSink(aof)
+	return c, rec, aof
+}
+
+func replayTestAOF(t *testing.T, path string) *cache.MemoryCache {
+	t.Helper()
+
+	c := cache.NewMemoryCache(0)
+	_, err := ReplayAOF(path, c, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	requireNoError(t, err, "ReplayAOF() failed: %v", err)
+	return c
+}
+
+// requireSameContents fails unless got and want hold the same keys and
+// values, with expirations equal up to the millisecond precision of the
+// file.
+func requireSameContents(t *testing.T, got, want []cache.Entry) {
+	t.Helper()
+
+	require(t, len(got) == len(want), "got %d entries %v, want %d entries %v", len(got), got, len(want), want)
+	for i := range want {
+		g, w := got[i], want[i]
+		require(t, g.Key == w.Key && g.Type == w.Type && reflect.DeepEqual(g.Value, w.Value),
+			"entry %d = %v, want %v", i, g, w)
+		require(t, g.ExpireAt.IsZero() == w.ExpireAt.IsZero() && g.ExpireAt.Sub(w.ExpireAt).Abs() < 10*time.Millisecond,
+			"entry %q expires at %v, want %v", g.Key, g.ExpireAt, w.ExpireAt)
+	}
+}
+
+func TestAOF_Replay(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	c, rec, aof := openTestAOF(t, path, AOFOptions{Fsync: FsyncAlways})
+
+	requireNoError(t, rec.Set("string", "value"), "Set() failed")
+	requireNoError(t, rec.SetWithTTL("ttl", "value", time.Hour), "SetWithTTL() failed")
+	requireNoError(t, rec.Update("ttl", "updated"), "Update() failed")
+	requireNoError(t, rec.Set("gone", "value"), "Set() failed")
+	requireNoError(t, rec.Remove("gone"), "Remove() failed")
+	requireNoError(t, rec.Set("persisted", "value"), "Set() failed")
+	requireNoError(t, rec.SetTTL("persisted", time.Minute), "SetTTL() failed")
+	requireNoError(t, rec.RemoveTTL("persisted"), "RemoveTTL() failed")
+	requireNoError(t, rec.Set("expiring", "value"), "Set() failed")
+	_, err := rec.ExpireWithOptions("expiring", time.Minute, cache.ExpireOptions{NX: true})
+	requireNoError(t, err, "ExpireWithOptions() failed: %v", err)
+	_, err = rec.ExpireWithOptions("expiring", time.Second, cache.ExpireOptions{GT: true})
+	requireNoError(t, err, "ExpireWithOptions() failed: %v", err)
+	_, err = rec.ExpireAtWithOptions("expiring", time.Now().Add(time.Hour), cache.ExpireOptions{XX: true, GT: true})
+	requireNoError(t, err, "ExpireAtWithOptions() failed: %v", err)
+	requireNoError(t, rec.Set("expired", "value"), "Set() failed")
+	_, err = rec.ExpireAtWithOptions("expired", time.Now().Add(-time.Second), cache.ExpireOptions{LT: true})
+	requireNoError(t, err, "ExpireAtWithOptions() failed: %v", err)
+	requireNoError(t, rec.Copy("ttl", "copied", false), "Copy() failed")
+	requireNoError(t, rec.Copy("string", "copied", true), "Copy() failed")
+	requireNoError(t, rec.Rename("copied", "renamed", true), "Rename() failed")
+	requireNoError(t, rec.Copy("ttl", "renamed:ttl", false), "Copy() failed")
+	requireNoError(t, rec.Rename("renamed:ttl", "renamed:nx", false), "Rename() failed")
+	_, err = rec.SetWithOptions("opts", "a", cache.SetOptions{ExpireAt: time.Now().Add(time.Hour)})
+	requireNoError(t, err, "SetWithOptions() failed: %v", err)
+	_, err = rec.SetWithOptions("opts", "b", cache.SetOptions{XX: true, KeepTTL: true})
+	requireNoError(t, err, "SetWithOptions() failed: %v", err)
+	_, err = rec.SetWithOptions("opts", "c", cache.SetOptions{NX: true})
+	requireNoError(t, err, "SetWithOptions() failed: %v", err)
+	requireNoError(t, rec.MSet(map[string]string{"m1": "a", "m2": "b"}), "MSet() failed")
+	_, err = rec.MSetNX(map[string]string{"m2": "x", "m3": "y"})
+	requireNoError(t, err, "MSetNX() failed: %v", err)
+	_, err = rec.MSetNX(map[string]string{"m3": "y", "m4": "z"})
+	requireNoError(t, err, "MSetNX() failed: %v", err)
+	_, err = rec.IncrBy("counter", 41)
+	requireNoError(t, err, "IncrBy() failed: %v", err)
+	_, err = rec.Incr("counter")
+	requireNoError(t, err, "Incr() failed: %v", err)
+	_, err = rec.DecrBy("ttl:counter", 3)
+	requireNoError(t, err, "DecrBy() failed: %v", err)
+	requireNoError(t, rec.SetTTL("ttl:counter", time.Hour), "SetTTL() failed")
+	_, err = rec.IncrByFloat("ttl:counter", 0.1)
+	requireNoError(t, err, "IncrByFloat() failed: %v", err)
+
+	for _, v := range []string{"a", "b", "c"} {
+		requireNoError(t, rec.PushBack("list", v), "PushBack() failed")
+	}
+	requireNoError(t, rec.PushFront("list", "z"), "PushFront() failed")
+	_, err = rec.ListPush("list", cache.ListFront, "w", "v")
+	requireNoError(t, err, "ListPush() failed: %v", err)
+	rec.PopBack("list")
+	rec.PopFront("empty")
+	requireNoError(t, rec.ListSet("list", -1, "B"), "ListSet() failed")
+	_, err = rec.ListInsert("list", true, "a", "y")
+	requireNoError(t, err, "ListInsert() failed: %v", err)
+	_, err = rec.ListRemove("list", 0, "z")
+	requireNoError(t, err, "ListRemove() failed: %v", err)
+	requireNoError(t, rec.ListTrim("list", 0, -2), "ListTrim() failed")
+	requireNoError(t, rec.PushBack("trimmed", "x"), "PushBack() failed")
+	requireNoError(t, rec.ListTrim("trimmed", 5, 10), "ListTrim() failed")
+	_, _, err = rec.ListMove("list", "list", cache.ListFront, cache.ListBack)
+	requireNoError(t, err, "ListMove() failed: %v", err)
+	_, _, err = rec.ListMove("list", "moved", cache.ListBack, cache.ListFront)
+	requireNoError(t, err, "ListMove() failed: %v", err)
+
+	_, err = rec.HSet("hash", map[string]string{"f": "1", "g": "2"})
+	requireNoError(t, err, "HSet() failed: %v", err)
+	_, err = rec.HIncrBy("hash", "f", 41)
+	requireNoError(t, err, "HIncrBy() failed: %v", err)
+	_, err = rec.HDel("hash", "g")
+	requireNoError(t, err, "HDel() failed: %v", err)
+
+	_, err = rec.SAdd("s1", "a", "b", "c")
+	requireNoError(t, err, "SAdd() failed: %v", err)
+	_, err = rec.SAdd("s2", "b", "c", "d")
+	requireNoError(t, err, "SAdd() failed: %v", err)
+	_, err = rec.SInterStore("inter", "s1", "s2")
+	requireNoError(t, err, "SInterStore() failed: %v", err)
+	_, err = rec.SRem("s1", "a")
+	requireNoError(t, err, "SRem() failed: %v", err)
+
+	_, err = rec.ZAdd("zset", cache.ZAddOptions{}, cache.Z{Member: "a", Score: 1.5}, cache.Z{Member: "b", Score: 2}, cache.Z{Member: "c", Score: 3})
+	requireNoError(t, err, "ZAdd() failed: %v", err)
+	_, err = rec.ZAdd("zset", cache.ZAddOptions{GT: true}, cache.Z{Member: "a", Score: 0})
+	requireNoError(t, err, "ZAdd() failed: %v", err)
+	_, err = rec.ZIncrBy("zset", 0.25, "b")
+	requireNoError(t, err, "ZIncrBy() failed: %v", err)
+	_, err = rec.ZPopMax("zset", 1)
+	requireNoError(t, err, "ZPopMax() failed: %v", err)
+
+	requireNoError(t, aof.Close(), "Close() failed")
+
+	requireSameContents(t, replayTestAOF(t, path).Dump(), c.Dump())
+}
+
+func TestAOF_ReplayEvicted(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	c := cache.NewMemoryCache(0, cache.WithMaxMemory(1024, cache.AllKeysRandom))
+	rec := command.NewRecorder(c)
+	aof, err := OpenAOF(path, rec, AOFOptions{Fsync: FsyncNo}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	requireNoError(t, err, "OpenAOF() failed: %v", err)
+	rec.AddSink(aof)
+
+	for i := range 100 {
+		requireNoError(t, rec.Set(fmt.Sprint("key", i), "value"), "Set() failed")
+	}
+	require(t, c.MemoryStats().EvictedKeys > 0, "no key was evicted")
+	requireNoError(t, aof.Close(), "Close() failed")
+
+	// Evictions are recorded, so the replayed cache holds the same keys
+	// even without a memory limit.
+	requireSameContents(t, replayTestAOF(t, path).Dump(), c.Dump())
+}
+
+func TestAOF_ReplayTx(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	c, rec, aof := openTestAOF(t, path, AOFOptions{Fsync: FsyncNo})
+
+	requireNoError(t, rec.PushBack("source", "a"), "PushBack() failed")
+	results, err := command.ExecTx(rec, []command.Command{
+		command.New("LPOP", "source"),
+		command.New("RPUSH", "destination", "a"),
+		command.New("GET", "destination"),
+	})
+	requireNoError(t, err, "ExecTx() failed: %v", err)
+	require(t, results[0].Value == "a" && results[2].Err != nil, "ExecTx() = %+v", results)
+	requireNoError(t, aof.Close(), "Close() failed")
+
+	data, err := os.ReadFile(path)
+	requireNoError(t, err, "ReadFile() failed: %v", err)
+	require(t, strings.Contains(string(data), "MULTI") && strings.Contains(string(data), "EXEC"), "transaction was not recorded between MULTI and EXEC:\n%s", data)
+
+	requireSameContents(t, replayTestAOF(t, path).Dump(), c.Dump())
+}
+
+func TestAOF_ReplayExpired(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	_, rec, aof := openTestAOF(t, path, AOFOptions{Fsync: FsyncNo})
+
+	requireNoError(t, rec.SetWithTTL("short", "value", 20*time.Millisecond), "SetWithTTL() failed")
+	requireNoError(t, rec.Set("long", "value"), "Set() failed")
+	requireNoError(t, rec.SetTTL("long", time.Hour), "SetTTL() failed")
+	requireNoError(t, aof.Close(), "Close() failed")
+
+	// Keys that expired while the server was down stay expired.
+	time.Sleep(30 * time.Millisecond)
+	c := replayTestAOF(t, path)
+	require(t, !c.Exists("short"), "ReplayAOF() resurrected an expired key")
+	ttl, ok := c.GetTTL("long")
+	require(t, ok && ttl > 59*time.Minute, "GetTTL() = %v, %v", ttl, ok)
+}
+
+func TestAOF_ReplayExactExpiration(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	c, rec, aof := openTestAOF(t, path, AOFOptions{Fsync: FsyncNo})
+
+	requireNoError(t, rec.SetWithTTL("ttl", "value", time.Hour), "SetWithTTL() failed")
+	_, err := rec.SetWithOptions("options", "value", cache.SetOptions{TTL: time.Hour})
+	requireNoError(t, err, "SetWithOptions() failed: %v", err)
+	requireNoError(t, rec.Set("expire", "value"), "Set() failed")
+	requireNoError(t, rec.SetTTL("expire", time.Hour), "SetTTL() failed")
+	requireNoError(t, aof.Close(), "Close() failed")
+
+	// The recorded expirations are the ones the keys were given, to the
+	// millisecond.
+	replayed := replayTestAOF(t, path)
+	for _, key := range []string{"ttl", "options", "expire"} {
+		want, _ := c.ExpireTime(key)
+		got, _ := replayed.ExpireTime(key)
+		require(t, got.UnixMilli() == want.UnixMilli(), "%q expires at %v after replay, want %v", key, got, want)
+	}
+}
+
+func TestAOF_ReplayCancelledClear(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	c, rec, aof := openTestAOF(t, path, AOFOptions{Fsync: FsyncNo})
+
+	requireNoError(t, rec.Set("kept", "value"), "Set() failed")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := rec.ClearContext(ctx)
+	require(t, errors.Is(err, context.Canceled), "ClearContext() error = %v, want context.Canceled", err)
+	requireNoError(t, aof.Close(), "Close() failed")
+
+	// A clear that did not happen is not recorded.
+	requireSameContents(t, replayTestAOF(t, path).Dump(), c.Dump())
+	require(t, c.Exists("kept"), "a cancelled ClearContext() removed the key")
+}
+
+func TestAOF_ReplayDamaged(t *testing.T) {
+	t.Parallel()
+
+	const valid = "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n"
+
+	tests := []struct {
+		name     string
+		data     string
+		wantErr  error
+		wantSize int64
+	}{
+		{
+			name:     "truncated array",
+			data:     valid + "*3\r\n$3\r\nSET\r\n$1\r\nk",
+			wantSize: int64(len(valid)),
+		},
+		{
+			name:     "truncated header",
+			data:     valid + "*3",
+			wantSize: int64(len(valid)),
+		},
+		{
+			name:     "unterminated transaction",
+			data:     valid + "*1\r\n$5\r\nMULTI\r\n" + "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nw\r\n",
+			wantSize: int64(len(valid)),
+		},
+		{
+			name:     "truncated transaction",
+			data:     valid + "*1\r\n$5\r\nMULTI\r\n" + "*3\r\n$3\r\nSET",
+			wantSize: int64(len(valid)),
+		},
+		{
+			name:    "unknown command in transaction",
+			data:    valid + "*1\r\n$5\r\nMULTI\r\n*1\r\n$4\r\nNOPE\r\n*1\r\n$4\r\nEXEC\r\n",
+			wantErr: ErrCorruptAOF,
+		},
+		{
+			name:    "unknown command",
+			data:    valid + "*1\r\n$4\r\nNOPE\r\n",
+			wantErr: ErrCorruptAOF,
+		},
+		{
+			name:    "malformed record",
+			data:    "*1\r\n$x\r\n" + valid,
+			wantErr: ErrCorruptAOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "appendonly.aof")
+			requireNoError(t, os.WriteFile(path, []byte(tt.data), 0o644), "WriteFile() failed")
+
+			c := cache.NewMemoryCache(0)
+			_, err := ReplayAOF(path, c, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if tt.wantErr != nil {
+				require(t, errors.Is(err, tt.wantErr), "ReplayAOF() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			requireNoError(t, err, "ReplayAOF() failed: %v", err)
+
+			value, ok := c.Get("k")
+			require(t, ok && value == "v", "Get() = %q, %v", value, ok)
+			stat, err := os.Stat(path)
+			requireNoError(t, err, "Stat() failed: %v", err)
+			require(t, stat.Size() == tt.wantSize, "file size = %d, want %d", stat.Size(), tt.wantSize)
+		})
+	}
+
+	_, err := ReplayAOF(filepath.Join(t.TempDir(), "missing.aof"), cache.NewMemoryCache(0), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require(t, errors.Is(err, fs.ErrNotExist), "ReplayAOF() error = %v, want %v", err, fs.ErrNotExist)
+}
+
+// shortFile is an appendFile whose next write stops halfway with err, as
+// on a full disk.
+type shortFile struct {
+	appendFile
+	err error
+}
+
+func (f *shortFile) Write(p []byte) (int, error) {
+	if f.err == nil {
+		return f.appendFile.Write(p)
+	}
+	n, _ := f.appendFile.Write(p[:len(p)/2])
+	err := f.err
+	f.err = nil
+	return n, err
+}
+
+func TestAOF_ShortWrite(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	_, rec, aof := openTestAOF(t, path, AOFOptions{Fsync: FsyncAlways})
+
+	requireNoError(t, rec.Set("before", "1"), "Set() failed")
+	aof.mu.Lock()
+	aof.f = &shortFile{appendFile: aof.f, err: errors.New("no space left on device")}
+	aof.mu.Unlock()
+	requireNoError(t, rec.Set("lost", "2"), "Set() failed")
+	requireNoError(t, rec.Set("after", "3"), "Set() failed")
+
+	size := aof.Size()
+	requireNoError(t, aof.Close(), "Close() failed")
+	stat, err := os.Stat(path)
+	requireNoError(t, err, "Stat() failed: %v", err)
+	require(t, stat.Size() == size, "file size = %d, want %d", stat.Size(), size)
+
+	replayed := replayTestAOF(t, path)
+	for key, want := range map[string]bool{"before": true, "lost": false, "after": true} {
+		_, ok := replayed.Get(key)
+		require(t, ok == want, "replayed Get(%q) found = %v, want %v", key, ok, want)
+	}
+}
+
+func TestAOF_Rewrite(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	c, rec, aof := openTestAOF(t, path, AOFOptions{Fsync: FsyncEverySec})
+
+	for i := range 200 {
+		requireNoError(t, rec.Set("counter", fmt.Sprint(i)), "Set() failed")
+		requireNoError(t, rec.PushBack("list", fmt.Sprint(i)), "PushBack() failed")
+	}
+	before := aof.Size()
+
+	// Writes racing with the rewrite must survive it.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 100 {
+			_, _ = rec.SAdd("racing", fmt.Sprint(i))
+		}
+	}()
+
+	info, err := aof.Rewrite()
+	requireNoError(t, err, "Rewrite() failed: %v", err)
+	wg.Wait()
+	require(t, info.Size < before, "Rewrite() size = %d, want less than %d", info.Size, before)
+
+	requireNoError(t, rec.Set("after", "rewrite"), "Set() failed")
+	requireNoError(t, aof.Close(), "Close() failed")
+
+	requireSameContents(t, replayTestAOF(t, path).Dump(), c.Dump())
+
+	matches, err := filepath.Glob(path + ".tmp-*")
+	requireNoError(t, err, "Glob() failed: %v", err)
+	require(t, len(matches) == 0, "Rewrite() left temporary files %v", matches)
+}
+
+func TestAOF_AutoRewrite(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	c, rec, aof := openTestAOF(t, path, AOFOptions{Fsync: FsyncNo, RewritePercentage: 100, RewriteMinSize: 1024})
+
+	// Keep writing until a rewrite shrinks the file.
+	var rewritten bool
+	deadline := time.Now().Add(5 * time.Second)
+	for i := 0; !rewritten && time.Now().Before(deadline); i++ {
+		size := aof.Size()
+		requireNoError(t, rec.Set("key", fmt.Sprint(i)), "Set() failed")
+		rewritten = aof.Size() < size
+	}
+	require(t, rewritten, "the file was not rewritten after reaching %d bytes", aof.Size())
+
+	requireNoError(t, aof.Close(), "Close() failed")
+	requireSameContents(t, replayTestAOF(t, path).Dump(), c.Dump())
+}
+
+func TestParseFsyncPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    FsyncPolicy
+		wantErr error
+	}{
+		{in: "", want: FsyncEverySec},
+		{in: "always", want: FsyncAlways},
+		{in: "everysec", want: FsyncEverySec},
+		{in: "no", want: FsyncNo},
+		{in: "sometimes", wantErr: ErrInvalidFsyncPolicy},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFsyncPolicy(tt.in)
+		require(t, errors.Is(err, tt.wantErr), "ParseFsyncPolicy(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+		require(t, got == tt.want, "ParseFsyncPolicy(%q) = %q, want %q", tt.in, got, tt.want)
+	}
+}
@@ -0,0 +1,446 @@
+// Package persistence saves the contents of a cache to disk and loads them
+// back.
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// Common errors.
+var (
+	ErrInvalidSnapshot     = errors.New("invalid snapshot")
+	ErrUnsupportedVersion  = errors.New("unsupported snapshot version")
+	ErrChecksumMismatch    = fmt.Errorf("%w: checksum mismatch", ErrInvalidSnapshot)
+	errUnexpectedEndOfFile = fmt.Errorf("%w: unexpected end of file", ErrInvalidSnapshot)
+)
+
+// The snapshot format is:
+//
+//	header  "GREDIS" magic, uint16 format version (big endian)
+//	entry*  type opcode (1 byte)
+//	        expiration as signed varint Unix milliseconds, 0 for none
+//	        key as string
+//	        value, depending on the type:
+//	          string           string
+//	          list, set        uvarint count, count strings
+//	          hash             uvarint count, count field and value strings
+//	          sorted set       uvarint count, count member strings each
+//	                           followed by its score as a little endian
+//	                           IEEE 754 float64
+//	trailer opEOF (1 byte), little endian CRC-64 (ECMA) of all
+//	        preceding bytes
+//
+// Strings are encoded as a uvarint length followed by the bytes.
+const (
+	snapshotMagic   = "GREDIS"
+	snapshotVersion = 1
+)
+
+// Opcodes of the snapshot format. They are part of the file format and must
+// not be renumbered.
+const (
+	opString    byte = 0
+	opList      byte = 1
+	opHash      byte = 2
+	opSet       byte = 3
+	opSortedSet byte = 4
+	opEOF       byte = 0xFF
+)
+
+// maxStringLen bounds the length of a single string, so that a corrupt
+// length cannot make the reader allocate unbounded memory.
+const maxStringLen = 512 << 20
+
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
+// WriteSnapshot encodes entries to w in the snapshot format.
+func WriteSnapshot(w io.Writer, entries []cache.Entry) error {
+	crc := crc64.New(crcTable)
+	enc := &encoder{w: bufio.NewWriter(io.MultiWriter(w, crc))}
+
+	enc.bytes([]byte(snapshotMagic))
+	enc.bytes(binary.BigEndian.AppendUint16(nil, snapshotVersion))
+
+	for _, entry := range entries {
+		if err := enc.entry(entry); err != nil {
+			return err
+		}
+	}
+
+	_ = enc.w.WriteByte(opEOF)
+	if err := enc.w.Flush(); err != nil {
+		return err
+	}
+
+	_, err := w.Write(binary.LittleEndian.AppendUint64(nil, crc.Sum64()))
+	return err
+}
+
+// ReadSnapshot decodes a snapshot written by WriteSnapshot.
+func ReadSnapshot(r io.Reader) ([]cache.Entry, error) {
+	dec := &decoder{r: bufio.NewReader(r), crc: crc64.New(crcTable)}
+
+	magic := make([]byte, len(snapshotMagic))
+	if err := dec.readFull(magic); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, []byte(snapshotMagic)) {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidSnapshot)
+	}
+
+	version := make([]byte, 2)
+	if err := dec.readFull(version); err != nil {
+		return nil, err
+	}
+	if v := binary.BigEndian.Uint16(version); v != snapshotVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, v)
+	}
+
+	var entries []cache.Entry
+	for {
+		op, err := dec.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if op == opEOF {
+			break
+		}
+
+		entry, err := dec.entry(op)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	want := dec.crc.Sum64()
+	sum := make([]byte, 8)
+	if _, err := io.ReadFull(dec.r, sum); err != nil {
+		return nil, errUnexpectedEndOfFile
+	}
+	if binary.LittleEndian.Uint64(sum) != want {
+		return nil, ErrChecksumMismatch
+	}
+	if _, err := dec.r.ReadByte(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: trailing data after checksum", ErrInvalidSnapshot)
+	}
+
+	return entries, nil
+}
+
+// SaveSnapshot writes entries to the snapshot file at path. The file is
+// replaced atomically, so a crash never leaves a partially written
+// snapshot behind. It returns the size of the file.
+func SaveSnapshot(path string, entries []cache.Entry) (int64, error) {
+	var size int64
+	err := writeFileAtomic(path, func(f *os.File) error {
+		cw := &countingWriter{w: f}
+		if err := WriteSnapshot(cw, entries); err != nil {
+			return err
+		}
+		size = cw.n
+		return nil
+	})
+	return size, err
+}
+
+// LoadSnapshot reads the snapshot file at path. If the file does not exist,
+// the returned error satisfies errors.Is(err, fs.ErrNotExist).
+func LoadSnapshot(path string) ([]cache.Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := ReadSnapshot(f)
+	if err != nil {
+		return nil, fmt.Errorf("load snapshot %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// encoder writes the snapshot encoding of values. Write errors are sticky
+// in the underlying bufio.Writer and reported by its final Flush.
+type encoder struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (e *encoder) bytes(b []byte) {
+	_, _ = e.w.Write(b)
+}
+
+func (e *encoder) uvarint(n uint64) {
+	e.bytes(binary.AppendUvarint(e.buf[:0], n))
+}
+
+func (e *encoder) varint(n int64) {
+	e.bytes(binary.AppendVarint(e.buf[:0], n))
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	_, _ = e.w.WriteString(s)
+}
+
+func (e *encoder) entry(entry cache.Entry) error {
+	op, ok := opcodes[entry.Type]
+	if !ok {
+		return fmt.Errorf("%w: key %q has unknown type %d", cache.ErrInvalidEntry, entry.Key, entry.Type)
+	}
+	_ = e.w.WriteByte(op)
+
+	var expireAt int64
+	if !entry.ExpireAt.IsZero() {
+		expireAt = entry.ExpireAt.UnixMilli()
+	}
+	e.varint(expireAt)
+	e.string(entry.Key)
+
+	switch op {
+	case opString:
+		value, ok := entry.Value.(string)
+		if !ok {
+			return invalidValue(entry)
+		}
+		e.string(value)
+	case opList, opSet:
+		values, ok := entry.Value.([]string)
+		if !ok {
+			return invalidValue(entry)
+		}
+		e.uvarint(uint64(len(values)))
+		for _, value := range values {
+			e.string(value)
+		}
+	case opHash:
+		hash, ok := entry.Value.(map[string]string)
+		if !ok {
+			return invalidValue(entry)
+		}
+		e.uvarint(uint64(len(hash)))
+		for _, field := range slices.Sorted(maps.Keys(hash)) {
+			e.string(field)
+			e.string(hash[field])
+		}
+	case opSortedSet:
+		members, ok := entry.Value.([]cache.Z)
+		if !ok {
+			return invalidValue(entry)
+		}
+		e.uvarint(uint64(len(members)))
+		for _, z := range members {
+			e.string(z.Member)
+			e.bytes(binary.LittleEndian.AppendUint64(e.buf[:0], math.Float64bits(z.Score)))
+		}
+	}
+
+	return nil
+}
+
+func invalidValue(entry cache.Entry) error {
+	return fmt.Errorf("%w: key %q holds %T, not a %s value", cache.ErrInvalidEntry, entry.Key, entry.Value, entry.Type)
+}
+
+var opcodes = map[cache.DataType]byte{
+	cache.StringType:    opString,
+	cache.ListType:      opList,
+	cache.HashType:      opHash,
+	cache.SetType:       opSet,
+	cache.SortedSetType: opSortedSet,
+}
+
+// decoder reads the snapshot encoding of values and keeps a running
+// checksum of every byte it consumes.
+type decoder struct {
+	r   *bufio.Reader
+	crc hash.Hash64
+}
+
+// ReadByte implements io.ByteReader for binary.ReadUvarint.
+func (d *decoder) ReadByte() (byte, error) {
+	return d.readByte()
+}
+
+func (d *decoder) readByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, errUnexpectedEndOfFile
+	}
+	_, _ = d.crc.Write([]byte{b})
+	return b, nil
+}
+
+func (d *decoder) readFull(p []byte) error {
+	if _, err := io.ReadFull(d.r, p); err != nil {
+		return errUnexpectedEndOfFile
+	}
+	_, _ = d.crc.Write(p)
+	return nil
+}
+
+func (d *decoder) uvarint() (uint64, error) {
+	n, err := binary.ReadUvarint(d)
+	if err != nil {
+		return 0, varintError(err)
+	}
+	return n, nil
+}
+
+func (d *decoder) varint() (int64, error) {
+	n, err := binary.ReadVarint(d)
+	if err != nil {
+		return 0, varintError(err)
+	}
+	return n, nil
+}
+
+// varintError reports a failure to decode a varint: either the end of the
+// file or an overflow.
+func varintError(err error) error {
+	if errors.Is(err, ErrInvalidSnapshot) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+}
+
+func (d *decoder) string() (string, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return "", err
+	}
+	if n > maxStringLen {
+		return "", fmt.Errorf("%w: string of %d bytes is too long", ErrInvalidSnapshot, n)
+	}
+
+	b := make([]byte, n)
+	if err = d.readFull(b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (d *decoder) strings() ([]string, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, min(n, 1024))
+	for range n {
+		s, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+func (d *decoder) entry(op byte) (cache.Entry, error) {
+	var entry cache.Entry
+
+	expireAt, err := d.varint()
+	if err != nil {
+		return entry, err
+	}
+	if expireAt != 0 {
+		entry.ExpireAt = time.UnixMilli(expireAt)
+	}
+
+	if entry.Key, err = d.string(); err != nil {
+		return entry, err
+	}
+
+	switch op {
+	case opString:
+		entry.Type = cache.StringType
+		entry.Value, err = d.string()
+	case opList:
+		entry.Type = cache.ListType
+		entry.Value, err = d.strings()
+	case opSet:
+		entry.Type = cache.SetType
+		entry.Value, err = d.strings()
+	case opHash:
+		entry.Type = cache.HashType
+		entry.Value, err = d.hash()
+	case opSortedSet:
+		entry.Type = cache.SortedSetType
+		entry.Value, err = d.sortedSet()
+	default:
+		return entry, fmt.Errorf("%w: unknown opcode 0x%02x", ErrInvalidSnapshot, op)
+	}
+
+	return entry, err
+}
+
+func (d *decoder) hash() (map[string]string, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := make(map[string]string, min(n, 1024))
+	for range n {
+		field, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		if hash[field], err = d.string(); err != nil {
+			return nil, err
+		}
+	}
+	return hash, nil
+}
+
+func (d *decoder) sortedSet() ([]cache.Z, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]cache.Z, 0, min(n, 1024))
+	score := make([]byte, 8)
+	for range n {
+		member, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		if err = d.readFull(score); err != nil {
+			return nil, err
+		}
+		members = append(members, cache.Z{
+			Member: member,
+			Score:  math.Float64frombits(binary.LittleEndian.Uint64(score)),
+		})
+	}
+	return members, nil
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
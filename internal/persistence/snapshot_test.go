@@ -0,0 +1,196 @@
+package persistence
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+func testEntries() []cache.Entry {
+	return []cache.Entry{
+		{Key: "hash", Type: cache.HashType, Value: map[string]string{"b": "2", "a": "1"}},
+		{Key: "list", Type: cache.ListType, Value: []string{"x", "", "y"}},
+		{Key: "set", Type: cache.SetType, Value: []string{"m", "n"}},
+		{Key: "string", Type: cache.StringType, Value: "binary\x00\r\nvalue", ExpireAt: time.UnixMilli(4102444800000)},
+		{Key: "zset", Type: cache.SortedSetType, Value: []cache.Z{{Member: "low", Score: -1.5}, {Member: "high", Score: 1e300}}},
+	}
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	requireNoError(t, WriteSnapshot(&buf, testEntries()), "WriteSnapshot() failed")
+
+	got, err := ReadSnapshot(bytes.NewReader(buf.Bytes()))
+	requireNoError(t, err, "ReadSnapshot() failed: %v", err)
+	require(t, reflect.DeepEqual(got, testEntries()), "ReadSnapshot() = %v, want %v", got, testEntries())
+
+	// Encoding is deterministic, hash fields included.
+	var again bytes.Buffer
+	requireNoError(t, WriteSnapshot(&again, got), "WriteSnapshot() failed")
+	require(t, bytes.Equal(buf.Bytes(), again.Bytes()), "WriteSnapshot() is not deterministic")
+
+	var empty bytes.Buffer
+	requireNoError(t, WriteSnapshot(&empty, nil), "WriteSnapshot() failed")
+	got, err = ReadSnapshot(&empty)
+	requireNoError(t, err, "ReadSnapshot() failed: %v", err)
+	require(t, len(got) == 0, "ReadSnapshot() = %v, want no entries", got)
+}
+
+func TestSnapshot_Corrupt(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	requireNoError(t, WriteSnapshot(&buf, testEntries()), "WriteSnapshot() failed")
+	valid := buf.Bytes()
+
+	tests := []struct {
+		name    string
+		data    func() []byte
+		wantErr error
+	}{
+		{
+			name:    "Empty file",
+			data:    func() []byte { return nil },
+			wantErr: ErrInvalidSnapshot,
+		},
+		{
+			name: "Bad magic",
+			data: func() []byte {
+				return append([]byte("REDIS0"), valid[6:]...)
+			},
+			wantErr: ErrInvalidSnapshot,
+		},
+		{
+			name: "Unsupported version",
+			data: func() []byte {
+				data := bytes.Clone(valid)
+				data[7] = 99
+				return data
+			},
+			wantErr: ErrUnsupportedVersion,
+		},
+		{
+			name: "Flipped bit",
+			data: func() []byte {
+				data := bytes.Clone(valid)
+				data[len(data)/2] ^= 0x01
+				return data
+			},
+			wantErr: ErrInvalidSnapshot,
+		},
+		{
+			name: "Truncated",
+			data: func() []byte {
+				return valid[:len(valid)-3]
+			},
+			wantErr: ErrInvalidSnapshot,
+		},
+		{
+			name: "Trailing data",
+			data: func() []byte {
+				return append(bytes.Clone(valid), 0)
+			},
+			wantErr: ErrInvalidSnapshot,
+		},
+		{
+			name: "Bad checksum",
+			data: func() []byte {
+				data := bytes.Clone(valid)
+				data[len(data)-1] ^= 0xFF
+				return data
+			},
+			wantErr: ErrChecksumMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadSnapshot(bytes.NewReader(tt.data()))
+			require(t, errors.Is(err, tt.wantErr), "ReadSnapshot() error = %v, want %v", err, tt.wantErr)
+		})
+	}
+}
+
+func TestSnapshotter(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data", "dump.gredis")
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	src := cache.NewMemoryCache(0)
+	requireNoError(t, src.Restore(testEntries()), "Restore() failed")
+
+	// Loading a missing snapshot leaves the cache empty.
+	dst := cache.NewMemoryCache(0)
+	n, err := NewSnapshotter(dst, path, 0, logger).Load()
+	requireNoError(t, err, "Load() failed: %v", err)
+	require(t, n == 0, "Load() = %d, want 0", n)
+
+	snapshotter := NewSnapshotter(src, path, 0, logger)
+	info, err := snapshotter.Save()
+	requireNoError(t, err, "Save() failed: %v", err)
+	require(t, info.Keys == 5 && info.Path == path, "Save() = %+v", info)
+	require(t, snapshotter.LastSave() == info, "LastSave() = %+v, want %+v", snapshotter.LastSave(), info)
+
+	stat, err := os.Stat(path)
+	requireNoError(t, err, "Stat() failed: %v", err)
+	require(t, stat.Size() == info.Size, "file size = %d, want %d", stat.Size(), info.Size)
+
+	// No temporary files are left behind.
+	files, err := os.ReadDir(filepath.Dir(path))
+	requireNoError(t, err, "ReadDir() failed: %v", err)
+	require(t, len(files) == 1, "snapshot directory holds %d files, want 1", len(files))
+
+	n, err = NewSnapshotter(dst, path, 0, logger).Load()
+	requireNoError(t, err, "Load() failed: %v", err)
+	require(t, n == 5, "Load() = %d, want 5", n)
+	require(t, reflect.DeepEqual(dst.Dump(), src.Dump()), "Load() did not restore the cache")
+
+	requireNoError(t, os.WriteFile(path, []byte("garbage"), 0o644), "WriteFile() failed")
+	_, err = NewSnapshotter(dst, path, 0, logger).Load()
+	require(t, errors.Is(err, ErrInvalidSnapshot), "Load() error = %v, want %v", err, ErrInvalidSnapshot)
+}
+
+func TestSnapshotter_Periodic(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "dump.gredis")
+	c := cache.NewMemoryCache(0)
+	requireNoError(t, c.Set("key", "value"), "Set() failed")
+
+	snapshotter := NewSnapshotter(c, path, 10*time.Millisecond, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	snapshotter.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for snapshotter.LastSave().SavedAt.IsZero() {
+		require(t, time.Now().Before(deadline), "no periodic snapshot was saved")
+		time.Sleep(5 * time.Millisecond)
+	}
+	snapshotter.Stop()
+
+	entries, err := LoadSnapshot(path)
+	requireNoError(t, err, "LoadSnapshot() failed: %v", err)
+	require(t, len(entries) == 1 && entries[0].Key == "key", "LoadSnapshot() = %v", entries)
+}
+
+func requireNoError(t *testing.T, err error, format string, args ...any) {
+	t.Helper()
+	require(t, errors.Is(err, nil), format, args...)
+}
+
+func require(t *testing.T, condition bool, format string, args ...any) {
+	t.Helper()
+	if !condition {
+		t.Fatalf(format, args...)
+	}
+}
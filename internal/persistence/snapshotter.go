@@ -0,0 +1,141 @@
+package persistence
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+// SnapshotInfo describes a saved snapshot.
+type SnapshotInfo struct {
+	Path     string
+	Keys     int
+	Size     int64
+	SavedAt  time.Time
+	Duration time.Duration
+}
+
+// Snapshotter saves the contents of a cache to a snapshot file, on demand
+// and periodically, and restores them at startup.
+type Snapshotter struct {
+	cache    cache.Dumper
+	path     string
+	interval time.Duration
+	logger   *slog.Logger
+
+	// mu serializes saves.
+	mu   sync.Mutex
+	last SnapshotInfo
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewSnapshotter creates a snapshotter for the snapshot file at path. If
+// interval is positive, Start saves a snapshot every interval.
+func NewSnapshotter(c cache.Dumper, path string, interval time.Duration, logger *slog.Logger) *Snapshotter {
+	return &Snapshotter{
+		cache:    c,
+		path:     path,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Load restores the cache from the snapshot file and returns the number of
+// entries read. A missing file is not an error: the cache is left as is.
+func (s *Snapshotter) Load() (int, error) {
+	entries, err := LoadSnapshot(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err = s.cache.Restore(entries); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// Save writes a snapshot of the cache to the snapshot file.
+func (s *Snapshotter) Save() (SnapshotInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := time.Now()
+	entries := s.cache.Dump()
+
+	size, err := SaveSnapshot(s.path, entries)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	s.last = SnapshotInfo{
+		Path:     s.path,
+		Keys:     len(entries),
+		Size:     size,
+		SavedAt:  time.Now(),
+		Duration: time.Since(start),
+	}
+	return s.last, nil
+}
+
+// LastSave returns information about the last successful save, or the
+// zero SnapshotInfo if there was none.
+func (s *Snapshotter) LastSave() SnapshotInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.last
+}
+
+// Start begins saving a snapshot every interval. It does nothing if the
+// interval is not positive.
+func (s *Snapshotter) Start() {
+	if s.interval <= 0 {
+		return
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run()
+}
+
+// Stop stops the periodic saves started by Start. It does not save a final
+// snapshot; call Save for that.
+func (s *Snapshotter) Stop() {
+	if s.stop == nil {
+		return
+	}
+
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+}
+
+func (s *Snapshotter) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			info, err := s.Save()
+			if err != nil {
+				s.logger.Error("Failed to save snapshot", "path", s.path, "error", err)
+				continue
+			}
+			s.logger.Debug("Snapshot saved", "path", info.Path, "keys", info.Keys, "size", info.Size, "duration", info.Duration.String())
+		case <-s.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,298 @@
+// Package pubsub implements a publish/subscribe message broker with Redis
+// semantics: subscribers listen on exact channel names or glob patterns and
+// PUBLISH reports how many of them received the message.
+package pubsub
+
+import (
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/dsha256/gredis/internal/glob"
+)
+
+// Common errors.
+var (
+	ErrClosed         = errors.New("subscription closed")
+	ErrSlowSubscriber = errors.New("subscription closed: subscriber is not keeping up with published messages")
+)
+
+// bufferSize is the number of messages a subscription buffers before it is
+// considered too slow and closed, like a Redis client that exceeds its
+// output buffer limit.
+const bufferSize = 1024
+
+// Message is a message delivered to a subscription.
+type Message struct {
+	// Channel is the channel the message was published to.
+	Channel string `json:"channel"`
+	// Pattern is the pattern that matched Channel, or empty if the message
+	// was delivered through an exact channel subscription.
+	Pattern string `json:"pattern,omitempty"`
+	// Payload is the published message.
+	Payload string `json:"payload"`
+}
+
+// Broker routes published messages to subscriptions. The zero value is not
+// usable; create brokers with NewBroker.
+type Broker struct {
+	mu       sync.RWMutex
+	channels map[string]map[*Subscription]struct{}
+	patterns map[string]map[*Subscription]struct{}
+}
+
+// NewBroker creates an empty broker.
+func NewBroker() *Broker {
+	return &Broker{
+		channels: make(map[string]map[*Subscription]struct{}),
+		patterns: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Publish sends payload to every subscription of channel and to every
+// pattern subscription matching it. It returns the number of deliveries; a
+// subscription matching both ways receives, and counts, the message twice.
+func (b *Broker) Publish(channel, payload string) int {
+	var receivers int
+	var slow []*Subscription
+
+	b.mu.RLock()
+	for sub := range b.channels[channel] {
+		if sub.deliver(Message{Channel: channel, Payload: payload}) {
+			receivers++
+		} else {
+			slow = append(slow, sub)
+		}
+	}
+	for pattern, subs := range b.patterns {
+		if !glob.Match(pattern, channel) {
+			continue
+		}
+		for sub := range subs {
+			if sub.deliver(Message{Channel: channel, Pattern: pattern, Payload: payload}) {
+				receivers++
+			} else {
+				slow = append(slow, sub)
+			}
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range slow {
+		sub.close(ErrSlowSubscriber)
+	}
+
+	return receivers
+}
+
+// Subscribe creates a subscription to the given channels.
+func (b *Broker) Subscribe(channels ...string) *Subscription {
+	sub := b.newSubscription()
+	_ = sub.Subscribe(channels...)
+	return sub
+}
+
+// PSubscribe creates a subscription to the channels matching the given glob
+// patterns.
+func (b *Broker) PSubscribe(patterns ...string) *Subscription {
+	sub := b.newSubscription()
+	_ = sub.PSubscribe(patterns...)
+	return sub
+}
+
+func (b *Broker) newSubscription() *Subscription {
+	return &Subscription{
+		broker:   b,
+		ch:       make(chan Message, bufferSize),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+}
+
+// add registers sub under name in index. The caller must hold b.mu.
+func add(index map[string]map[*Subscription]struct{}, name string, sub *Subscription) {
+	subs, ok := index[name]
+	if !ok {
+		subs = make(map[*Subscription]struct{})
+		index[name] = subs
+	}
+	subs[sub] = struct{}{}
+}
+
+// remove unregisters sub from name in index. The caller must hold b.mu.
+func remove(index map[string]map[*Subscription]struct{}, name string, sub *Subscription) {
+	subs := index[name]
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(index, name)
+	}
+}
+
+// Subscription receives the messages published to its channels and
+// patterns. The set of channels and patterns may change over its lifetime.
+// A subscription must be closed once it is no longer used.
+type Subscription struct {
+	broker *Broker
+
+	// mu guards the fields below. When both are needed, broker.mu is
+	// acquired first.
+	mu       sync.Mutex
+	ch       chan Message
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	closed   bool
+	err      error
+}
+
+// C returns the channel on which messages are delivered. It is closed when
+// the subscription is closed.
+func (s *Subscription) C() <-chan Message {
+	return s.ch
+}
+
+// Subscribe adds channels to the subscription.
+func (s *Subscription) Subscribe(channels ...string) error {
+	return s.update(s.broker.channels, s.channels, channels, true)
+}
+
+// PSubscribe adds glob patterns to the subscription.
+func (s *Subscription) PSubscribe(patterns ...string) error {
+	return s.update(s.broker.patterns, s.patterns, patterns, true)
+}
+
+// Unsubscribe removes channels from the subscription, or all of them if
+// none are given.
+func (s *Subscription) Unsubscribe(channels ...string) error {
+	return s.update(s.broker.channels, s.channels, channels, false)
+}
+
+// PUnsubscribe removes patterns from the subscription, or all of them if
+// none are given.
+func (s *Subscription) PUnsubscribe(patterns ...string) error {
+	return s.update(s.broker.patterns, s.patterns, patterns, false)
+}
+
+// update adds names to, or removes them from, current (the channel or
+// pattern set of the subscription) and the matching broker index. Removing
+// no names removes every name in current.
+func (s *Subscription) update(index map[string]map[*Subscription]struct{}, current map[string]struct{}, names []string, subscribe bool) error {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	if !subscribe && len(names) == 0 {
+		for name := range current {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		if subscribe {
+			current[name] = struct{}{}
+			add(index, name, s)
+		} else if _, ok := current[name]; ok {
+			delete(current, name)
+			remove(index, name, s)
+		}
+	}
+
+	return nil
+}
+
+// Channels returns the sorted channels of the subscription.
+func (s *Subscription) Channels() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortedKeys(s.channels)
+}
+
+// Patterns returns the sorted patterns of the subscription.
+func (s *Subscription) Patterns() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortedKeys(s.patterns)
+}
+
+// Count returns the number of channels and patterns of the subscription.
+func (s *Subscription) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.channels) + len(s.patterns)
+}
+
+// Err returns the reason the subscription was closed by the broker, or nil
+// if it is open or was closed by its owner.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// Close unsubscribes from every channel and pattern and closes C. It is
+// safe to call Close more than once.
+func (s *Subscription) Close() error {
+	s.close(nil)
+	return nil
+}
+
+// close removes the subscription from the broker and closes C, recording
+// err as the reason.
+func (s *Subscription) close(err error) {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	for name := range s.channels {
+		remove(s.broker.channels, name, s)
+	}
+	for name := range s.patterns {
+		remove(s.broker.patterns, name, s)
+	}
+	clear(s.channels)
+	clear(s.patterns)
+
+	s.closed = true
+	s.err = err
+	close(s.ch)
+}
+
+// deliver queues msg without blocking. It reports false if the buffer is
+// full, in which case the subscription must be closed.
+func (s *Subscription) deliver(msg Message) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	select {
+	case s.ch <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,175 @@
+package pubsub
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBroker_Publish(t *testing.T) {
+	t.Parallel()
+	broker := NewBroker()
+
+	news := broker.Subscribe("news", "sport")
+	defer news.Close()
+	all := broker.PSubscribe("news.*", "*")
+	defer all.Close()
+
+	tests := []struct {
+		channel       string
+		wantReceivers int
+		wantNews      []Message
+		wantAll       []Message
+	}{
+		{
+			channel:       "news",
+			wantReceivers: 2,
+			wantNews:      []Message{{Channel: "news", Payload: "news"}},
+			wantAll:       []Message{{Channel: "news", Pattern: "*", Payload: "news"}},
+		},
+		{
+			channel:       "news.tech",
+			wantReceivers: 2,
+			wantAll: []Message{
+				{Channel: "news.tech", Pattern: "*", Payload: "news.tech"},
+				{Channel: "news.tech", Pattern: "news.*", Payload: "news.tech"},
+			},
+		},
+		{
+			channel:       "sport",
+			wantReceivers: 2,
+			wantNews:      []Message{{Channel: "sport", Payload: "sport"}},
+			wantAll:       []Message{{Channel: "sport", Pattern: "*", Payload: "sport"}},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := broker.Publish(tt.channel, tt.channel); got != tt.wantReceivers {
+			t.Fatalf("Publish(%q) = %d, want %d", tt.channel, got, tt.wantReceivers)
+		}
+		if got := receive(t, news, len(tt.wantNews)); !sameMessages(got, tt.wantNews) {
+			t.Errorf("Publish(%q): channel subscriber got %v, want %v", tt.channel, got, tt.wantNews)
+		}
+		if got := receive(t, all, len(tt.wantAll)); !sameMessages(got, tt.wantAll) {
+			t.Errorf("Publish(%q): pattern subscriber got %v, want %v", tt.channel, got, tt.wantAll)
+		}
+	}
+}
+
+func TestSubscription_Update(t *testing.T) {
+	t.Parallel()
+	broker := NewBroker()
+
+	sub := broker.Subscribe("a", "b")
+	if err := sub.PSubscribe("c*"); err != nil {
+		t.Fatalf("PSubscribe() error = %v", err)
+	}
+	if got := sub.Count(); got != 3 {
+		t.Fatalf("Count() = %d, want 3", got)
+	}
+
+	if err := sub.Unsubscribe("a", "missing"); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if got := sub.Channels(); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("Channels() = %v, want [b]", got)
+	}
+	if got := broker.Publish("a", "x"); got != 0 {
+		t.Errorf("Publish(a) after Unsubscribe = %d, want 0", got)
+	}
+
+	if err := sub.PUnsubscribe(); err != nil {
+		t.Fatalf("PUnsubscribe() error = %v", err)
+	}
+	if got := sub.Patterns(); len(got) != 0 {
+		t.Errorf("Patterns() = %v, want none", got)
+	}
+
+	if err := sub.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, ok := <-sub.C(); ok {
+		t.Error("C() is not closed after Close()")
+	}
+	if err := sub.Subscribe("a"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Subscribe() after Close() error = %v, want %v", err, ErrClosed)
+	}
+	if got := broker.Publish("b", "x"); got != 0 {
+		t.Errorf("Publish(b) after Close = %d, want 0", got)
+	}
+	if err := sub.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+}
+
+func TestSubscription_SlowSubscriber(t *testing.T) {
+	t.Parallel()
+	broker := NewBroker()
+
+	sub := broker.Subscribe("flood")
+	for range bufferSize {
+		broker.Publish("flood", "x")
+	}
+	if got := broker.Publish("flood", "x"); got != 0 {
+		t.Errorf("Publish() to a full subscription = %d, want 0", got)
+	}
+	if err := sub.Err(); !errors.Is(err, ErrSlowSubscriber) {
+		t.Errorf("Err() = %v, want %v", err, ErrSlowSubscriber)
+	}
+
+	// Buffered messages are still delivered before C is closed.
+	received := 0
+	for range sub.C() {
+		received++
+	}
+	if received != bufferSize {
+		t.Errorf("received %d messages, want %d", received, bufferSize)
+	}
+}
+
+// receive reads n messages from sub.
+func receive(t *testing.T, sub *Subscription, n int) []Message {
+	t.Helper()
+
+	var msgs []Message
+	for range n {
+		select {
+		case msg := <-sub.C():
+			msgs = append(msgs, msg)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for message %d of %d", len(msgs)+1, n)
+		}
+	}
+
+	select {
+	case msg := <-sub.C():
+		t.Fatalf("unexpected message %v", msg)
+	default:
+	}
+
+	return msgs
+}
+
+// sameMessages reports whether got and want hold the same messages in any
+// order, since pattern subscriptions are not delivered in a defined order.
+func sameMessages(got, want []Message) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	remaining := append([]Message(nil), want...)
+	for _, msg := range got {
+		found := false
+		for i, w := range remaining {
+			if w == msg {
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
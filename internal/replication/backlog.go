@@ -0,0 +1,62 @@
+package replication
+
+// backlog keeps the most recent bytes of the replication stream in a ring
+// buffer, so that a follower that briefly lost its connection can resume
+// from its offset instead of doing a full sync.
+type backlog struct {
+	buf []byte
+	// start and end are the replication offsets of the first byte held and
+	// of the byte after the last one.
+	start int64
+	end   int64
+}
+
+func newBacklog(size int, offset int64) *backlog {
+	return &backlog{
+		buf:   make([]byte, size),
+		start: offset,
+		end:   offset,
+	}
+}
+
+// write appends p to the backlog, discarding the oldest bytes if needed.
+func (b *backlog) write(p []byte) {
+	size := int64(len(b.buf))
+	if size == 0 {
+		b.start += int64(len(p))
+		b.end = b.start
+		return
+	}
+
+	// Only the last size bytes of p can be kept.
+	if n := int64(len(p)); n > size {
+		b.end += n - size
+		p = p[n-size:]
+	}
+
+	for len(p) > 0 {
+		pos := b.end % size
+		n := copy(b.buf[pos:], p)
+		p = p[n:]
+		b.end += int64(n)
+	}
+	b.start = max(b.start, b.end-size)
+}
+
+// since returns a copy of the bytes from offset to the end of the stream. It
+// reports false if offset is not held by the backlog.
+func (b *backlog) since(offset int64) ([]byte, bool) {
+	if offset < b.start || offset > b.end {
+		return nil, false
+	}
+
+	size := int64(len(b.buf))
+	out := make([]byte, 0, b.end-offset)
+	for offset < b.end {
+		pos := offset % size
+		chunk := b.buf[pos:min(size, pos+b.end-offset)]
+		out = append(out, chunk...)
+		offset += int64(len(chunk))
+	}
+	return out, true
+}
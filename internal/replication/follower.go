@@ -0,0 +1,325 @@
+package replication
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+	"github.com/dsha256/gredis/internal/command"
+	"github.com/dsha256/gredis/internal/persistence"
+	"github.com/dsha256/gredis/internal/resp"
+)
+
+// Follower timings.
+const (
+	dialTimeout   = 5 * time.Second
+	retryInterval = time.Second
+	ackInterval   = time.Second
+)
+
+// ErrUnexpectedReply is returned when the leader answers PSYNC with
+// something other than FULLRESYNC or CONTINUE.
+var ErrUnexpectedReply = errors.New("unexpected reply from leader")
+
+// FollowerStatus describes the replication state of a follower.
+type FollowerStatus struct {
+	LeaderAddr string
+	// ReplID and Offset identify the position of the follower in the
+	// stream of the leader; ReplID is empty before the first sync.
+	ReplID    string
+	Offset    int64
+	Connected bool
+}
+
+// Follower keeps a cache in sync with a leader. It connects to the RESP
+// server of the leader, loads a full snapshot and then applies the stream
+// of writes. After a disconnect it reconnects and resumes from its offset
+// if the leader still has it in its backlog, or does a full sync again.
+type Follower struct {
+	leaderAddr string
+	store      cache.Store
+	logger     *slog.Logger
+
+	// mu guards the fields below.
+	mu        sync.Mutex
+	replID    string
+	offset    int64
+	conn      net.Conn
+	connected bool
+	stopped   bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewFollower creates a follower applying the stream of the leader at
+// leaderAddr to store.
+func NewFollower(leaderAddr string, store cache.Store, logger *slog.Logger) *Follower {
+	return &Follower{
+		leaderAddr: leaderAddr,
+		store:      store,
+		logger:     logger,
+	}
+}
+
+// Status returns the replication state of the follower.
+func (f *Follower) Status() FollowerStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return FollowerStatus{
+		LeaderAddr: f.leaderAddr,
+		ReplID:     f.replID,
+		Offset:     f.offset,
+		Connected:  f.connected,
+	}
+}
+
+// Start begins replicating in the background until Stop is called.
+func (f *Follower) Start() {
+	f.stop = make(chan struct{})
+	f.done = make(chan struct{})
+	go f.run()
+}
+
+// Stop disconnects from the leader and stops replicating. The cache keeps
+// the data replicated so far.
+func (f *Follower) Stop() {
+	if f.stop == nil {
+		return
+	}
+
+	f.mu.Lock()
+	f.stopped = true
+	if f.conn != nil {
+		_ = f.conn.Close()
+	}
+	f.mu.Unlock()
+
+	close(f.stop)
+	<-f.done
+	f.stop = nil
+}
+
+func (f *Follower) run() {
+	defer close(f.done)
+
+	for {
+		err := f.sync()
+
+		select {
+		case <-f.stop:
+			return
+		default:
+		}
+		f.logger.Warn("Replication link lost, reconnecting", "leader_addr", f.leaderAddr, "error", err)
+
+		select {
+		case <-time.After(retryInterval):
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// sync runs a single connection to the leader: it synchronizes with it and
+// applies its stream until the connection fails.
+func (f *Follower) sync() error {
+	conn, err := net.DialTimeout("tcp", f.leaderAddr, dialTimeout)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	if f.stopped {
+		f.mu.Unlock()
+		return conn.Close()
+	}
+	f.conn = conn
+	replID, offset := f.replID, f.offset
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.conn = nil
+		f.connected = false
+		f.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	cr := &countingReader{r: conn}
+	rd := resp.NewReader(cr)
+	wr := resp.NewWriter(conn)
+
+	if replID == "" {
+		err = wr.WriteCommand("PSYNC", "?", "-1")
+	} else {
+		err = wr.WriteCommand("PSYNC", replID, strconv.FormatInt(offset, 10))
+	}
+	if err == nil {
+		err = wr.Flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = f.handshake(rd); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go f.ack(wr, done)
+
+	var (
+		// tx holds the commands of an open transaction and pending the
+		// bytes consumed since the offset was last advanced. The offset
+		// only moves past a transaction once it has been applied, so that
+		// a follower resuming after a disconnect receives it again whole.
+		tx      []command.Command
+		pending int64
+	)
+	for {
+		start := cr.n - int64(rd.Buffered())
+		args, err := rd.ReadCommand()
+		if err != nil {
+			return err
+		}
+		pending += cr.n - int64(rd.Buffered()) - start
+
+		cmd := command.New(args[0], args[1:]...)
+		switch {
+		case cmd.Name == command.Multi && tx == nil:
+			tx = []command.Command{}
+		case cmd.Name == command.Exec && tx != nil:
+			f.applyTx(tx)
+			tx = nil
+		case tx != nil:
+			tx = append(tx, cmd)
+		default:
+			if _, err = command.Execute(f.store, cmd); err != nil {
+				f.logger.Warn("Failed to apply replicated command", "command", args[0], "error", err)
+			}
+		}
+
+		if tx == nil {
+			f.mu.Lock()
+			f.offset += pending
+			f.mu.Unlock()
+			pending = 0
+		}
+	}
+}
+
+// applyTx applies the commands of a replicated transaction atomically.
+func (f *Follower) applyTx(cmds []command.Command) {
+	results, err := command.ExecTx(f.store, cmds)
+	if err != nil {
+		f.logger.Warn("Failed to apply replicated transaction", "error", err)
+		return
+	}
+	for i, result := range results {
+		if result.Err != nil {
+			f.logger.Warn("Failed to apply replicated command", "command", cmds[i].Name, "error", result.Err)
+		}
+	}
+}
+
+// handshake reads the reply of the leader to PSYNC and, for a full sync,
+// loads the snapshot that follows it.
+func (f *Follower) handshake(rd *resp.Reader) error {
+	reply, err := rd.ReadValue()
+	if err != nil {
+		return err
+	}
+	if e, ok := reply.(resp.Error); ok {
+		return fmt.Errorf("leader refused to sync: %w", e)
+	}
+	status, _ := reply.(string)
+	fields := strings.Fields(status)
+
+	switch {
+	case len(fields) == 3 && fields[0] == "FULLRESYNC":
+		offset, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %q", ErrUnexpectedReply, status)
+		}
+
+		start := time.Now()
+		var snapshot bytes.Buffer
+		if _, err = rd.ReadBulkBytes(&snapshot); err != nil {
+			return err
+		}
+		entries, err := persistence.ReadSnapshot(&snapshot)
+		if err != nil {
+			return err
+		}
+		if err = f.store.Clear(); err != nil {
+			return err
+		}
+		if err = f.store.Restore(entries); err != nil {
+			return err
+		}
+
+		f.mu.Lock()
+		f.replID, f.offset, f.connected = fields[1], offset, true
+		f.mu.Unlock()
+		f.logger.Info("Full sync with leader completed", "leader_addr", f.leaderAddr, "keys", len(entries), "offset", offset, "duration", time.Since(start).String())
+	case len(fields) == 2 && fields[0] == "CONTINUE":
+		f.mu.Lock()
+		f.replID, f.connected = fields[1], true
+		offset := f.offset
+		f.mu.Unlock()
+		f.logger.Info("Resumed replication from leader", "leader_addr", f.leaderAddr, "offset", offset)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnexpectedReply, status)
+	}
+
+	return nil
+}
+
+// ack reports the offset of the follower to the leader every ackInterval
+// until done is closed.
+func (f *Follower) ack(wr *resp.Writer, done <-chan struct{}) {
+	ticker := time.NewTicker(ackInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			f.mu.Lock()
+			offset := f.offset
+			f.mu.Unlock()
+
+			err := wr.WriteCommand("REPLCONF", "ACK", strconv.FormatInt(offset, 10))
+			if err == nil {
+				err = wr.Flush()
+			}
+			if err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
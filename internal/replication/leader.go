@@ -0,0 +1,330 @@
+// Package replication keeps follower instances in sync with a leader over
+// the RESP protocol.
+//
+// A follower sends PSYNC with the replication ID and offset it last saw.
+// The leader either resumes it from its backlog or sends a full snapshot,
+// then streams every write as a RESP command. The offset counts the bytes
+// of that stream.
+package replication
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dsha256/gredis/internal/cache"
+	"github.com/dsha256/gredis/internal/command"
+	"github.com/dsha256/gredis/internal/persistence"
+	"github.com/dsha256/gredis/internal/resp"
+)
+
+// DefaultBacklogSize is the backlog size used when none is configured.
+const DefaultBacklogSize = 1 << 20
+
+var (
+	errFollowerClosed = errors.New("follower disconnected")
+	errFollowerBehind = errors.New("follower fell too far behind")
+)
+
+// followerBufferLimit bounds the bytes queued for a single follower. A
+// follower that falls further behind is disconnected and has to resync.
+const followerBufferLimit = 64 << 20
+
+// Source provides the contents a follower is fully synced from. Exclusive
+// must hold off writes to the cache while fn runs, so that a dump taken by
+// fn is consistent with the commands streamed after it. command.Recorder
+// implements it.
+type Source interface {
+	Dump() []cache.Entry
+	Exclusive(fn func())
+}
+
+// FollowerInfo describes a follower connected to a leader.
+type FollowerInfo struct {
+	Addr string
+	// AckOffset is the last replication offset the follower acknowledged.
+	AckOffset int64
+}
+
+// Leader streams the writes applied to a cache to its followers. It
+// implements command.Sink, so that a command.Recorder can feed it every
+// write, and resp.Replicator, so that followers can connect to it through
+// the RESP server.
+type Leader struct {
+	source Source
+	logger *slog.Logger
+	replID string
+
+	// mu guards the fields below.
+	mu        sync.Mutex
+	enc       *resp.Writer
+	buf       bytes.Buffer
+	offset    int64
+	backlog   *backlog
+	followers map[*followerConn]struct{}
+}
+
+// NewLeader creates a leader replicating source, keeping the last
+// backlogSize bytes of the stream for partial resyncs.
+func NewLeader(source Source, backlogSize int, logger *slog.Logger) *Leader {
+	l := &Leader{
+		source:    source,
+		logger:    logger,
+		replID:    newReplID(),
+		backlog:   newBacklog(backlogSize, 0),
+		followers: make(map[*followerConn]struct{}),
+	}
+	l.enc = resp.NewWriter(&l.buf)
+	return l
+}
+
+// newReplID returns a random replication ID. A new ID is generated every
+// time a leader starts, since its stream starts over.
+func newReplID() string {
+	id := make([]byte, 20)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// ReplID returns the replication ID of the leader.
+func (l *Leader) ReplID() string {
+	return l.replID
+}
+
+// Offset returns the number of bytes written to the replication stream.
+func (l *Leader) Offset() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.offset
+}
+
+// Followers returns the connected followers.
+func (l *Leader) Followers() []FollowerInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	infos := make([]FollowerInfo, 0, len(l.followers))
+	for f := range l.followers {
+		infos = append(infos, FollowerInfo{Addr: f.addr, AckOffset: f.ack.Load()})
+	}
+	return infos
+}
+
+// Append adds cmd to the replication stream.
+func (l *Leader) Append(cmd command.Command) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf.Reset()
+	_ = l.enc.WriteCommand(append([]string{cmd.Name}, cmd.Args...)...)
+	_ = l.enc.Flush()
+	record := l.buf.Bytes()
+
+	l.backlog.write(record)
+	l.offset += int64(len(record))
+	for f := range l.followers {
+		if err := f.send(record); err != nil {
+			if errors.Is(err, errFollowerBehind) {
+				l.logger.Warn("Disconnecting follower", "remote_addr", f.addr, "error", err)
+			}
+			delete(l.followers, f)
+		}
+	}
+}
+
+// ServeFollower implements resp.Replicator. It answers PSYNC replid offset
+// with either +FULLRESYNC replid offset followed by a snapshot of the
+// cache as a bulk string, or +CONTINUE replid if the follower can resume
+// from the backlog, then streams the replication feed until the follower
+// disconnects. Followers acknowledge their offset with REPLCONF ACK offset.
+func (l *Leader) ServeFollower(conn net.Conn, rd *resp.Reader, args []string) error {
+	wr := resp.NewWriter(conn)
+	if len(args) != 3 || !strings.EqualFold(args[0], "PSYNC") {
+		_ = wr.WriteError("ERR wrong number of arguments for 'psync' command")
+		return wr.Flush()
+	}
+	replID := args[1]
+	offset, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		_ = wr.WriteError("ERR " + command.ErrNotInteger.Error())
+		return wr.Flush()
+	}
+
+	f := newFollowerConn(conn.RemoteAddr().String())
+	defer l.detach(f)
+
+	if backlog, ok := l.resume(f, replID, offset); ok {
+		l.logger.Info("Follower resumed", "remote_addr", f.addr, "offset", offset, "bytes", len(backlog))
+		_ = wr.WriteStatus("CONTINUE " + l.replID)
+		_, _ = wr.Write(backlog)
+	} else if err = l.fullSync(f, wr); err != nil {
+		return err
+	}
+	if err = wr.Flush(); err != nil {
+		return err
+	}
+
+	go f.readAcks(rd)
+	return f.stream(conn)
+}
+
+// resume attaches f to the stream at offset if the backlog still holds it,
+// and returns the bytes the follower is missing.
+func (l *Leader) resume(f *followerConn, replID string, offset int64) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if replID != l.replID {
+		return nil, false
+	}
+	missing, ok := l.backlog.since(offset)
+	if !ok {
+		return nil, false
+	}
+
+	f.ack.Store(offset)
+	l.followers[f] = struct{}{}
+	return missing, true
+}
+
+// fullSync attaches f to the stream and writes the FULLRESYNC reply and a
+// snapshot of the cache consistent with the attach offset.
+func (l *Leader) fullSync(f *followerConn, wr *resp.Writer) error {
+	var entries []cache.Entry
+	var offset int64
+	l.source.Exclusive(func() {
+		entries = l.source.Dump()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		offset = l.offset
+		l.followers[f] = struct{}{}
+	})
+	l.logger.Info("Starting full sync", "remote_addr", f.addr, "keys", len(entries), "offset", offset)
+
+	var snapshot bytes.Buffer
+	if err := persistence.WriteSnapshot(&snapshot, entries); err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	_ = wr.WriteStatus(fmt.Sprintf("FULLRESYNC %s %d", l.replID, offset))
+	_ = wr.WriteBulkHeader(int64(snapshot.Len()))
+	_, _ = wr.Write(snapshot.Bytes())
+	return wr.WriteBulkTrailer()
+}
+
+// detach removes f from the followers of the leader.
+func (l *Leader) detach(f *followerConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.followers, f)
+	f.close()
+}
+
+// followerConn is the leader side of a follower connection. The stream is
+// queued in memory and written by the connection goroutine, so that a slow
+// follower never blocks writes on the leader.
+type followerConn struct {
+	addr string
+	ack  atomic.Int64
+
+	mu      sync.Mutex
+	pending []byte
+	closed  bool
+	// ready is signaled when pending data or a close is available.
+	ready chan struct{}
+}
+
+func newFollowerConn(addr string) *followerConn {
+	return &followerConn{
+		addr:  addr,
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// send queues p for the follower. It closes the follower and returns
+// errFollowerBehind if the queue would exceed followerBufferLimit.
+func (f *followerConn) send(p []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return errFollowerClosed
+	}
+	if len(f.pending)+len(p) > followerBufferLimit {
+		f.closeLocked()
+		return errFollowerBehind
+	}
+	f.pending = append(f.pending, p...)
+	f.signal()
+	return nil
+}
+
+func (f *followerConn) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closeLocked()
+}
+
+func (f *followerConn) closeLocked() {
+	f.closed = true
+	f.pending = nil
+	f.signal()
+}
+
+func (f *followerConn) signal() {
+	select {
+	case f.ready <- struct{}{}:
+	default:
+	}
+}
+
+// stream writes the queued stream to conn until the follower is closed or
+// a write fails.
+func (f *followerConn) stream(conn net.Conn) error {
+	for range f.ready {
+		f.mu.Lock()
+		pending, closed := f.pending, f.closed
+		f.pending = nil
+		f.mu.Unlock()
+
+		if closed {
+			return nil
+		}
+		if _, err := conn.Write(pending); err != nil {
+			f.close()
+			return err
+		}
+	}
+	return nil
+}
+
+// readAcks reads REPLCONF ACK offset commands from the follower until the
+// connection fails, then closes the follower.
+func (f *followerConn) readAcks(rd *resp.Reader) {
+	defer f.close()
+
+	for {
+		args, err := rd.ReadCommand()
+		if err != nil {
+			return
+		}
+		if len(args) == 3 && strings.EqualFold(args[0], "REPLCONF") && strings.EqualFold(args[1], "ACK") {
+			if offset, err := strconv.ParseInt(args[2], 10, 64); err == nil {
+				f.ack.Store(offset)
+			}
+		}
+	}
+}
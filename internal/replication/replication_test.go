@@ -0,0 +1,317 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+	"github.com/dsha256/gredis/internal/command"
+	"github.com/dsha256/gredis/internal/persistence"
+	"github.com/dsha256/gredis/internal/resp"
+)
+
+// node is an in-process gredis instance serving RESP on loopback.
+type node struct {
+	cache    *cache.MemoryCache
+	recorder *command.Recorder
+	server   *resp.Server
+	addr     string
+}
+
+func newNode() *node {
+	c := cache.NewMemoryCache(0)
+	return &node{cache: c, recorder: command.NewRecorder(c)}
+}
+
+// serve starts the RESP server of the node.
+func (n *node) serve(t *testing.T, opts ...resp.Option) {
+	t.Helper()
+
+	n.server = resp.NewServer(n.recorder, testLogger(), opts...)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	requireNoError(t, err, "Listen() failed: %v", err)
+	n.addr = l.Addr().String()
+
+	done := make(chan error, 1)
+	go func() { done <- n.server.Serve(l) }()
+	t.Cleanup(func() {
+		requireNoError(t, n.server.Shutdown(context.Background()), "Shutdown() failed")
+		require(t, errors.Is(<-done, resp.ErrServerClosed), "Serve() did not return ErrServerClosed")
+	})
+}
+
+func startLeader(t *testing.T, backlogSize int) (*node, *Leader) {
+	t.Helper()
+
+	n := newNode()
+	l := NewLeader(n.recorder, backlogSize, testLogger())
+	n.recorder.AddSink(l)
+	n.serve(t, resp.WithReplicator(l))
+	return n, l
+}
+
+func startFollower(t *testing.T, leaderAddr string) (*node, *Follower) {
+	t.Helper()
+
+	n := newNode()
+	n.serve(t, resp.WithReadOnly())
+	f := NewFollower(leaderAddr, n.recorder, testLogger())
+	f.Start()
+	t.Cleanup(f.Stop)
+	return n, f
+}
+
+// waitInSync waits until the follower has applied the whole stream of the
+// leader and holds the same data.
+func waitInSync(t *testing.T, leader *node, l *Leader, follower *node, f *Follower) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status := f.Status()
+		if status.Connected && status.Offset == l.Offset() && reflect.DeepEqual(dump(follower), dump(leader)) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("follower not in sync: status %+v, leader offset %d\nleader:   %v\nfollower: %v",
+		f.Status(), l.Offset(), leader.cache.Dump(), follower.cache.Dump())
+}
+
+// dump returns the contents of the node with expirations rounded to the
+// millisecond precision of the replication stream.
+func dump(n *node) []cache.Entry {
+	entries := n.cache.Dump()
+	for i, entry := range entries {
+		if !entry.ExpireAt.IsZero() {
+			entries[i].ExpireAt = time.UnixMilli(entry.ExpireAt.UnixMilli())
+		}
+	}
+	return entries
+}
+
+// disconnect drops the connection of the follower to the leader and waits
+// for the leader to notice.
+func disconnect(t *testing.T, l *Leader, f *Follower) {
+	t.Helper()
+
+	f.mu.Lock()
+	if f.conn != nil {
+		_ = f.conn.Close()
+	}
+	f.mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(l.Followers()) > 0 || f.Status().Connected {
+		require(t, time.Now().Before(deadline), "follower still connected")
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReplication(t *testing.T) {
+	t.Parallel()
+
+	leader, l := startLeader(t, DefaultBacklogSize)
+
+	// Data written before the follower connects arrives with the full sync.
+	requireNoError(t, leader.recorder.Set("before", "sync"), "Set() failed")
+	_, err := leader.recorder.ZAdd("zset", cache.ZAddOptions{}, cache.Z{Member: "a", Score: 1})
+	requireNoError(t, err, "ZAdd() failed: %v", err)
+
+	follower, f := startFollower(t, leader.addr)
+	waitInSync(t, leader, l, follower, f)
+	replID := f.Status().ReplID
+	require(t, replID == l.ReplID(), "follower ReplID = %q, want %q", replID, l.ReplID())
+
+	// Later writes are streamed.
+	_, err = leader.recorder.ListPush("list", cache.ListBack, "x", "y")
+	requireNoError(t, err, "ListPush() failed: %v", err)
+	_, err = leader.recorder.HIncrBy("hash", "counter", 5)
+	requireNoError(t, err, "HIncrBy() failed: %v", err)
+	requireNoError(t, leader.recorder.SetWithTTL("ttl", "value", time.Hour), "SetWithTTL() failed")
+	requireNoError(t, leader.recorder.Remove("before"), "Remove() failed")
+	_, err = command.ExecTx(leader.recorder, []command.Command{
+		command.New("RPOP", "list"),
+		command.New("SADD", "set", "x"),
+	})
+	requireNoError(t, err, "ExecTx() failed: %v", err)
+	waitInSync(t, leader, l, follower, f)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		followers := l.Followers()
+		if len(followers) == 1 && followers[0].AckOffset == l.Offset() {
+			break
+		}
+		require(t, time.Now().Before(deadline), "Followers() = %+v, want one follower acknowledging offset %d", followers, l.Offset())
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The follower rejects writes from its own clients.
+	conn, err := net.Dial("tcp", follower.addr)
+	requireNoError(t, err, "Dial() failed: %v", err)
+	defer conn.Close()
+	wr, rd := resp.NewWriter(conn), resp.NewReader(conn)
+	requireNoError(t, wr.WriteCommand("SET", "key", "value"), "WriteCommand() failed")
+	requireNoError(t, wr.WriteCommand("GET", "list"), "WriteCommand() failed")
+	requireNoError(t, wr.WriteCommand("LRANGE", "list", "0", "-1"), "WriteCommand() failed")
+	requireNoError(t, wr.Flush(), "Flush() failed")
+
+	for _, want := range []any{
+		resp.Error("READONLY You can't write against a read only replica."),
+		resp.Error("WRONGTYPE Operation against a key holding the wrong kind of value"),
+		[]any{"x"},
+	} {
+		got, err := rd.ReadValue()
+		requireNoError(t, err, "ReadValue() failed: %v", err)
+		require(t, reflect.DeepEqual(got, want), "reply = %#v, want %#v", got, want)
+	}
+}
+
+func TestReplication_PartialResync(t *testing.T) {
+	t.Parallel()
+
+	leader, l := startLeader(t, DefaultBacklogSize)
+	follower, f := startFollower(t, leader.addr)
+	requireNoError(t, leader.recorder.Set("key", "1"), "Set() failed")
+	waitInSync(t, leader, l, follower, f)
+
+	disconnect(t, l, f)
+	for i := range 10 {
+		requireNoError(t, leader.recorder.Set("key", fmt.Sprint(i)), "Set() failed")
+	}
+	// A key only the follower has would be dropped by a full sync.
+	requireNoError(t, follower.cache.Set("local", "marker"), "Set() failed")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for value, _ := follower.cache.Get("key"); value != "9"; value, _ = follower.cache.Get("key") {
+		require(t, time.Now().Before(deadline), "follower did not resume, key = %q", value)
+		time.Sleep(5 * time.Millisecond)
+	}
+	require(t, follower.cache.Exists("local"), "follower did a full sync instead of resuming")
+	require(t, f.Status().Offset == l.Offset(), "follower offset = %d, want %d", f.Status().Offset, l.Offset())
+}
+
+func TestReplication_FullResyncAfterBacklogOverflow(t *testing.T) {
+	t.Parallel()
+
+	leader, l := startLeader(t, 64)
+	follower, f := startFollower(t, leader.addr)
+	requireNoError(t, leader.recorder.Set("key", "value"), "Set() failed")
+	waitInSync(t, leader, l, follower, f)
+
+	disconnect(t, l, f)
+	for i := range 20 {
+		requireNoError(t, leader.recorder.Set(fmt.Sprint("key", i), "value"), "Set() failed")
+	}
+	requireNoError(t, follower.cache.Set("local", "marker"), "Set() failed")
+
+	waitInSync(t, leader, l, follower, f)
+	require(t, !follower.cache.Exists("local"), "follower resumed from a backlog that no longer held its offset")
+}
+
+func TestBacklog(t *testing.T) {
+	t.Parallel()
+
+	b := newBacklog(8, 100)
+	got, ok := b.since(100)
+	require(t, ok && len(got) == 0, "since(100) = %q, %v on an empty backlog", got, ok)
+
+	b.write([]byte("abcde"))
+	b.write([]byte("fghij"))
+
+	tests := []struct {
+		offset int64
+		want   string
+		ok     bool
+	}{
+		{offset: 101, ok: false},
+		{offset: 102, want: "cdefghij", ok: true},
+		{offset: 107, want: "hij", ok: true},
+		{offset: 110, want: "", ok: true},
+		{offset: 111, ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := b.since(tt.offset)
+		require(t, ok == tt.ok && bytes.Equal(got, []byte(tt.want)), "since(%d) = %q, %v, want %q, %v", tt.offset, got, ok, tt.want, tt.ok)
+	}
+
+	// Writes larger than the backlog keep only their tail.
+	b.write([]byte("0123456789"))
+	got, ok = b.since(112)
+	require(t, ok && string(got) == "23456789", "since(112) = %q, %v", got, ok)
+}
+
+// BenchmarkServedStack_Set writes from GOMAXPROCS goroutines through the
+// stack a leader serves: a recorder feeding the replication backlog and,
+// optionally, the append-only file. Unlike the cache benchmarks, it
+// includes the cost of recording every write.
+func BenchmarkServedStack_Set(b *testing.B) {
+	const keys = 10000
+
+	stores := []struct {
+		name  string
+		store func() cache.Store
+	}{
+		{"MemoryCache", func() cache.Store { return cache.NewMemoryCache(0) }},
+		{"ShardedCache/16", func() cache.Store { return cache.NewShardedCache(16, 0) }},
+	}
+	for _, bs := range stores {
+		for _, withAOF := range []bool{false, true} {
+			name := bs.name + "/leader"
+			if withAOF {
+				name += "+aof"
+			}
+			b.Run(name, func(b *testing.B) {
+				recorder := command.NewRecorder(bs.store())
+				recorder.AddSink(NewLeader(recorder, DefaultBacklogSize, testLogger()))
+				if withAOF {
+					path := filepath.Join(b.TempDir(), "appendonly.aof")
+					aof, err := persistence.OpenAOF(path, recorder, persistence.AOFOptions{Fsync: persistence.FsyncEverySec}, testLogger())
+					if err != nil {
+						b.Fatalf("OpenAOF() failed: %v", err)
+					}
+					defer aof.Close()
+					recorder.AddSink(aof)
+				}
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					i := 0
+					for pb.Next() {
+						_ = recorder.Set("key:"+strconv.Itoa(i%keys), "value")
+						i++
+					}
+				})
+			})
+		}
+	}
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func requireNoError(t *testing.T, err error, format string, args ...any) {
+	t.Helper()
+	require(t, err == nil, format, args...)
+}
+
+func require(t *testing.T, condition bool, format string, args ...any) {
+	t.Helper()
+	if !condition {
+		t.Fatalf(format, args...)
+	}
+}
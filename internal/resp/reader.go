@@ -0,0 +1,249 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Protocol limits, matching the defaults of Redis.
+const (
+	maxBulkLen  = 512 << 20
+	maxArrayLen = 1 << 20
+)
+
+// Limits on what a peer can make the reader allocate before sending the
+// data it announced, and on the nesting of arrays.
+const (
+	maxBulkPrealloc  = 64 << 10
+	maxArrayPrealloc = 1024
+	maxArrayDepth    = 8
+)
+
+// ErrProtocol is returned when the peer sends malformed RESP data.
+var ErrProtocol = errors.New("protocol error")
+
+// Reader parses RESP2 values from a stream.
+type Reader struct {
+	rd *bufio.Reader
+}
+
+// NewReader creates a new Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		rd: bufio.NewReader(r),
+	}
+}
+
+// Buffered returns the number of bytes that can be read without blocking.
+func (r *Reader) Buffered() int {
+	return r.rd.Buffered()
+}
+
+// ReadCommand reads a request, either an array of bulk strings or an inline
+// command, and returns its parts.
+//
+// It returns io.EOF if the stream ends cleanly between two requests and
+// io.ErrUnexpectedEOF if it ends in the middle of one.
+func (r *Reader) ReadCommand() ([]string, error) {
+	for {
+		prefix, err := r.rd.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+
+		if prefix[0] != '*' {
+			args, err := r.readInline()
+			if err != nil || len(args) > 0 {
+				return args, err
+			}
+			// Skip empty lines, like Redis does.
+			continue
+		}
+
+		value, err := r.ReadValue()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+
+		items, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: expected array of bulk strings", ErrProtocol)
+		}
+
+		args := make([]string, 0, len(items))
+		for _, item := range items {
+			arg, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: expected bulk string", ErrProtocol)
+			}
+			args = append(args, arg)
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		return args, nil
+	}
+}
+
+// ReadValue reads a single RESP value. Simple strings and bulk strings are
+// returned as string, integers as int64, errors as Error, arrays as []any and
+// null bulk strings or arrays as nil.
+func (r *Reader) ReadValue() (any, error) {
+	return r.readValue(0)
+}
+
+// readValue reads a single RESP value nested in depth arrays.
+func (r *Reader) readValue(depth int) (any, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, fmt.Errorf("%w: empty line", ErrProtocol)
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid integer", ErrProtocol)
+		}
+		return n, nil
+	case '$':
+		return r.readBulk(line[1:])
+	case '*':
+		return r.readArray(line[1:], depth)
+	default:
+		return nil, fmt.Errorf("%w: unexpected type byte %q", ErrProtocol, line[0])
+	}
+}
+
+// ReadBulkBytes reads a bulk string header and streams its payload to w.
+// It is used for payloads too large to be held as a single string.
+func (r *Reader) ReadBulkBytes(w io.Writer) (int64, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return 0, err
+	}
+	if len(line) == 0 || line[0] != '$' {
+		return 0, fmt.Errorf("%w: expected bulk string", ErrProtocol)
+	}
+
+	n, err := strconv.ParseInt(line[1:], 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: invalid bulk length", ErrProtocol)
+	}
+
+	if _, err = io.CopyN(w, r.rd, n); err != nil {
+		return 0, unexpected(err)
+	}
+	return n, r.readCRLF()
+}
+
+// readBulk reads the payload of a bulk string. The buffer grows as the
+// payload arrives rather than to the announced length.
+func (r *Reader) readBulk(header string) (any, error) {
+	n, err := strconv.Atoi(header)
+	if err != nil || n < -1 || n > maxBulkLen {
+		return nil, fmt.Errorf("%w: invalid bulk length", ErrProtocol)
+	}
+	if n == -1 {
+		return nil, nil
+	}
+
+	var buf strings.Builder
+	buf.Grow(min(n, maxBulkPrealloc))
+	if _, err = io.CopyN(&buf, r.rd, int64(n)); err != nil {
+		return nil, unexpected(err)
+	}
+	if err = r.readCRLF(); err != nil {
+		return nil, err
+	}
+
+	return buf.String(), nil
+}
+
+// readArray reads the items of an array nested in depth arrays. Like the
+// buffer of a bulk string, the slice grows as the items arrive.
+func (r *Reader) readArray(header string, depth int) (any, error) {
+	n, err := strconv.Atoi(header)
+	if err != nil || n < -1 || n > maxArrayLen {
+		return nil, fmt.Errorf("%w: invalid multibulk length", ErrProtocol)
+	}
+	if n == -1 {
+		return nil, nil
+	}
+	if depth >= maxArrayDepth {
+		return nil, fmt.Errorf("%w: arrays nested too deeply", ErrProtocol)
+	}
+
+	items := make([]any, 0, min(n, maxArrayPrealloc))
+	for range n {
+		item, err := r.readValue(depth + 1)
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func (r *Reader) readInline() ([]string, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(line), nil
+}
+
+// readLine reads a line terminated by CRLF (or a bare LF) without the
+// terminator.
+func (r *Reader) readLine() (string, error) {
+	line, err := r.rd.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return "", fmt.Errorf("%w: line too long", ErrProtocol)
+	}
+	if err != nil {
+		if len(line) > 0 {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+
+	return string(line), nil
+}
+
+func (r *Reader) readCRLF() error {
+	var crlf [2]byte
+	if _, err := io.ReadFull(r.rd, crlf[:]); err != nil {
+		return unexpected(err)
+	}
+	if crlf != [2]byte{'\r', '\n'} {
+		return fmt.Errorf("%w: expected CRLF", ErrProtocol)
+	}
+	return nil
+}
+
+// unexpected converts io.EOF into io.ErrUnexpectedEOF for reads that happen
+// in the middle of a value.
+func unexpected(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,491 @@
+package resp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+)
+
+func TestReader_ReadCommand(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		input   string
+		want    [][]string
+		wantErr error
+	}{
+		{
+			name:  "Array of bulk strings",
+			input: "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n",
+			want:  [][]string{{"SET", "key", "value"}},
+		},
+		{
+			name:  "Inline command",
+			input: "GET key\r\n",
+			want:  [][]string{{"GET", "key"}},
+		},
+		{
+			name:  "Pipelined commands with empty lines",
+			input: "PING\r\n\r\n*1\r\n$4\r\nPING\r\n",
+			want:  [][]string{{"PING"}, {"PING"}},
+		},
+		{
+			name:  "Binary safe bulk string",
+			input: "*2\r\n$4\r\nECHO\r\n$4\r\na\r\nb\r\n",
+			want:  [][]string{{"ECHO", "a\r\nb"}},
+		},
+		{
+			name:    "Truncated request",
+			input:   "*2\r\n$3\r\nGET\r\n$3\r\nke",
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "Invalid bulk length",
+			input:   "*1\r\n$x\r\n",
+			wantErr: ErrProtocol,
+		},
+		{
+			name:    "Announced bulk string never sent",
+			input:   "*1\r\n$536870912\r\nabc",
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rd := NewReader(strings.NewReader(tt.input))
+
+			var got [][]string
+			for {
+				args, err := rd.ReadCommand()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					require(t, errors.Is(err, tt.wantErr), "ReadCommand() error = %v, want %v", err, tt.wantErr)
+					return
+				}
+				got = append(got, args)
+			}
+
+			require(t, tt.wantErr == nil, "ReadCommand() error = nil, want %v", tt.wantErr)
+			require(t, reflect.DeepEqual(got, tt.want), "ReadCommand() = %q, want %q", got, tt.want)
+		})
+	}
+}
+
+// TestReader_AnnouncedLengths checks that the lengths announced by a peer
+// do not make the reader allocate memory for data that never arrives. It
+// does not run in parallel, so that the allocations it measures are its
+// own.
+func TestReader_AnnouncedLengths(t *testing.T) {
+	inputs := []string{
+		"*1\r\n$536870912\r\nabc",
+		"*1048576\r\n$1\r\na\r\n",
+	}
+	for _, input := range inputs {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := NewReader(strings.NewReader(input)).ReadCommand()
+		runtime.ReadMemStats(&after)
+
+		require(t, errors.Is(err, io.ErrUnexpectedEOF), "ReadCommand(%q) error = %v, want %v", input, err, io.ErrUnexpectedEOF)
+		allocated := after.TotalAlloc - before.TotalAlloc
+		require(t, allocated < 1<<20, "ReadCommand(%q) allocated %d bytes", input, allocated)
+	}
+}
+
+func TestReader_ArrayDepth(t *testing.T) {
+	t.Parallel()
+
+	nested := func(depth int) string {
+		return strings.Repeat("*1\r\n", depth) + ":1\r\n"
+	}
+	_, err := NewReader(strings.NewReader(nested(maxArrayDepth))).ReadValue()
+	requireNoError(t, err, "ReadValue() of arrays nested %d deep failed: %v", maxArrayDepth, err)
+	_, err = NewReader(strings.NewReader(nested(maxArrayDepth + 1))).ReadValue()
+	require(t, errors.Is(err, ErrProtocol), "ReadValue() of arrays nested too deeply error = %v, want %v", err, ErrProtocol)
+}
+
+func TestWriter_WriteValue(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{name: "Null", value: nil, want: "$-1\r\n"},
+		{name: "Bulk string", value: "hello", want: "$5\r\nhello\r\n"},
+		{name: "Integer", value: int64(-42), want: ":-42\r\n"},
+		{name: "Error", value: Error("ERR boom"), want: "-ERR boom\r\n"},
+		{name: "String array", value: []string{"a", "bc"}, want: "*2\r\n$1\r\na\r\n$2\r\nbc\r\n"},
+		{name: "Nested array", value: []any{int64(1), nil}, want: "*2\r\n:1\r\n$-1\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			wr := NewWriter(&buf)
+			requireNoError(t, wr.WriteValue(tt.value), "WriteValue() failed")
+			requireNoError(t, wr.Flush(), "Flush() failed")
+			require(t, buf.String() == tt.want, "WriteValue() = %q, want %q", buf.String(), tt.want)
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	memCache := cache.NewMemoryCache(100 * time.Millisecond)
+	defer memCache.Stop()
+
+	srv := NewServer(memCache, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	requireNoError(t, err, "Listen() failed: %v", err)
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(l) }()
+	defer func() {
+		requireNoError(t, srv.Shutdown(context.Background()), "Shutdown() failed")
+		require(t, errors.Is(<-done, ErrServerClosed), "Serve() did not return ErrServerClosed")
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	requireNoError(t, err, "Dial() failed: %v", err)
+	defer conn.Close()
+
+	rd := NewReader(conn)
+	wr := NewWriter(conn)
+
+	tests := []struct {
+		args []string
+		want any
+	}{
+		{args: []string{"PING"}, want: "PONG"},
+		{args: []string{"SET", "greeting", "hello"}, want: "OK"},
+		{args: []string{"GET", "greeting"}, want: "hello"},
+		{args: []string{"GET", "missing"}, want: nil},
+		{args: []string{"SET", "temp", "value", "EX", "100"}, want: "OK"},
+		{args: []string{"TTL", "temp"}, want: int64(100)},
+		{args: []string{"PERSIST", "temp"}, want: int64(1)},
+		{args: []string{"TTL", "temp"}, want: int64(-1)},
+		{args: []string{"TTL", "missing"}, want: int64(-2)},
+		{args: []string{"SET", "temp", "kept", "XX", "KEEPTTL"}, want: "OK"},
+		{args: []string{"SET", "missing", "value", "XX", "KEEPTTL"}, want: nil},
+		{args: []string{"SET", "lock", "a", "NX", "PX", "60000"}, want: "OK"},
+		{args: []string{"SET", "lock", "b", "NX"}, want: nil},
+		{args: []string{"SET", "lock", "b", "NX", "GET"}, want: "a"},
+		{args: []string{"SET", "lock", "c", "GET", "KEEPTTL"}, want: "a"},
+		{args: []string{"SET", "fresh", "v", "GET"}, want: nil},
+		{args: []string{"SET", "lock", "d", "NX", "XX"}, want: Error("ERR syntax error")},
+		{args: []string{"SET", "lock", "d", "EX", "1", "KEEPTTL"}, want: Error("ERR syntax error")},
+		{args: []string{"SET", "temp", "value", "PXAT", "32503680000000"}, want: "OK"},
+		{args: []string{"PEXPIREAT", "temp", "1"}, want: int64(1)},
+		{args: []string{"EXISTS", "temp"}, want: int64(0)},
+		{args: []string{"PEXPIREAT", "missing", "1"}, want: int64(0)},
+		{args: []string{"SET", "temp", "value"}, want: "OK"},
+		{args: []string{"PEXPIRE", "temp", "100000", "XX"}, want: int64(0)},
+		{args: []string{"PEXPIRE", "temp", "100000", "NX"}, want: int64(1)},
+		{args: []string{"TTL", "temp"}, want: int64(100)},
+		{args: []string{"EXPIRE", "temp", "50", "GT"}, want: int64(0)},
+		{args: []string{"EXPIRE", "temp", "50", "XX", "LT"}, want: int64(1)},
+		{args: []string{"EXPIREAT", "temp", "32503680000", "GT"}, want: int64(1)},
+		{args: []string{"EXPIRETIME", "temp"}, want: int64(32503680000)},
+		{args: []string{"PEXPIREAT", "temp", "32503680000123"}, want: int64(1)},
+		{args: []string{"PEXPIRETIME", "temp"}, want: int64(32503680000123)},
+		{args: []string{"EXPIRE", "temp", "1", "NX", "GT"}, want: Error("ERR invalid option combination")},
+		{args: []string{"EXPIRE", "temp", "1", "SOON"}, want: Error("ERR syntax error")},
+		{args: []string{"EXPIRE", "temp", "9223372036854775807"}, want: Error("ERR invalid expire time")},
+		{args: []string{"PERSIST", "temp"}, want: int64(1)},
+		{args: []string{"PTTL", "temp"}, want: int64(-1)},
+		{args: []string{"EXPIRETIME", "temp"}, want: int64(-1)},
+		{args: []string{"PTTL", "missing"}, want: int64(-2)},
+		{args: []string{"PEXPIRETIME", "missing"}, want: int64(-2)},
+		{args: []string{"EXPIREAT", "temp", "1"}, want: int64(1)},
+		{args: []string{"EXISTS", "temp"}, want: int64(0)},
+		{args: []string{"MSET", "m1", "a", "m2", "b", "m1", "c"}, want: "OK"},
+		{args: []string{"MGET", "m1", "missing", "m2"}, want: []any{"c", nil, "b"}},
+		{args: []string{"MSETNX", "m2", "x", "m3", "y"}, want: int64(0)},
+		{args: []string{"MSETNX", "m3", "y", "m4", "z"}, want: int64(1)},
+		{args: []string{"MSET", "m1", "a", "m2"}, want: Error("ERR wrong number of arguments for 'mset' command")},
+		{args: []string{"INCR", "counter"}, want: int64(1)},
+		{args: []string{"INCRBY", "counter", "41"}, want: int64(42)},
+		{args: []string{"DECR", "counter"}, want: int64(41)},
+		{args: []string{"DECRBY", "counter", "-9"}, want: int64(50)},
+		{args: []string{"INCRBYFLOAT", "counter", "0.5"}, want: "50.5"},
+		{args: []string{"INCR", "counter"}, want: Error("ERR value is not an integer")},
+		{args: []string{"INCRBY", "counter", "x"}, want: Error("ERR value is not an integer or out of range")},
+		{args: []string{"INCRBYFLOAT", "counter", "x"}, want: Error("ERR value is not a valid float")},
+		{args: []string{"SET", "counter", "9223372036854775807"}, want: "OK"},
+		{args: []string{"INCR", "counter"}, want: Error("ERR increment or decrement would overflow")},
+		{args: []string{"RPUSH", "list", "a", "b", "c"}, want: int64(3)},
+		{args: []string{"LPUSH", "list", "z"}, want: int64(4)},
+		{args: []string{"LRANGE", "list", "0", "-1"}, want: []any{"z", "a", "b", "c"}},
+		{args: []string{"LPOP", "list"}, want: "z"},
+		{args: []string{"RPOP", "list"}, want: "c"},
+		{args: []string{"LLEN", "list"}, want: int64(2)},
+		{args: []string{"LLEN", "missing"}, want: int64(0)},
+		{args: []string{"RPUSH", "popped", "a", "b"}, want: int64(2)},
+		{args: []string{"LPOP", "popped"}, want: "a"},
+		{args: []string{"RPOP", "popped"}, want: "b"},
+		{args: []string{"EXISTS", "popped"}, want: int64(0)},
+		{args: []string{"TYPE", "popped"}, want: "none"},
+		{args: []string{"RPUSH", "list", "a", "c", "a"}, want: int64(5)},
+		{args: []string{"LINDEX", "list", "-1"}, want: "a"},
+		{args: []string{"LINDEX", "list", "9"}, want: nil},
+		{args: []string{"LSET", "list", "1", "B"}, want: "OK"},
+		{args: []string{"LSET", "list", "9", "x"}, want: Error("ERR index out of range")},
+		{args: []string{"LINSERT", "list", "BEFORE", "c", "b"}, want: int64(6)},
+		{args: []string{"LINSERT", "list", "AFTER", "nope", "x"}, want: int64(-1)},
+		{args: []string{"LINSERT", "missing", "AFTER", "a", "x"}, want: int64(0)},
+		{args: []string{"LPOS", "list", "a"}, want: int64(0)},
+		{args: []string{"LPOS", "list", "a", "RANK", "-1", "COUNT", "2"}, want: []any{int64(5), int64(2)}},
+		{args: []string{"LPOS", "list", "x"}, want: nil},
+		{args: []string{"LPOS", "list", "a", "RANK", "0"}, want: Error("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")},
+		{args: []string{"LREM", "list", "-2", "a"}, want: int64(2)},
+		{args: []string{"LTRIM", "list", "1", "-2"}, want: "OK"},
+		{args: []string{"LRANGE", "list", "0", "-1"}, want: []any{"B", "b"}},
+		{args: []string{"LMOVE", "list", "list", "LEFT", "RIGHT"}, want: "B"},
+		{args: []string{"RPOPLPUSH", "list", "moved"}, want: "B"},
+		{args: []string{"LMOVE", "missing", "moved", "LEFT", "LEFT"}, want: nil},
+		{args: []string{"LMOVE", "list", "moved", "UP", "LEFT"}, want: Error("ERR syntax error")},
+		{args: []string{"LRANGE", "moved", "0", "-1"}, want: []any{"B"}},
+		{args: []string{"GET", "list"}, want: Error("WRONGTYPE Operation against a key holding the wrong kind of value")},
+		{args: []string{"TYPE", "list"}, want: "list"},
+		{args: []string{"TYPE", "missing"}, want: "none"},
+		{args: []string{"HSET", "hash", "b", "2", "a", "1"}, want: int64(2)},
+		{args: []string{"HINCRBY", "hash", "a", "41"}, want: int64(42)},
+		{args: []string{"HGETALL", "hash"}, want: []any{"a", "42", "b", "2"}},
+		{args: []string{"HDEL", "hash", "b", "c"}, want: int64(1)},
+		{args: []string{"HGET", "hash", "b"}, want: nil},
+		{args: []string{"HSET", "hash", "a"}, want: Error("ERR wrong number of arguments for 'hset' command")},
+		{args: []string{"SADD", "set1", "a", "b", "c"}, want: int64(3)},
+		{args: []string{"SADD", "set2", "b", "c", "d"}, want: int64(3)},
+		{args: []string{"SINTER", "set1", "set2"}, want: []any{"b", "c"}},
+		{args: []string{"SDIFFSTORE", "set3", "set1", "set2"}, want: int64(1)},
+		{args: []string{"SISMEMBER", "set3", "a"}, want: int64(1)},
+		{args: []string{"ZADD", "board", "10", "alice", "20", "bob", "1.5", "carol"}, want: int64(3)},
+		{args: []string{"ZADD", "board", "GT", "CH", "5", "alice", "25", "bob"}, want: int64(1)},
+		{args: []string{"ZRANGE", "board", "0", "-1", "WITHSCORES"}, want: []any{"carol", "1.5", "alice", "10", "bob", "25"}},
+		{args: []string{"ZRANGE", "board", "+inf", "(1.5", "BYSCORE", "REV", "LIMIT", "0", "1"}, want: []any{"bob"}},
+		{args: []string{"ZREVRANK", "board", "carol"}, want: int64(2)},
+		{args: []string{"ZINCRBY", "board", "0.5", "carol"}, want: "2"},
+		{args: []string{"ZPOPMIN", "board"}, want: []any{"carol", "2"}},
+		{args: []string{"ZCOUNT", "board", "-inf", "+inf"}, want: int64(2)},
+		{args: []string{"EXPIRE", "greeting", "10"}, want: int64(1)},
+		{args: []string{"EXPIRE", "missing", "10"}, want: int64(0)},
+		{args: []string{"EXISTS", "greeting", "list", "missing"}, want: int64(2)},
+		{args: []string{"DEL", "greeting", "missing"}, want: int64(1)},
+		{args: []string{"COPY", "hash", "hash2"}, want: int64(1)},
+		{args: []string{"COPY", "hash", "hash2"}, want: int64(0)},
+		{args: []string{"COPY", "hash", "hash2", "REPLACE"}, want: int64(1)},
+		{args: []string{"COPY", "hash", "hash2", "NOW"}, want: Error("ERR syntax error")},
+		{args: []string{"RENAMENX", "hash2", "list"}, want: int64(0)},
+		{args: []string{"RENAME", "hash2", "renamed"}, want: "OK"},
+		{args: []string{"RENAME", "hash2", "renamed"}, want: Error("ERR key not found")},
+		{args: []string{"HGETALL", "renamed"}, want: []any{"a", "42"}},
+		{args: []string{"DEL", "renamed"}, want: int64(1)},
+		{args: []string{"KEYS", "set*"}, want: []any{"set1", "set2", "set3"}},
+		{args: []string{"SCAN", "0", "MATCH", "b*", "COUNT", "1000"}, want: []any{"0", []any{"board"}}},
+		{args: []string{"SCAN", "0", "MATCH", "b*", "COUNT", "9223372036854775807"}, want: []any{"0", []any{"board"}}},
+		{args: []string{"SCAN", "0", "TYPE", "HASH", "COUNT", "1000"}, want: []any{"0", []any{"hash"}}},
+		{args: []string{"SCAN", "-1"}, want: Error("ERR invalid cursor")},
+		{args: []string{"SCAN", "0", "COUNT"}, want: Error("ERR syntax error")},
+		{args: []string{"FLUSHDB"}, want: "OK"},
+		{args: []string{"EXISTS", "list"}, want: int64(0)},
+		{args: []string{"GET"}, want: Error("ERR wrong number of arguments for 'get' command")},
+		{args: []string{"NOPE"}, want: Error("ERR unknown command 'NOPE'")},
+	}
+
+	// Pipeline every command, then read all replies.
+	for _, tt := range tests {
+		requireNoError(t, wr.WriteCommand(tt.args...), "WriteCommand() failed")
+	}
+	requireNoError(t, wr.Flush(), "Flush() failed")
+
+	requireNoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)), "SetReadDeadline() failed")
+	for _, tt := range tests {
+		got, err := rd.ReadValue()
+		requireNoError(t, err, "%v: ReadValue() error = %v", tt.args, err)
+		require(t, reflect.DeepEqual(got, tt.want), "%v: reply = %#v, want %#v", tt.args, got, tt.want)
+	}
+}
+
+func TestServer_PubSub(t *testing.T) {
+	t.Parallel()
+
+	memCache := cache.NewMemoryCache(0)
+
+	srv := NewServer(memCache, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	requireNoError(t, err, "Listen() failed: %v", err)
+
+	go func() { _ = srv.Serve(l) }()
+	defer func() {
+		requireNoError(t, srv.Shutdown(context.Background()), "Shutdown() failed")
+	}()
+
+	subscriber, err := net.Dial("tcp", l.Addr().String())
+	requireNoError(t, err, "Dial() failed: %v", err)
+	defer subscriber.Close()
+	publisher, err := net.Dial("tcp", l.Addr().String())
+	requireNoError(t, err, "Dial() failed: %v", err)
+	defer publisher.Close()
+
+	requireNoError(t, subscriber.SetReadDeadline(time.Now().Add(5*time.Second)), "SetReadDeadline() failed")
+	requireNoError(t, publisher.SetReadDeadline(time.Now().Add(5*time.Second)), "SetReadDeadline() failed")
+
+	sub := struct {
+		*Reader
+		*Writer
+	}{NewReader(subscriber), NewWriter(subscriber)}
+	pub := struct {
+		*Reader
+		*Writer
+	}{NewReader(publisher), NewWriter(publisher)}
+
+	roundTrip := func(conn interface {
+		WriteCommand(args ...string) error
+		Flush() error
+		ReadValue() (any, error)
+	}, args []string, want ...any) {
+		t.Helper()
+		requireNoError(t, conn.WriteCommand(args...), "WriteCommand() failed")
+		requireNoError(t, conn.Flush(), "Flush() failed")
+		for _, w := range want {
+			got, err := conn.ReadValue()
+			requireNoError(t, err, "%v: ReadValue() error = %v", args, err)
+			require(t, reflect.DeepEqual(got, w), "%v: reply = %#v, want %#v", args, got, w)
+		}
+	}
+
+	roundTrip(sub, []string{"SUBSCRIBE", "news", "sport"},
+		[]any{"subscribe", "news", int64(1)},
+		[]any{"subscribe", "sport", int64(2)})
+	roundTrip(sub, []string{"PSUBSCRIBE", "n*"},
+		[]any{"psubscribe", "n*", int64(3)})
+	roundTrip(sub, []string{"GET", "key"},
+		Error("ERR Can't execute 'get': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context"))
+	roundTrip(sub, []string{"PING"}, []any{"pong", ""})
+
+	roundTrip(pub, []string{"PUBLISH", "news", "hello"}, int64(2))
+	roundTrip(pub, []string{"PUBLISH", "weather", "sunny"}, int64(0))
+
+	for _, want := range []any{
+		[]any{"message", "news", "hello"},
+		[]any{"pmessage", "n*", "news", "hello"},
+	} {
+		got, err := sub.ReadValue()
+		requireNoError(t, err, "ReadValue() error = %v", err)
+		require(t, reflect.DeepEqual(got, want), "message = %#v, want %#v", got, want)
+	}
+
+	roundTrip(sub, []string{"UNSUBSCRIBE"},
+		[]any{"unsubscribe", "news", int64(2)},
+		[]any{"unsubscribe", "sport", int64(1)})
+	roundTrip(sub, []string{"PUNSUBSCRIBE", "n*"},
+		[]any{"punsubscribe", "n*", int64(0)})
+	roundTrip(sub, []string{"GET", "key"}, nil)
+}
+
+func requireNoError(t *testing.T, err error, format string, args ...any) {
+	t.Helper()
+	require(t, errors.Is(err, nil), format, args...)
+}
+
+func require(t *testing.T, condition bool, format string, args ...any) {
+	t.Helper()
+	if !condition {
+		t.Fatalf(format, args...)
+	}
+}
+
+func TestServer_Tx(t *testing.T) {
+	t.Parallel()
+
+	memCache := cache.NewMemoryCache(0)
+
+	srv := NewServer(memCache, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	requireNoError(t, err, "Listen() failed: %v", err)
+
+	go func() { _ = srv.Serve(l) }()
+	defer func() {
+		requireNoError(t, srv.Shutdown(context.Background()), "Shutdown() failed")
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	requireNoError(t, err, "Dial() failed: %v", err)
+	defer conn.Close()
+
+	rd := NewReader(conn)
+	wr := NewWriter(conn)
+
+	tests := []struct {
+		args []string
+		want any
+	}{
+		{args: []string{"EXEC"}, want: Error("ERR EXEC without MULTI")},
+		{args: []string{"DISCARD"}, want: Error("ERR DISCARD without MULTI")},
+		{args: []string{"RPUSH", "source", "a", "b"}, want: int64(2)},
+		{args: []string{"MULTI"}, want: "OK"},
+		{args: []string{"MULTI"}, want: Error("ERR MULTI calls can not be nested")},
+		{args: []string{"LPOP", "source"}, want: "QUEUED"},
+		{args: []string{"RPUSH", "destination", "a"}, want: "QUEUED"},
+		{args: []string{"HGET", "source", "field"}, want: "QUEUED"},
+		{args: []string{"LRANGE", "destination", "0", "-1"}, want: "QUEUED"},
+		{args: []string{"EXEC"}, want: []any{
+			"a",
+			int64(1),
+			Error("WRONGTYPE Operation against a key holding the wrong kind of value"),
+			[]any{"a"},
+		}},
+		{args: []string{"MULTI"}, want: "OK"},
+		{args: []string{"SET", "discarded", "value"}, want: "QUEUED"},
+		{args: []string{"DISCARD"}, want: "OK"},
+		{args: []string{"EXISTS", "discarded"}, want: int64(0)},
+		{args: []string{"MULTI"}, want: "OK"},
+		{args: []string{"SET", "aborted", "value"}, want: "QUEUED"},
+		{args: []string{"GET"}, want: Error("ERR wrong number of arguments for 'get' command")},
+		{args: []string{"EXEC"}, want: Error("EXECABORT Transaction discarded because of previous errors.")},
+		{args: []string{"EXISTS", "aborted"}, want: int64(0)},
+		{args: []string{"WATCH", "watched"}, want: "OK"},
+		{args: []string{"SET", "watched", "1"}, want: "OK"},
+		{args: []string{"MULTI"}, want: "OK"},
+		{args: []string{"WATCH", "other"}, want: Error("ERR WATCH inside MULTI is not allowed")},
+		{args: []string{"SET", "watched", "2"}, want: "QUEUED"},
+		{args: []string{"EXEC"}, want: nil},
+		{args: []string{"GET", "watched"}, want: "1"},
+		{args: []string{"WATCH", "watched"}, want: "OK"},
+		{args: []string{"MULTI"}, want: "OK"},
+		{args: []string{"SET", "watched", "3"}, want: "QUEUED"},
+		{args: []string{"EXEC"}, want: []any{"OK"}},
+		{args: []string{"WATCH", "watched"}, want: "OK"},
+		{args: []string{"DEL", "watched"}, want: int64(1)},
+		{args: []string{"UNWATCH"}, want: "OK"},
+		{args: []string{"MULTI"}, want: "OK"},
+		{args: []string{"EXEC"}, want: []any{}},
+	}
+
+	for _, tt := range tests {
+		requireNoError(t, wr.WriteCommand(tt.args...), "WriteCommand() failed")
+	}
+	requireNoError(t, wr.Flush(), "Flush() failed")
+
+	requireNoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)), "SetReadDeadline() failed")
+	for _, tt := range tests {
+		got, err := rd.ReadValue()
+		requireNoError(t, err, "%v: ReadValue() error = %v", tt.args, err)
+		require(t, reflect.DeepEqual(got, tt.want), "%v: reply = %#v, want %#v", tt.args, got, tt.want)
+	}
+}
@@ -0,0 +1,500 @@
+package resp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dsha256/gredis/internal/cache"
+	"github.com/dsha256/gredis/internal/command"
+	"github.com/dsha256/gredis/internal/pubsub"
+)
+
+// ErrServerClosed is returned by Serve after a call to Shutdown.
+var ErrServerClosed = errors.New("resp: server closed")
+
+// Replicator takes over connections that send PSYNC to replicate the
+// server. It returns when the follower disconnects.
+type Replicator interface {
+	ServeFollower(conn net.Conn, rd *Reader, args []string) error
+}
+
+// errReadOnly is the reply to write commands on a read-only server.
+const errReadOnly = "READONLY You can't write against a read only replica."
+
+// Server serves the cache over the Redis serialization protocol (RESP2).
+type Server struct {
+	cache      cache.Cache
+	logger     *slog.Logger
+	replicator Replicator
+	readOnly   bool
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// Option configures optional Server behavior.
+type Option func(*Server)
+
+// WithReplicator lets followers replicate the server with PSYNC.
+func WithReplicator(r Replicator) Option {
+	return func(s *Server) {
+		s.replicator = r
+	}
+}
+
+// WithReadOnly makes the server reject write commands, as a follower does.
+func WithReadOnly() Option {
+	return func(s *Server) {
+		s.readOnly = true
+	}
+}
+
+// NewServer creates a new RESP server backed by the given cache.
+func NewServer(cache cache.Cache, logger *slog.Logger, opts ...Option) *Server {
+	s := &Server{
+		cache:  cache,
+		logger: logger,
+		conns:  make(map[net.Conn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ListenAndServe listens on the TCP address addr and serves connections.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until Shutdown is called.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = l.Close()
+		return ErrServerClosed
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		if !s.track(conn) {
+			_ = conn.Close()
+			return ErrServerClosed
+		}
+
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+// Addr returns the listener address, or nil if the server is not serving.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Shutdown stops accepting connections, closes open ones and waits for their
+// goroutines to finish or ctx to be done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	if s.listener != nil {
+		_ = s.listener.Close()
+	}
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, conn)
+}
+
+// session is the state of a single client connection.
+type session struct {
+	conn net.Conn
+
+	// mu serializes replies written by the command loop with messages
+	// written by the pub/sub forwarding goroutine.
+	mu sync.Mutex
+	wr *Writer
+
+	// sub is created by the first (P)SUBSCRIBE and lives until the
+	// connection is closed. forwarding tracks the goroutine writing its
+	// messages.
+	sub        *pubsub.Subscription
+	forwarding sync.WaitGroup
+
+	// multi is set between MULTI and EXEC or DISCARD, while the commands
+	// sent are queued. txFailed records that one of them was rejected, in
+	// which case EXEC discards the transaction. watched holds the versions
+	// of the keys watched with WATCH until the next EXEC, DISCARD or
+	// UNWATCH.
+	multi    bool
+	queued   []command.Command
+	txFailed bool
+	watched  map[string]uint64
+}
+
+// serveConn reads commands from conn and writes their replies until the
+// client disconnects or sends QUIT. Replies to pipelined commands are
+// flushed once the input buffer has been drained.
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer s.untrack(conn)
+	defer conn.Close()
+
+	start := time.Now()
+	s.logger.Debug("RESP connection opened", "remote_addr", conn.RemoteAddr())
+
+	rd := NewReader(conn)
+	sess := &session{conn: conn, wr: NewWriter(conn)}
+	defer sess.close()
+
+	for {
+		args, err := rd.ReadCommand()
+		if err != nil {
+			if errors.Is(err, ErrProtocol) {
+				sess.mu.Lock()
+				_ = sess.wr.WriteError("ERR " + err.Error())
+				_ = sess.wr.Flush()
+				sess.mu.Unlock()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.logger.Debug("RESP connection read failed", "remote_addr", conn.RemoteAddr(), "error", err)
+			}
+			break
+		}
+
+		if s.replicator != nil && strings.EqualFold(args[0], "PSYNC") && !sess.subscribed() {
+			s.replicate(sess, rd, args)
+			break
+		}
+
+		sess.mu.Lock()
+		quit := s.dispatch(sess, args)
+		if quit || rd.Buffered() == 0 {
+			err = sess.wr.Flush()
+		}
+		sess.mu.Unlock()
+
+		if quit || err != nil {
+			break
+		}
+	}
+
+	s.logger.Debug("RESP connection closed", "remote_addr", conn.RemoteAddr(), "duration", time.Since(start).String())
+}
+
+// dispatch executes a single command and writes its reply. It reports
+// whether the connection should be closed. The caller must hold sess.mu.
+func (s *Server) dispatch(sess *session, args []string) bool {
+	wr := sess.wr
+	name := strings.ToUpper(args[0])
+
+	// A subscribed connection only accepts commands that manage its
+	// subscriptions, as in Redis.
+	if sess.subscribed() {
+		switch name {
+		case "SUBSCRIBE", "PSUBSCRIBE", "UNSUBSCRIBE", "PUNSUBSCRIBE", "QUIT":
+		case "PING":
+			payload := ""
+			if len(args) > 1 {
+				payload = args[1]
+			}
+			_ = wr.WriteValue([]any{"pong", payload})
+			return false
+		default:
+			_ = wr.WriteError("ERR Can't execute '" + strings.ToLower(name) +
+				"': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context")
+			return false
+		}
+	}
+
+	// Inside a transaction, commands are queued until EXEC.
+	if sess.multi {
+		switch name {
+		case "MULTI", "EXEC", "DISCARD", "WATCH", "QUIT":
+		default:
+			s.queue(sess, name, args[1:])
+			return false
+		}
+	}
+
+	switch name {
+	case "MULTI":
+		if sess.multi {
+			_ = wr.WriteError("ERR MULTI calls can not be nested")
+			return false
+		}
+		sess.multi = true
+		_ = wr.WriteStatus("OK")
+		return false
+	case "EXEC":
+		s.exec(sess)
+		return false
+	case "WATCH":
+		s.watch(sess, args[1:])
+		return false
+	case "UNWATCH":
+		sess.watched = nil
+		_ = wr.WriteStatus("OK")
+		return false
+	case "DISCARD":
+		if !sess.multi {
+			_ = wr.WriteError("ERR DISCARD without MULTI")
+			return false
+		}
+		sess.discard()
+		_ = wr.WriteStatus("OK")
+		return false
+	case "PING":
+		switch len(args) {
+		case 1:
+			_ = wr.WriteStatus("PONG")
+		case 2:
+			_ = wr.WriteBulk(args[1])
+		default:
+			_ = wr.WriteError("ERR wrong number of arguments for 'ping' command")
+		}
+		return false
+	case "ECHO":
+		if len(args) != 2 {
+			_ = wr.WriteError("ERR wrong number of arguments for 'echo' command")
+			return false
+		}
+		_ = wr.WriteBulk(args[1])
+		return false
+	case "QUIT":
+		_ = wr.WriteStatus("OK")
+		return true
+	case "SELECT":
+		if len(args) != 2 || args[1] != "0" {
+			_ = wr.WriteError("ERR DB index is out of range")
+			return false
+		}
+		_ = wr.WriteStatus("OK")
+		return false
+	case "CLIENT":
+		// Connection naming and library info are accepted and ignored.
+		_ = wr.WriteStatus("OK")
+		return false
+	case "COMMAND":
+		// Clients only use COMMAND for introspection; an empty reply makes
+		// them fall back to their built-in command tables.
+		_ = wr.WriteArrayHeader(0)
+		return false
+	case "SUBSCRIBE", "PSUBSCRIBE", "UNSUBSCRIBE", "PUNSUBSCRIBE":
+		return s.subscribe(sess, name, args[1:])
+	}
+
+	if s.readOnly && command.IsWrite(name) {
+		_ = wr.WriteError(errReadOnly)
+		return false
+	}
+
+	reply, err := command.Execute(s.cache, command.Command{Name: name, Args: args[1:]})
+	if err != nil {
+		_ = wr.WriteError(errorReply(err))
+		return false
+	}
+
+	if err = writeReply(wr, reply); err != nil {
+		s.logger.Error("Failed to encode RESP reply", "command", name, "error", err)
+		_ = wr.WriteError("ERR internal error")
+	}
+	return false
+}
+
+// replicate hands the connection over to the replicator once the replies
+// to earlier commands have been sent.
+func (s *Server) replicate(sess *session, rd *Reader, args []string) {
+	sess.mu.Lock()
+	err := sess.wr.Flush()
+	sess.mu.Unlock()
+	if err != nil {
+		return
+	}
+
+	s.logger.Info("Follower connected", "remote_addr", sess.conn.RemoteAddr())
+	if err = s.replicator.ServeFollower(sess.conn, rd, args); err != nil && !errors.Is(err, net.ErrClosed) {
+		s.logger.Warn("Replication to follower failed", "remote_addr", sess.conn.RemoteAddr(), "error", err)
+	}
+	s.logger.Info("Follower disconnected", "remote_addr", sess.conn.RemoteAddr())
+}
+
+// subscribe implements SUBSCRIBE, PSUBSCRIBE, UNSUBSCRIBE and PUNSUBSCRIBE.
+// Each affected channel or pattern gets its own confirmation carrying the
+// number of remaining subscriptions. It reports whether the connection
+// should be closed. The caller must hold sess.mu.
+func (s *Server) subscribe(sess *session, name string, names []string) bool {
+	wr := sess.wr
+	kind := strings.ToLower(name)
+
+	if (name == "SUBSCRIBE" || name == "PSUBSCRIBE") && len(names) == 0 {
+		_ = wr.WriteError("ERR wrong number of arguments for '" + kind + "' command")
+		return false
+	}
+
+	if sess.sub == nil {
+		sess.sub = s.cache.Subscribe()
+		sess.forwarding.Add(1)
+		go sess.forward()
+	}
+
+	var update func(...string) error
+	switch name {
+	case "SUBSCRIBE":
+		update = sess.sub.Subscribe
+	case "PSUBSCRIBE":
+		update = sess.sub.PSubscribe
+	case "UNSUBSCRIBE":
+		update = sess.sub.Unsubscribe
+		if len(names) == 0 {
+			names = sess.sub.Channels()
+		}
+	case "PUNSUBSCRIBE":
+		update = sess.sub.PUnsubscribe
+		if len(names) == 0 {
+			names = sess.sub.Patterns()
+		}
+	}
+
+	if len(names) == 0 {
+		_ = wr.WriteValue([]any{kind, nil, int64(sess.sub.Count())})
+		return false
+	}
+
+	for _, n := range names {
+		if err := update(n); err != nil {
+			// The broker dropped the subscription because the client did not
+			// keep up with its messages.
+			return true
+		}
+		_ = wr.WriteValue([]any{kind, n, int64(sess.sub.Count())})
+	}
+	return false
+}
+
+// subscribed reports whether the connection has an active subscription.
+func (sess *session) subscribed() bool {
+	return sess.sub != nil && sess.sub.Count() > 0
+}
+
+// forward writes the messages of the session subscription to the client
+// until the subscription is closed. A subscription dropped by the broker
+// for being too slow closes the connection.
+func (sess *session) forward() {
+	defer sess.forwarding.Done()
+
+	for msg := range sess.sub.C() {
+		var push []any
+		if msg.Pattern != "" {
+			push = []any{"pmessage", msg.Pattern, msg.Channel, msg.Payload}
+		} else {
+			push = []any{"message", msg.Channel, msg.Payload}
+		}
+
+		sess.mu.Lock()
+		err := sess.wr.WriteValue(push)
+		if err == nil {
+			err = sess.wr.Flush()
+		}
+		sess.mu.Unlock()
+
+		if err != nil {
+			_ = sess.conn.Close()
+		}
+	}
+
+	if sess.sub.Err() != nil {
+		_ = sess.conn.Close()
+	}
+}
+
+// close releases the session subscription, if any.
+func (sess *session) close() {
+	if sess.sub != nil {
+		_ = sess.sub.Close()
+		sess.forwarding.Wait()
+	}
+}
+
+// writeReply writes a command reply.
+func writeReply(wr *Writer, reply any) error {
+	if status, ok := reply.(command.Status); ok {
+		return wr.WriteStatus(string(status))
+	}
+	return wr.WriteValue(reply)
+}
+
+// errorReply converts an error into a RESP error message with a Redis
+// compatible prefix.
+func errorReply(err error) string {
+	switch {
+	case errors.Is(err, cache.ErrTypeMismatch):
+		return "WRONGTYPE Operation against a key holding the wrong kind of value"
+	case errors.Is(err, cache.ErrOutOfMemory):
+		return "OOM command not allowed when used memory > 'maxmemory'."
+	default:
+		return "ERR " + err.Error()
+	}
+}
@@ -0,0 +1,111 @@
+package resp
+
+import (
+	"github.com/dsha256/gredis/internal/cache"
+	"github.com/dsha256/gredis/internal/command"
+)
+
+// errTxUnsupported is the reply to EXEC if the cache cannot apply
+// transactions.
+const errTxUnsupported = "ERR transactions are not supported by the cache"
+
+// queue adds a command sent after MULTI to the transaction of sess and
+// replies QUEUED. A command that could not be executed is rejected and
+// makes EXEC discard the transaction, as in Redis.
+func (s *Server) queue(sess *session, name string, args []string) {
+	cmd := command.Command{Name: name, Args: args}
+	err := command.Validate(cmd)
+	switch {
+	case err != nil:
+		_ = sess.wr.WriteError(errorReply(err))
+	case s.readOnly && command.IsWrite(name):
+		_ = sess.wr.WriteError(errReadOnly)
+	default:
+		sess.queued = append(sess.queued, cmd)
+		_ = sess.wr.WriteStatus("QUEUED")
+		return
+	}
+	sess.txFailed = true
+}
+
+// watch records the current versions of keys, so that the next EXEC of
+// sess aborts if one of them changes.
+func (s *Server) watch(sess *session, keys []string) {
+	wr := sess.wr
+	switch t, ok := s.cache.(cache.Transactor); {
+	case sess.multi:
+		_ = wr.WriteError("ERR WATCH inside MULTI is not allowed")
+	case len(keys) == 0:
+		_ = wr.WriteError("ERR wrong number of arguments for 'watch' command")
+	case !ok:
+		_ = wr.WriteError(errTxUnsupported)
+	default:
+		if sess.watched == nil {
+			sess.watched = make(map[string]uint64)
+		}
+		for _, key := range keys {
+			if _, found := sess.watched[key]; !found {
+				sess.watched[key] = t.Version(key)
+			}
+		}
+		_ = wr.WriteStatus("OK")
+	}
+}
+
+// exec applies the transaction of sess atomically and replies with the
+// reply of each of its commands, or with a null array if a watched key
+// changed.
+func (s *Server) exec(sess *session) {
+	wr := sess.wr
+	if !sess.multi {
+		_ = wr.WriteError("ERR EXEC without MULTI")
+		return
+	}
+	cmds, failed, watched := sess.queued, sess.txFailed, sess.watched
+	sess.discard()
+
+	if failed {
+		_ = wr.WriteError("EXECABORT Transaction discarded because of previous errors.")
+		return
+	}
+	t, ok := s.cache.(cache.Transactor)
+	if !ok {
+		_ = wr.WriteError(errTxUnsupported)
+		return
+	}
+
+	tx := cache.NewTx(t)
+	for key, version := range watched {
+		tx.WatchVersion(key, version)
+	}
+	if err := command.QueueTx(tx, cmds); err != nil {
+		_ = wr.WriteError(errorReply(err))
+		return
+	}
+	results, ok := tx.Exec()
+	if !ok {
+		_ = wr.WriteNullArray()
+		return
+	}
+
+	_ = wr.WriteArrayHeader(len(results))
+	for i, result := range results {
+		if result.Err != nil {
+			_ = wr.WriteError(errorReply(result.Err))
+			continue
+		}
+		if err := writeReply(wr, result.Value); err != nil {
+			s.logger.Error("Failed to encode RESP reply", "command", cmds[i].Name, "error", err)
+			_ = wr.WriteError("ERR internal error")
+		}
+	}
+}
+
+// discard ends the transaction of sess, dropping its queued commands and
+// watched keys.
+func (sess *session) discard() {
+	sess.multi = false
+	sess.queued = nil
+	sess.txFailed = false
+	sess.watched = nil
+}
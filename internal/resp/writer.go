@@ -0,0 +1,166 @@
+package resp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Error is a RESP error reply, e.g. "ERR syntax error".
+type Error string
+
+// Error implements the error interface.
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Writer encodes RESP2 values to a buffered stream. Call Flush to send
+// buffered data to the underlying writer.
+type Writer struct {
+	wr  *bufio.Writer
+	num []byte
+}
+
+// NewWriter creates a new Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		wr: bufio.NewWriter(w),
+	}
+}
+
+// WriteStatus writes a simple string reply.
+func (w *Writer) WriteStatus(s string) error {
+	return w.writeLine('+', s)
+}
+
+// WriteError writes an error reply.
+func (w *Writer) WriteError(s string) error {
+	return w.writeLine('-', s)
+}
+
+// WriteInt writes an integer reply.
+func (w *Writer) WriteInt(n int64) error {
+	w.num = strconv.AppendInt(w.num[:0], n, 10)
+	return w.writeLine(':', string(w.num))
+}
+
+// WriteBulk writes a bulk string reply.
+func (w *Writer) WriteBulk(s string) error {
+	if err := w.WriteBulkHeader(int64(len(s))); err != nil {
+		return err
+	}
+	if _, err := w.wr.WriteString(s); err != nil {
+		return err
+	}
+	_, err := w.wr.WriteString("\r\n")
+	return err
+}
+
+// WriteBulkHeader writes the header of a bulk string of n bytes. The caller
+// must follow it with exactly n bytes and a call to WriteBulkTrailer.
+func (w *Writer) WriteBulkHeader(n int64) error {
+	w.num = strconv.AppendInt(w.num[:0], n, 10)
+	return w.writeLine('$', string(w.num))
+}
+
+// WriteBulkTrailer terminates a bulk string started with WriteBulkHeader.
+func (w *Writer) WriteBulkTrailer() error {
+	_, err := w.wr.WriteString("\r\n")
+	return err
+}
+
+// WriteNull writes a null bulk string reply.
+func (w *Writer) WriteNull() error {
+	_, err := w.wr.WriteString("$-1\r\n")
+	return err
+}
+
+// WriteNullArray writes a null array reply.
+func (w *Writer) WriteNullArray() error {
+	_, err := w.wr.WriteString("*-1\r\n")
+	return err
+}
+
+// WriteArrayHeader writes the header of an array of n elements.
+func (w *Writer) WriteArrayHeader(n int) error {
+	w.num = strconv.AppendInt(w.num[:0], int64(n), 10)
+	return w.writeLine('*', string(w.num))
+}
+
+// WriteCommand writes a request as an array of bulk strings.
+func (w *Writer) WriteCommand(args ...string) error {
+	if err := w.WriteArrayHeader(len(args)); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := w.WriteBulk(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteValue writes v using the RESP type matching its Go type.
+func (w *Writer) WriteValue(v any) error {
+	switch v := v.(type) {
+	case nil:
+		return w.WriteNull()
+	case string:
+		return w.WriteBulk(v)
+	case Error:
+		return w.WriteError(string(v))
+	case int:
+		return w.WriteInt(int64(v))
+	case int64:
+		return w.WriteInt(v)
+	case bool:
+		if v {
+			return w.WriteInt(1)
+		}
+		return w.WriteInt(0)
+	case []string:
+		if err := w.WriteArrayHeader(len(v)); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := w.WriteBulk(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		if err := w.WriteArrayHeader(len(v)); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := w.WriteValue(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("resp: unsupported reply type %T", v)
+	}
+}
+
+// Write writes raw bytes to the stream, bypassing RESP encoding.
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.wr.Write(p)
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.wr.Flush()
+}
+
+func (w *Writer) writeLine(prefix byte, s string) error {
+	if err := w.wr.WriteByte(prefix); err != nil {
+		return err
+	}
+	if _, err := w.wr.WriteString(s); err != nil {
+		return err
+	}
+	_, err := w.wr.WriteString("\r\n")
+	return err
+}